@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/manzanit0/weathry/cmd/bot/conversation"
+	"github.com/manzanit0/weathry/cmd/bot/msg"
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+func (g *MessageController) ProcessBriefCommand(ctx context.Context, p *tgram.WebhookRequest) string {
+	query := strings.TrimSpace(tgram.ExtractCommandQuery(p.Message.Text))
+
+	switch strings.ToLower(query) {
+	case "":
+		b, err := g.briefings.GetBriefing(ctx, p.GetFromID())
+		if err != nil {
+			slog.Error("get briefing", "error", err.Error())
+			return msg.MsgUnexpectedError
+		}
+
+		if b == nil {
+			return msg.MsgBriefUsage
+		}
+
+		return msg.NewBriefStatusMessage(b.Time(), b.Timezone, b.Paused)
+
+	case "pause", "resume":
+		paused := strings.EqualFold(query, "pause")
+		found, err := g.briefings.SetPaused(ctx, p.GetFromID(), paused)
+		if err != nil {
+			slog.Error("set briefing paused", "error", err.Error())
+			return msg.MsgUnexpectedError
+		}
+
+		switch {
+		case !found:
+			return msg.MsgBriefNotSubscribed
+		case paused:
+			return msg.MsgBriefPaused
+		default:
+			return msg.MsgBriefResumed
+		}
+
+	case "off":
+		_, err := g.briefings.Unsubscribe(ctx, p.GetFromID())
+		if err != nil {
+			slog.Error("unsubscribe from briefing", "error", err.Error())
+			return msg.MsgUnexpectedError
+		}
+
+		return msg.MsgBriefUnsubscribed
+	}
+
+	return g.subscribeBrief(ctx, p, query)
+}
+
+// ProcessBriefCallback handles the inline buttons of briefs. When it returns a
+// keyboard, the brief's buttons should be replaced with it instead of replying
+// with the message.
+func (g *MessageController) ProcessBriefCallback(ctx context.Context, p *tgram.WebhookRequest, data tgram.CallbackData) (string, *tgram.ReplyMarkup) {
+	button, err := msg.ParseBriefCallback(data)
+	if err != nil {
+		slog.Error("unexpected brief callback data", "callback_data", data, "error", err.Error())
+		return msg.MsgUnexpectedError, nil
+	}
+
+	switch button {
+	case msg.BriefButtonPause, msg.BriefButtonResume:
+		paused := button == msg.BriefButtonPause
+		found, err := g.briefings.SetPaused(ctx, p.GetFromID(), paused)
+		if err != nil {
+			slog.Error("set briefing paused", "error", err.Error())
+			return msg.MsgUnexpectedError, nil
+		}
+
+		if !found {
+			return msg.MsgBriefNotSubscribed, nil
+		}
+
+		keyboard, err := msg.NewBriefKeyboard(ctx, g.codec, paused)
+		if err != nil {
+			slog.Error("create brief keyboard", "error", err.Error())
+			return msg.MsgUnexpectedError, nil
+		}
+
+		return "", keyboard
+
+	case msg.BriefButtonTime:
+		_, err := g.convos.AddQuestion(ctx, fmt.Sprint(p.GetFromID()), conversation.QuestionBriefTime)
+		if err != nil {
+			slog.Error("add question", "error", err.Error())
+			return msg.MsgUnexpectedError, nil
+		}
+
+		return msg.MsgBriefTimeQuestion, nil
+
+	default:
+		slog.Error("unexpected brief button", "button", button)
+		return msg.MsgUnexpectedError, nil
+	}
+}
+
+func (g *MessageController) subscribeBrief(ctx context.Context, p *tgram.WebhookRequest, text string) string {
+	hour, minute, ok := parseTimeOfDay(text)
+	if !ok {
+		return msg.MsgBriefInvalidTime
+	}
+
+	user := lookupUser(ctx, g.users, p.GetFromID())
+
+	err := g.briefings.Subscribe(ctx, p.GetFromID(), hour, minute)
+	if err != nil {
+		slog.Error("subscribe to briefing", "error", err.Error())
+		return msg.MsgUnexpectedError
+	}
+
+	message := msg.NewBriefSubscribedMessage(fmt.Sprintf("%02d:%02d", hour, minute), user.Timezone)
+
+	home, err := g.locations.GetHome(ctx, p.GetFromID())
+	if err != nil {
+		slog.Error("get home", "error", err.Error())
+	} else if home == nil {
+		message += msg.MsgBriefMissingHome
+	}
+
+	return message
+}
@@ -0,0 +1,49 @@
+package api_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/manzanit0/weathry/cmd/bot/api"
+	"github.com/manzanit0/weathry/cmd/bot/briefings"
+	"github.com/manzanit0/weathry/cmd/bot/location"
+	"github.com/manzanit0/weathry/cmd/bot/users"
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+// unknownUsers is a users repository without any users.
+type unknownUsers struct{ users.Repository }
+
+func (unknownUsers) GetUser(context.Context, int) (*users.User, error) { return nil, nil }
+
+type subscriptions struct {
+	briefings.Repository
+	subscribed map[int]string
+}
+
+func (s *subscriptions) Subscribe(_ context.Context, userID, hour, minute int) error {
+	s.subscribed[userID] = fmt.Sprintf("%02d:%02d", hour, minute)
+	return nil
+}
+
+type homeless struct{ location.Repository }
+
+func (homeless) GetHome(context.Context, int) (*location.HomeLocation, error) { return nil, nil }
+
+func TestProcessBriefCommandForUnknownUser(t *testing.T) {
+	b := &subscriptions{subscribed: map[int]string{}}
+	c := api.NewMessageController(nil, nil, nil, api.Repositories{Users: unknownUsers{}, Briefings: b, Locations: homeless{}})
+
+	p := &tgram.WebhookRequest{Message: &tgram.Message{From: tgram.From{ID: 42}, Text: "/brief 07:30"}}
+	got := c.ProcessBriefCommand(context.Background(), p)
+
+	if at := b.subscribed[42]; at != "07:30" {
+		t.Errorf("got the user subscribed at %q, expected 07:30", at)
+	}
+
+	if !strings.Contains(got, "`"+users.DefaultTimezone+"`") {
+		t.Errorf("expected the brief to be in the default timezone, got %q", got)
+	}
+}
@@ -1,13 +1,13 @@
 package api
 
 import (
-	"strconv"
-	"strings"
+	"context"
 
 	"log/slog"
 
 	"github.com/manzanit0/weathry/cmd/bot/msg"
 	"github.com/manzanit0/weathry/cmd/bot/services"
+	"github.com/manzanit0/weathry/cmd/bot/users"
 	"github.com/manzanit0/weathry/pkg/geocode"
 	"github.com/manzanit0/weathry/pkg/tgram"
 	"github.com/manzanit0/weathry/pkg/weather"
@@ -15,57 +15,42 @@ import (
 
 type CallbackController struct {
 	weatherService *services.WeatherService
+	users          users.Repository
 }
 
-func NewCallbackController(l geocode.Client, w weather.Client) *CallbackController {
-	srv := services.NewWeatherService(l, w)
-	return &CallbackController{weatherService: srv}
+func NewCallbackController(l geocode.Client, w weather.Client, u users.Repository, codec *tgram.CallbackCodec) *CallbackController {
+	srv := services.NewWeatherService(l, w, codec)
+	return &CallbackController{weatherService: srv, users: u}
 }
 
-func (g *CallbackController) ProcessCallbackQuery(p *tgram.WebhookRequest) string {
-	s := strings.Split(p.CallbackQuery.Data, ":")
-	if len(s) != 2 {
-		slog.Error("unexpected callback query data format", "callback_data", p.CallbackQuery.Data, "error", "expected format: hourly:lat,lon")
-		return msg.MsgUnexpectedError
-	}
-
-	ss := strings.Split(s[1], ",")
-	if len(s) != 2 {
-		slog.Error("unexpected callback query data format", "callback_data", p.CallbackQuery.Data, "error", "expected format: hourly:lat,lon")
-		return msg.MsgUnexpectedError
-	}
-
-	lat, err := strconv.ParseFloat(ss[0], 64)
+// ProcessCallbackQuery handles the forecast buttons. Buttons on forecast
+// messages edit them in place, which is what the returned bool tells. Others,
+// such as those in alerts, get the forecast in a new message.
+func (g *CallbackController) ProcessCallbackQuery(ctx context.Context, p *tgram.WebhookRequest, data tgram.CallbackData) (string, *tgram.ReplyMarkup, bool) {
+	c, err := msg.ParseForecastCallback(data)
 	if err != nil {
-		slog.Error("invalid latitude format", "error", err.Error(), "callback_data", p.CallbackQuery.Data)
-		return msg.MsgUnexpectedError
+		slog.Error("unexpected forecast callback data", "callback_data", data, "error", err.Error())
+		return msg.MsgUnexpectedError, nil, false
 	}
 
-	lon, err := strconv.ParseFloat(ss[1], 64)
-	if err != nil {
-		slog.Error("invalid longitude format", "error", err.Error(), "callback_data", p.CallbackQuery.Data)
-		return msg.MsgUnexpectedError
-	}
+	user := lookupUser(ctx, g.users, p.GetFromID())
+	loc := user.Location()
 
-	switch s[0] {
-	case "hourly":
-		message, err := g.weatherService.GetHourlyWeatherByCoordinates(lat, lon)
+	if c.View == msg.ViewNow {
+		message, err := g.weatherService.GetCurrentWeatherByCoordinates(ctx, c.Latitude, c.Longitude, loc)
 		if err != nil {
-			slog.Error("get hourly weather", "error", err.Error())
-			return msg.MsgUnableToGetReport
+			slog.Error("get current weather", "error", err.Error())
+			return msg.MsgUnableToGetReport, nil, false
 		}
 
-		return message
-	case "daily":
-		message, err := g.weatherService.GetDailyWeatherByCoordinates(lat, lon)
-		if err != nil {
-			slog.Error("get daily weather", "error", err.Error())
-			return msg.MsgUnableToGetReport
-		}
+		return message, nil, false
+	}
 
-		return message
-	default:
-		slog.Error("unreachable line reached")
-		return msg.MsgUnexpectedError
+	message, keyboard, err := g.weatherService.GetForecastPageByCoordinates(ctx, c.Latitude, c.Longitude, c.View, c.Page, loc, layoutOf(user))
+	if err != nil {
+		slog.Error("get forecast page", "error", err.Error(), "view", string(c.View), "page", c.Page)
+		return msg.MsgUnableToGetReport, nil, false
 	}
+
+	return message, keyboard, c.InPlace
 }
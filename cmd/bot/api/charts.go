@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/manzanit0/weathry/cmd/bot/msg"
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+// IsChartCommand tells whether text asks for the chart of the forecast rather
+// than its table, such as "/hourly chart Lisbon".
+func IsChartCommand(text string) bool {
+	_, _, ok := parseChartCommand(text)
+	return ok
+}
+
+func parseChartCommand(text string) (msg.ForecastView, string, bool) {
+	var view msg.ForecastView
+	switch {
+	case strings.HasPrefix(text, "/hourly"):
+		view = msg.ViewHourly
+	case strings.HasPrefix(text, "/daily"):
+		view = msg.ViewDaily
+	default:
+		return "", "", false
+	}
+
+	first, place, _ := strings.Cut(strings.TrimSpace(tgram.ExtractCommandQuery(text)), " ")
+	if !strings.EqualFold(first, "chart") {
+		return "", "", false
+	}
+
+	return view, strings.TrimSpace(place), true
+}
+
+// ProcessChartCommand draws the forecast of the place, or of the user's home
+// when there's no place. Charts can't be sent as a webhook response, so it
+// returns the photo to send or, when there's none, the message to reply with.
+func (g *MessageController) ProcessChartCommand(ctx context.Context, p *tgram.WebhookRequest) (*tgram.SendPhotoRequest, string) {
+	err := g.convos.MarkQuestionAnswered(ctx, fmt.Sprint(p.GetFromID()))
+	if err != nil {
+		slog.Error("unable to mark question as answered", "error", err.Error())
+		return nil, msg.MsgUnexpectedError
+	}
+
+	view, place, ok := parseChartCommand(p.Message.Text)
+	if !ok {
+		return nil, msg.MsgUnexpectedError
+	}
+
+	loc := g.userLocation(ctx, p.GetFromID())
+
+	var photo []byte
+	var caption string
+	if place != "" {
+		photo, caption, err = g.forecaster.GetForecastChartByLocationName(ctx, place, view, loc)
+	} else {
+		home, homeErr := g.locations.GetHome(ctx, p.GetFromID())
+		if homeErr != nil {
+			slog.Error("get home", "error", homeErr.Error())
+			return nil, msg.MsgUnexpectedError
+		}
+
+		if home == nil {
+			return nil, msg.MsgChartMissingPlace
+		}
+
+		photo, caption, err = g.forecaster.GetForecastChart(ctx, &home.Location, view, loc)
+	}
+
+	if err != nil {
+		slog.Error("get forecast chart", "error", err.Error(), "view", string(view))
+		return nil, msg.MsgUnableToGetReport
+	}
+
+	return &tgram.SendPhotoRequest{
+		ChatID:    int64(p.GetFromID()),
+		Photo:     photo,
+		Caption:   caption,
+		ParseMode: tgram.ParseModeMarkdownV2,
+	}, ""
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/manzanit0/weathry/cmd/bot/msg"
+	"github.com/manzanit0/weathry/cmd/bot/nlq"
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+// ProcessDayCommand shows the hourly forecast of a single day, such as
+// "/day saturday Lisbon".
+func (g *MessageController) ProcessDayCommand(ctx context.Context, p *tgram.WebhookRequest) string {
+	err := g.convos.MarkQuestionAnswered(ctx, fmt.Sprint(p.GetFromID()))
+	if err != nil {
+		slog.Error("unable to mark question as answered", "error", err.Error())
+		return msg.MsgUnexpectedError
+	}
+
+	now := g.clock.Now().In(g.userLocation(ctx, p.GetFromID()))
+
+	day, place, ok := nlq.ParseDay(tgram.ExtractCommandQuery(p.Message.Text), now)
+	if !ok || day.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())) {
+		return msg.MsgDayUsage
+	}
+
+	return g.daysWeather(ctx, p, strings.TrimSpace(place), day, day)
+}
+
+// ProcessWeekendCommand shows the hourly forecast of the upcoming weekend.
+func (g *MessageController) ProcessWeekendCommand(ctx context.Context, p *tgram.WebhookRequest) string {
+	err := g.convos.MarkQuestionAnswered(ctx, fmt.Sprint(p.GetFromID()))
+	if err != nil {
+		slog.Error("unable to mark question as answered", "error", err.Error())
+		return msg.MsgUnexpectedError
+	}
+
+	now := g.clock.Now().In(g.userLocation(ctx, p.GetFromID()))
+	saturday, sunday := nlq.Weekend(now)
+
+	place := strings.TrimSpace(tgram.ExtractCommandQuery(p.Message.Text))
+	return g.daysWeather(ctx, p, place, saturday, sunday)
+}
+
+// daysWeather renders the hourly forecast from first to last for place, or
+// for the user's home when there's no place.
+func (g *MessageController) daysWeather(ctx context.Context, p *tgram.WebhookRequest, place string, first, last time.Time) string {
+	if place != "" {
+		message, err := g.forecaster.GetDaysWeatherByLocationName(ctx, place, first, last)
+		if err != nil {
+			slog.Error("get days weather", "error", err.Error())
+			return msg.MsgUnableToGetReport
+		}
+
+		return message
+	}
+
+	home, err := g.locations.GetHome(ctx, p.GetFromID())
+	if err != nil {
+		slog.Error("get home", "error", err.Error())
+		return msg.MsgUnexpectedError
+	}
+
+	if home == nil {
+		return msg.MsgDaysMissingPlace
+	}
+
+	message, err := g.forecaster.GetDaysWeather(ctx, &home.Location, first, last)
+	if err != nil {
+		slog.Error("get days weather", "error", err.Error())
+		return msg.MsgUnableToGetReport
+	}
+
+	return message
+}
@@ -3,58 +3,195 @@ package api
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"log/slog"
 
+	"github.com/manzanit0/weathry/cmd/bot/briefings"
 	"github.com/manzanit0/weathry/cmd/bot/conversation"
 	"github.com/manzanit0/weathry/cmd/bot/location"
 	"github.com/manzanit0/weathry/cmd/bot/msg"
+	"github.com/manzanit0/weathry/cmd/bot/nlq"
+	"github.com/manzanit0/weathry/cmd/bot/notifications"
+	"github.com/manzanit0/weathry/cmd/bot/rules"
+	"github.com/manzanit0/weathry/cmd/bot/schedules"
 	"github.com/manzanit0/weathry/cmd/bot/services"
+	"github.com/manzanit0/weathry/cmd/bot/templates"
+	"github.com/manzanit0/weathry/cmd/bot/users"
+	"github.com/manzanit0/weathry/pkg/clock"
+	"github.com/manzanit0/weathry/pkg/expr"
 	"github.com/manzanit0/weathry/pkg/geocode"
 	"github.com/manzanit0/weathry/pkg/tgram"
 	"github.com/manzanit0/weathry/pkg/weather"
 )
 
+// historyLength is the amount of notifications shown by /history.
+const historyLength = 10
+
+type Repositories struct {
+	Convos        *conversation.ConvoRepository
+	Locations     location.Repository
+	Rules         rules.Repository
+	Notifications notifications.Repository
+	Users         users.Repository
+	Schedules     schedules.Repository
+	Briefings     briefings.Repository
+	Templates     templates.Repository
+}
+
 type MessageController struct {
-	geocoder   geocode.Client
-	convos     *conversation.ConvoRepository
-	locations  location.Repository
-	forecaster *services.WeatherService
+	geocoder      geocode.Client
+	convos        *conversation.ConvoRepository
+	locations     location.Repository
+	rules         rules.Repository
+	notifications notifications.Repository
+	users         users.Repository
+	schedules     schedules.Repository
+	briefings     briefings.Repository
+	templates     templates.Repository
+	forecaster    *services.WeatherService
+	codec         *tgram.CallbackCodec
+	clock         clock.Clock
+	admin         int64
+}
+
+type options struct {
+	clock clock.Clock
+	admin int64
+}
+
+type Option func(*options)
+
+// WithClock makes the controllers read the time from c instead of the system
+// clock.
+func WithClock(c clock.Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// WithAdmin lets the user with the chat ID change everyone's templates.
+func WithAdmin(chatID int64) Option {
+	return func(o *options) {
+		o.admin = chatID
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{clock: clock.System{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+func NewMessageController(l geocode.Client, w weather.Client, codec *tgram.CallbackCodec, r Repositories, opts ...Option) *MessageController {
+	s := services.NewWeatherService(l, w, codec)
+	o := newOptions(opts)
+	return &MessageController{
+		geocoder:      l,
+		convos:        r.Convos,
+		locations:     r.Locations,
+		rules:         r.Rules,
+		notifications: r.Notifications,
+		users:         r.Users,
+		schedules:     r.Schedules,
+		briefings:     r.Briefings,
+		templates:     r.Templates,
+		forecaster:    s,
+		codec:         codec,
+		clock:         o.clock,
+		admin:         o.admin,
+	}
 }
 
-func NewMessageController(l geocode.Client, w weather.Client, c *conversation.ConvoRepository, ll location.Repository) *MessageController {
-	s := services.NewWeatherService(l, w)
-	return &MessageController{l, c, ll, s}
+// userLocation returns the timezone forecasts should be rendered in for the
+// user, falling back to UTC when the user is unknown.
+func (g *MessageController) userLocation(ctx context.Context, userID int) *time.Location {
+	return lookupLocation(ctx, g.users, userID)
 }
 
-func (g *MessageController) ProcessDailyCommand(ctx context.Context, p *tgram.WebhookRequest) string {
+func lookupLocation(ctx context.Context, repo users.Repository, userID int) *time.Location {
+	return lookupUser(ctx, repo, userID).Location()
+}
+
+// layoutOf returns how the user wants daily forecasts rendered, falling back
+// to a table.
+func layoutOf(user *users.User) msg.Layout {
+	if layout, ok := msg.ParseLayout(user.DefaultView); ok {
+		return layout
+	}
+
+	return msg.LayoutTable
+}
+
+// lookupUser returns the user, falling back to one with the default settings
+// when the user is unknown.
+func lookupUser(ctx context.Context, repo users.Repository, userID int) *users.User {
+	fallback := &users.User{ID: userID, Timezone: users.DefaultTimezone, DefaultView: users.DefaultView}
+
+	user, err := repo.GetUser(ctx, userID)
+	if err != nil {
+		slog.Warn("unable to get user, defaulting to the default settings", "error", err.Error(), "ctx.user_id", userID)
+		return fallback
+	}
+
+	if user == nil {
+		return fallback
+	}
+
+	return user
+}
+
+func (g *MessageController) ProcessDailyCommand(ctx context.Context, p *tgram.WebhookRequest) (string, *tgram.ReplyMarkup) {
 	err := g.convos.MarkQuestionAnswered(ctx, fmt.Sprint(p.GetFromID()))
 	if err != nil {
 		slog.Error("unable to mark question as answered", "error", err.Error())
-		return msg.MsgUnexpectedError
+		return msg.MsgUnexpectedError, nil
 	}
 
 	query := tgram.ExtractCommandQuery(p.Message.Text)
-	message, err := g.forecaster.GetDailyWeatherByLocationName(query)
+	user := lookupUser(ctx, g.users, p.GetFromID())
+	message, keyboard, err := g.forecaster.GetDailyWeatherByLocationName(ctx, query, user.Location(), layoutOf(user))
 	if err != nil {
 		slog.Error("get upcoming weather", "error", err.Error())
-		return msg.MsgUnableToGetReport
+		return msg.MsgUnableToGetReport, nil
 	}
 
-	return message
+	return message, keyboard
 }
 
-func (g *MessageController) ProcessHourlyCommand(ctx context.Context, p *tgram.WebhookRequest) string {
+func (g *MessageController) ProcessHourlyCommand(ctx context.Context, p *tgram.WebhookRequest) (string, *tgram.ReplyMarkup) {
 	err := g.convos.MarkQuestionAnswered(ctx, fmt.Sprint(p.GetFromID()))
 	if err != nil {
 		slog.Error("unable to mark question as answered", "error", err.Error())
-		return msg.MsgUnexpectedError
+		return msg.MsgUnexpectedError, nil
 	}
 
 	query := tgram.ExtractCommandQuery(p.Message.Text)
-	message, err := g.forecaster.GetHourlyWeatherByLocationName(query)
+	message, keyboard, err := g.forecaster.GetHourlyWeatherByLocationName(ctx, query, g.userLocation(ctx, p.GetFromID()))
 	if err != nil {
 		slog.Error("get upcoming weather", "error", err.Error())
+		return msg.MsgUnableToGetReport, nil
+	}
+
+	return message, keyboard
+}
+
+func (g *MessageController) ProcessNowCommand(ctx context.Context, p *tgram.WebhookRequest) string {
+	err := g.convos.MarkQuestionAnswered(ctx, fmt.Sprint(p.GetFromID()))
+	if err != nil {
+		slog.Error("unable to mark question as answered", "error", err.Error())
+		return msg.MsgUnexpectedError
+	}
+
+	query := tgram.ExtractCommandQuery(p.Message.Text)
+	message, err := g.forecaster.GetCurrentWeatherByLocationName(ctx, query, g.userLocation(ctx, p.GetFromID()))
+	if err != nil {
+		slog.Error("get current weather", "error", err.Error())
 		return msg.MsgUnableToGetReport
 	}
 
@@ -90,7 +227,7 @@ func (g *MessageController) setHome(ctx context.Context, p *tgram.WebhookRequest
 
 	// if it just has the name, we hydrate the database.
 	if location.Latitude == 0 || location.Longitude == 0 {
-		remote, err := g.geocoder.Geocode(locationName)
+		remote, err := g.geocoder.Geocode(ctx, locationName)
 		if err != nil {
 			slog.Error("find location in third party", "error", err.Error())
 			return msg.MsgUnableToGetReport
@@ -114,22 +251,109 @@ func (g *MessageController) setHome(ctx context.Context, p *tgram.WebhookRequest
 		return msg.MsgUnableToGetReport
 	}
 
-	return fmt.Sprintf("Successfully set %s as your home\\! From now on, I\\'ll let you know of any relevant weather changes there 🙂", locationName)
+	return msg.NewHomeSetMessage(locationName)
+}
+
+func (g *MessageController) ProcessRuleCommand(ctx context.Context, p *tgram.WebhookRequest) string {
+	err := g.convos.MarkQuestionAnswered(ctx, fmt.Sprint(p.GetFromID()))
+	if err != nil {
+		slog.Error("unable to mark question as answered", "error", err.Error())
+		return msg.MsgUnexpectedError
+	}
+
+	query := tgram.ExtractCommandQuery(p.Message.Text)
+	return g.addRule(ctx, p, query)
+}
+
+func (g *MessageController) addRule(ctx context.Context, p *tgram.WebhookRequest, expression string) string {
+	// Rules are validated on save so users get the feedback right away, and not
+	// when the pinger evaluates them.
+	_, err := expr.Compile(expression)
+	if err != nil {
+		return msg.NewRuleErrorMessage(expression, err)
+	}
+
+	existing, err := g.rules.ListRules(ctx, p.GetFromID())
+	if err != nil {
+		slog.Error("list rules", "error", err.Error())
+		return msg.MsgUnexpectedError
+	}
+
+	if len(existing) >= rules.MaxRulesPerUser {
+		return msg.MsgRuleLimitReached
+	}
+
+	rule, err := g.rules.CreateRule(ctx, p.GetFromID(), strings.TrimSpace(expression))
+	if err != nil {
+		slog.Error("create rule", "error", err.Error())
+		return msg.MsgUnexpectedError
+	}
+
+	message := msg.NewRuleCreatedMessage(rule)
+
+	home, err := g.locations.GetHome(ctx, p.GetFromID())
+	if err != nil {
+		slog.Error("get home", "error", err.Error())
+	} else if home == nil {
+		message += msg.MsgRuleMissingHome
+	}
+
+	return message
+}
+
+func (g *MessageController) ProcessRulesCommand(ctx context.Context, p *tgram.WebhookRequest) string {
+	rr, err := g.rules.ListRules(ctx, p.GetFromID())
+	if err != nil {
+		slog.Error("list rules", "error", err.Error())
+		return msg.MsgUnexpectedError
+	}
+
+	return msg.NewRulesListMessage(rr)
+}
+
+func (g *MessageController) ProcessDeleteRuleCommand(ctx context.Context, p *tgram.WebhookRequest) string {
+	query := tgram.ExtractCommandQuery(p.Message.Text)
+	ruleID, err := strconv.ParseInt(strings.TrimSpace(query), 10, 64)
+	if err != nil {
+		return msg.MsgRuleInvalidID
+	}
+
+	deleted, err := g.rules.DeleteRule(ctx, p.GetFromID(), ruleID)
+	if err != nil {
+		slog.Error("delete rule", "error", err.Error())
+		return msg.MsgUnexpectedError
+	}
+
+	if !deleted {
+		return msg.MsgRuleNotFound
+	}
+
+	return msg.MsgRuleDeleted
+}
+
+func (g *MessageController) ProcessHistoryCommand(ctx context.Context, p *tgram.WebhookRequest) string {
+	nn, err := g.notifications.ListNotifications(ctx, p.GetFromID(), historyLength)
+	if err != nil {
+		slog.Error("list notifications", "error", err.Error())
+		return msg.MsgUnexpectedError
+	}
+
+	return msg.NewHistoryMessage(nn, msg.InLocation(g.userLocation(ctx, p.GetFromID())))
 }
 
-func (g *MessageController) ProcessNonCommand(ctx context.Context, p *tgram.WebhookRequest) string {
+func (g *MessageController) ProcessNonCommand(ctx context.Context, p *tgram.WebhookRequest) (string, *tgram.ReplyMarkup) {
 	convo, err := g.convos.Find(ctx, fmt.Sprint(p.GetFromID()))
 
 	switch {
 	case err != nil:
 		slog.Error("find conversation", "error", err.Error())
-		return msg.MsgUnexpectedError
+		return msg.MsgUnexpectedError, nil
 
 	case convo == nil:
-		return msg.MsgUnknownText
+		return g.answerQuery(ctx, p), nil
 
 	case convo != nil && convo.Answered:
-		return msg.MsgUnknownText
+		return g.answerQuery(ctx, p), nil
 
 	case convo.LastQuestionAsked == conversation.QuestionHome:
 		err = g.convos.MarkQuestionAnswered(ctx, fmt.Sprint(p.GetFromID()))
@@ -137,7 +361,23 @@ func (g *MessageController) ProcessNonCommand(ctx context.Context, p *tgram.Webh
 			slog.Error("unable to mark question as answered", "error", err.Error())
 		}
 
-		return g.setHome(ctx, p, p.Message.Text)
+		return g.setHome(ctx, p, p.Message.Text), nil
+
+	case convo.LastQuestionAsked == conversation.QuestionRule:
+		err = g.convos.MarkQuestionAnswered(ctx, fmt.Sprint(p.GetFromID()))
+		if err != nil {
+			slog.Error("unable to mark question as answered", "error", err.Error())
+		}
+
+		return g.addRule(ctx, p, p.Message.Text), nil
+
+	case convo.LastQuestionAsked == conversation.QuestionBriefTime:
+		err = g.convos.MarkQuestionAnswered(ctx, fmt.Sprint(p.GetFromID()))
+		if err != nil {
+			slog.Error("unable to mark question as answered", "error", err.Error())
+		}
+
+		return g.subscribeBrief(ctx, p, p.Message.Text), nil
 
 	case convo.LastQuestionAsked == conversation.QuestionHourlyWeather:
 		err = g.convos.MarkQuestionAnswered(ctx, fmt.Sprint(p.GetFromID()))
@@ -145,13 +385,27 @@ func (g *MessageController) ProcessNonCommand(ctx context.Context, p *tgram.Webh
 			slog.Error("unable to mark question as answered", "error", err.Error())
 		}
 
-		message, err := g.forecaster.GetHourlyWeatherByLocationName(p.Message.Text)
+		message, keyboard, err := g.forecaster.GetHourlyWeatherByLocationName(ctx, p.Message.Text, g.userLocation(ctx, p.GetFromID()))
 		if err != nil {
 			slog.Error("get forecast from question", "error", err.Error())
-			return msg.MsgUnableToGetReport
+			return msg.MsgUnableToGetReport, nil
+		}
+
+		return message, keyboard
+
+	case convo.LastQuestionAsked == conversation.QuestionCurrentWeather:
+		err = g.convos.MarkQuestionAnswered(ctx, fmt.Sprint(p.GetFromID()))
+		if err != nil {
+			slog.Error("unable to mark question as answered", "error", err.Error())
+		}
+
+		message, err := g.forecaster.GetCurrentWeatherByLocationName(ctx, p.Message.Text, g.userLocation(ctx, p.GetFromID()))
+		if err != nil {
+			slog.Error("get current weather from question", "error", err.Error())
+			return msg.MsgUnableToGetReport, nil
 		}
 
-		return message
+		return message, nil
 
 	case convo.LastQuestionAsked == conversation.QuestionDailyWeather:
 		err = g.convos.MarkQuestionAnswered(ctx, fmt.Sprint(p.GetFromID()))
@@ -159,15 +413,51 @@ func (g *MessageController) ProcessNonCommand(ctx context.Context, p *tgram.Webh
 			slog.Error("unable to mark question as answered", "error", err.Error())
 		}
 
-		message, err := g.forecaster.GetDailyWeatherByLocationName(p.Message.Text)
+		user := lookupUser(ctx, g.users, p.GetFromID())
+		message, keyboard, err := g.forecaster.GetDailyWeatherByLocationName(ctx, p.Message.Text, user.Location(), layoutOf(user))
 		if err != nil {
 			slog.Error("get forecast from question", "error", err.Error())
-			return msg.MsgUnableToGetReport
+			return msg.MsgUnableToGetReport, nil
 		}
 
-		return message
+		return message, keyboard
 
 	default:
+		return g.answerQuery(ctx, p), nil
+	}
+}
+
+// answerQuery tries to make sense of free text as a question about the
+// weather, such as "will it rain tomorrow?".
+func (g *MessageController) answerQuery(ctx context.Context, p *tgram.WebhookRequest) string {
+	loc := g.userLocation(ctx, p.GetFromID())
+	now := g.clock.Now().In(loc)
+
+	q, ok := nlq.Parse(p.Message.Text, now)
+	if !ok {
 		return msg.MsgUnknownText
 	}
+
+	logger := slog.With("ctx.user_id", p.GetFromID(), "intent", q.Intent.String(), "language", string(q.Language))
+
+	var home *location.Location
+	if q.Place == "" {
+		h, err := g.locations.GetHome(ctx, p.GetFromID())
+		if err != nil {
+			logger.Error("get home", "error", err.Error())
+			return msg.MsgUnexpectedError
+		}
+
+		if h != nil {
+			home = &h.Location
+		}
+	}
+
+	message, err := g.forecaster.AnswerQuery(ctx, q, home, now)
+	if err != nil {
+		logger.Error("answer query", "error", err.Error())
+		return msg.MsgUnableToGetReport
+	}
+
+	return message
 }
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/manzanit0/weathry/cmd/bot/msg"
+	"github.com/manzanit0/weathry/cmd/bot/users"
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+// maxMuteDuration stops users from muting alerts forever by mistake. They can
+// always delete their home if they don't want them.
+const maxMuteDuration = 90 * 24 * time.Hour
+
+func (g *MessageController) ProcessMuteCommand(ctx context.Context, p *tgram.WebhookRequest) string {
+	query := strings.ToLower(strings.TrimSpace(tgram.ExtractCommandQuery(p.Message.Text)))
+
+	switch query {
+	case "":
+		user, err := g.users.GetUser(ctx, p.GetFromID())
+		if err != nil {
+			slog.Error("get user", "error", err.Error())
+			return msg.MsgUnexpectedError
+		}
+
+		if user == nil || !user.IsMuted(g.clock.Now()) {
+			return msg.MsgMuteUsage
+		}
+
+		return msg.NewMuteStatusMessage(user.MutedUntil.In(user.Location()))
+
+	case "off":
+		err := g.users.SetMutedUntil(ctx, p.GetFromID(), nil)
+		if err != nil {
+			slog.Error("unmute user", "error", err.Error())
+			return msg.MsgUnexpectedError
+		}
+
+		return msg.MsgUnmuted
+	}
+
+	d, ok := parseMuteDuration(query)
+	if !ok {
+		return msg.MsgMuteInvalid
+	}
+
+	return g.mute(ctx, p.GetFromID(), d)
+}
+
+// ProcessSnoozeCallback handles the snooze buttons on alerts.
+func (g *MessageController) ProcessSnoozeCallback(ctx context.Context, p *tgram.WebhookRequest, data tgram.CallbackData) string {
+	duration, err := msg.ParseSnoozeCallback(data)
+	if err != nil {
+		slog.Error("unexpected snooze callback data", "callback_data", data, "error", err.Error())
+		return msg.MsgUnexpectedError
+	}
+
+	d, ok := parseMuteDuration(duration)
+	if !ok {
+		slog.Error("unexpected snooze duration", "duration", duration)
+		return msg.MsgUnexpectedError
+	}
+
+	return g.mute(ctx, p.GetFromID(), d)
+}
+
+func (g *MessageController) ProcessQuietCommand(ctx context.Context, p *tgram.WebhookRequest) string {
+	query := strings.ToLower(strings.TrimSpace(tgram.ExtractCommandQuery(p.Message.Text)))
+
+	switch query {
+	case "":
+		user, err := g.users.GetUser(ctx, p.GetFromID())
+		if err != nil {
+			slog.Error("get user", "error", err.Error())
+			return msg.MsgUnexpectedError
+		}
+
+		if user == nil || user.QuietHours == nil {
+			return msg.MsgQuietHoursUsage
+		}
+
+		return msg.NewQuietHoursMessage(user.QuietHours.String(), user.Timezone)
+
+	case "off":
+		err := g.users.SetQuietHours(ctx, p.GetFromID(), nil)
+		if err != nil {
+			slog.Error("remove quiet hours", "error", err.Error())
+			return msg.MsgUnexpectedError
+		}
+
+		return msg.MsgQuietHoursRemove
+	}
+
+	start, end, found := strings.Cut(query, "-")
+	if !found {
+		return msg.MsgQuietHoursWrong
+	}
+
+	startHour, startMinute, ok := parseTimeOfDay(start)
+	if !ok {
+		return msg.MsgQuietHoursWrong
+	}
+
+	endHour, endMinute, ok := parseTimeOfDay(end)
+	if !ok {
+		return msg.MsgQuietHoursWrong
+	}
+
+	q := users.QuietHours{Start: startHour*60 + startMinute, End: endHour*60 + endMinute}
+	if q.Start == q.End {
+		return msg.MsgQuietHoursWrong
+	}
+
+	user, err := g.users.GetUser(ctx, p.GetFromID())
+	if err != nil {
+		slog.Error("get user", "error", err.Error())
+		return msg.MsgUnexpectedError
+	}
+
+	err = g.users.SetQuietHours(ctx, p.GetFromID(), &q)
+	if err != nil {
+		slog.Error("set quiet hours", "error", err.Error())
+		return msg.MsgUnexpectedError
+	}
+
+	return msg.NewQuietHoursMessage(q.String(), user.Timezone)
+}
+
+func (g *MessageController) mute(ctx context.Context, userID int, d time.Duration) string {
+	user, err := g.users.GetUser(ctx, userID)
+	if err != nil {
+		slog.Error("get user", "error", err.Error())
+		return msg.MsgUnexpectedError
+	}
+
+	until := g.clock.Now().Add(d)
+	err = g.users.SetMutedUntil(ctx, userID, &until)
+	if err != nil {
+		slog.Error("mute user", "error", err.Error())
+		return msg.MsgUnexpectedError
+	}
+
+	return msg.NewMutedMessage(until.In(user.Location()))
+}
+
+// parseMuteDuration parses durations such as "3h", "2d" or "1w".
+func parseMuteDuration(s string) (time.Duration, bool) {
+	if len(s) < 2 {
+		return 0, false
+	}
+
+	var unit time.Duration
+	switch s[len(s)-1] {
+	case 'h':
+		unit = time.Hour
+	case 'd':
+		unit = 24 * time.Hour
+	case 'w':
+		unit = 7 * 24 * time.Hour
+	default:
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+
+	d := time.Duration(n) * unit
+	if d > maxMuteDuration {
+		return 0, false
+	}
+
+	return d, true
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/manzanit0/weathry/cmd/bot/msg"
+	"github.com/manzanit0/weathry/pkg/cron"
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+// minScheduleInterval prevents users from scheduling checks so often that we
+// would burn through the weather API quota.
+const minScheduleInterval = time.Hour
+
+func (g *MessageController) ProcessTimezoneCommand(ctx context.Context, p *tgram.WebhookRequest) string {
+	query := strings.TrimSpace(tgram.ExtractCommandQuery(p.Message.Text))
+
+	if query == "" {
+		user, err := g.users.GetUser(ctx, p.GetFromID())
+		if err != nil {
+			slog.Error("get user", "error", err.Error())
+			return msg.MsgUnexpectedError
+		}
+
+		return msg.NewCurrentTimezoneMessage(user.Timezone)
+	}
+
+	// "Local" is the timezone of the server, which means nothing to users.
+	loc, err := time.LoadLocation(query)
+	if err != nil || query == "Local" {
+		return msg.NewInvalidTimezoneMessage(query)
+	}
+
+	err = g.users.SetTimezone(ctx, p.GetFromID(), loc.String())
+	if err != nil {
+		slog.Error("set timezone", "error", err.Error())
+		return msg.MsgUnexpectedError
+	}
+
+	return msg.NewTimezoneSetMessage(loc.String(), g.clock.Now().In(loc))
+}
+
+func (g *MessageController) ProcessScheduleCommand(ctx context.Context, p *tgram.WebhookRequest) string {
+	query := strings.TrimSpace(tgram.ExtractCommandQuery(p.Message.Text))
+
+	switch strings.ToLower(query) {
+	case "":
+		s, err := g.schedules.GetSchedule(ctx, p.GetFromID())
+		if err != nil {
+			slog.Error("get schedule", "error", err.Error())
+			return msg.MsgUnexpectedError
+		}
+
+		if s == nil {
+			return msg.MsgScheduleUsage
+		}
+
+		return msg.NewCurrentScheduleMessage(s.CronExpression, s.Timezone)
+
+	case "off":
+		_, err := g.schedules.DeleteSchedule(ctx, p.GetFromID())
+		if err != nil {
+			slog.Error("delete schedule", "error", err.Error())
+			return msg.MsgUnexpectedError
+		}
+
+		return msg.MsgScheduleDeleted
+	}
+
+	expression, err := parseScheduleQuery(query, g.clock.Now())
+	if err != nil {
+		return msg.NewInvalidScheduleMessage(err)
+	}
+
+	user, err := g.users.GetUser(ctx, p.GetFromID())
+	if err != nil {
+		slog.Error("get user", "error", err.Error())
+		return msg.MsgUnexpectedError
+	}
+
+	err = g.schedules.SetSchedule(ctx, p.GetFromID(), expression)
+	if err != nil {
+		slog.Error("set schedule", "error", err.Error())
+		return msg.MsgUnexpectedError
+	}
+
+	next := cron.MustParse(expression).Next(g.clock.Now().In(user.Location()))
+	message := msg.NewScheduleSetMessage(expression, user.Timezone, next)
+
+	home, err := g.locations.GetHome(ctx, p.GetFromID())
+	if err != nil {
+		slog.Error("get home", "error", err.Error())
+	} else if home == nil {
+		message += msg.MsgScheduleMissingHome
+	}
+
+	return message
+}
+
+// parseScheduleQuery accepts either a time of the day, such as "07:30", or a
+// cron expression, and returns the cron expression. Expressions are checked
+// against the runs which follow now.
+func parseScheduleQuery(query string, now time.Time) (string, error) {
+	if looksLikeTimeOfDay(query) {
+		hour, minute, ok := parseTimeOfDay(query)
+		if !ok {
+			return "", fmt.Errorf("%s isn't a valid time of the day", query)
+		}
+
+		return fmt.Sprintf("%d %d * * *", minute, hour), nil
+	}
+
+	s, err := cron.Parse(query)
+	if err != nil {
+		return "", err
+	}
+
+	// Check a whole week's worth of runs to catch expressions such as
+	// "* 7 * * *", which would run every minute from 07:00 to 07:59.
+	t := s.Next(now)
+	for i := 0; i < 7*24 && !t.IsZero(); i++ {
+		next := s.Next(t)
+		if !next.IsZero() && next.Sub(t) < minScheduleInterval {
+			return "", fmt.Errorf("checks can't be scheduled more often than once every %s", "hour")
+		}
+
+		t = next
+	}
+
+	return s.String(), nil
+}
+
+func looksLikeTimeOfDay(s string) bool {
+	return strings.Contains(s, ":") && !strings.Contains(s, " ")
+}
+
+// parseTimeOfDay parses times of the day such as "07:30" or "7:30".
+func parseTimeOfDay(s string) (hour, minute int, ok bool) {
+	h, m, found := strings.Cut(strings.TrimSpace(s), ":")
+	if !found || len(m) != 2 {
+		return 0, 0, false
+	}
+
+	hour, err := strconv.Atoi(h)
+	if err != nil || hour < 0 || hour > 23 {
+		return 0, 0, false
+	}
+
+	minute, err = strconv.Atoi(m)
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, 0, false
+	}
+
+	return hour, minute, true
+}
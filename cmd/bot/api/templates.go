@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"unicode"
+
+	"github.com/manzanit0/weathry/cmd/bot/msg"
+	"github.com/manzanit0/weathry/cmd/bot/templates"
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+// ProcessTemplateCommand shows or changes the templates the user's alerts and
+// briefs are worded with. The admin can change everyone's templates with
+// "/template global ...".
+func (g *MessageController) ProcessTemplateCommand(ctx context.Context, p *tgram.WebhookRequest) string {
+	_, query := cutWord(p.Message.Text)
+	userID := p.GetFromID()
+
+	name, rest := cutWord(query)
+	if strings.EqualFold(name, "global") {
+		if int64(userID) != g.admin {
+			return msg.MsgTemplateAdminOnly
+		}
+
+		userID = templates.Global
+		name, rest = cutWord(rest)
+	}
+
+	kind, ok := templates.ParseKind(name)
+	if !ok {
+		return msg.MsgTemplateUsage
+	}
+
+	switch {
+	case rest == "":
+		t, err := g.templates.GetTemplate(ctx, userID, kind)
+		if err != nil {
+			slog.Error("get template", "error", err.Error(), "ctx.user_id", p.GetFromID())
+			return msg.MsgUnexpectedError
+		}
+
+		if t == nil {
+			return msg.NewTemplateMessage(string(kind), templates.Default(kind), false)
+		}
+
+		return msg.NewTemplateMessage(string(kind), t.Body, t.UserID == userID)
+
+	case strings.EqualFold(rest, "off"):
+		found, err := g.templates.DeleteTemplate(ctx, userID, kind)
+		if err != nil {
+			slog.Error("delete template", "error", err.Error(), "ctx.user_id", p.GetFromID())
+			return msg.MsgUnexpectedError
+		}
+
+		return msg.NewTemplateDeletedMessage(string(kind), found)
+	}
+
+	if err := templates.Validate(kind, rest); err != nil {
+		return msg.NewInvalidTemplateMessage(err)
+	}
+
+	err := g.templates.SetTemplate(ctx, userID, kind, rest)
+	if err != nil {
+		slog.Error("set template", "error", err.Error(), "ctx.user_id", p.GetFromID())
+		return msg.MsgUnexpectedError
+	}
+
+	return msg.NewTemplateSetMessage(string(kind))
+}
+
+// cutWord returns the first word of s and the rest of it, trimmed. Unlike
+// tgram.ExtractCommandQuery, it splits on any space, since templates usually
+// start on a new line.
+func cutWord(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, unicode.IsSpace)
+	if i < 0 {
+		return s, ""
+	}
+
+	return s[:i], strings.TrimSpace(s[i:])
+}
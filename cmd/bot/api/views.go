@@ -0,0 +1,44 @@
+package api
+
+import (
+	"context"
+	"strings"
+
+	"log/slog"
+
+	"github.com/manzanit0/weathry/cmd/bot/msg"
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+// ProcessViewCommand shows or changes how the user wants daily forecasts
+// rendered.
+func (g *MessageController) ProcessViewCommand(ctx context.Context, p *tgram.WebhookRequest) string {
+	query := strings.TrimSpace(tgram.ExtractCommandQuery(p.Message.Text))
+
+	if query == "" {
+		user, err := g.users.GetUser(ctx, p.GetFromID())
+		if err != nil {
+			slog.Error("get user", "error", err.Error(), "ctx.user_id", p.GetFromID())
+			return msg.MsgUnexpectedError
+		}
+
+		if user == nil {
+			return msg.MsgLayoutUsage
+		}
+
+		return msg.NewCurrentLayoutMessage(layoutOf(user))
+	}
+
+	layout, ok := msg.ParseLayout(query)
+	if !ok {
+		return msg.MsgLayoutUsage
+	}
+
+	err := g.users.SetDefaultView(ctx, p.GetFromID(), string(layout))
+	if err != nil {
+		slog.Error("set default view", "error", err.Error(), "ctx.user_id", p.GetFromID())
+		return msg.MsgUnexpectedError
+	}
+
+	return msg.NewLayoutSetMessage(layout)
+}
@@ -0,0 +1,207 @@
+package briefings
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// MaxDelay is how late a brief can be sent, for instance when the pinger was
+// down at the time. Past that, it's skipped until the next day.
+const MaxDelay = 2 * time.Hour
+
+// Briefing is a user's subscription to a daily summary of the forecast of
+// their home.
+type Briefing struct {
+	UserID int
+	Hour   int
+	Minute int
+	Paused bool
+
+	// Timezone is the user's timezone, in which Hour and Minute are.
+	Timezone   string
+	LastSentAt *time.Time
+	CreatedAt  time.Time
+}
+
+// Location returns the briefing's timezone, falling back to UTC if it's
+// invalid.
+func (b *Briefing) Location() *time.Location {
+	loc, err := time.LoadLocation(b.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// Time returns the time of the day the brief is sent at, such as "07:30".
+func (b *Briefing) Time() string {
+	return fmt.Sprintf("%02d:%02d", b.Hour, b.Minute)
+}
+
+// Due reports whether the brief should be sent now, i.e. it hasn't been sent
+// since the latest time it was meant to, and that was less than MaxDelay ago.
+func (b *Briefing) Due(now time.Time) bool {
+	if b.Paused {
+		return false
+	}
+
+	local := now.In(b.Location())
+	scheduled := time.Date(local.Year(), local.Month(), local.Day(), b.Hour, b.Minute, 0, 0, local.Location())
+	if scheduled.After(local) {
+		scheduled = time.Date(local.Year(), local.Month(), local.Day()-1, b.Hour, b.Minute, 0, 0, local.Location())
+	}
+
+	if now.Sub(scheduled) > MaxDelay {
+		return false
+	}
+
+	return b.LastSentAt == nil || b.LastSentAt.Before(scheduled)
+}
+
+type dbBriefing struct {
+	UserID     string     `db:"user_id"`
+	Hour       int        `db:"hour"`
+	Minute     int        `db:"minute"`
+	Paused     bool       `db:"paused"`
+	Timezone   string     `db:"timezone"`
+	LastSentAt *time.Time `db:"last_sent_at"`
+	CreatedAt  time.Time  `db:"created_at"`
+}
+
+type Repository interface {
+	Subscribe(ctx context.Context, userID, hour, minute int) error
+	GetBriefing(ctx context.Context, userID int) (*Briefing, error)
+	SetPaused(ctx context.Context, userID int, paused bool) (bool, error)
+	Unsubscribe(ctx context.Context, userID int) (bool, error)
+	ListActive(ctx context.Context) ([]*Briefing, error)
+	MarkSent(ctx context.Context, userID int, at time.Time) error
+}
+
+type pgRepo struct {
+	db *sqlx.DB
+}
+
+var _ Repository = (*pgRepo)(nil)
+
+func NewPgRepository(db *sql.DB) *pgRepo {
+	return &pgRepo{db: sqlx.NewDb(db, "postgres")}
+}
+
+// Subscribe creates the user's subscription or changes its time, resuming it
+// if it was paused. The brief is considered to have been sent now, so changing
+// the time to one earlier in the day doesn't send it twice.
+func (r *pgRepo) Subscribe(ctx context.Context, userID, hour, minute int) error {
+	query := `
+	INSERT INTO briefings (user_id, hour, minute, last_sent_at)
+	VALUES ($1, $2, $3, now())
+	ON CONFLICT (user_id) DO UPDATE SET hour = $2, minute = $3, paused = FALSE, last_sent_at = now();`
+
+	_, err := r.db.ExecContext(ctx, query, fmt.Sprint(userID), hour, minute)
+	if err != nil {
+		return fmt.Errorf("upsert briefing: %w", err)
+	}
+
+	return nil
+}
+
+func (r *pgRepo) GetBriefing(ctx context.Context, userID int) (*Briefing, error) {
+	var b dbBriefing
+
+	query := `
+	SELECT b.user_id, b.hour, b.minute, b.paused, b.last_sent_at, b.created_at, u.timezone
+	FROM briefings b
+	INNER JOIN users u ON u.chat_id = b.user_id
+	WHERE b.user_id = $1;`
+
+	err := r.db.GetContext(ctx, &b, query, fmt.Sprint(userID))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("select briefing: %w", err)
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	return b.Map(), nil
+}
+
+// SetPaused pauses or resumes the user's subscription. It returns false if the
+// user isn't subscribed.
+func (r *pgRepo) SetPaused(ctx context.Context, userID int, paused bool) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `UPDATE briefings SET paused = $1 WHERE user_id = $2`, paused, fmt.Sprint(userID))
+	if err != nil {
+		return false, fmt.Errorf("update briefing: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("rows affected: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+func (r *pgRepo) Unsubscribe(ctx context.Context, userID int) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM briefings WHERE user_id = $1`, fmt.Sprint(userID))
+	if err != nil {
+		return false, fmt.Errorf("delete briefing: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("rows affected: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+func (r *pgRepo) ListActive(ctx context.Context) ([]*Briefing, error) {
+	var bb []dbBriefing
+
+	query := `
+	SELECT b.user_id, b.hour, b.minute, b.paused, b.last_sent_at, b.created_at, u.timezone
+	FROM briefings b
+	INNER JOIN users u ON u.chat_id = b.user_id
+	WHERE NOT b.paused AND u.active;`
+
+	err := r.db.SelectContext(ctx, &bb, query)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("select briefings: %w", err)
+	}
+
+	bbx := make([]*Briefing, len(bb))
+	for i := range bb {
+		bbx[i] = bb[i].Map()
+	}
+
+	return bbx, nil
+}
+
+func (r *pgRepo) MarkSent(ctx context.Context, userID int, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE briefings SET last_sent_at = $1 WHERE user_id = $2`, at, fmt.Sprint(userID))
+	if err != nil {
+		return fmt.Errorf("update briefing: %w", err)
+	}
+
+	return nil
+}
+
+func (b dbBriefing) Map() *Briefing {
+	uid, _ := strconv.Atoi(b.UserID)
+	return &Briefing{
+		UserID:     uid,
+		Hour:       b.Hour,
+		Minute:     b.Minute,
+		Paused:     b.Paused,
+		Timezone:   b.Timezone,
+		LastSentAt: b.LastSentAt,
+		CreatedAt:  b.CreatedAt,
+	}
+}
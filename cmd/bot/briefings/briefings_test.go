@@ -0,0 +1,62 @@
+package briefings_test
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/manzanit0/weathry/cmd/bot/briefings"
+)
+
+func TestDue(t *testing.T) {
+	// 07:30 in Madrid is 05:30 UTC in summer.
+	now := time.Date(2022, time.August, 1, 5, 45, 0, 0, time.UTC)
+	yesterday := now.Add(-24 * time.Hour)
+	earlierToday := now.Add(-10 * time.Minute)
+
+	testCases := []struct {
+		desc     string
+		briefing briefings.Briefing
+		want     bool
+	}{
+		{
+			desc:     "when it was last sent yesterday and the time has passed, it should be due",
+			briefing: briefings.Briefing{Hour: 7, Minute: 30, Timezone: "Europe/Madrid", LastSentAt: &yesterday},
+			want:     true,
+		},
+		{
+			desc:     "when it has already been sent today, it should not be due",
+			briefing: briefings.Briefing{Hour: 7, Minute: 30, Timezone: "Europe/Madrid", LastSentAt: &earlierToday},
+			want:     false,
+		},
+		{
+			desc:     "when the time hasn't come yet, it should not be due",
+			briefing: briefings.Briefing{Hour: 8, Minute: 0, Timezone: "Europe/Madrid", LastSentAt: &yesterday},
+			want:     false,
+		},
+		{
+			desc:     "when it's paused, it should not be due",
+			briefing: briefings.Briefing{Hour: 7, Minute: 30, Timezone: "Europe/Madrid", LastSentAt: &yesterday, Paused: true},
+			want:     false,
+		},
+		{
+			desc:     "when the time was too long ago, it should be skipped until tomorrow",
+			briefing: briefings.Briefing{Hour: 1, Minute: 0, Timezone: "Europe/Madrid", LastSentAt: &yesterday},
+			want:     false,
+		},
+		{
+			desc:     "when the timezone is UTC, the time should be in UTC",
+			briefing: briefings.Briefing{Hour: 5, Minute: 30, Timezone: "UTC", LastSentAt: &yesterday},
+			want:     true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got := tC.briefing.Due(now)
+			if got != tC.want {
+				t.Errorf("got %t, expected %t", got, tC.want)
+			}
+		})
+	}
+}
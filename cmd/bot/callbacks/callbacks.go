@@ -0,0 +1,73 @@
+// Package callbacks stores the payloads of inline buttons which are too long
+// to fit in their callback data, so both the bot and the pinger can use them.
+package callbacks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+// Retention is how long payloads are kept. Buttons on messages older than that
+// are answered as if their payload was never stored.
+const Retention = 30 * 24 * time.Hour
+
+type pgRepo struct {
+	db *sqlx.DB
+}
+
+var _ tgram.CallbackStore = (*pgRepo)(nil)
+
+func NewPgRepository(db *sql.DB) *pgRepo {
+	return &pgRepo{db: sqlx.NewDb(db, "postgres")}
+}
+
+func (r *pgRepo) SaveCallbackData(ctx context.Context, key, payload string) error {
+	_, err := r.db.ExecContext(ctx, `INSERT INTO callback_payloads (key, payload) VALUES ($1, $2)`, key, payload)
+	if err != nil {
+		return fmt.Errorf("insert callback payload: %w", err)
+	}
+
+	return nil
+}
+
+func (r *pgRepo) GetCallbackData(ctx context.Context, key string) (string, error) {
+	var payload string
+	query := `
+	SELECT payload
+	FROM callback_payloads
+	WHERE key = $1 AND created_at > now() - make_interval(secs => $2);`
+
+	err := r.db.GetContext(ctx, &payload, query, key, Retention.Seconds())
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", tgram.ErrCallbackDataNotFound
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("select callback payload: %w", err)
+	}
+
+	return payload, nil
+}
+
+// DeleteExpired deletes the payloads older than the retention period and
+// returns how many there were.
+func (r *pgRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `DELETE FROM callback_payloads WHERE created_at <= now() - make_interval(secs => $1)`
+	res, err := r.db.ExecContext(ctx, query, Retention.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("delete expired callback payloads: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("count deleted callback payloads: %w", err)
+	}
+
+	return n, nil
+}
@@ -9,9 +9,12 @@ import (
 )
 
 const (
-	QuestionHourlyWeather = "AWAITING_HOURLY_WEATHER_CITY"
-	QuestionDailyWeather  = "AWAITING_DAILY_WEATHER_CITY"
-	QuestionHome          = "AWAITING_HOME"
+	QuestionHourlyWeather  = "AWAITING_HOURLY_WEATHER_CITY"
+	QuestionDailyWeather   = "AWAITING_DAILY_WEATHER_CITY"
+	QuestionCurrentWeather = "AWAITING_CURRENT_WEATHER_CITY"
+	QuestionHome           = "AWAITING_HOME"
+	QuestionRule           = "AWAITING_RULE"
+	QuestionBriefTime      = "AWAITING_BRIEF_TIME"
 )
 
 type ConversationState struct {
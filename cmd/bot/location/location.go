@@ -143,7 +143,7 @@ func (r *pgRepo) GetHome(ctx context.Context, userID int) (*HomeLocation, error)
 		return nil, nil
 	}
 
-	home := HomeLocation{Location: Location{Name: u.Name}, UserID: userID}
+	home := HomeLocation{Location: *u.Map(), UserID: userID}
 	return &home, nil
 }
 
@@ -190,7 +190,8 @@ func (r *pgRepo) ListHomes(ctx context.Context) ([]*HomeLocation, error) {
 	SELECT lo.*, ul.user_id
 	FROM user_locations ul
 	INNER JOIN locations lo ON lo.name = ul.location_name
-	WHERE ul.is_home = True;`
+	INNER JOIN users u ON u.chat_id = ul.user_id
+	WHERE ul.is_home = True AND u.active;`
 
 	err := r.db.SelectContext(ctx, &homes, query)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
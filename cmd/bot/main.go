@@ -5,26 +5,36 @@ import (
 	"database/sql"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/jackc/pgx/v4/stdlib"
 
 	"github.com/manzanit0/weathry/cmd/bot/api"
+	"github.com/manzanit0/weathry/cmd/bot/briefings"
+	"github.com/manzanit0/weathry/cmd/bot/callbacks"
 	"github.com/manzanit0/weathry/cmd/bot/conversation"
 	"github.com/manzanit0/weathry/cmd/bot/location"
 	"github.com/manzanit0/weathry/cmd/bot/msg"
+	"github.com/manzanit0/weathry/cmd/bot/notifications"
+	"github.com/manzanit0/weathry/cmd/bot/rules"
+	"github.com/manzanit0/weathry/cmd/bot/schedules"
+	"github.com/manzanit0/weathry/cmd/bot/templates"
 	"github.com/manzanit0/weathry/cmd/bot/users"
 	"github.com/manzanit0/weathry/pkg/env"
 	"github.com/manzanit0/weathry/pkg/geocode"
 	"github.com/manzanit0/weathry/pkg/logger"
+	"github.com/manzanit0/weathry/pkg/metrics"
 	"github.com/manzanit0/weathry/pkg/middleware"
 	"github.com/manzanit0/weathry/pkg/tgram"
+	"github.com/manzanit0/weathry/pkg/tracing"
 	"github.com/manzanit0/weathry/pkg/weather"
 	"github.com/manzanit0/weathry/pkg/whttp"
 )
@@ -36,6 +46,11 @@ func init() {
 }
 
 func main() {
+	shutdownTracing, err := tracing.Init(ServiceName)
+	if err != nil {
+		panic(err)
+	}
+
 	db, err := sql.Open("pgx", os.Getenv("DATABASE_URL"))
 	if err != nil {
 		panic(fmt.Errorf("unable to open db conn: %w", err))
@@ -54,9 +69,16 @@ func main() {
 		slog.Info("connected to the database successfully")
 	}
 
-	convos := conversation.ConvoRepository{DB: db}
-
-	locations := location.NewPgRepository(db)
+	repositories := api.Repositories{
+		Convos:        &conversation.ConvoRepository{DB: db},
+		Locations:     location.NewPgRepository(db),
+		Rules:         rules.NewPgRepository(db),
+		Notifications: notifications.NewPgRepository(db),
+		Users:         users.NewPgRepository(db),
+		Schedules:     schedules.NewPgRepository(db),
+		Briefings:     briefings.NewPgRepository(db),
+		Templates:     templates.NewPgRepository(db),
+	}
 
 	owmClient, err := newWeatherClient()
 	if err != nil {
@@ -68,6 +90,11 @@ func main() {
 		panic(err)
 	}
 
+	tgramClient, err := newTelegramClient()
+	if err != nil {
+		panic(err)
+	}
+
 	errorTgramClient, err := env.NewErroryTgramClient()
 	if err != nil {
 		panic(err)
@@ -78,10 +105,17 @@ func main() {
 		panic(err)
 	}
 
+	callbackSecret, err := env.CallbackSecret()
+	if err != nil {
+		panic(err)
+	}
+
+	codec := tgram.NewCallbackCodec(callbackSecret, callbacks.NewPgRepository(db))
+
 	usersClient := users.NewDBClient(db)
 
 	r := gin.New()
-	r.Use(middleware.TraceID())
+	r.Use(middleware.Tracing())
 	r.Use(middleware.Recovery(errorTgramClient, myTelegramChatID))
 	r.Use(middleware.Logger(false))
 
@@ -91,8 +125,43 @@ func main() {
 		})
 	})
 
+	// The bot still serves what it can with an upstream down, so it's reported
+	// as degraded rather than unhealthy, which would get it restarted.
+	r.GET("/health", func(c *gin.Context) {
+		status := "ok"
+		upstreams := gin.H{}
+		for name, state := range whttp.Breakers() {
+			upstreams[name] = state.String()
+			if state != whttp.BreakerClosed {
+				status = "degraded"
+			}
+		}
+
+		c.JSON(200, gin.H{
+			"status":    status,
+			"upstreams": upstreams,
+		})
+	})
+
+	r.GET("/metrics", gin.WrapH(metrics.Default.Handler()))
+
+	metrics.NewGaugeFunc("weathry_active_users", "Users who haven't blocked the bot.", func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		n, err := repositories.Users.CountActive(ctx)
+		if err != nil {
+			slog.Error("count active users", "error", err.Error())
+			return math.NaN()
+		}
+
+		return float64(n)
+	})
+
+	r.Use(middleware.Metrics(commands...))
 	r.Use(middleware.TelegramAuth(usersClient))
-	r.POST("/telegram/webhook", telegramWebhookController(geocoder, owmClient, &convos, locations))
+	r.Use(middleware.CommandSpan(commands...))
+	r.POST("/telegram/webhook", telegramWebhookController(geocoder, owmClient, tgramClient, codec, repositories, myTelegramChatID))
 
 	// background job to ping users on weather changes
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
@@ -126,11 +195,35 @@ func main() {
 		slog.Error("server forced to shutdown", "error", err.Error())
 	}
 
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("failed to export pending spans", "error", err.Error())
+	}
+
 	slog.Info("server exited")
 }
 
 // @see https://core.telegram.org/bots/api#markdownv2-style
-func webhookResponse(p *tgram.WebhookRequest, text string) gin.H {
+//
+// Texts too long for a single message are split: all parts but the last are
+// sent straight to the API, since a response can only send one message, and
+// the last one goes in the response so that any keyboard ends up below it.
+func webhookResponse(ctx context.Context, t tgram.Client, p *tgram.WebhookRequest, text string) gin.H {
+	if parts := tgram.SplitMessage(text, tgram.ParseModeMarkdownV2, tgram.MaxMessageLength); len(parts) > 1 {
+		text = parts[len(parts)-1]
+		for i, part := range parts[:len(parts)-1] {
+			err := t.SendMessage(ctx, tgram.SendMessageRequest{
+				ChatID:    int64(p.GetFromID()),
+				Text:      part,
+				ParseMode: tgram.ParseModeMarkdownV2,
+			})
+			if err != nil {
+				slog.ErrorContext(ctx, "send message part", "part", i+1, "parts", len(parts), "error", err.Error(), "ctx.user_id", p.GetFromID())
+				text = msg.MsgUnexpectedError
+				break
+			}
+		}
+	}
+
 	return gin.H{
 		"method":     "sendMessage",
 		"chat_id":    p.GetFromID(),
@@ -139,14 +232,55 @@ func webhookResponse(p *tgram.WebhookRequest, text string) gin.H {
 	}
 }
 
+// @see https://core.telegram.org/bots/api#editmessagetext
+func editMessageResponse(p *tgram.WebhookRequest, text string, keyboard *tgram.ReplyMarkup) gin.H {
+	return withKeyboard(gin.H{
+		"method":     "editMessageText",
+		"chat_id":    p.GetFromID(),
+		"message_id": p.CallbackQuery.Message.MessageID,
+		"text":       text,
+		"parse_mode": "MarkdownV2",
+	}, keyboard)
+}
+
+// withKeyboard adds the inline keyboard to the response, if there's any.
+func withKeyboard(h gin.H, keyboard *tgram.ReplyMarkup) gin.H {
+	if keyboard != nil {
+		h["reply_markup"] = keyboard
+	}
+
+	return h
+}
+
+// @see https://core.telegram.org/bots/api#editmessagereplymarkup
+func editKeyboardResponse(p *tgram.WebhookRequest, keyboard *tgram.ReplyMarkup) gin.H {
+	return gin.H{
+		"method":       "editMessageReplyMarkup",
+		"chat_id":      p.GetFromID(),
+		"message_id":   p.CallbackQuery.Message.MessageID,
+		"reply_markup": keyboard,
+	}
+}
+
+// commands are the commands the bot understands, which webhook metrics are
+// labelled with.
+var commands = []string{
+	"/start", "/help", "/hourly", "/daily", "/day", "/weekend", "/now", "/home",
+	"/rule", "/rules", "/delrule", "/timezone", "/schedule", "/brief", "/mute",
+	"/quiet", "/history", "/view", "/template",
+}
+
 func telegramWebhookController(
 	geocoder geocode.Client,
 	weatherClient weather.Client,
-	convos *conversation.ConvoRepository,
-	locations location.Repository,
+	telegram tgram.Client,
+	codec *tgram.CallbackCodec,
+	repositories api.Repositories,
+	adminChatID int64,
 ) func(c *gin.Context) {
-	callbackCtrl := api.NewCallbackController(geocoder, weatherClient)
-	messageCtrl := api.NewMessageController(geocoder, weatherClient, convos, locations)
+	callbackCtrl := api.NewCallbackController(geocoder, weatherClient, repositories.Users, codec)
+	messageCtrl := api.NewMessageController(geocoder, weatherClient, codec, repositories, api.WithAdmin(adminChatID))
+	convos := repositories.Convos
 	return func(c *gin.Context) {
 		var p *tgram.WebhookRequest
 
@@ -158,13 +292,49 @@ func telegramWebhookController(
 		}
 
 		if p.IsCallbackQuery() {
-			message := callbackCtrl.ProcessCallbackQuery(p)
-			c.JSON(200, webhookResponse(p, message))
+			ctx := c.Request.Context()
+
+			// Buttons which weren't signed by us, or which were created
+			// before buttons were signed, aren't acted upon.
+			data, err := codec.Decode(ctx, p.CallbackQuery.Data)
+			if err != nil {
+				slog.Warn("rejected callback data", "callback_data", p.CallbackQuery.Data, "error", err.Error(), "ctx.user_id", p.GetFromID())
+				c.JSON(200, webhookResponse(c.Request.Context(), telegram, p, msg.MsgExpiredButton))
+				return
+			}
+
+			switch data.Action {
+			case msg.ActionBrief:
+				message, keyboard := messageCtrl.ProcessBriefCallback(ctx, p, data)
+				if keyboard != nil {
+					c.JSON(200, editKeyboardResponse(p, keyboard))
+					return
+				}
+
+				c.JSON(200, webhookResponse(c.Request.Context(), telegram, p, message))
+
+			case msg.ActionSnooze:
+				c.JSON(200, webhookResponse(c.Request.Context(), telegram, p, messageCtrl.ProcessSnoozeCallback(ctx, p, data)))
+
+			case msg.ActionForecast:
+				message, keyboard, edit := callbackCtrl.ProcessCallbackQuery(ctx, p, data)
+				if edit {
+					c.JSON(200, editMessageResponse(p, message, keyboard))
+					return
+				}
+
+				c.JSON(200, withKeyboard(webhookResponse(c.Request.Context(), telegram, p, message), keyboard))
+
+			default:
+				slog.Error("unknown callback action", "action", data.Action)
+				c.JSON(200, webhookResponse(c.Request.Context(), telegram, p, msg.MsgExpiredButton))
+			}
+
 			return
 		}
 
 		if p.Message == nil {
-			c.JSON(200, webhookResponse(p, msg.MsgUnsupportedInteraction))
+			c.JSON(200, webhookResponse(c.Request.Context(), telegram, p, msg.MsgUnsupportedInteraction))
 			return
 		}
 
@@ -175,35 +345,99 @@ func telegramWebhookController(
 			if question != "" {
 				_, err := convos.AddQuestion(ctx, fmt.Sprint(p.GetFromID()), question)
 				if err != nil {
-					c.JSON(200, webhookResponse(p, msg.MsgUnexpectedError))
+					c.JSON(200, webhookResponse(c.Request.Context(), telegram, p, msg.MsgUnexpectedError))
 					return
 				}
 
-				c.JSON(200, webhookResponse(p, prompt))
+				c.JSON(200, webhookResponse(c.Request.Context(), telegram, p, prompt))
 				return
 			}
 		}
 
 		var message string
+		var keyboard *tgram.ReplyMarkup
 
 		switch {
+		// NB: charts are matched before /daily and /hourly since they share
+		// the commands.
+		case api.IsChartCommand(p.Message.Text):
+			photo, reply := messageCtrl.ProcessChartCommand(ctx, p)
+			if photo == nil {
+				message = reply
+				break
+			}
+
+			if err := telegram.SendPhoto(ctx, *photo); err != nil {
+				slog.Error("send chart", "error", err.Error(), "ctx.user_id", p.GetFromID())
+				message = msg.MsgUnableToGetReport
+				break
+			}
+
+			// The chart went straight to the API, so there's nothing to reply.
+			c.JSON(200, gin.H{})
+			return
+
 		case strings.HasPrefix(p.Message.Text, "/daily"):
-			message = messageCtrl.ProcessDailyCommand(ctx, p)
+			message, keyboard = messageCtrl.ProcessDailyCommand(ctx, p)
 
 		case strings.HasPrefix(p.Message.Text, "/hourly"):
-			message = messageCtrl.ProcessHourlyCommand(ctx, p)
+			message, keyboard = messageCtrl.ProcessHourlyCommand(ctx, p)
+
+		// NB: /daily is matched before /day since they share the prefix.
+		case strings.HasPrefix(p.Message.Text, "/day"):
+			message = messageCtrl.ProcessDayCommand(ctx, p)
+
+		case strings.HasPrefix(p.Message.Text, "/weekend"):
+			message = messageCtrl.ProcessWeekendCommand(ctx, p)
+
+		case strings.HasPrefix(p.Message.Text, "/now"):
+			message = messageCtrl.ProcessNowCommand(ctx, p)
 
 		case strings.HasPrefix(p.Message.Text, "/home"):
 			message = messageCtrl.ProcessHomeCommand(ctx, p)
 
+		// NB: /rules must be matched before /rule since they share the prefix.
+		case strings.HasPrefix(p.Message.Text, "/rules"):
+			message = messageCtrl.ProcessRulesCommand(ctx, p)
+
+		case strings.HasPrefix(p.Message.Text, "/rule"):
+			message = messageCtrl.ProcessRuleCommand(ctx, p)
+
+		case strings.HasPrefix(p.Message.Text, "/delrule"):
+			message = messageCtrl.ProcessDeleteRuleCommand(ctx, p)
+
+		case strings.HasPrefix(p.Message.Text, "/timezone"):
+			message = messageCtrl.ProcessTimezoneCommand(ctx, p)
+
+		case strings.HasPrefix(p.Message.Text, "/schedule"):
+			message = messageCtrl.ProcessScheduleCommand(ctx, p)
+
+		case strings.HasPrefix(p.Message.Text, "/brief"):
+			message = messageCtrl.ProcessBriefCommand(ctx, p)
+
+		case strings.HasPrefix(p.Message.Text, "/mute"):
+			message = messageCtrl.ProcessMuteCommand(ctx, p)
+
+		case strings.HasPrefix(p.Message.Text, "/quiet"):
+			message = messageCtrl.ProcessQuietCommand(ctx, p)
+
+		case strings.HasPrefix(p.Message.Text, "/history"):
+			message = messageCtrl.ProcessHistoryCommand(ctx, p)
+
+		case strings.HasPrefix(p.Message.Text, "/view"):
+			message = messageCtrl.ProcessViewCommand(ctx, p)
+
+		case strings.HasPrefix(p.Message.Text, "/template"):
+			message = messageCtrl.ProcessTemplateCommand(ctx, p)
+
 		case strings.HasPrefix(p.Message.Text, "/help"):
-			message = fmt.Sprintf(msg.MsgHelp, p.GetFromFirstName())
+			message = msg.NewHelpMessage(p.GetFromFirstName())
 
 		default:
-			message = messageCtrl.ProcessNonCommand(ctx, p)
+			message, keyboard = messageCtrl.ProcessNonCommand(ctx, p)
 		}
 
-		c.JSON(200, webhookResponse(p, message))
+		c.JSON(200, withKeyboard(webhookResponse(c.Request.Context(), telegram, p, message), keyboard))
 	}
 }
 
@@ -213,8 +447,12 @@ func getQuestionAndPrompt(text string) (string, string) {
 		return conversation.QuestionDailyWeather, msg.MsgLocationQuestionDay
 	case "/hourly":
 		return conversation.QuestionHourlyWeather, msg.MsgLocationQuestionWeek
+	case "/now":
+		return conversation.QuestionCurrentWeather, msg.MsgLocationQuestionNow
 	case "/home":
 		return conversation.QuestionHome, msg.MsgHomeQuestion
+	case "/rule":
+		return conversation.QuestionRule, msg.NewRuleQuestionMessage()
 	default:
 		return "", ""
 	}
@@ -226,10 +464,20 @@ func newWeatherClient() (weather.Client, error) {
 		return nil, fmt.Errorf("missing OPENWEATHERMAP_API_KEY environment variable. Please check your environment.")
 	}
 
-	httpClient := whttp.NewLoggingClient()
-	return weather.NewOpenWeatherMapClient(httpClient, openWeatherMapAPIKey), nil
+	httpClient := whttp.NewClient(whttp.OpenWeatherMap)
+	return weather.NewCachedClient(weather.NewOpenWeatherMapClient(httpClient, openWeatherMapAPIKey), weather.DefaultCacheTTL), nil
 }
 
 func newGeocoder() (geocode.Client, error) {
-	return geocode.NewOpenstreetmapClient(), nil
+	return geocode.NewCachedClient(geocode.NewOpenstreetmapClient(whttp.NewClient(whttp.Nominatim)), geocode.DefaultCacheTTL), nil
+}
+
+func newTelegramClient() (tgram.Client, error) {
+	var telegramBotToken string
+	if telegramBotToken = os.Getenv("TELEGRAM_BOT_TOKEN"); telegramBotToken == "" {
+		return nil, fmt.Errorf("missing TELEGRAM_BOT_TOKEN environment variable. Please check your environment.")
+	}
+
+	httpClient := whttp.NewClient(whttp.Telegram)
+	return tgram.NewClient(httpClient, telegramBotToken), nil
 }
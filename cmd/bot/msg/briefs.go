@@ -0,0 +1,95 @@
+package msg
+
+import (
+	"context"
+
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+// Buttons on briefs.
+const (
+	BriefButtonPause  = "pause"
+	BriefButtonResume = "resume"
+	BriefButtonTime   = "time"
+)
+
+const briefCallbackVersion = 1
+
+var MsgBriefUsage = withBriefCommands(tgram.NewRichText().
+	Plain("I can send you a brief of the day's weather at home every morning. Subscribe with the time you want it at, such as ").
+	Code("/brief 07:30").
+	Plain(". You can also ")).
+	Plain(".\n\nTimes are in your timezone, which you can set with /timezone.").
+	MarkdownV2()
+
+var (
+	MsgBriefTimeQuestion  = plain("What time do you want your brief at? Something like 07:30 will do.")
+	MsgBriefInvalidTime   = plain("That doesn't look like a time of the day, try with something like 07:30.")
+	MsgBriefNotSubscribed = tgram.NewRichText().Plain("You aren't subscribed to the daily brief. Subscribe with something like ").Code("/brief 07:30").Plain(".").MarkdownV2()
+	MsgBriefPaused        = tgram.NewRichText().Plain("Done, I won't send you the brief until you ").Code("/brief resume").Plain(".").MarkdownV2()
+	MsgBriefResumed       = plain("Done, you'll get your brief again from tomorrow.")
+	MsgBriefUnsubscribed  = plain("Done, I won't send you the brief anymore.")
+	MsgBriefMissingHome   = plain("\n\nRemember to set your /home so I know which forecast to send you.")
+)
+
+// withBriefCommands appends the /brief commands besides the one to
+// subscribe.
+func withBriefCommands(t *tgram.RichText) *tgram.RichText {
+	return t.Code("/brief pause").Plain(", ").Code("/brief resume").Plain(" or ").Code("/brief off")
+}
+
+// NewBriefKeyboard returns the inline buttons of briefs, which depend on
+// whether the subscription is paused.
+func NewBriefKeyboard(ctx context.Context, codec *tgram.CallbackCodec, paused bool) (*tgram.ReplyMarkup, error) {
+	text, button := "⏸ Pause", BriefButtonPause
+	if paused {
+		text, button = "▶️ Resume", BriefButtonResume
+	}
+
+	toggle, err := newButton(ctx, codec, text, tgram.NewCallbackData(ActionBrief, briefCallbackVersion, button))
+	if err != nil {
+		return nil, err
+	}
+
+	changeTime, err := newButton(ctx, codec, "🕗 Change time", tgram.NewCallbackData(ActionBrief, briefCallbackVersion, BriefButtonTime))
+	if err != nil {
+		return nil, err
+	}
+
+	return &tgram.ReplyMarkup{
+		InlineKeyboard: [][]tgram.InlineKeyboardElement{{toggle, changeTime}},
+	}, nil
+}
+
+// ParseBriefCallback returns which of the brief's buttons was pressed.
+func ParseBriefCallback(d tgram.CallbackData) (string, error) {
+	if err := checkCallback(d, ActionBrief, briefCallbackVersion); err != nil {
+		return "", err
+	}
+
+	return d.String(0)
+}
+
+func NewBriefStatusMessage(at, timezone string, paused bool) string {
+	status := "I send you the brief"
+	if paused {
+		status = "Your brief is paused, but it's set to be sent"
+	}
+
+	t := tgram.NewRichText().
+		Plain(status + " every day at " + at + ", in the ").
+		Code(timezone).
+		Plain(" timezone. Change it with something like ").
+		Code("/brief 07:30").
+		Plain(", or ")
+
+	return withBriefCommands(t).Plain(".").MarkdownV2()
+}
+
+func NewBriefSubscribedMessage(at, timezone string) string {
+	return tgram.NewRichText().
+		Plain("Done! I'll send you a brief of the day's weather at home every day at " + at + ", in the ").
+		Code(timezone).
+		Plain(" timezone.").
+		MarkdownV2()
+}
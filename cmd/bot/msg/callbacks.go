@@ -0,0 +1,38 @@
+package msg
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+// Actions of the inline buttons, as encoded in their callback data. They're
+// short since every byte counts against Telegram's limit.
+const (
+	ActionForecast = "fc"
+	ActionSnooze   = "sn"
+	ActionBrief    = "br"
+)
+
+var MsgExpiredButton = plain("That button is from an older version of me and doesn't work anymore. Try the commands in /help instead.")
+
+// newButton encodes the callback data of an inline button.
+func newButton(ctx context.Context, codec *tgram.CallbackCodec, text string, data tgram.CallbackData) (tgram.InlineKeyboardElement, error) {
+	encoded, err := codec.Encode(ctx, data)
+	if err != nil {
+		return tgram.InlineKeyboardElement{}, fmt.Errorf("encode %s button: %w", data.Action, err)
+	}
+
+	return tgram.InlineKeyboardElement{Text: text, CallbackData: encoded}, nil
+}
+
+// checkCallback makes sure the callback data is of the given action and
+// version.
+func checkCallback(d tgram.CallbackData, action string, version int) error {
+	if d.Action != action || d.Version != version {
+		return fmt.Errorf("%w: expected %s v%d, got %s v%d", tgram.ErrInvalidCallbackData, action, version, d.Action, d.Version)
+	}
+
+	return nil
+}
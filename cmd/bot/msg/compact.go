@@ -0,0 +1,156 @@
+package msg
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/manzanit0/weathry/cmd/bot/location"
+	"github.com/manzanit0/weathry/pkg/tgram"
+	"github.com/manzanit0/weathry/pkg/weather"
+)
+
+// Layout is how daily forecasts are rendered.
+type Layout string
+
+const (
+	// LayoutTable renders a table with a row per day.
+	LayoutTable Layout = "table"
+
+	// LayoutCompact renders a line per day, such as "Tue 🌧 12–18° ▂▃▅▇ 60%".
+	LayoutCompact Layout = "compact"
+)
+
+// ParseLayout returns the layout called name, or false if there's none.
+func ParseLayout(name string) (Layout, bool) {
+	switch l := Layout(strings.ToLower(strings.TrimSpace(name))); l {
+	case LayoutTable, LayoutCompact:
+		return l, true
+	default:
+		return "", false
+	}
+}
+
+var MsgLayoutUsage = tgram.NewRichText().
+	Plain("Pick how I show you daily forecasts: ").
+	Code("/view table").
+	Plain(" for a table, or ").
+	Code("/view compact").
+	Plain(" for a line per day.").
+	MarkdownV2()
+
+func NewCurrentLayoutMessage(l Layout) string {
+	return tgram.NewRichText().
+		Plain("I'm showing you daily forecasts as a ").
+		Bold(layoutName(l)).
+		Plain(". Switch with ").
+		Code("/view table").
+		Plain(" or ").
+		Code("/view compact").
+		Plain(".").
+		MarkdownV2()
+}
+
+func NewLayoutSetMessage(l Layout) string {
+	return tgram.NewRichText().
+		Plain("Done! From now on I'll show you daily forecasts as a ").
+		Bold(layoutName(l)).
+		Plain(".").
+		MarkdownV2()
+}
+
+func layoutName(l Layout) string {
+	if l == LayoutCompact {
+		return "line per day"
+	}
+
+	return "table"
+}
+
+// sparks are the bars of sparklines, from lowest to highest.
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// NewCompactForecastMessage renders the daily forecasts a line per day, with
+// the condition, the temperatures, a sparkline of the hourly temperatures of
+// the day and the chance of precipitation. The hourly forecasts only cover a
+// few days, so the last days might have no sparkline.
+func NewCompactForecastMessage(loc *location.Location, daily, hourly []*weather.Forecast, opts ...MessageOption) string {
+	if len(daily) == 0 {
+		return msgNoForecasts
+	}
+
+	options := messageOptions{location: time.UTC}
+	for _, f := range opts {
+		f(&options)
+	}
+
+	// Sparklines share their scale, so days can be compared at a glance. It
+	// only spans the hours which are drawn, lest hours of other days flatten
+	// them.
+	lo, hi := math.Inf(1), math.Inf(-1)
+	temps := make([][]float64, len(daily))
+	for i, d := range daily {
+		day := d.Time().In(options.location)
+		for _, f := range hourly {
+			if sameDay(day, f.Time().In(options.location)) {
+				temps[i] = append(temps[i], f.MinimumTemperature)
+				lo, hi = min(lo, f.MinimumTemperature), max(hi, f.MinimumTemperature)
+			}
+		}
+	}
+
+	t := tgram.NewRichText().Bold(loc.Name).Plain("\n")
+	for i, d := range daily {
+		day := d.Time().In(options.location)
+
+		line := fmt.Sprintf("%s %s %.0f–%.0f°", day.Format("Mon"), ConditionEmoji(d.Condition), d.MinimumTemperature, d.MaximumTemperature)
+		if len(temps[i]) > 0 {
+			line += " " + sparkline(temps[i], lo, hi)
+		}
+
+		line += fmt.Sprintf(" %.0f%%", d.PrecipitationProbability*100)
+
+		t.Plain("\n" + line)
+	}
+
+	return t.MarkdownV2()
+}
+
+// sparkline draws the values as bars, scaled from lo to hi.
+func sparkline(values []float64, lo, hi float64) string {
+	var sb strings.Builder
+	for _, v := range values {
+		i := 0
+		if hi > lo {
+			i = int(math.Round((v - lo) / (hi - lo) * float64(len(sparks)-1)))
+		}
+
+		sb.WriteRune(sparks[max(0, min(i, len(sparks)-1))])
+	}
+
+	return sb.String()
+}
+
+// ConditionEmoji returns the emoji of the condition, which are those of
+// weather.Forecast.
+func ConditionEmoji(condition string) string {
+	switch condition {
+	case "clear":
+		return "☀️"
+	case "clouds":
+		return "☁️"
+	case "rain":
+		return "🌧"
+	case "drizzle":
+		return "🌦"
+	case "thunderstorm":
+		return "⛈"
+	case "snow":
+		return "❄️"
+	case "atmosphere":
+		return "🌫"
+	default:
+		return "🌡"
+	}
+}
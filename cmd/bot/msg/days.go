@@ -0,0 +1,17 @@
+package msg
+
+import "github.com/manzanit0/weathry/pkg/tgram"
+
+var MsgDayUsage = tgram.NewRichText().
+	Plain("Tell me the day and, optionally, the place, like ").
+	Code("/day saturday").Plain(", ").Code("/day tomorrow Lisbon").Plain(" or ").Code("/day 24/12 Madrid").
+	Plain(". Without a place, I'll check your /home.").
+	MarkdownV2()
+
+var MsgDaysMissingPlace = tgram.NewRichText().
+	Plain("Where? Tell me the place, like ").
+	Code("/weekend Lisbon").
+	Plain(", or set your /home.").
+	MarkdownV2()
+
+var MsgDaysOutOfRange = plain("I can only see the next five days hour by hour, so that's too far ahead. Try /daily for the rest of the week.")
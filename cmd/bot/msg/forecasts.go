@@ -0,0 +1,150 @@
+package msg
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/manzanit0/weathry/cmd/bot/location"
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+// ForecastView is what a forecast message shows.
+type ForecastView string
+
+const (
+	ViewHourly ForecastView = "h"
+	ViewDaily  ForecastView = "d"
+	ViewNow    ForecastView = "n"
+)
+
+// Rows of each page of forecasts: a day of hourly ones, or a week of daily
+// ones.
+const (
+	HourlyPageSize = 9
+	DailyPageSize  = 7
+)
+
+const forecastCallbackVersion = 1
+
+// ForecastCallback is the callback data of the forecast buttons. InPlace
+// tells whether pressing the button edits the message it's on, as when paging,
+// or sends a new one, as from alerts.
+type ForecastCallback struct {
+	View      ForecastView
+	Page      int
+	InPlace   bool
+	Latitude  float64
+	Longitude float64
+}
+
+func (c ForecastCallback) CallbackData() tgram.CallbackData {
+	return tgram.NewCallbackData(ActionForecast, forecastCallbackVersion, string(c.View), c.Page, c.InPlace, c.Latitude, c.Longitude)
+}
+
+func ParseForecastCallback(d tgram.CallbackData) (ForecastCallback, error) {
+	var c ForecastCallback
+	if err := checkCallback(d, ActionForecast, forecastCallbackVersion); err != nil {
+		return c, err
+	}
+
+	view, err := d.String(0)
+	if err != nil {
+		return c, err
+	}
+
+	switch v := ForecastView(view); v {
+	case ViewHourly, ViewDaily, ViewNow:
+		c.View = v
+	default:
+		return c, fmt.Errorf("%w: unknown view %q", tgram.ErrInvalidCallbackData, view)
+	}
+
+	c.Page, err = d.Int(1)
+	if err != nil {
+		return c, err
+	}
+
+	if c.Page < 0 {
+		return c, fmt.Errorf("%w: negative page %d", tgram.ErrInvalidCallbackData, c.Page)
+	}
+
+	c.InPlace, err = d.Bool(2)
+	if err != nil {
+		return c, err
+	}
+
+	c.Latitude, err = d.Float(3)
+	if err != nil {
+		return c, err
+	}
+
+	c.Longitude, err = d.Float(4)
+	if err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+// NewForecastPageKeyboard returns the buttons to move around the pages of a
+// forecast message and switch between the hourly and daily views.
+func NewForecastPageKeyboard(ctx context.Context, codec *tgram.CallbackCodec, view ForecastView, page, pages int, latitude, longitude float64) (*tgram.ReplyMarkup, error) {
+	at := func(text string, v ForecastView, p int) (tgram.InlineKeyboardElement, error) {
+		c := ForecastCallback{View: v, Page: p, InPlace: true, Latitude: latitude, Longitude: longitude}
+		return newButton(ctx, codec, text, c.CallbackData())
+	}
+
+	var paging []tgram.InlineKeyboardElement
+	if page > 0 {
+		earlier, err := at("◀ Earlier", view, page-1)
+		if err != nil {
+			return nil, err
+		}
+
+		paging = append(paging, earlier)
+	}
+
+	if page < pages-1 {
+		later, err := at("Later ▶", view, page+1)
+		if err != nil {
+			return nil, err
+		}
+
+		paging = append(paging, later)
+	}
+
+	toggle, err := at("⏰ Hourly", ViewHourly, 0)
+	if view == ViewHourly {
+		toggle, err = at("📆 Daily", ViewDaily, 0)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	keyboard := &tgram.ReplyMarkup{}
+	if len(paging) > 0 {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, paging)
+	}
+
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []tgram.InlineKeyboardElement{toggle})
+
+	return keyboard, nil
+}
+
+var MsgChartMissingPlace = tgram.NewRichText().
+	Plain("Where? Tell me the place, like ").
+	Code("/hourly chart Lisbon").
+	Plain(", or set your /home.").
+	MarkdownV2()
+
+// NewForecastChartCaption is the caption of the chart of the forecasts of
+// the place.
+func NewForecastChartCaption(l *location.Location, view ForecastView) string {
+	title := "⏰ Hourly forecast for "
+	if view == ViewDaily {
+		title = "📆 Daily forecast for "
+	}
+
+	return tgram.NewRichText().Plain(title).Bold(l.Name).MarkdownV2()
+}
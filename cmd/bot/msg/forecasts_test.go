@@ -0,0 +1,134 @@
+package msg_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/manzanit0/weathry/cmd/bot/msg"
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+func TestParseForecastCallback(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		callback msg.ForecastCallback
+	}{
+		{
+			desc:     "hourly page in place",
+			callback: msg.ForecastCallback{View: msg.ViewHourly, Page: 2, InPlace: true, Latitude: 40.4168, Longitude: -3.7038},
+		},
+		{
+			desc:     "daily in a new message",
+			callback: msg.ForecastCallback{View: msg.ViewDaily, Latitude: -33.8688, Longitude: 151.2093},
+		},
+		{
+			desc:     "now",
+			callback: msg.ForecastCallback{View: msg.ViewNow, Latitude: 1.5, Longitude: 2.5},
+		},
+	}
+
+	codec := tgram.NewCallbackCodec([]byte("secret"), nil)
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			data, err := codec.Encode(context.Background(), tC.callback.CallbackData())
+			if err != nil {
+				t.Fatalf("unexpected error encoding: %s", err.Error())
+			}
+
+			decoded, err := codec.Decode(context.Background(), data)
+			if err != nil {
+				t.Fatalf("unexpected error decoding: %s", err.Error())
+			}
+
+			got, err := msg.ParseForecastCallback(decoded)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			if got != tC.callback {
+				t.Errorf("got %+v, expected %+v", got, tC.callback)
+			}
+		})
+	}
+
+	invalid := []struct {
+		desc string
+		data tgram.CallbackData
+	}{
+		{desc: "other action", data: tgram.NewCallbackData(msg.ActionSnooze, 1, "h", 0, false, 1.0, 2.0)},
+		{desc: "other version", data: tgram.NewCallbackData(msg.ActionForecast, 2, "h", 0, false, 1.0, 2.0)},
+		{desc: "unknown view", data: tgram.NewCallbackData(msg.ActionForecast, 1, "x", 0, false, 1.0, 2.0)},
+		{desc: "negative page", data: tgram.NewCallbackData(msg.ActionForecast, 1, "h", -1, false, 1.0, 2.0)},
+		{desc: "missing longitude", data: tgram.NewCallbackData(msg.ActionForecast, 1, "h", 0, false, 1.0)},
+	}
+
+	for _, tC := range invalid {
+		t.Run("rejects "+tC.desc, func(t *testing.T) {
+			if _, err := msg.ParseForecastCallback(tC.data); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestNewForecastPageKeyboard(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		view     msg.ForecastView
+		page     int
+		pages    int
+		expected [][]string
+	}{
+		{desc: "first page", view: msg.ViewHourly, page: 0, pages: 5, expected: [][]string{{"Later ▶"}, {"📆 Daily"}}},
+		{desc: "middle page", view: msg.ViewHourly, page: 2, pages: 5, expected: [][]string{{"◀ Earlier", "Later ▶"}, {"📆 Daily"}}},
+		{desc: "last page", view: msg.ViewDaily, page: 1, pages: 2, expected: [][]string{{"◀ Earlier"}, {"⏰ Hourly"}}},
+		{desc: "single page", view: msg.ViewDaily, page: 0, pages: 1, expected: [][]string{{"⏰ Hourly"}}},
+	}
+
+	ctx := context.Background()
+	codec := tgram.NewCallbackCodec([]byte("secret"), nil)
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			k, err := msg.NewForecastPageKeyboard(ctx, codec, tC.view, tC.page, tC.pages, 40.4168, -3.7038)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			if len(k.InlineKeyboard) != len(tC.expected) {
+				t.Fatalf("got %d rows, expected %d", len(k.InlineKeyboard), len(tC.expected))
+			}
+
+			for i, row := range k.InlineKeyboard {
+				if len(row) != len(tC.expected[i]) {
+					t.Fatalf("got %d buttons in row %d, expected %d", len(row), i, len(tC.expected[i]))
+				}
+
+				for j, button := range row {
+					if button.Text != tC.expected[i][j] {
+						t.Errorf("got button %q, expected %q", button.Text, tC.expected[i][j])
+					}
+
+					if len(button.CallbackData) > tgram.MaxCallbackDataLength {
+						t.Errorf("button %q has %d bytes of callback data", button.Text, len(button.CallbackData))
+					}
+
+					data, err := codec.Decode(ctx, button.CallbackData)
+					if err != nil {
+						t.Fatalf("button %q has invalid callback data %q: %s", button.Text, button.CallbackData, err.Error())
+					}
+
+					c, err := msg.ParseForecastCallback(data)
+					if err != nil {
+						t.Errorf("button %q has invalid forecast callback: %s", button.Text, err.Error())
+					}
+
+					if !c.InPlace {
+						t.Errorf("button %q should edit the message in place", button.Text)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package msg
+
+import (
+	"time"
+
+	"github.com/manzanit0/weathry/cmd/bot/notifications"
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+var MsgHistoryEmpty = plain("I haven't sent you any alerts yet. Set your /home so I can keep an eye on the weather for you!")
+
+// NewHistoryMessage lists the notifications, with the times they were sent at
+// in the location of the options, UTC by default.
+func NewHistoryMessage(nn []*notifications.Notification, opts ...MessageOption) string {
+	if len(nn) == 0 {
+		return MsgHistoryEmpty
+	}
+
+	options := messageOptions{location: time.UTC}
+	for _, f := range opts {
+		f(&options)
+	}
+
+	t := tgram.NewRichText().Plain("These are the last alerts I sent you:\n")
+	for _, n := range nn {
+		t.Plain("\n").Bold(n.CreatedAt.In(options.location).Format("Mon, 02 Jan 15:04")).Plain("\n" + n.Message + "\n")
+	}
+
+	return t.MarkdownV2()
+}
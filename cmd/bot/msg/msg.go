@@ -7,71 +7,68 @@ import (
 	"time"
 
 	"github.com/manzanit0/weathry/cmd/bot/location"
+	"github.com/manzanit0/weathry/pkg/tgram"
 	"github.com/manzanit0/weathry/pkg/weather"
 	"github.com/olekukonko/tablewriter"
 )
 
-const (
-	MsgLocationQuestionGeneric = "What location do you want me to check the weather for?"
-	MsgLocationQuestionWeek    = "What location do you want me to check this week\\'s weather for?"
-	MsgLocationQuestionDay     = "What location do you want me to check today\\'s weather for?"
-	MsgHomeQuestion            = "What location do you want to save as your home?"
-	MsgUnknownText             = "I\\'m not sure what you mean with that\\. Try hitting me up with the /hourly or /daily commands if you need me to check the weather for you ☔️"
-	MsgUnableToGetReport       = "I\\'m sorry, the network isn\\'t doing it\\'s best job and I can\\'t get your report just now\\. Please try again in a bit\\."
-	MsgUnsupportedInteraction  = "Unsupported type of interaction"
-	MsgUnexpectedError         = "Whops\\! Something\\'s not working like it should\\. Try again in a bit\\."
-	MsgHelp                    = "👋 Hi %s\\! My name is weathry, great to meet you\\!\n\nI\\'ve been programmed to pretty much help you with any of your weather needs\\. These are some of the things I can do\\:\n\n1\\. /hourly, Check the hourly forcast for you\\.\n2\\. /daily, Check the whole week's forcast for you\\.\n3\\. /home, Keep track of your home so I can send you timely reminders of when there's going to be a weather change\\.\n\nWith regards to the reminders I can send, I just track low and high temperatures and rain\\. This means that if the temperature drops or increases too much in an upcoming day, or it\\'s simply going to rain, then I\\'ll let you know\\."
+var (
+	MsgLocationQuestionGeneric = plain("What location do you want me to check the weather for?")
+	MsgLocationQuestionWeek    = plain("What location do you want me to check this week's weather for?")
+	MsgLocationQuestionDay     = plain("What location do you want me to check today's weather for?")
+	MsgHomeQuestion            = plain("What location do you want to save as your home?")
+	MsgUnknownText             = plain("I'm not sure what you mean with that. Try hitting me up with the /hourly or /daily commands if you need me to check the weather for you, or just ask me something like \"will it rain tomorrow in Lisbon?\" ☔️")
+	MsgUnableToGetReport       = plain("I'm sorry, the network isn't doing it's best job and I can't get your report just now. Please try again in a bit.")
+	MsgUnsupportedInteraction  = plain("Unsupported type of interaction")
+	MsgUnexpectedError         = plain("Whops! Something's not working like it should. Try again in a bit.")
+
+	msgNoForecasts = plain("hey, not sure why but I couldn't get any forecasts ¯\\_(ツ)_/¯")
 )
 
-func NewEmojifiedDailyMessage(f []*weather.Forecast) string {
-	if len(f) == 0 {
-		return "hey, not sure why but I couldn't get any forecasts ¯\\_(ツ)_/¯"
-	}
-
-	// TODO: extract from here...
-	// we just want the next 3 forecasts
-	ff := make([]*weather.Forecast, 3)
-	for i := 0; i < 3; i++ {
-		ff[i] = f[i]
-	}
-
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("Weather Report for %s", f[0].Location))
-	for _, v := range f {
-		ts := v.FormattedDateTime()
-		sb.WriteString(fmt.Sprintf(`
-- - - - - - - - - - - - - - - - - - - - - -
-📅 %s
-🏷 %s
-🌡 %0.2f°C - %0.2f°C
-💨 %0.2f m/s
-💧 %d%%`, ts, v.Description, v.MinimumTemperature, v.MaximumTemperature, v.WindSpeed, v.Humidity))
-	}
+// plain renders text without any formatting, for messages sent as
+// MarkdownV2.
+func plain(text string) string {
+	return tgram.NewRichText().Plain(text).MarkdownV2()
+}
 
-	sb.WriteString("\n- - - - - - - - - - - - - - - - - - - - - -")
+func NewHelpMessage(firstName string) string {
+	return tgram.NewRichText().
+		Plain("👋 Hi " + firstName + "! My name is weathry, great to meet you!\n\n").
+		Plain("I've been programmed to pretty much help you with any of your weather needs. These are some of the things I can do:\n\n").
+		Plain("1. /hourly, Check the hourly forcast for you. Or /now for the current weather.\n").
+		Plain("2. /daily, Check the whole week's forcast for you. Or a single /day and the /weekend hour by hour.\n").
+		Plain("3. /home, Keep track of your home so I can send you timely reminders of when there's going to be a weather change.\n").
+		Plain("4. /rule, Tell me exactly what weather you want to be warned about, like rain during your commute. Check them with /rules.\n").
+		Plain("5. /history, Check the last alerts I sent you.\n").
+		Plain("6. /schedule, Pick when I check the weather of your home, in your /timezone.\n").
+		Plain("7. /brief, Get a brief of the day's weather at home every morning.\n").
+		Plain("8. /quiet and /mute, Hold back my alerts during the night or for a while.\n").
+		Plain("9. /view, Pick whether I show you daily forecasts as a table or a line per day.\n").
+		Plain("10. /template, Word my alerts and briefs your way.\n\n").
+		Plain("With regards to the reminders I can send, I just track low and high temperatures and rain. This means that if the temperature drops or increases too much in an upcoming day, or it's simply going to rain, then I'll let you know.").
+		MarkdownV2()
+}
 
-	return sb.String()
+func NewHomeSetMessage(locationName string) string {
+	return tgram.NewRichText().
+		Plain("Successfully set ").
+		Bold(locationName).
+		Plain(" as your home! From now on, I'll let you know of any relevant weather changes there 🙂").
+		MarkdownV2()
 }
 
-func NewEmojifiedHourlyMessage(f []*weather.Forecast) string {
+func NewEmojifiedHourlyMessage(f []*weather.Forecast, loc *time.Location) string {
 	if len(f) == 0 {
-		return "hey, not sure why but I couldn't get any forecasts ¯\\_(ツ)_/¯"
+		return msgNoForecasts
 	}
 
-	// TODO: extract from here...
 	// we just want the next 9 forecasts
-	ff := make([]*weather.Forecast, 9)
-	for i := 0; i < 9; i++ {
-		ff[i] = f[i]
-	}
+	ff := f[:min(len(f), 9)]
 
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("Weather Report for %s", f[0].Location))
 	for _, v := range ff {
-		ts, err := v.LocalTime("Europe/Madrid")
-		if err != nil {
-			ts = v.FormattedDateTime()
-		}
+		ts := v.Time().In(loc).Format("Mon, 02 Jan 15:04 MST")
 
 		sb.WriteString(fmt.Sprintf(`
 - - - - - - - - - - - - - - - - - - - - - -
@@ -84,12 +81,13 @@ func NewEmojifiedHourlyMessage(f []*weather.Forecast) string {
 
 	sb.WriteString("\n- - - - - - - - - - - - - - - - - - - - - -")
 
-	return sb.String()
+	return plain(sb.String())
 }
 
 type messageOptions struct {
 	withTempDiff bool
 	withTime     bool
+	location     *time.Location
 }
 
 type MessageOption func(*messageOptions)
@@ -106,16 +104,55 @@ func WithTime() MessageOption {
 	}
 }
 
+// InLocation renders dates and times in the given location. Defaults to UTC.
+func InLocation(loc *time.Location) MessageOption {
+	return func(config *messageOptions) {
+		if loc != nil {
+			config.location = loc
+		}
+	}
+}
+
 func NewForecastTableMessage(loc *location.Location, f []*weather.Forecast, opts ...MessageOption) string {
 	if len(f) == 0 {
-		return "hey, not sure why but I couldn't get any forecasts ¯\\_(ツ)_/¯"
+		return msgNoForecasts
 	}
 
-	options := messageOptions{withTempDiff: false, withTime: false}
+	options := messageOptions{withTempDiff: false, withTime: false, location: time.UTC}
 	for _, f := range opts {
 		f(&options)
 	}
 
+	if !options.withTime {
+		return newPreMessage(fmt.Sprintf("%s  \n%s", loc.Name, renderForecastTable(f, options)))
+	}
+
+	// Hourly forecasts are split by day, so each gets the right date header.
+	days := splitByDay(f, options.location)
+	if len(days) == 1 {
+		return newPreMessage(fmt.Sprintf("%s  \n%s  \n%s",
+			days[0][0].Time().In(options.location).Format("Mon, 02 Jan 2006"),
+			loc.Name,
+			renderForecastTable(days[0], options),
+		))
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s  \n", loc.Name))
+	for _, day := range days {
+		sb.WriteString(fmt.Sprintf("\n%s  \n%s", day[0].Time().In(options.location).Format("Mon, 02 Jan 2006"), renderForecastTable(day, options)))
+	}
+
+	return newPreMessage(sb.String())
+}
+
+// newPreMessage renders text as a single block of monospace text, which keeps
+// tables aligned.
+func newPreMessage(text string) string {
+	return tgram.NewRichText().Pre(strings.TrimSuffix(text, "\n"), "").MarkdownV2()
+}
+
+func renderForecastTable(f []*weather.Forecast, options messageOptions) string {
 	b := bytes.NewBuffer([]byte{})
 	table := tablewriter.NewWriter(b)
 
@@ -131,9 +168,9 @@ func NewForecastTableMessage(loc *location.Location, f []*weather.Forecast, opts
 			temp = fmt.Sprintf("%.0fºC - %.0fºC", v.MinimumTemperature, v.MaximumTemperature)
 		}
 
-		dt := v.FormattedDate()
+		dt := v.FormattedDate(options.location)
 		if options.withTime {
-			dt = v.FormattedTime()
+			dt = v.FormattedTime(options.location)
 		}
 
 		table.Append([]string{dt, fmt.Sprintf("%s\n%s", v.Description, temp)})
@@ -145,17 +182,23 @@ func NewForecastTableMessage(loc *location.Location, f []*weather.Forecast, opts
 
 	table.Render()
 
-	// we're making the assumption here that all forecasts belong to the same day.
-	if options.withTime {
-		return fmt.Sprintf("```\n%s  \n%s  \n%s```",
-			time.Unix(int64(f[0].DateTimeTS), 0).Format("Mon, 02 Jan 2006"),
-			loc.Name,
-			b.String(),
-		)
+	return b.String()
+}
+
+// splitByDay groups consecutive forecasts which fall on the same day in loc.
+func splitByDay(f []*weather.Forecast, loc *time.Location) [][]*weather.Forecast {
+	var days [][]*weather.Forecast
+	for i, v := range f {
+		if i == 0 || !sameDay(f[i-1].Time().In(loc), v.Time().In(loc)) {
+			days = append(days, nil)
+		}
+
+		days[len(days)-1] = append(days[len(days)-1], v)
 	}
 
-	return fmt.Sprintf("```\n%s  \n%s```",
-		loc.Name,
-		b.String(),
-	)
+	return days
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
 }
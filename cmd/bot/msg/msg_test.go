@@ -0,0 +1,174 @@
+package msg_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/manzanit0/weathry/cmd/bot/location"
+	"github.com/manzanit0/weathry/cmd/bot/msg"
+	"github.com/manzanit0/weathry/cmd/bot/notifications"
+	"github.com/manzanit0/weathry/pkg/weather"
+)
+
+func TestNewForecastTableMessage(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Fatalf("load location: %s", err.Error())
+	}
+
+	at := func(day, hour int) *weather.Forecast {
+		return &weather.Forecast{Description: "clear sky", DateTimeTS: int(time.Date(2022, time.August, day, hour, 0, 0, 0, time.UTC).Unix())}
+	}
+
+	l := &location.Location{Name: "Madrid"}
+
+	testCases := []struct {
+		desc      string
+		forecasts []*weather.Forecast
+		loc       *time.Location
+		headers   []string
+	}{
+		{
+			desc:      "forecasts of a single day get a single header",
+			forecasts: []*weather.Forecast{at(6, 9), at(6, 12), at(6, 15)},
+			loc:       time.UTC,
+			headers:   []string{"Sat, 06 Aug 2022"},
+		},
+		{
+			desc:      "forecasts across days get a header per day",
+			forecasts: []*weather.Forecast{at(6, 18), at(6, 21), at(7, 0), at(7, 3)},
+			loc:       time.UTC,
+			headers:   []string{"Sat, 06 Aug 2022", "Sun, 07 Aug 2022"},
+		},
+		{
+			desc:      "days are split in the given location",
+			forecasts: []*weather.Forecast{at(6, 18), at(6, 21), at(7, 0)},
+			loc:       madrid,
+			headers:   []string{"Sat, 06 Aug 2022", "Sun, 07 Aug 2022"},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			m := msg.NewForecastTableMessage(l, tC.forecasts, msg.WithTime(), msg.InLocation(tC.loc))
+
+			last := -1
+			for _, h := range tC.headers {
+				i := strings.Index(m, h)
+				if i <= last {
+					t.Fatalf("expected header %q after position %d in:\n%s", h, last, m)
+				}
+
+				last = i
+			}
+
+			for _, h := range []string{"Fri, 05 Aug 2022", "Mon, 08 Aug 2022"} {
+				if strings.Contains(m, h) {
+					t.Errorf("unexpected header %q in:\n%s", h, m)
+				}
+			}
+
+			if got := strings.Count(m, "Aug 2022"); got != len(tC.headers) {
+				t.Errorf("got %d headers, expected %d in:\n%s", got, len(tC.headers), m)
+			}
+		})
+	}
+}
+
+func TestNewCompactForecastMessage(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Fatalf("load location: %s", err.Error())
+	}
+
+	day := func(d int, condition string, lo, hi, pop float64) *weather.Forecast {
+		return &weather.Forecast{
+			Condition:                condition,
+			MinimumTemperature:       lo,
+			MaximumTemperature:       hi,
+			PrecipitationProbability: pop,
+			DateTimeTS:               int(time.Date(2022, time.August, d, 10, 0, 0, 0, time.UTC).Unix()),
+		}
+	}
+
+	hour := func(d, h int, temp float64) *weather.Forecast {
+		return &weather.Forecast{MinimumTemperature: temp, DateTimeTS: int(time.Date(2022, time.August, d, h, 0, 0, 0, time.UTC).Unix())}
+	}
+
+	l := &location.Location{Name: "St. John's"}
+
+	testCases := []struct {
+		desc     string
+		daily    []*weather.Forecast
+		hourly   []*weather.Forecast
+		loc      *time.Location
+		expected string
+	}{
+		{
+			desc:     "days get a sparkline of their hourly temperatures",
+			daily:    []*weather.Forecast{day(9, "rain", 12, 18, 0.6), day(10, "clear", 14, 22, 0)},
+			hourly:   []*weather.Forecast{hour(9, 6, 12), hour(9, 12, 15), hour(9, 18, 18), hour(10, 6, 14), hour(10, 12, 22)},
+			loc:      time.UTC,
+			expected: "*St\\. John's*\n\nTue 🌧 12–18° ▁▃▅ 60%\nWed ☀️ 14–22° ▂█ 0%",
+		},
+		{
+			desc:     "hours of days which aren't shown don't stretch the scale",
+			daily:    []*weather.Forecast{day(10, "clear", 14, 22, 0)},
+			hourly:   []*weather.Forecast{hour(9, 6, 2), hour(9, 12, 40), hour(10, 6, 14), hour(10, 12, 18), hour(10, 18, 22)},
+			loc:      time.UTC,
+			expected: "*St\\. John's*\n\nWed ☀️ 14–22° ▁▅█ 0%",
+		},
+		{
+			desc:     "days beyond the hourly forecasts get no sparkline",
+			daily:    []*weather.Forecast{day(9, "snow", -2, 1, 0.25), day(10, "", 0, 3, 0.1)},
+			hourly:   []*weather.Forecast{hour(9, 12, 0), hour(9, 15, 0)},
+			loc:      time.UTC,
+			expected: "*St\\. John's*\n\nTue ❄️ \\-2–1° ▁▁ 25%\nWed 🌡 0–3° 10%",
+		},
+		{
+			desc:     "hours are grouped by day in the given location",
+			daily:    []*weather.Forecast{day(9, "clouds", 10, 20, 0)},
+			hourly:   []*weather.Forecast{hour(8, 23, 10), hour(9, 12, 20)},
+			loc:      madrid,
+			expected: "*St\\. John's*\n\nTue ☁️ 10–20° ▁█ 0%",
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got := msg.NewCompactForecastMessage(l, tC.daily, tC.hourly, msg.InLocation(tC.loc))
+			if got != tC.expected {
+				t.Errorf("got:\n%s\nexpected:\n%s", got, tC.expected)
+			}
+		})
+	}
+}
+
+func TestNewHistoryMessage(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Fatalf("load location: %s", err.Error())
+	}
+
+	nn := []*notifications.Notification{{Message: "It's going to rain", CreatedAt: time.Date(2022, time.August, 1, 7, 0, 0, 0, time.UTC)}}
+
+	testCases := []struct {
+		desc     string
+		opts     []msg.MessageOption
+		expected string
+	}{
+		{desc: "times are in UTC by default", expected: "*Mon, 01 Aug 07:00*"},
+		{desc: "times are in the location", opts: []msg.MessageOption{msg.InLocation(madrid)}, expected: "*Mon, 01 Aug 09:00*"},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got := msg.NewHistoryMessage(nn, tC.opts...)
+			if !strings.Contains(got, tC.expected) {
+				t.Errorf("expected %q in:\n%s", tC.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package msg
+
+import (
+	"context"
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+const snoozeCallbackVersion = 1
+
+var MsgMuteUsage = tgram.NewRichText().
+	Plain("You can mute my alerts for a while with something like ").
+	Code("/mute 3h").Plain(", ").Code("/mute 2d").Plain(" or ").Code("/mute 1w").
+	Plain(", and unmute them with ").Code("/mute off").Plain(".").
+	MarkdownV2()
+
+var MsgQuietHoursUsage = tgram.NewRichText().
+	Plain("During your quiet hours I hold back my alerts until the hours are over. Set them with something like ").
+	Code("/quiet 22:00-07:00").
+	Plain(", in your /timezone, and remove them with ").Code("/quiet off").Plain(".").
+	MarkdownV2()
+
+var (
+	MsgMuteInvalid      = plain("I didn't get that. ") + MsgMuteUsage
+	MsgUnmuted          = plain("Done, you'll get my alerts again.")
+	MsgQuietHoursRemove = plain("Done, I'll send you alerts at any time of the day.")
+	MsgQuietHoursWrong  = plain("I didn't get that. ") + MsgQuietHoursUsage
+)
+
+// NewSnoozeKeyboardRow returns the snooze buttons added to alerts. Their
+// callback data is the duration, as in /mute.
+func NewSnoozeKeyboardRow(ctx context.Context, codec *tgram.CallbackCodec) ([]tgram.InlineKeyboardElement, error) {
+	day, err := newButton(ctx, codec, "🔕 Snooze 1 day", tgram.NewCallbackData(ActionSnooze, snoozeCallbackVersion, "1d"))
+	if err != nil {
+		return nil, err
+	}
+
+	week, err := newButton(ctx, codec, "🔕 Snooze 1 week", tgram.NewCallbackData(ActionSnooze, snoozeCallbackVersion, "1w"))
+	if err != nil {
+		return nil, err
+	}
+
+	return []tgram.InlineKeyboardElement{day, week}, nil
+}
+
+// ParseSnoozeCallback returns the duration of the snooze button pressed, as
+// in /mute.
+func ParseSnoozeCallback(d tgram.CallbackData) (string, error) {
+	if err := checkCallback(d, ActionSnooze, snoozeCallbackVersion); err != nil {
+		return "", err
+	}
+
+	return d.String(0)
+}
+
+func NewMutedMessage(until time.Time) string {
+	return tgram.NewRichText().
+		Plain("Done, I won't send you any alerts until " + until.Format("Mon 02 Jan at 15:04") + ". You can unmute them earlier with ").
+		Code("/mute off").
+		Plain(".").
+		MarkdownV2()
+}
+
+func NewMuteStatusMessage(until time.Time) string {
+	return tgram.NewRichText().
+		Plain("Your alerts are muted until " + until.Format("Mon 02 Jan at 15:04") + ". You can unmute them with ").
+		Code("/mute off").
+		Plain(".").
+		MarkdownV2()
+}
+
+func NewQuietHoursMessage(window, timezone string) string {
+	return tgram.NewRichText().
+		Plain("Your quiet hours are " + window + ", in the ").
+		Code(timezone).
+		Plain(" timezone. I'll hold back any alerts until they are over. You can remove them with ").
+		Code("/quiet off").
+		Plain(".").
+		MarkdownV2()
+}
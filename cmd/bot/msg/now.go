@@ -0,0 +1,71 @@
+package msg
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/manzanit0/weathry/cmd/bot/location"
+	"github.com/manzanit0/weathry/pkg/tgram"
+	"github.com/manzanit0/weathry/pkg/weather"
+)
+
+var MsgLocationQuestionNow = plain("What location do you want me to check the current weather for?")
+
+// NewForecastKeyboardRow returns the buttons to check the weather of the
+// given coordinates. The forecast comes in a new message instead of replacing
+// the one with the buttons.
+func NewForecastKeyboardRow(ctx context.Context, codec *tgram.CallbackCodec, latitude, longitude float64) ([]tgram.InlineKeyboardElement, error) {
+	buttons := []struct {
+		text string
+		view ForecastView
+	}{
+		{"⏰ Check hourly forecast", ViewHourly},
+		{"📆 Check daily forecast", ViewDaily},
+		{"🌡 Now", ViewNow},
+	}
+
+	row := make([]tgram.InlineKeyboardElement, len(buttons))
+	for i, b := range buttons {
+		c := ForecastCallback{View: b.view, Latitude: latitude, Longitude: longitude}
+
+		var err error
+		row[i], err = newButton(ctx, codec, b.text, c.CallbackData())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return row, nil
+}
+
+// NewObservationMessage renders the current conditions, with the time of the
+// observation in loc since stations don't always report on the hour.
+func NewObservationMessage(l *location.Location, o *weather.Observation, loc *time.Location) string {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	return newPreMessage(fmt.Sprintf("%s  \nObserved at %s  \n\n%s  \n🌡 %.0fºC, feels like %.0fºC  \n💨 %.1f m/s %s  \n💧 %d%%",
+		l.Name,
+		o.Time().In(loc).Format("15:04 MST"),
+		o.Description,
+		o.Temperature,
+		o.FeelsLike,
+		o.WindSpeed,
+		compassPoint(o.WindDirection),
+		o.Humidity,
+	))
+}
+
+// compassPoint returns where the wind blows from, out of eight points.
+func compassPoint(degrees int) string {
+	points := []string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}
+	i := int(math.Round(float64(degrees%360)/45)) % len(points)
+	if i < 0 {
+		i += len(points)
+	}
+
+	return points[i]
+}
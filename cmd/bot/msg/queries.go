@@ -0,0 +1,159 @@
+package msg
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/manzanit0/weathry/cmd/bot/nlq"
+)
+
+var spanishWeekdays = [...]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"}
+
+func NewQueryMissingPlaceMessage(lang nlq.Language) string {
+	if lang == nlq.Spanish {
+		return plain("¿Dónde? Dime el sitio, como en \"¿va a llover en Lisboa mañana?\", o guarda tu casa con /home.")
+	}
+
+	return plain("Where? Tell me the place, like \"will it rain in Lisbon tomorrow?\", or set your /home.")
+}
+
+// NewQueryAnswerMessage answers a question in a single sentence, in the
+// language it was asked in. hourly tells whether the summary was made of
+// hourly forecasts, which tell the time rain starts.
+func NewQueryAnswerMessage(q nlq.Query, place string, s nlq.Summary, loc *time.Location, hourly bool) string {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	if len(s.Forecasts) == 0 {
+		if q.Language == nlq.Spanish {
+			return plain("Aún no tengo la previsión para esos días, solo veo unos pocos días por delante.")
+		}
+
+		return plain("I don't have the forecast for those days yet, I can only see a few days ahead.")
+	}
+
+	var answer string
+	if q.Language == nlq.Spanish {
+		answer = spanishAnswer(q, place, s, loc, hourly)
+	} else {
+		answer = englishAnswer(q, place, s, loc, hourly)
+	}
+
+	return plain(answer)
+}
+
+func englishAnswer(q nlq.Query, place string, s nlq.Summary, loc *time.Location, hourly bool) string {
+	when := englishPeriod(q)
+	chance := fmt.Sprintf("%.0f%%", s.Chance*100)
+
+	switch q.Intent {
+	case nlq.IntentRain:
+		switch s.Rain {
+		case nlq.RainLikely:
+			from := ""
+			if hourly {
+				from = " from " + s.RainFrom.In(loc).Format("15:04")
+			}
+
+			return fmt.Sprintf("🌧 Yes, rain is likely in %s %s%s, with a %s chance.", place, when, from, chance)
+		case nlq.RainPossible:
+			return fmt.Sprintf("🌦 Maybe, there's a %s chance of rain in %s %s.", chance, place, when)
+		default:
+			return fmt.Sprintf("☀️ No, rain isn't expected in %s %s.", place, when)
+		}
+
+	case nlq.IntentTemperature:
+		return fmt.Sprintf("🌡 In %s %s it'll be between %.0fºC and %.0fºC.", place, when, s.MinTemperature, s.MaxTemperature)
+
+	case nlq.IntentWind:
+		return fmt.Sprintf("💨 In %s %s the wind will be %s, up to %.1f m/s.", place, when, windStrength(s.MaxWindSpeed, nlq.English), s.MaxWindSpeed)
+
+	default:
+		return fmt.Sprintf("⛅️ %s %s: %s, between %.0fºC and %.0fºC, %s chance of rain and wind up to %.1f m/s.",
+			place, when, strings.Join(s.Descriptions, ", "), s.MinTemperature, s.MaxTemperature, chance, s.MaxWindSpeed)
+	}
+}
+
+func spanishAnswer(q nlq.Query, place string, s nlq.Summary, loc *time.Location, hourly bool) string {
+	when := spanishPeriod(q)
+	chance := fmt.Sprintf("%.0f%%", s.Chance*100)
+
+	switch q.Intent {
+	case nlq.IntentRain:
+		switch s.Rain {
+		case nlq.RainLikely:
+			from := ""
+			if hourly {
+				from = " a partir de las " + s.RainFrom.In(loc).Format("15:04")
+			}
+
+			return fmt.Sprintf("🌧 Sí, es probable que llueva en %s %s%s, con una probabilidad del %s.", place, when, from, chance)
+		case nlq.RainPossible:
+			return fmt.Sprintf("🌦 Puede, hay un %s de probabilidad de lluvia en %s %s.", chance, place, when)
+		default:
+			return fmt.Sprintf("☀️ No, no se espera lluvia en %s %s.", place, when)
+		}
+
+	case nlq.IntentTemperature:
+		return fmt.Sprintf("🌡 En %s %s estará entre %.0fºC y %.0fºC.", place, when, s.MinTemperature, s.MaxTemperature)
+
+	case nlq.IntentWind:
+		return fmt.Sprintf("💨 En %s %s el viento será %s, de hasta %.1f m/s.", place, when, windStrength(s.MaxWindSpeed, nlq.Spanish), s.MaxWindSpeed)
+
+	default:
+		return fmt.Sprintf("⛅️ En %s %s: entre %.0fºC y %.0fºC, %s de probabilidad de lluvia y viento de hasta %.1f m/s.",
+			place, when, s.MinTemperature, s.MaxTemperature, chance, s.MaxWindSpeed)
+	}
+}
+
+func englishPeriod(q nlq.Query) string {
+	switch q.Period {
+	case nlq.PeriodTomorrow:
+		return "tomorrow"
+	case nlq.PeriodDayAfterTomorrow:
+		return "the day after tomorrow"
+	case nlq.PeriodWeekday:
+		return "on " + q.From.Weekday().String()
+	case nlq.PeriodWeekend:
+		return "this weekend"
+	default:
+		return "today"
+	}
+}
+
+func spanishPeriod(q nlq.Query) string {
+	switch q.Period {
+	case nlq.PeriodTomorrow:
+		return "mañana"
+	case nlq.PeriodDayAfterTomorrow:
+		return "pasado mañana"
+	case nlq.PeriodWeekday:
+		return "el " + spanishWeekdays[q.From.Weekday()]
+	case nlq.PeriodWeekend:
+		return "este fin de semana"
+	default:
+		return "hoy"
+	}
+}
+
+// windStrength follows the Beaufort scale loosely: up to a gentle breeze is
+// light, up to a fresh breeze is moderate and up to a near gale is strong.
+func windStrength(speed float64, lang nlq.Language) string {
+	labels := []string{"light", "moderate", "strong", "very strong"}
+	if lang == nlq.Spanish {
+		labels = []string{"flojo", "moderado", "fuerte", "muy fuerte"}
+	}
+
+	switch {
+	case speed < 5.5:
+		return labels[0]
+	case speed < 10.8:
+		return labels[1]
+	case speed < 17.2:
+		return labels[2]
+	default:
+		return labels[3]
+	}
+}
@@ -0,0 +1,90 @@
+package msg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/manzanit0/weathry/cmd/bot/rules"
+	"github.com/manzanit0/weathry/pkg/expr"
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+var MsgRuleUsage = ruleUsage().MarkdownV2()
+
+var (
+	MsgRuleLimitReached = plain("You already have the maximum amount of rules. Delete one with /delrule before adding a new one.")
+	MsgRuleNotFound     = plain("I couldn't find that rule. Check your rules with /rules.")
+	MsgRuleInvalidID    = tgram.NewRichText().Plain("That doesn't look like a rule ID. Try something like ").Code("/delrule 3").Plain(".").MarkdownV2()
+	MsgRulesEmpty       = plain("You don't have any rules yet. Add one with /rule!")
+	MsgRuleDeleted      = plain("Done, I won't check that rule anymore.")
+	MsgRuleMissingHome  = plain("\n\nRemember to set your /home so I know where to check it.")
+)
+
+func ruleUsage() *tgram.RichText {
+	return tgram.NewRichText().
+		Plain("Rules let me know what kind of weather you care about. For example, ").
+		Code("pop > 0.6 && hour between 7 and 9 && weekday").
+		Plain(" would warn you about rainy commutes.")
+}
+
+// NewRuleQuestionMessage explains how to write rules, listing all the fields
+// available.
+func NewRuleQuestionMessage() string {
+	t := tgram.NewRichText().
+		Plain("What rule do you want me to keep an eye on? ").
+		Append(ruleUsage()).
+		Plain("\n\nThese are the values you can use:\n")
+
+	for _, f := range expr.Fields() {
+		t.Plain("\n• ").Code(f.Name).Plain(", " + f.Doc)
+	}
+
+	t.Plain("\n\nCombine them with ").
+		Code("&&").Plain(", ").Code("||").Plain(", ").Code("!").Plain(", ").Code("between").
+		Plain(" and comparisons like ").Code(">").Plain(" or ").Code("==").Plain(".")
+
+	return t.MarkdownV2()
+}
+
+func NewRuleCreatedMessage(r *rules.Rule) string {
+	return tgram.NewRichText().
+		Plain("Got it! I'll let you know whenever ").
+		Code(r.Expression).
+		Plain(" matches the forecast of your home.").
+		MarkdownV2()
+}
+
+// NewRuleErrorMessage explains why the rule couldn't be compiled, pointing at
+// the exact column where the error is when possible.
+func NewRuleErrorMessage(expression string, err error) string {
+	var exprErr *expr.Error
+	if !errors.As(err, &exprErr) {
+		return plain("That rule doesn't look right: " + err.Error())
+	}
+
+	expression = strings.TrimSpace(expression)
+	pointer := strings.Repeat(" ", exprErr.Pos-1) + "^"
+	if exprErr.Pos-1 > len([]rune(expression)) {
+		pointer = ""
+	}
+
+	return tgram.NewRichText().
+		Plain("That rule doesn't look right, "+exprErr.Msg+".\n").
+		Pre(expression+"\n"+pointer, "").
+		MarkdownV2()
+}
+
+func NewRulesListMessage(rr []*rules.Rule) string {
+	if len(rr) == 0 {
+		return MsgRulesEmpty
+	}
+
+	t := tgram.NewRichText().Plain("These are your rules:\n")
+	for _, r := range rr {
+		t.Plain("\n").Bold(fmt.Sprint(r.ID)).Plain(". ").Code(r.Expression)
+	}
+
+	t.Plain("\n\nYou can delete any of them with ").Code("/delrule <number>").Plain(".")
+	return t.MarkdownV2()
+}
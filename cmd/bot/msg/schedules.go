@@ -0,0 +1,65 @@
+package msg
+
+import (
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+var MsgScheduleUsage = tgram.NewRichText().
+	Plain("By default I check the weather of your home at 08:00 and 19:00 UTC. You can pick your own time with ").
+	Code("/schedule 07:30").
+	Plain(", or go wild with a cron expression like ").
+	Code("/schedule 30 7 * * mon-fri").
+	Plain(".\n\nTimes are in your timezone, which you can set with /timezone.").
+	MarkdownV2()
+
+var (
+	MsgScheduleDeleted     = plain("Done, I'll check the weather of your home at the usual times.")
+	MsgScheduleMissingHome = plain("\n\nRemember to set your /home so I know where to check.")
+)
+
+func NewCurrentTimezoneMessage(timezone string) string {
+	return tgram.NewRichText().
+		Plain("Your timezone is ").Code(timezone).
+		Plain(". You can change it with something like ").Code("/timezone Europe/Madrid").
+		Plain(".").
+		MarkdownV2()
+}
+
+func NewInvalidTimezoneMessage(timezone string) string {
+	return tgram.NewRichText().
+		Plain("I don't know the timezone ").Code(timezone).
+		Plain(". Try with something like ").Code("/timezone Europe/Madrid").
+		Plain(" or ").Code("/timezone America/New_York").
+		Plain(".").
+		MarkdownV2()
+}
+
+func NewTimezoneSetMessage(timezone string, now time.Time) string {
+	return tgram.NewRichText().
+		Plain("Done! Your timezone is now ").Code(timezone).
+		Plain(", where it's " + now.Format("15:04") + ".").
+		MarkdownV2()
+}
+
+func NewCurrentScheduleMessage(expression, timezone string) string {
+	return tgram.NewRichText().
+		Plain("I check the weather of your home on the schedule ").Code(expression).
+		Plain(", in the ").Code(timezone).
+		Plain(" timezone. Change it with /schedule or go back to the usual times with ").Code("/schedule off").
+		Plain(".").
+		MarkdownV2()
+}
+
+func NewInvalidScheduleMessage(err error) string {
+	return plain("That schedule doesn't look right: "+err.Error()+".\n\n") + MsgScheduleUsage
+}
+
+func NewScheduleSetMessage(expression, timezone string, next time.Time) string {
+	return tgram.NewRichText().
+		Plain("Done! I'll check the weather of your home on the schedule ").Code(expression).
+		Plain(", in the ").Code(timezone).
+		Plain(" timezone. The next check is on " + next.Format("Mon 02 Jan at 15:04") + ".").
+		MarkdownV2()
+}
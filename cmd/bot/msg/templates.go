@@ -0,0 +1,55 @@
+package msg
+
+import (
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+var MsgTemplateUsage = tgram.NewRichText().
+	Plain("I can word your alerts and briefs the way you like with a template. Check yours with ").
+	Code("/template alert").
+	Plain(" or ").
+	Code("/template brief").
+	Plain(", change it by sending the new one after the kind, such as ").
+	Code("/template alert {{range .Alerts}}{{.Text}}{{end}}").
+	Plain(", or go back to mine with ").
+	Code("/template alert off").
+	Plain(".").
+	MarkdownV2()
+
+var MsgTemplateAdminOnly = plain("Only the admin can change the templates of everyone.")
+
+// NewTemplateMessage shows the template of the kind the user gets, which
+// might be everyone's or the default one.
+func NewTemplateMessage(kind, body string, own bool) string {
+	t := tgram.NewRichText()
+	if own {
+		t.Plain("This is your " + kind + " template:")
+	} else {
+		t.Plain("You haven't got your own " + kind + " template, so you're getting this one:")
+	}
+
+	return t.Plain("\n\n").Pre(body, "").MarkdownV2()
+}
+
+func NewTemplateSetMessage(kind string) string {
+	return tgram.NewRichText().
+		Plain("Done! From now on I'll word your " + kind + "s with your template. Go back to mine with ").
+		Code("/template " + kind + " off").
+		Plain(".").
+		MarkdownV2()
+}
+
+func NewTemplateDeletedMessage(kind string, found bool) string {
+	if !found {
+		return plain("You haven't got your own " + kind + " template, so nothing changed.")
+	}
+
+	return plain("Done, I'll word your " + kind + "s my way again.")
+}
+
+func NewInvalidTemplateMessage(err error) string {
+	return tgram.NewRichText().
+		Plain("That template doesn't work, so I've kept the one you had:\n\n").
+		Pre(err.Error(), "").
+		MarkdownV2()
+}
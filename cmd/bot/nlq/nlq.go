@@ -0,0 +1,549 @@
+// Package nlq understands weather questions written in plain English or
+// Spanish, such as "will it rain in Lisbon tomorrow?" or "¿hará calor en
+// Sevilla el fin de semana?".
+//
+// It's rule-based: questions are split into words and matched against
+// keyword tables for the date, the intent and the place. Anything that doesn't
+// mention the weather isn't considered a question.
+package nlq
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+type Intent int
+
+const (
+	IntentGeneral Intent = iota
+	IntentRain
+	IntentTemperature
+	IntentWind
+)
+
+func (i Intent) String() string {
+	switch i {
+	case IntentRain:
+		return "rain"
+	case IntentTemperature:
+		return "temperature"
+	case IntentWind:
+		return "wind"
+	default:
+		return "general"
+	}
+}
+
+type Language string
+
+const (
+	English Language = "en"
+	Spanish Language = "es"
+)
+
+type Period int
+
+const (
+	PeriodToday Period = iota
+	PeriodTomorrow
+	PeriodDayAfterTomorrow
+	PeriodWeekday
+	PeriodWeekend
+)
+
+type Query struct {
+	Intent   Intent
+	Language Language
+
+	// Place is the place as written by the user. It's empty when the question
+	// doesn't mention any, which usually means they're asking about home.
+	Place string
+
+	Period Period
+
+	// From and To are the first and last day asked about, at midnight in the
+	// location of the time the question was parsed with.
+	From time.Time
+	To   time.Time
+}
+
+// Includes reports whether t falls on any of the days asked about.
+func (q Query) Includes(t time.Time) bool {
+	t = t.In(q.From.Location())
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return !day.Before(q.From) && !day.After(q.To)
+}
+
+// Parse extracts the query out of text. now is when the question was asked,
+// in the timezone of the user, so "today" and "tomorrow" mean what they
+// think. It returns false when text isn't a question about the weather.
+func Parse(text string, now time.Time) (Query, bool) {
+	words := tokenize(text)
+	if len(words) == 0 {
+		return Query{}, false
+	}
+
+	q := Query{Language: detectLanguage(words)}
+
+	consumed := make([]bool, len(words))
+	period, from, to := parseDate(words, consumed, now)
+	q.Period, q.From, q.To = period, from, to
+
+	intent, ok := parseIntent(words, consumed)
+	if !ok {
+		return Query{}, false
+	}
+
+	q.Intent = intent
+	q.Place = parsePlace(words, consumed)
+
+	return q, true
+}
+
+type word struct {
+	// text is the word as written, to keep the case of place names.
+	text string
+
+	// norm is lowercase and without accents, to match against keywords
+	// regardless of how careful the user was typing.
+	norm string
+}
+
+func tokenize(text string) []word {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '’' && r != '-'
+	})
+
+	words := make([]word, 0, len(fields))
+	for _, f := range fields {
+		f = strings.Trim(f, "'’-")
+		if f == "" {
+			continue
+		}
+
+		words = append(words, word{text: f, norm: normalize(f)})
+	}
+
+	return words
+}
+
+var accents = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n", "’", "'")
+
+func normalize(s string) string {
+	return accents.Replace(strings.ToLower(s))
+}
+
+var spanishWords = set(
+	"va", "vaya", "en", "el", "la", "los", "las", "del", "de", "que", "como", "hace", "hara", "habra",
+	"hay", "esta", "este", "estara", "sera", "para", "se", "lo", "muy", "mucho", "mucha",
+)
+
+var englishWords = set(
+	"will", "it", "is", "be", "going", "to", "the", "in", "at", "on", "what", "how", "this", "does",
+	"next", "for", "there", "any", "much", "like",
+)
+
+func detectLanguage(words []word) Language {
+	var es, en int
+	for _, w := range words {
+		switch {
+		case spanishWords[w.norm], spanishKeywords[w.norm]:
+			es++
+		case englishWords[w.norm], englishKeywords[w.norm]:
+			en++
+		}
+	}
+
+	if es > en {
+		return Spanish
+	}
+
+	return English
+}
+
+type datePhrase struct {
+	words  []string
+	period Period
+
+	// weekday is only set for PeriodWeekday.
+	weekday time.Weekday
+
+	// filler phrases are consumed so they aren't mistaken for places, but
+	// don't say anything about the date.
+	filler bool
+}
+
+// datePhrases are matched longest first, so "pasado mañana" wins over
+// "mañana", and "esta mañana" (this morning) isn't taken for tomorrow.
+var datePhrases = []datePhrase{
+	{words: []string{"day", "after", "tomorrow"}, period: PeriodDayAfterTomorrow},
+	{words: []string{"este", "fin", "de", "semana"}, period: PeriodWeekend},
+	{words: []string{"el", "fin", "de", "semana"}, period: PeriodWeekend},
+	{words: []string{"fin", "de", "semana"}, period: PeriodWeekend},
+	{words: []string{"por", "la", "manana"}, filler: true},
+	{words: []string{"por", "la", "tarde"}, filler: true},
+	{words: []string{"por", "la", "noche"}, filler: true},
+	{words: []string{"in", "the", "morning"}, filler: true},
+	{words: []string{"in", "the", "afternoon"}, filler: true},
+	{words: []string{"in", "the", "evening"}, filler: true},
+	{words: []string{"at", "night"}, filler: true},
+	{words: []string{"pasado", "manana"}, period: PeriodDayAfterTomorrow},
+	{words: []string{"esta", "manana"}, period: PeriodToday},
+	{words: []string{"esta", "tarde"}, period: PeriodToday},
+	{words: []string{"esta", "noche"}, period: PeriodToday},
+	{words: []string{"this", "morning"}, period: PeriodToday},
+	{words: []string{"this", "afternoon"}, period: PeriodToday},
+	{words: []string{"this", "evening"}, period: PeriodToday},
+	{words: []string{"right", "now"}, period: PeriodToday},
+	{words: []string{"this", "weekend"}, period: PeriodWeekend},
+	{words: []string{"the", "weekend"}, period: PeriodWeekend},
+	{words: []string{"este", "finde"}, period: PeriodWeekend},
+	{words: []string{"el", "finde"}, period: PeriodWeekend},
+	{words: []string{"weekend"}, period: PeriodWeekend},
+	{words: []string{"finde"}, period: PeriodWeekend},
+	{words: []string{"today"}, period: PeriodToday},
+	{words: []string{"tonight"}, period: PeriodToday},
+	{words: []string{"now"}, period: PeriodToday},
+	{words: []string{"hoy"}, period: PeriodToday},
+	{words: []string{"ahora"}, period: PeriodToday},
+	{words: []string{"tomorrow"}, period: PeriodTomorrow},
+	{words: []string{"manana"}, period: PeriodTomorrow},
+	{words: []string{"morning"}, filler: true},
+	{words: []string{"afternoon"}, filler: true},
+	{words: []string{"evening"}, filler: true},
+	{words: []string{"night"}, filler: true},
+}
+
+var weekdays = map[string]time.Weekday{
+	"monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday, "thursday": time.Thursday,
+	"friday": time.Friday, "saturday": time.Saturday, "sunday": time.Sunday,
+	"lunes": time.Monday, "martes": time.Tuesday, "miercoles": time.Wednesday, "jueves": time.Thursday,
+	"viernes": time.Friday, "sabado": time.Saturday, "domingo": time.Sunday,
+}
+
+// datePrefixes are the words leading to a date, as in "on friday" or "el
+// próximo viernes". They are consumed along with it.
+var datePrefixes = set("on", "this", "next", "coming", "over", "during", "for", "el", "este", "proximo", "durante", "para", "en", "in", "at")
+
+func parseDate(words []word, consumed []bool, now time.Time) (Period, time.Time, time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	period, weekday, found := PeriodToday, time.Sunday, false
+	next := false
+
+	for i := 0; i < len(words); i++ {
+		if consumed[i] {
+			continue
+		}
+
+		match := matchDatePhrase(words, i)
+		if match == nil {
+			continue
+		}
+
+		end := i + len(match.words)
+		for j := i; j < end; j++ {
+			consumed[j] = true
+		}
+
+		if match.filler {
+			i = end - 1
+			continue
+		}
+
+		// Walk back over "on", "this", "el próximo"...
+		for j := i - 1; j >= 0 && !consumed[j] && datePrefixes[words[j].norm]; j-- {
+			consumed[j] = true
+			if words[j].norm == "next" || words[j].norm == "proximo" {
+				next = true
+			}
+		}
+
+		// The first date mentioned wins.
+		if !found {
+			period, weekday, found = match.period, match.weekday, true
+		}
+
+		i = end - 1
+	}
+
+	switch period {
+	case PeriodTomorrow:
+		d := today.AddDate(0, 0, 1)
+		return period, d, d
+
+	case PeriodDayAfterTomorrow:
+		d := today.AddDate(0, 0, 2)
+		return period, d, d
+
+	case PeriodWeekday:
+		ahead := (int(weekday) - int(today.Weekday()) + 7) % 7
+		if ahead == 0 && next {
+			ahead = 7
+		}
+
+		d := today.AddDate(0, 0, ahead)
+		return period, d, d
+
+	case PeriodWeekend:
+		saturday, sunday := Weekend(now)
+		return period, saturday, sunday
+
+	default:
+		return PeriodToday, today, today
+	}
+}
+
+// Weekend returns the days of the upcoming weekend, at midnight in now's
+// location. On weekends, it's what's left of the current one.
+func Weekend(now time.Time) (time.Time, time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	sunday := today.AddDate(0, 0, (int(time.Sunday)-int(today.Weekday())+7)%7)
+	saturday := sunday.AddDate(0, 0, -1)
+	if saturday.Before(today) {
+		saturday = today
+	}
+
+	return saturday, sunday
+}
+
+// ParseDay parses the day at the start of text, which may be a word such as
+// "tomorrow", "friday" or "next monday", or a date such as "2022-08-06" or
+// "06/08". It returns the day, at midnight in now's location, and what's left
+// of the text.
+func ParseDay(text string, now time.Time) (time.Time, string, bool) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return time.Time{}, "", false
+	}
+
+	if d, ok := parseCalendarDate(fields[0], today); ok {
+		return d, strings.Join(fields[1:], " "), true
+	}
+
+	words := tokenize(strings.Join(fields, " "))
+	consumed := make([]bool, len(words))
+
+	i, next := 0, false
+	for i < len(words) && datePrefixes[words[i].norm] {
+		next = next || words[i].norm == "next" || words[i].norm == "proximo"
+		i++
+	}
+
+	if i >= len(words) {
+		return time.Time{}, "", false
+	}
+
+	match := matchDatePhrase(words, i)
+	if match == nil || match.filler || match.period == PeriodWeekend {
+		return time.Time{}, "", false
+	}
+
+	for j := 0; j < i+len(match.words); j++ {
+		consumed[j] = true
+	}
+
+	var rest []string
+	for j, w := range words {
+		if !consumed[j] && (len(rest) > 0 || !placePrepositions[w.norm]) {
+			rest = append(rest, w.text)
+		}
+	}
+
+	day := today
+	switch match.period {
+	case PeriodTomorrow:
+		day = today.AddDate(0, 0, 1)
+	case PeriodDayAfterTomorrow:
+		day = today.AddDate(0, 0, 2)
+	case PeriodWeekday:
+		ahead := (int(match.weekday) - int(today.Weekday()) + 7) % 7
+		if ahead == 0 && next {
+			ahead = 7
+		}
+
+		day = today.AddDate(0, 0, ahead)
+	}
+
+	return day, strings.Join(rest, " "), true
+}
+
+// parseCalendarDate parses dates written as 2006-01-02 or, European style,
+// 02/01. Dates without a year are in the next twelve months.
+func parseCalendarDate(s string, today time.Time) (time.Time, bool) {
+	if t, err := time.ParseInLocation("2006-01-02", s, today.Location()); err == nil {
+		return t, true
+	}
+
+	for _, layout := range []string{"2/1", "02/01", "2/1/2006", "02/01/2006"} {
+		t, err := time.ParseInLocation(layout, s, today.Location())
+		if err != nil {
+			continue
+		}
+
+		if !strings.Contains(layout, "2006") {
+			t = time.Date(today.Year(), t.Month(), t.Day(), 0, 0, 0, 0, today.Location())
+			if t.Before(today) {
+				t = t.AddDate(1, 0, 0)
+			}
+		}
+
+		return t, true
+	}
+
+	return time.Time{}, false
+}
+
+func matchDatePhrase(words []word, i int) *datePhrase {
+	if wd, ok := weekdays[words[i].norm]; ok {
+		return &datePhrase{words: []string{words[i].norm}, period: PeriodWeekday, weekday: wd}
+	}
+
+	for k := range datePhrases {
+		p := &datePhrases[k]
+		if i+len(p.words) > len(words) {
+			continue
+		}
+
+		matches := true
+		for j, w := range p.words {
+			if words[i+j].norm != w {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			return p
+		}
+	}
+
+	return nil
+}
+
+var intentKeywords = map[Intent][]string{
+	IntentRain: {
+		"rain", "raining", "rains", "rainy", "umbrella", "shower", "showers", "drizzle", "storm", "storms", "stormy",
+		"llover", "llueve", "llovera", "lloviendo", "lluvia", "lluvias", "llueva", "paraguas", "tormenta", "tormentas", "chubascos", "llovizna",
+	},
+	IntentTemperature: {
+		"hot", "cold", "warm", "chilly", "freezing", "temperature", "temperatures", "degrees", "heat",
+		"calor", "frio", "temperatura", "temperaturas", "grados", "caluroso", "fresco", "helada",
+	},
+	IntentWind: {
+		"wind", "windy", "breezy", "breeze", "gusts", "gusty",
+		"viento", "ventoso", "rachas", "brisa",
+	},
+	IntentGeneral: {
+		"weather", "forecast", "forecasts",
+		"tiempo", "clima", "pronostico",
+	},
+}
+
+var intents = map[string]Intent{}
+
+func init() {
+	for intent, keywords := range intentKeywords {
+		for _, k := range keywords {
+			intents[k] = intent
+		}
+	}
+}
+
+// englishKeywords and spanishKeywords help telling the language of the
+// question, on top of the more common words.
+var (
+	englishKeywords = set(
+		"rain", "raining", "rains", "rainy", "umbrella", "hot", "cold", "warm", "wind", "windy", "weather",
+		"forecast", "tomorrow", "today", "tonight", "weekend",
+		"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday",
+	)
+
+	spanishKeywords = set(
+		"llover", "llueve", "llovera", "lluvia", "llueva", "calor", "frio", "viento", "tiempo", "clima",
+		"manana", "hoy", "finde", "semana", "pasado",
+		"lunes", "martes", "miercoles", "jueves", "viernes", "sabado", "domingo",
+	)
+)
+
+// parseIntent returns the first intent mentioned in the text.
+func parseIntent(words []word, consumed []bool) (Intent, bool) {
+	for i, w := range words {
+		if consumed[i] {
+			continue
+		}
+
+		if intent, ok := intents[w.norm]; ok {
+			consumed[i] = true
+			return intent, true
+		}
+	}
+
+	return IntentGeneral, false
+}
+
+// placePrepositions introduce a place, as in "in Lisbon" or "en Lisboa".
+var placePrepositions = set("in", "at", "for", "en", "para")
+
+// placeBreaks end a place name.
+var placeBreaks = set(
+	"will", "is", "be", "going", "it", "does", "do", "and", "or", "with", "like", "right",
+	"y", "o", "va", "hace", "hara", "habra", "hay", "estara", "sera", "con",
+)
+
+// placeConnectors can be part of a place name, as in "Santiago de
+// Compostela", but never end one. Articles can start one, as in "Las Palmas".
+var (
+	placeConnectors = set("de", "del", "la", "las", "los", "el", "the", "of")
+	placeArticles   = set("la", "las", "los", "el", "the")
+)
+
+// parsePlace returns the first run of words after a preposition which isn't
+// part of the date or the intent.
+func parsePlace(words []word, consumed []bool) string {
+	for i, w := range words {
+		if consumed[i] || !placePrepositions[w.norm] {
+			continue
+		}
+
+		var run []string
+		for j := i + 1; j < len(words); j++ {
+			if consumed[j] || placeBreaks[words[j].norm] || placePrepositions[words[j].norm] {
+				break
+			}
+
+			if _, ok := intents[words[j].norm]; ok {
+				break
+			}
+
+			run = append(run, words[j].text)
+		}
+
+		for len(run) > 0 && placeConnectors[normalize(run[0])] && !placeArticles[normalize(run[0])] {
+			run = run[1:]
+		}
+
+		for len(run) > 0 && placeConnectors[normalize(run[len(run)-1])] {
+			run = run[:len(run)-1]
+		}
+
+		if len(run) > 0 {
+			return strings.Join(run, " ")
+		}
+	}
+
+	return ""
+}
+
+func set(ss ...string) map[string]bool {
+	m := make(map[string]bool, len(ss))
+	for _, s := range ss {
+		m[s] = true
+	}
+
+	return m
+}
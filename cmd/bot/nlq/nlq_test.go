@@ -0,0 +1,225 @@
+package nlq_test
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/manzanit0/weathry/cmd/bot/nlq"
+)
+
+func TestParse(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Fatalf("load location: %s", err.Error())
+	}
+
+	// Wednesday.
+	now := time.Date(2022, time.August, 3, 10, 0, 0, 0, madrid)
+	day := func(d int) time.Time { return time.Date(2022, time.August, d, 0, 0, 0, 0, madrid) }
+
+	testCases := []struct {
+		text     string
+		intent   nlq.Intent
+		place    string
+		period   nlq.Period
+		from     time.Time
+		to       time.Time
+		language nlq.Language
+	}{
+		// English, rain.
+		{text: "will it rain in Lisbon tomorrow?", intent: nlq.IntentRain, place: "Lisbon", period: nlq.PeriodTomorrow, from: day(4), to: day(4), language: nlq.English},
+		{text: "Will it rain tomorrow in Lisbon", intent: nlq.IntentRain, place: "Lisbon", period: nlq.PeriodTomorrow, from: day(4), to: day(4), language: nlq.English},
+		{text: "is it going to rain in New York today", intent: nlq.IntentRain, place: "New York", period: nlq.PeriodToday, from: day(3), to: day(3), language: nlq.English},
+		{text: "do I need an umbrella in London this afternoon?", intent: nlq.IntentRain, place: "London", period: nlq.PeriodToday, from: day(3), to: day(3), language: nlq.English},
+		{text: "any showers in Porto on Friday?", intent: nlq.IntentRain, place: "Porto", period: nlq.PeriodWeekday, from: day(5), to: day(5), language: nlq.English},
+		{text: "will it rain tomorrow?", intent: nlq.IntentRain, period: nlq.PeriodTomorrow, from: day(4), to: day(4), language: nlq.English},
+		{text: "rain in Paris the day after tomorrow", intent: nlq.IntentRain, place: "Paris", period: nlq.PeriodDayAfterTomorrow, from: day(5), to: day(5), language: nlq.English},
+		{text: "Is it going to be rainy in Dublin tomorrow morning?", intent: nlq.IntentRain, place: "Dublin", period: nlq.PeriodTomorrow, from: day(4), to: day(4), language: nlq.English},
+		{text: "will there be storms in Miami next Wednesday", intent: nlq.IntentRain, place: "Miami", period: nlq.PeriodWeekday, from: day(10), to: day(10), language: nlq.English},
+
+		// English, temperature.
+		{text: "how hot will it be in Seville this weekend?", intent: nlq.IntentTemperature, place: "Seville", period: nlq.PeriodWeekend, from: day(6), to: day(7), language: nlq.English},
+		{text: "is it cold in Oslo?", intent: nlq.IntentTemperature, place: "Oslo", period: nlq.PeriodToday, from: day(3), to: day(3), language: nlq.English},
+		{text: "temperature in Rio de Janeiro on Sunday", intent: nlq.IntentTemperature, place: "Rio de Janeiro", period: nlq.PeriodWeekday, from: day(7), to: day(7), language: nlq.English},
+		{text: "what's the temperature going to be at the weekend", intent: nlq.IntentTemperature, period: nlq.PeriodWeekend, from: day(6), to: day(7), language: nlq.English},
+		{text: "will it be warm in Rome on Monday", intent: nlq.IntentTemperature, place: "Rome", period: nlq.PeriodWeekday, from: day(8), to: day(8), language: nlq.English},
+
+		// English, wind.
+		{text: "is it windy in Tarifa right now?", intent: nlq.IntentWind, place: "Tarifa", period: nlq.PeriodToday, from: day(3), to: day(3), language: nlq.English},
+		{text: "how strong will the wind be in Chicago tomorrow", intent: nlq.IntentWind, place: "Chicago", period: nlq.PeriodTomorrow, from: day(4), to: day(4), language: nlq.English},
+		{text: "gusts in Wellington over the weekend?", intent: nlq.IntentWind, place: "Wellington", period: nlq.PeriodWeekend, from: day(6), to: day(7), language: nlq.English},
+
+		// English, general.
+		{text: "what's the weather like in London today?", intent: nlq.IntentGeneral, place: "London", period: nlq.PeriodToday, from: day(3), to: day(3), language: nlq.English},
+		{text: "weather for Berlin on Saturday", intent: nlq.IntentGeneral, place: "Berlin", period: nlq.PeriodWeekday, from: day(6), to: day(6), language: nlq.English},
+		{text: "forecast for tomorrow", intent: nlq.IntentGeneral, period: nlq.PeriodTomorrow, from: day(4), to: day(4), language: nlq.English},
+		{text: "How's the weather in Santiago de Compostela", intent: nlq.IntentGeneral, place: "Santiago de Compostela", period: nlq.PeriodToday, from: day(3), to: day(3), language: nlq.English},
+		{text: "weather this Wednesday in Lyon", intent: nlq.IntentGeneral, place: "Lyon", period: nlq.PeriodWeekday, from: day(3), to: day(3), language: nlq.English},
+
+		// Spanish, rain.
+		{text: "¿Va a llover en Lisboa mañana?", intent: nlq.IntentRain, place: "Lisboa", period: nlq.PeriodTomorrow, from: day(4), to: day(4), language: nlq.Spanish},
+		{text: "¿lloverá mañana en Bilbao?", intent: nlq.IntentRain, place: "Bilbao", period: nlq.PeriodTomorrow, from: day(4), to: day(4), language: nlq.Spanish},
+		{text: "va a llover esta tarde", intent: nlq.IntentRain, period: nlq.PeriodToday, from: day(3), to: day(3), language: nlq.Spanish},
+		{text: "¿Necesito paraguas en Santiago de Compostela el viernes?", intent: nlq.IntentRain, place: "Santiago de Compostela", period: nlq.PeriodWeekday, from: day(5), to: day(5), language: nlq.Spanish},
+		{text: "¿lloverá pasado mañana en A Coruña?", intent: nlq.IntentRain, place: "A Coruña", period: nlq.PeriodDayAfterTomorrow, from: day(5), to: day(5), language: nlq.Spanish},
+		{text: "lluvia en Oviedo mañana por la mañana", intent: nlq.IntentRain, place: "Oviedo", period: nlq.PeriodTomorrow, from: day(4), to: day(4), language: nlq.Spanish},
+		{text: "¿Va a llover esta mañana en Madrid?", intent: nlq.IntentRain, place: "Madrid", period: nlq.PeriodToday, from: day(3), to: day(3), language: nlq.Spanish},
+		{text: "¿llueve el próximo miércoles en Vigo?", intent: nlq.IntentRain, place: "Vigo", period: nlq.PeriodWeekday, from: day(10), to: day(10), language: nlq.Spanish},
+
+		// Spanish, temperature.
+		{text: "¿Hará calor en Sevilla el fin de semana?", intent: nlq.IntentTemperature, place: "Sevilla", period: nlq.PeriodWeekend, from: day(6), to: day(7), language: nlq.Spanish},
+		{text: "¿hace frío en Burgos?", intent: nlq.IntentTemperature, place: "Burgos", period: nlq.PeriodToday, from: day(3), to: day(3), language: nlq.Spanish},
+		{text: "temperatura en Las Palmas el sábado", intent: nlq.IntentTemperature, place: "Las Palmas", period: nlq.PeriodWeekday, from: day(6), to: day(6), language: nlq.Spanish},
+		{text: "cuántos grados hará este finde", intent: nlq.IntentTemperature, period: nlq.PeriodWeekend, from: day(6), to: day(7), language: nlq.Spanish},
+
+		// Spanish, wind.
+		{text: "¿hace viento en Tarifa hoy?", intent: nlq.IntentWind, place: "Tarifa", period: nlq.PeriodToday, from: day(3), to: day(3), language: nlq.Spanish},
+		{text: "viento en Cádiz el domingo", intent: nlq.IntentWind, place: "Cádiz", period: nlq.PeriodWeekday, from: day(7), to: day(7), language: nlq.Spanish},
+
+		// Spanish, general.
+		{text: "¿Qué tiempo hará mañana en Madrid?", intent: nlq.IntentGeneral, place: "Madrid", period: nlq.PeriodTomorrow, from: day(4), to: day(4), language: nlq.Spanish},
+		{text: "que tiempo hace en valencia", intent: nlq.IntentGeneral, place: "valencia", period: nlq.PeriodToday, from: day(3), to: day(3), language: nlq.Spanish},
+		{text: "el tiempo para el lunes en Granada", intent: nlq.IntentGeneral, place: "Granada", period: nlq.PeriodWeekday, from: day(8), to: day(8), language: nlq.Spanish},
+		{text: "pronóstico de este fin de semana en Málaga", intent: nlq.IntentGeneral, place: "Málaga", period: nlq.PeriodWeekend, from: day(6), to: day(7), language: nlq.Spanish},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.text, func(t *testing.T) {
+			q, ok := nlq.Parse(tC.text, now)
+			if !ok {
+				t.Fatal("expected the text to be understood")
+			}
+
+			if q.Intent != tC.intent {
+				t.Errorf("got intent %s, expected %s", q.Intent, tC.intent)
+			}
+
+			if q.Place != tC.place {
+				t.Errorf("got place %q, expected %q", q.Place, tC.place)
+			}
+
+			if q.Period != tC.period {
+				t.Errorf("got period %d, expected %d", q.Period, tC.period)
+			}
+
+			if !q.From.Equal(tC.from) || !q.To.Equal(tC.to) {
+				t.Errorf("got days %s - %s, expected %s - %s", q.From, q.To, tC.from, tC.to)
+			}
+
+			if q.Language != tC.language {
+				t.Errorf("got language %s, expected %s", q.Language, tC.language)
+			}
+		})
+	}
+}
+
+func TestParseIgnoresUnrelatedText(t *testing.T) {
+	now := time.Date(2022, time.August, 3, 10, 0, 0, 0, time.UTC)
+
+	for _, text := range []string{
+		"hello!",
+		"hola, ¿qué tal?",
+		"thanks",
+		"see you tomorrow in Lisbon",
+		"nos vemos mañana",
+		"",
+		"🙂",
+	} {
+		t.Run(text, func(t *testing.T) {
+			if q, ok := nlq.Parse(text, now); ok {
+				t.Errorf("expected the text to be ignored, got %+v", q)
+			}
+		})
+	}
+}
+
+func TestParseWeekend(t *testing.T) {
+	testCases := []struct {
+		desc string
+		now  time.Time
+		from time.Time
+		to   time.Time
+	}{
+		{
+			desc: "on a weekday it's the upcoming weekend",
+			now:  time.Date(2022, time.August, 5, 22, 0, 0, 0, time.UTC),
+			from: time.Date(2022, time.August, 6, 0, 0, 0, 0, time.UTC),
+			to:   time.Date(2022, time.August, 7, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			desc: "on Saturday it's today and tomorrow",
+			now:  time.Date(2022, time.August, 6, 9, 0, 0, 0, time.UTC),
+			from: time.Date(2022, time.August, 6, 0, 0, 0, 0, time.UTC),
+			to:   time.Date(2022, time.August, 7, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			desc: "on Sunday it's just today",
+			now:  time.Date(2022, time.August, 7, 9, 0, 0, 0, time.UTC),
+			from: time.Date(2022, time.August, 7, 0, 0, 0, 0, time.UTC),
+			to:   time.Date(2022, time.August, 7, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			q, ok := nlq.Parse("will it rain this weekend", tC.now)
+			if !ok {
+				t.Fatal("expected the text to be understood")
+			}
+
+			if !q.From.Equal(tC.from) || !q.To.Equal(tC.to) {
+				t.Errorf("got days %s - %s, expected %s - %s", q.From, q.To, tC.from, tC.to)
+			}
+		})
+	}
+}
+
+func TestParseDay(t *testing.T) {
+	// Wednesday.
+	now := time.Date(2022, time.August, 3, 10, 0, 0, 0, time.UTC)
+	day := func(m time.Month, d int) time.Time { return time.Date(2022, m, d, 0, 0, 0, 0, time.UTC) }
+
+	testCases := []struct {
+		text  string
+		day   time.Time
+		place string
+	}{
+		{text: "today", day: day(time.August, 3)},
+		{text: "tomorrow Lisbon", day: day(time.August, 4), place: "Lisbon"},
+		{text: "saturday", day: day(time.August, 6)},
+		{text: "Saturday in New York", day: day(time.August, 6), place: "New York"},
+		{text: "wednesday", day: day(time.August, 3)},
+		{text: "next wednesday", day: day(time.August, 10)},
+		{text: "sábado en Sevilla", day: day(time.August, 6), place: "Sevilla"},
+		{text: "pasado mañana", day: day(time.August, 5)},
+		{text: "2022-08-05 Porto", day: day(time.August, 5), place: "Porto"},
+		{text: "06/08", day: day(time.August, 6)},
+		{text: "1/8 Madrid", day: time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC), place: "Madrid"},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.text, func(t *testing.T) {
+			d, place, ok := nlq.ParseDay(tC.text, now)
+			if !ok {
+				t.Fatal("expected a day")
+			}
+
+			if !d.Equal(tC.day) {
+				t.Errorf("got %s, expected %s", d, tC.day)
+			}
+
+			if place != tC.place {
+				t.Errorf("got place %q, expected %q", place, tC.place)
+			}
+		})
+	}
+
+	for _, text := range []string{"", "Lisbon", "weekend", "in Lisbon", "32/13"} {
+		t.Run("ignores "+text, func(t *testing.T) {
+			if _, _, ok := nlq.ParseDay(text, now); ok {
+				t.Error("expected no day")
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package nlq
+
+import (
+	"strings"
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/weather"
+)
+
+// Chances of precipitation from which rain is worth mentioning.
+const (
+	LikelyRain   = 0.5
+	PossibleRain = 0.2
+)
+
+type RainOutlook int
+
+const (
+	RainUnlikely RainOutlook = iota
+	RainPossible
+	RainLikely
+)
+
+// Summary is what the forecasts of the days asked about have in common.
+type Summary struct {
+	// Forecasts are the forecasts the summary was made of. When empty, the
+	// question was about days beyond what the forecasts cover.
+	Forecasts []*weather.Forecast
+
+	Rain RainOutlook
+
+	// Chance is the highest chance of precipitation, between 0 and 1.
+	Chance float64
+
+	// RainFrom is the time of the first forecast where rain is likely. It's
+	// only meaningful for hourly forecasts.
+	RainFrom time.Time
+
+	MinTemperature float64
+	MaxTemperature float64
+	MaxWindSpeed   float64
+
+	// Descriptions are the distinct descriptions of the forecasts, in order.
+	Descriptions []string
+}
+
+// forecastWindow is how long hourly forecasts last.
+const forecastWindow = 3 * time.Hour
+
+// Select returns the forecasts for the days asked about. Forecasts which are
+// already over by now are left out, so "today" means the rest of the day.
+func Select(q Query, forecasts []*weather.Forecast, now time.Time) []*weather.Forecast {
+	var selected []*weather.Forecast
+	for _, f := range forecasts {
+		if q.Includes(f.Time()) && f.Time().Add(forecastWindow).After(now) {
+			selected = append(selected, f)
+		}
+	}
+
+	return selected
+}
+
+func Summarize(forecasts []*weather.Forecast) Summary {
+	s := Summary{Forecasts: forecasts}
+	if len(forecasts) == 0 {
+		return s
+	}
+
+	s.MinTemperature = forecasts[0].MinimumTemperature
+	s.MaxTemperature = forecasts[0].MaximumTemperature
+
+	seen := map[string]bool{}
+	for _, f := range forecasts {
+		s.MinTemperature = min(s.MinTemperature, f.MinimumTemperature)
+		s.MaxTemperature = max(s.MaxTemperature, f.MaximumTemperature)
+		s.MaxWindSpeed = max(s.MaxWindSpeed, f.WindSpeed)
+		s.Chance = max(s.Chance, f.PrecipitationProbability)
+
+		if f.PrecipitationProbability >= LikelyRain || isRainyDescription(f.Description) {
+			s.Rain = RainLikely
+			if s.RainFrom.IsZero() {
+				s.RainFrom = f.Time()
+			}
+		}
+
+		if !seen[f.Description] && f.Description != "" {
+			seen[f.Description] = true
+			s.Descriptions = append(s.Descriptions, f.Description)
+		}
+	}
+
+	if s.Rain != RainLikely && s.Chance >= PossibleRain {
+		s.Rain = RainPossible
+	}
+
+	return s
+}
+
+func isRainyDescription(d string) bool {
+	d = strings.ToLower(d)
+	return strings.Contains(d, "rain") || strings.Contains(d, "drizzle") || strings.Contains(d, "thunderstorm")
+}
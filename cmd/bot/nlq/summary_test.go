@@ -0,0 +1,69 @@
+package nlq_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/manzanit0/weathry/cmd/bot/nlq"
+	"github.com/manzanit0/weathry/pkg/weather"
+)
+
+func TestSummarize(t *testing.T) {
+	at := func(hour int) int {
+		return int(time.Date(2022, time.August, 3, hour, 0, 0, 0, time.UTC).Unix())
+	}
+
+	testCases := []struct {
+		desc      string
+		forecasts []*weather.Forecast
+		rain      nlq.RainOutlook
+		rainFrom  time.Time
+	}{
+		{
+			desc: "rain is likely from the first forecast with a high chance",
+			forecasts: []*weather.Forecast{
+				{DateTimeTS: at(9), Description: "few clouds", PrecipitationProbability: 0.1},
+				{DateTimeTS: at(12), Description: "light rain", PrecipitationProbability: 0.7},
+				{DateTimeTS: at(15), Description: "light rain", PrecipitationProbability: 0.9},
+			},
+			rain:     nlq.RainLikely,
+			rainFrom: time.Date(2022, time.August, 3, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			desc: "rain is likely when it's described even if the chance is low",
+			forecasts: []*weather.Forecast{
+				{DateTimeTS: at(9), Description: "light rain", PrecipitationProbability: 0.3},
+			},
+			rain:     nlq.RainLikely,
+			rainFrom: time.Date(2022, time.August, 3, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			desc: "rain is possible with a moderate chance",
+			forecasts: []*weather.Forecast{
+				{DateTimeTS: at(9), Description: "overcast clouds", PrecipitationProbability: 0.3},
+			},
+			rain: nlq.RainPossible,
+		},
+		{
+			desc: "rain is unlikely with a low chance",
+			forecasts: []*weather.Forecast{
+				{DateTimeTS: at(9), Description: "clear sky", PrecipitationProbability: 0.1},
+			},
+			rain: nlq.RainUnlikely,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			s := nlq.Summarize(tC.forecasts)
+
+			if s.Rain != tC.rain {
+				t.Errorf("got outlook %d, expected %d", s.Rain, tC.rain)
+			}
+
+			if !s.RainFrom.Equal(tC.rainFrom) {
+				t.Errorf("got rain from %s, expected %s", s.RainFrom, tC.rainFrom)
+			}
+		})
+	}
+}
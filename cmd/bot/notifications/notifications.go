@@ -0,0 +1,224 @@
+package notifications
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	RuleRain            = "rain"
+	RuleHighTemperature = "high_temperature"
+	RuleLowTemperature  = "low_temperature"
+)
+
+const (
+	// SuppressionWindow is how far back we look for equivalent notifications.
+	// It matches the horizon of the forecasts we check.
+	SuppressionWindow = 5 * 24 * time.Hour
+
+	// MaxTargetShift is how much the forecasted time can move before we
+	// consider the forecast to have materially changed.
+	MaxTargetShift = 3 * time.Hour
+
+	// MaxTemperatureShift is how much the forecasted temperature can change,
+	// in ºC, before we consider the forecast to have materially changed.
+	MaxTemperatureShift = 3.0
+)
+
+// UserRule returns the rule name for notifications triggered by user-defined
+// rules.
+func UserRule(ruleID int64) string {
+	return fmt.Sprintf("rule:%d", ruleID)
+}
+
+// ForecastChange returns the rule name for notifications triggered by changes
+// in the forecast since the last time it was checked.
+func ForecastChange(kind string) string {
+	return fmt.Sprintf("change:%s", kind)
+}
+
+type Notification struct {
+	ID          int64
+	UserID      int
+	Rule        string
+	TargetTime  time.Time
+	Temperature float64
+	Hash        string
+	Message     string
+	CreatedAt   time.Time
+
+	// OutboxMessageID is the outbox message the notification was queued in.
+	OutboxMessageID int64
+}
+
+// New creates a notification, calculating its hash.
+func New(userID int, rule string, target time.Time, temperature float64, message string) *Notification {
+	return &Notification{
+		UserID:      userID,
+		Rule:        rule,
+		TargetTime:  target,
+		Temperature: temperature,
+		Hash:        hash(userID, rule, target, temperature),
+		Message:     message,
+	}
+}
+
+// hash identifies equivalent notifications: same user, rule, hour and rounded
+// temperature.
+func hash(userID int, rule string, target time.Time, temperature float64) string {
+	s := fmt.Sprintf("%d|%s|%d|%.0f", userID, rule, target.UTC().Truncate(time.Hour).Unix(), math.Round(temperature))
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// Supersedes reports whether n should be sent even though prev was already
+// sent. That's the case when the forecast changed materially since, for
+// instance when the rain has moved several hours or the temperature shifted a
+// few degrees.
+func (n *Notification) Supersedes(prev *Notification) bool {
+	if prev == nil {
+		return true
+	}
+
+	if n.Hash == prev.Hash {
+		return false
+	}
+
+	shift := n.TargetTime.Sub(prev.TargetTime)
+	if shift > MaxTargetShift || shift < -MaxTargetShift {
+		return true
+	}
+
+	return math.Abs(n.Temperature-prev.Temperature) > MaxTemperatureShift
+}
+
+type dbNotification struct {
+	ID          int64     `db:"id"`
+	UserID      string    `db:"user_id"`
+	Rule        string    `db:"rule"`
+	TargetTime  time.Time `db:"target_time"`
+	Temperature float64   `db:"temperature"`
+	Hash        string    `db:"hash"`
+	Message     string    `db:"message"`
+	CreatedAt   time.Time `db:"created_at"`
+
+	OutboxMessageID sql.NullInt64 `db:"outbox_message_id"`
+}
+
+type Repository interface {
+	CreateNotification(ctx context.Context, n *Notification) error
+
+	// GetLatest returns the last notification of the rule sent to the user
+	// after since, which still targets a time after notBefore. Notifications
+	// whose outbox message failed are ignored, since the user never got them.
+	GetLatest(ctx context.Context, userID int, rule string, since, notBefore time.Time) (*Notification, error)
+
+	// ListNotifications returns the last notifications the user got, i.e.
+	// those whose outbox message was sent, as of when it was sent.
+	ListNotifications(ctx context.Context, userID int, limit int) ([]*Notification, error)
+}
+
+type pgRepo struct {
+	db *sqlx.DB
+}
+
+var _ Repository = (*pgRepo)(nil)
+
+func NewPgRepository(db *sql.DB) *pgRepo {
+	return &pgRepo{db: sqlx.NewDb(db, "postgres")}
+}
+
+func (r *pgRepo) CreateNotification(ctx context.Context, n *Notification) error {
+	query := `
+	INSERT INTO notifications (user_id, rule, target_time, temperature, hash, message, outbox_message_id)
+	VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, 0))
+	RETURNING id, created_at;`
+
+	var row struct {
+		ID        int64     `db:"id"`
+		CreatedAt time.Time `db:"created_at"`
+	}
+
+	err := r.db.GetContext(ctx, &row, query, fmt.Sprint(n.UserID), n.Rule, n.TargetTime, n.Temperature, n.Hash, n.Message, n.OutboxMessageID)
+	if err != nil {
+		return fmt.Errorf("insert notification: %w", err)
+	}
+
+	n.ID = row.ID
+	n.CreatedAt = row.CreatedAt
+	return nil
+}
+
+func (r *pgRepo) GetLatest(ctx context.Context, userID int, rule string, since, notBefore time.Time) (*Notification, error) {
+	var n dbNotification
+
+	query := `
+	SELECT n.id, n.user_id, n.rule, n.target_time, n.temperature, n.hash, n.message, n.created_at, n.outbox_message_id
+	FROM notifications n
+	LEFT JOIN outbox o ON o.id = n.outbox_message_id
+	WHERE n.user_id = $1 AND n.rule = $2 AND n.created_at > $3 AND n.target_time > $4
+	AND (o.status IS NULL OR o.status <> 'failed')
+	ORDER BY n.created_at DESC
+	LIMIT 1;`
+
+	err := r.db.GetContext(ctx, &n, query, fmt.Sprint(userID), rule, since, notBefore)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("select notification: %w", err)
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	return n.Map(), nil
+}
+
+func (r *pgRepo) ListNotifications(ctx context.Context, userID int, limit int) ([]*Notification, error) {
+	var nn []dbNotification
+
+	query := `
+	SELECT n.id, n.user_id, n.rule, n.target_time, n.temperature, n.hash, n.message, n.outbox_message_id,
+		COALESCE(o.sent_at, n.created_at) AS created_at
+	FROM notifications n
+	LEFT JOIN outbox o ON o.id = n.outbox_message_id
+	WHERE n.user_id = $1 AND (n.outbox_message_id IS NULL OR o.status = 'sent')
+	ORDER BY COALESCE(o.sent_at, n.created_at) DESC
+	LIMIT $2;`
+
+	err := r.db.SelectContext(ctx, &nn, query, fmt.Sprint(userID), limit)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("select notifications: %w", err)
+	}
+
+	nnx := make([]*Notification, len(nn))
+	for i := range nn {
+		nnx[i] = nn[i].Map()
+	}
+
+	return nnx, nil
+}
+
+func (n dbNotification) Map() *Notification {
+	uid, _ := strconv.Atoi(n.UserID)
+	return &Notification{
+		ID:          n.ID,
+		UserID:      uid,
+		Rule:        n.Rule,
+		TargetTime:  n.TargetTime,
+		Temperature: n.Temperature,
+		Hash:        n.Hash,
+		Message:     n.Message,
+		CreatedAt:   n.CreatedAt,
+
+		OutboxMessageID: n.OutboxMessageID.Int64,
+	}
+}
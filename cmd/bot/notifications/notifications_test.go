@@ -0,0 +1,72 @@
+package notifications_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/manzanit0/weathry/cmd/bot/notifications"
+)
+
+func TestSupersedes(t *testing.T) {
+	tuesday := time.Date(2022, time.August, 2, 15, 0, 0, 0, time.UTC)
+	prev := notifications.New(1, notifications.RuleRain, tuesday, 20, "Hey 👋! I'm expecting rain next Tue 02 at around 15:00h.")
+
+	testCases := []struct {
+		desc string
+		next *notifications.Notification
+		prev *notifications.Notification
+		want bool
+	}{
+		{
+			desc: "when nothing was sent before, it should be sent",
+			next: notifications.New(1, notifications.RuleRain, tuesday, 20, ""),
+			prev: nil,
+			want: true,
+		},
+		{
+			desc: "when the same notification was sent before, it should be suppressed",
+			next: notifications.New(1, notifications.RuleRain, tuesday, 20, ""),
+			prev: prev,
+			want: false,
+		},
+		{
+			desc: "when the rain moved less than 3 hours, it should be suppressed",
+			next: notifications.New(1, notifications.RuleRain, tuesday.Add(3*time.Hour), 20, ""),
+			prev: prev,
+			want: false,
+		},
+		{
+			desc: "when the rain moved more than 3 hours later, it should be sent",
+			next: notifications.New(1, notifications.RuleRain, tuesday.Add(6*time.Hour), 20, ""),
+			prev: prev,
+			want: true,
+		},
+		{
+			desc: "when the rain moved more than 3 hours earlier, it should be sent",
+			next: notifications.New(1, notifications.RuleRain, tuesday.Add(-6*time.Hour), 20, ""),
+			prev: prev,
+			want: true,
+		},
+		{
+			desc: "when the temperature shifted less than 3 degrees, it should be suppressed",
+			next: notifications.New(1, notifications.RuleRain, tuesday, 22.5, ""),
+			prev: prev,
+			want: false,
+		},
+		{
+			desc: "when the temperature shifted more than 3 degrees, it should be sent",
+			next: notifications.New(1, notifications.RuleRain, tuesday, 16.5, ""),
+			prev: prev,
+			want: true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got := tC.next.Supersedes(tC.prev)
+			if got != tC.want {
+				t.Errorf("got %t, expected %t", got, tC.want)
+			}
+		})
+	}
+}
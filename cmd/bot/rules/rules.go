@@ -0,0 +1,96 @@
+package rules
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// MaxRulesPerUser is the amount of rules a single user can have.
+const MaxRulesPerUser = 10
+
+// Rule is a user-defined notification rule. Expression is written in the
+// language of the expr package.
+type Rule struct {
+	ID         int64
+	UserID     int
+	Expression string
+}
+
+type dbRule struct {
+	ID         int64  `db:"id"`
+	UserID     string `db:"user_id"`
+	Expression string `db:"expression"`
+
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+type Repository interface {
+	CreateRule(ctx context.Context, userID int, expression string) (*Rule, error)
+	ListRules(ctx context.Context, userID int) ([]*Rule, error)
+	DeleteRule(ctx context.Context, userID int, ruleID int64) (bool, error)
+}
+
+type pgRepo struct {
+	db *sqlx.DB
+}
+
+var _ Repository = (*pgRepo)(nil)
+
+func NewPgRepository(db *sql.DB) *pgRepo {
+	return &pgRepo{db: sqlx.NewDb(db, "postgres")}
+}
+
+func (r *pgRepo) CreateRule(ctx context.Context, userID int, expression string) (*Rule, error) {
+	var id int64
+
+	query := `INSERT INTO rules (user_id, expression) VALUES ($1, $2) RETURNING id`
+	err := r.db.GetContext(ctx, &id, query, fmt.Sprint(userID), expression)
+	if err != nil {
+		return nil, fmt.Errorf("insert rule: %w", err)
+	}
+
+	return &Rule{ID: id, UserID: userID, Expression: expression}, nil
+}
+
+func (r *pgRepo) ListRules(ctx context.Context, userID int) ([]*Rule, error) {
+	var rules []dbRule
+
+	query := `SELECT * FROM rules WHERE user_id = $1 ORDER BY id`
+	err := r.db.SelectContext(ctx, &rules, query, fmt.Sprint(userID))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("select rules: %w", err)
+	}
+
+	rulesx := make([]*Rule, len(rules))
+	for i := range rules {
+		rulesx[i] = rules[i].Map()
+	}
+
+	return rulesx, nil
+}
+
+func (r *pgRepo) DeleteRule(ctx context.Context, userID int, ruleID int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM rules WHERE user_id = $1 AND id = $2`, fmt.Sprint(userID), ruleID)
+	if err != nil {
+		return false, fmt.Errorf("delete rule: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("rows affected: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+func (u dbRule) Map() *Rule {
+	uid, _ := strconv.Atoi(u.UserID)
+	return &Rule{ID: u.ID, UserID: uid, Expression: u.Expression}
+}
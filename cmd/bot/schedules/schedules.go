@@ -0,0 +1,155 @@
+package schedules
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Schedule is when a user wants to get their forecast checked, instead of the
+// default pinger runs.
+type Schedule struct {
+	UserID         int
+	CronExpression string
+
+	// Timezone is the user's timezone, in which the cron expression is
+	// evaluated.
+	Timezone  string
+	LastRunAt *time.Time
+	CreatedAt time.Time
+}
+
+// Location returns the schedule's timezone, falling back to UTC if it's
+// invalid.
+func (s *Schedule) Location() *time.Location {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+type dbSchedule struct {
+	UserID         string     `db:"user_id"`
+	CronExpression string     `db:"cron_expression"`
+	Timezone       string     `db:"timezone"`
+	LastRunAt      *time.Time `db:"last_run_at"`
+	CreatedAt      time.Time  `db:"created_at"`
+}
+
+type Repository interface {
+	SetSchedule(ctx context.Context, userID int, cronExpression string) error
+	GetSchedule(ctx context.Context, userID int) (*Schedule, error)
+	DeleteSchedule(ctx context.Context, userID int) (bool, error)
+	ListSchedules(ctx context.Context) ([]*Schedule, error)
+	MarkRun(ctx context.Context, userID int, at time.Time) error
+}
+
+type pgRepo struct {
+	db *sqlx.DB
+}
+
+var _ Repository = (*pgRepo)(nil)
+
+func NewPgRepository(db *sql.DB) *pgRepo {
+	return &pgRepo{db: sqlx.NewDb(db, "postgres")}
+}
+
+// SetSchedule creates or replaces the user's schedule. The schedule is
+// considered to have last run now, so it doesn't run straight away.
+func (r *pgRepo) SetSchedule(ctx context.Context, userID int, cronExpression string) error {
+	query := `
+	INSERT INTO user_schedules (user_id, cron_expression, last_run_at)
+	VALUES ($1, $2, now())
+	ON CONFLICT (user_id) DO UPDATE SET cron_expression = $2, last_run_at = now();`
+
+	_, err := r.db.ExecContext(ctx, query, fmt.Sprint(userID), cronExpression)
+	if err != nil {
+		return fmt.Errorf("upsert schedule: %w", err)
+	}
+
+	return nil
+}
+
+func (r *pgRepo) GetSchedule(ctx context.Context, userID int) (*Schedule, error) {
+	var s dbSchedule
+
+	query := `
+	SELECT us.user_id, us.cron_expression, us.last_run_at, us.created_at, u.timezone
+	FROM user_schedules us
+	INNER JOIN users u ON u.chat_id = us.user_id
+	WHERE us.user_id = $1;`
+
+	err := r.db.GetContext(ctx, &s, query, fmt.Sprint(userID))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("select schedule: %w", err)
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	return s.Map(), nil
+}
+
+func (r *pgRepo) DeleteSchedule(ctx context.Context, userID int) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM user_schedules WHERE user_id = $1`, fmt.Sprint(userID))
+	if err != nil {
+		return false, fmt.Errorf("delete schedule: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("rows affected: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+func (r *pgRepo) ListSchedules(ctx context.Context) ([]*Schedule, error) {
+	var ss []dbSchedule
+
+	query := `
+	SELECT us.user_id, us.cron_expression, us.last_run_at, us.created_at, u.timezone
+	FROM user_schedules us
+	INNER JOIN users u ON u.chat_id = us.user_id
+	WHERE u.active;`
+
+	err := r.db.SelectContext(ctx, &ss, query)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("select schedules: %w", err)
+	}
+
+	ssx := make([]*Schedule, len(ss))
+	for i := range ss {
+		ssx[i] = ss[i].Map()
+	}
+
+	return ssx, nil
+}
+
+func (r *pgRepo) MarkRun(ctx context.Context, userID int, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE user_schedules SET last_run_at = $1 WHERE user_id = $2`, at, fmt.Sprint(userID))
+	if err != nil {
+		return fmt.Errorf("update schedule: %w", err)
+	}
+
+	return nil
+}
+
+func (s dbSchedule) Map() *Schedule {
+	uid, _ := strconv.Atoi(s.UserID)
+	return &Schedule{
+		UserID:         uid,
+		CronExpression: s.CronExpression,
+		Timezone:       s.Timezone,
+		LastRunAt:      s.LastRunAt,
+		CreatedAt:      s.CreatedAt,
+	}
+}
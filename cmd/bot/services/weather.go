@@ -1,87 +1,278 @@
 package services
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/manzanit0/weathry/cmd/bot/location"
 	"github.com/manzanit0/weathry/cmd/bot/msg"
+	"github.com/manzanit0/weathry/cmd/bot/nlq"
+	"github.com/manzanit0/weathry/pkg/chart"
 	"github.com/manzanit0/weathry/pkg/geocode"
+	"github.com/manzanit0/weathry/pkg/tgram"
 	"github.com/manzanit0/weathry/pkg/weather"
 )
 
 type WeatherService struct {
 	geocoder   geocode.Client
 	forecaster weather.Client
+	codec      *tgram.CallbackCodec
 }
 
-func NewWeatherService(l geocode.Client, w weather.Client) *WeatherService {
-	return &WeatherService{geocoder: l, forecaster: w}
+func NewWeatherService(l geocode.Client, w weather.Client, codec *tgram.CallbackCodec) *WeatherService {
+	return &WeatherService{geocoder: l, forecaster: w, codec: codec}
 }
 
-func (a *WeatherService) GetDailyWeatherByLocationName(locationName string) (string, error) {
-	location, err := a.geocoder.Geocode(locationName)
+// dailyPages is how many pages of daily forecasts there are: two weeks.
+const dailyPages = 2
+
+func (a *WeatherService) GetDailyWeatherByLocationName(ctx context.Context, locationName string, loc *time.Location, layout msg.Layout) (string, *tgram.ReplyMarkup, error) {
+	location, err := a.geocoder.Geocode(ctx, locationName)
 	if err != nil {
-		return "", fmt.Errorf("find location: %w", err)
+		return "", nil, fmt.Errorf("find location: %w", err)
 	}
 
-	forecasts, err := a.forecaster.GetUpcomingWeather(location.Latitude, location.Longitude)
+	return a.GetForecastPage(ctx, MapLocation(location), msg.ViewDaily, 0, loc, layout)
+}
+
+func (a *WeatherService) GetHourlyWeatherByLocationName(ctx context.Context, locationName string, loc *time.Location) (string, *tgram.ReplyMarkup, error) {
+	location, err := a.geocoder.Geocode(ctx, locationName)
 	if err != nil {
-		return "", fmt.Errorf("get weather: %w", err)
+		return "", nil, fmt.Errorf("find location: %w", err)
 	}
 
-	return msg.NewForecastTableMessage(MapLocation(location), forecasts, msg.WithTemperatureDiff()), nil
+	return a.GetForecastPage(ctx, MapLocation(location), msg.ViewHourly, 0, loc, msg.LayoutTable)
 }
 
-func (a *WeatherService) GetDailyWeatherByCoordinates(latitude, longitude float64) (string, error) {
-	location, err := a.geocoder.ReverseGeocode(latitude, longitude)
+// GetForecastPageByCoordinates renders a page of forecasts for the place at
+// the given coordinates. The coordinates are kept as they are, rather than
+// those of the place, so moving around the pages doesn't drift.
+func (a *WeatherService) GetForecastPageByCoordinates(ctx context.Context, latitude, longitude float64, view msg.ForecastView, page int, loc *time.Location, layout msg.Layout) (string, *tgram.ReplyMarkup, error) {
+	location, err := a.geocoder.ReverseGeocode(ctx, latitude, longitude)
 	if err != nil {
-		return "", fmt.Errorf("find location: %w", err)
+		return "", nil, fmt.Errorf("find location: %w", err)
+	}
+
+	l := MapLocation(location)
+	l.Latitude, l.Longitude = latitude, longitude
+
+	return a.GetForecastPage(ctx, l, view, page, loc, layout)
+}
+
+// GetForecastPage renders a page of the hourly or daily forecasts, along with
+// the buttons to move to the other pages. Pages past the last one show the
+// last one, since forecasts come and go as time passes. Daily forecasts are
+// rendered in the given layout, while hourly ones are always a table.
+func (a *WeatherService) GetForecastPage(ctx context.Context, l *location.Location, view msg.ForecastView, page int, loc *time.Location, layout msg.Layout) (string, *tgram.ReplyMarkup, error) {
+	var forecasts []*weather.Forecast
+	var err error
+
+	size := msg.HourlyPageSize
+	opts := []msg.MessageOption{msg.InLocation(loc)}
+
+	switch view {
+	case msg.ViewDaily:
+		forecasts, err = a.forecaster.GetDailyForecast(ctx, l.Latitude, l.Longitude, dailyPages*msg.DailyPageSize)
+		size = msg.DailyPageSize
+		opts = append(opts, msg.WithTemperatureDiff())
+
+	case msg.ViewHourly:
+		forecasts, err = a.forecaster.GetHourlyForecast(ctx, l.Latitude, l.Longitude)
+		// We don't need the temperature diff because within the hour there's not much difference.
+		opts = append(opts, msg.WithTime())
+
+	default:
+		return "", nil, fmt.Errorf("unsupported view %q", view)
 	}
 
-	forecasts, err := a.forecaster.GetUpcomingWeather(location.Latitude, location.Longitude)
 	if err != nil {
-		return "", fmt.Errorf("get weather: %w", err)
+		return "", nil, fmt.Errorf("get weather: %w", err)
+	}
+
+	render := func(forecasts []*weather.Forecast) string {
+		return msg.NewForecastTableMessage(l, forecasts, opts...)
+	}
+
+	if view == msg.ViewDaily && layout == msg.LayoutCompact {
+		// The sparklines are drawn out of the hourly forecasts.
+		hourly, err := a.forecaster.GetHourlyForecast(ctx, l.Latitude, l.Longitude)
+		if err != nil {
+			return "", nil, fmt.Errorf("get hourly weather: %w", err)
+		}
+
+		render = func(forecasts []*weather.Forecast) string {
+			return msg.NewCompactForecastMessage(l, forecasts, hourly, msg.InLocation(loc))
+		}
+	}
+
+	pages := (len(forecasts) + size - 1) / size
+	if pages == 0 {
+		return render(forecasts), nil, nil
 	}
 
-	return msg.NewForecastTableMessage(MapLocation(location), forecasts, msg.WithTemperatureDiff()), nil
+	page = max(0, min(page, pages-1))
+	from, to := page*size, min((page+1)*size, len(forecasts))
+
+	keyboard, err := msg.NewForecastPageKeyboard(ctx, a.codec, view, page, pages, l.Latitude, l.Longitude)
+	if err != nil {
+		return "", nil, fmt.Errorf("create keyboard: %w", err)
+	}
+
+	return render(forecasts[from:to]), keyboard, nil
 }
 
-func (a *WeatherService) GetHourlyWeatherByLocationName(locationName string) (string, error) {
-	location, err := a.geocoder.Geocode(locationName)
+// Forecasts drawn in charts: two days hour by hour, or a week day by day.
+const (
+	chartHourlyForecasts = 16
+	chartDailyForecasts  = msg.DailyPageSize
+)
+
+func (a *WeatherService) GetForecastChartByLocationName(ctx context.Context, locationName string, view msg.ForecastView, loc *time.Location) ([]byte, string, error) {
+	location, err := a.geocoder.Geocode(ctx, locationName)
+	if err != nil {
+		return nil, "", fmt.Errorf("find location: %w", err)
+	}
+
+	return a.GetForecastChart(ctx, MapLocation(location), view, loc)
+}
+
+// GetForecastChart draws the hourly or daily forecasts of the location as a
+// PNG, and returns it along with its caption.
+func (a *WeatherService) GetForecastChart(ctx context.Context, l *location.Location, view msg.ForecastView, loc *time.Location) ([]byte, string, error) {
+	var forecasts []*weather.Forecast
+	var err error
+
+	opts := []chart.Option{chart.InLocation(loc)}
+
+	switch view {
+	case msg.ViewDaily:
+		forecasts, err = a.forecaster.GetDailyForecast(ctx, l.Latitude, l.Longitude, chartDailyForecasts)
+		opts = append(opts, chart.Daily())
+
+	case msg.ViewHourly:
+		forecasts, err = a.forecaster.GetHourlyForecast(ctx, l.Latitude, l.Longitude)
+		forecasts = forecasts[:min(len(forecasts), chartHourlyForecasts)]
+
+	default:
+		return nil, "", fmt.Errorf("unsupported view %q", view)
+	}
+
+	if err != nil {
+		return nil, "", fmt.Errorf("get weather: %w", err)
+	}
+
+	var b bytes.Buffer
+	if err := chart.Render(&b, forecasts, opts...); err != nil {
+		return nil, "", fmt.Errorf("render chart: %w", err)
+	}
+
+	return b.Bytes(), msg.NewForecastChartCaption(l, view), nil
+}
+
+func (a *WeatherService) GetCurrentWeatherByLocationName(ctx context.Context, locationName string, loc *time.Location) (string, error) {
+	location, err := a.geocoder.Geocode(ctx, locationName)
+	if err != nil {
+		return "", fmt.Errorf("find location: %w", err)
+	}
+
+	return getCurrentWeather(ctx, a.forecaster, MapLocation(location), loc)
+}
+
+func (a *WeatherService) GetCurrentWeatherByCoordinates(ctx context.Context, latitude, longitude float64, loc *time.Location) (string, error) {
+	location, err := a.geocoder.ReverseGeocode(ctx, latitude, longitude)
 	if err != nil {
 		return "", fmt.Errorf("find location: %w", err)
 	}
 
-	return getHourlyWeather(a.forecaster, MapLocation(location))
+	return getCurrentWeather(ctx, a.forecaster, MapLocation(location), loc)
+}
+
+func getCurrentWeather(ctx context.Context, weatherClient weather.Client, location *location.Location, loc *time.Location) (string, error) {
+	observation, err := weatherClient.GetCurrentWeather(ctx, location.Latitude, location.Longitude)
+	if err != nil {
+		return "", fmt.Errorf("get current weather: %w", err)
+	}
+
+	return msg.NewObservationMessage(location, observation, loc), nil
 }
 
-func (a *WeatherService) GetHourlyWeatherByCoordinates(latitude, longitude float64) (string, error) {
-	location, err := a.geocoder.ReverseGeocode(latitude, longitude)
+// GetDaysWeatherByLocationName renders the hourly forecasts of the days from
+// first to last, both included. Days are in the location of first.
+func (a *WeatherService) GetDaysWeatherByLocationName(ctx context.Context, locationName string, first, last time.Time) (string, error) {
+	location, err := a.geocoder.Geocode(ctx, locationName)
 	if err != nil {
 		return "", fmt.Errorf("find location: %w", err)
 	}
 
-	return getHourlyWeather(a.forecaster, MapLocation(location))
+	return a.GetDaysWeather(ctx, MapLocation(location), first, last)
 }
 
-func getHourlyWeather(weatherClient weather.Client, location *location.Location) (string, error) {
-	forecasts, err := weatherClient.GetHourlyForecast(location.Latitude, location.Longitude)
+// GetDaysWeather renders the hourly forecasts of the days from first to last,
+// both included. Days are in the location of first.
+func (a *WeatherService) GetDaysWeather(ctx context.Context, location *location.Location, first, last time.Time) (string, error) {
+	forecasts, err := a.forecaster.GetHourlyForecast(ctx, location.Latitude, location.Longitude)
 	if err != nil {
 		return "", fmt.Errorf("get weather: %w", err)
 	}
 
-	// Just 9 forecasts for the hourly, to cover 24h.
-	if len(forecasts) > 9 {
-		filtered := make([]*weather.Forecast, 9)
-		for i := 0; i < 9; i++ {
-			filtered[i] = forecasts[i]
+	loc := first.Location()
+	end := time.Date(last.Year(), last.Month(), last.Day()+1, 0, 0, 0, 0, loc)
+
+	var selected []*weather.Forecast
+	for _, f := range forecasts {
+		if !f.Time().Before(first) && f.Time().Before(end) {
+			selected = append(selected, f)
+		}
+	}
+
+	if len(selected) == 0 {
+		return msg.MsgDaysOutOfRange, nil
+	}
+
+	return msg.NewForecastTableMessage(location, selected, msg.WithTime(), msg.InLocation(loc)), nil
+}
+
+// AnswerQuery answers a question in plain words. When the question doesn't
+// mention any place, it's answered for home, which may be nil. now must be in
+// the timezone of the user.
+func (a *WeatherService) AnswerQuery(ctx context.Context, q nlq.Query, home *location.Location, now time.Time) (string, error) {
+	place := home
+	if q.Place != "" {
+		remote, err := a.geocoder.Geocode(ctx, q.Place)
+		if err != nil {
+			return "", fmt.Errorf("find location: %w", err)
 		}
 
-		return msg.NewForecastTableMessage(location, filtered, msg.WithTime()), nil
+		place = MapLocation(remote)
+		place.Name = q.Place
+	}
+
+	if place == nil {
+		return msg.NewQueryMissingPlaceMessage(q.Language), nil
+	}
+
+	// Hourly forecasts are more accurate for what's left of today, but they
+	// only cover a few days so the daily ones are used otherwise.
+	if q.Period == nlq.PeriodToday {
+		forecasts, err := a.forecaster.GetHourlyForecast(ctx, place.Latitude, place.Longitude)
+		if err != nil {
+			return "", fmt.Errorf("get hourly weather: %w", err)
+		}
+
+		if selected := nlq.Select(q, forecasts, now); len(selected) > 0 {
+			return msg.NewQueryAnswerMessage(q, place.Name, nlq.Summarize(selected), now.Location(), true), nil
+		}
+	}
+
+	forecasts, err := a.forecaster.GetUpcomingWeather(ctx, place.Latitude, place.Longitude)
+	if err != nil {
+		return "", fmt.Errorf("get weather: %w", err)
 	}
 
-	// We don't need the temperature diff because within the hour there's not much difference.
-	return msg.NewForecastTableMessage(location, forecasts, msg.WithTime()), nil
+	selected := nlq.Select(q, forecasts, now)
+	return msg.NewQueryAnswerMessage(q, place.Name, nlq.Summarize(selected), now.Location(), false), nil
 }
 
 func MapLocation(l *geocode.Location) *location.Location {
@@ -0,0 +1,124 @@
+package templates
+
+import (
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/weather"
+)
+
+// Data is what templates are executed with. Alert templates get the Alerts,
+// and brief templates the Brief.
+//
+// For instance, an alert template could be:
+//
+//	{{range .Alerts}}{{emoji .Forecast.Condition}} {{localtime .Forecast.Time "Mon 15:04"}}: {{round .Forecast.MaxTemperature}}{{$.Units.Temperature}}
+//	{{end}}
+type Data struct {
+	User     User
+	Location Location
+	Units    Units
+
+	// Forecasts are the upcoming forecasts the message was built from, hour
+	// by hour.
+	Forecasts []Forecast
+
+	Alerts []Alert
+	Brief  *Brief
+}
+
+type User struct {
+	ID int
+
+	// Timezone is the user's timezone, such as "Europe/Madrid", in which
+	// localtime renders times.
+	Timezone string
+}
+
+// Location is the place the message is about, usually the user's home.
+type Location struct {
+	Name      string
+	Latitude  float64
+	Longitude float64
+}
+
+// Units are the units measurements are in, to print along them.
+type Units struct {
+	Temperature string
+	WindSpeed   string
+}
+
+// DefaultUnits are the units forecasts come in.
+var DefaultUnits = Units{Temperature: "ºC", WindSpeed: "m/s"}
+
+// Forecast is the forecast of a window of a few hours, or of a day.
+type Forecast struct {
+	Time        time.Time
+	Condition   string
+	Description string
+
+	MinTemperature float64
+	MaxTemperature float64
+
+	// PrecipitationProbability is between 0 and 1.
+	PrecipitationProbability float64
+	WindSpeed                float64
+	Humidity                 int
+}
+
+func NewForecast(f *weather.Forecast) Forecast {
+	return Forecast{
+		Time:                     f.Time(),
+		Condition:                f.Condition,
+		Description:              f.Description,
+		MinTemperature:           f.MinimumTemperature,
+		MaxTemperature:           f.MaximumTemperature,
+		PrecipitationProbability: f.PrecipitationProbability,
+		WindSpeed:                f.WindSpeed,
+		Humidity:                 f.Humidity,
+	}
+}
+
+func NewForecasts(ff []*weather.Forecast) []Forecast {
+	forecasts := make([]Forecast, len(ff))
+	for i, f := range ff {
+		forecasts[i] = NewForecast(f)
+	}
+
+	return forecasts
+}
+
+// Alert is a single reason the user is notified about.
+type Alert struct {
+	// Rule is what triggered the alert, such as "rain", "high_temperature",
+	// "rule:42" for the user's rules or "change:warmer" for forecast changes.
+	Rule string
+
+	// Text is how the alert is worded by default.
+	Text string
+
+	// Standalone alerts are worded as full sentences. The rest are worded to
+	// follow "Hi! Just letting you know that...".
+	Standalone bool
+
+	// Today tells whether the forecast is for the day the alert is sent.
+	Today    bool
+	Forecast Forecast
+}
+
+// Brief is a summary of the weather for the rest of the day.
+type Brief struct {
+	Day         time.Time
+	High        float64
+	Low         float64
+	MaxWind     float64
+	RainWindows []TimeWindow
+
+	// Highlights are the clothing tips for the day, such as taking an
+	// umbrella.
+	Highlights []string
+}
+
+type TimeWindow struct {
+	From time.Time
+	To   time.Time
+}
@@ -0,0 +1,390 @@
+package templates
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/manzanit0/weathry/cmd/bot/msg"
+)
+
+// Limits of the templates, so a template can't hold up or flood the pinger.
+const (
+	// MaxBodyLength is the most bytes a template can have.
+	MaxBodyLength = 4096
+
+	// MaxOutputLength is the most bytes a template can render, which is
+	// enough for a few messages.
+	MaxOutputLength = 16384
+
+	// MaxIterations is the most times a template can go through the body of
+	// its ranges, all of them put together.
+	MaxIterations = 100000
+
+	// DefaultTimeout is how long a template can run for.
+	DefaultTimeout = 100 * time.Millisecond
+)
+
+var (
+	ErrBodyTooLong       = fmt.Errorf("templates can't be longer than %d characters", MaxBodyLength)
+	ErrOutputTooLong     = fmt.Errorf("templates can't render more than %d characters", MaxOutputLength)
+	ErrTooManyIterations = fmt.Errorf("templates can't loop more than %d times", MaxIterations)
+	ErrTimeout           = errors.New("template took too long to render")
+	ErrEmptyOutput       = errors.New("template rendered an empty message")
+)
+
+// DefaultAlert is the template of alerts for users without one.
+const DefaultAlert = `
+{{- range $i, $a := .Alerts}}
+	{{- if $i}}{{"\n"}}{{end}}
+	{{- if $a.Standalone}}{{$a.Text}}
+	{{- else if $i}}Also, on a separate note, {{$a.Text}}
+	{{- else}}Hi! Just letting you know that {{$a.Text}}
+	{{- end}}
+{{- end}}`
+
+// DefaultBrief is the template of briefs for users without one.
+const DefaultBrief = `☀️ Hi! Here's your {{.Brief.Day.Format "Monday"}} in {{.Location.Name}}:
+
+🌡 High of {{printf "%.0f" .Brief.High}}{{.Units.Temperature}} and low of {{printf "%.0f" .Brief.Low}}{{.Units.Temperature}}
+{{- range .Brief.RainWindows}}
+🌧 Rain from {{localtime .From "15:04"}} to {{localtime .To "15:04"}}
+{{- else}}
+🌂 No rain expected
+{{- end}}
+💨 Wind up to {{printf "%.0f" .Brief.MaxWind}} {{.Units.WindSpeed}}
+{{- if .Brief.Highlights}}
+{{range .Brief.Highlights}}
+{{.}}
+{{- end}}
+{{- end}}`
+
+// Default returns the template of the kind for users without one.
+func Default(kind Kind) string {
+	if kind == KindBrief {
+		return DefaultBrief
+	}
+
+	return DefaultAlert
+}
+
+// newFuncs returns the helpers templates can use, rendering times in loc, and
+// those ranges are bounded with.
+func newFuncs(loc *time.Location, b *budget) template.FuncMap {
+	return template.FuncMap{
+		stepFunc:      b.step,
+		rangeableFunc: rangeable,
+
+		// round rounds to the nearest integer, such as 12 for 11.6.
+		"round": func(v float64) float64 {
+			// Adding zero turns -0 into 0.
+			return math.Round(v) + 0
+		},
+
+		// emoji returns the emoji of a condition, such as 🌧 for "rain".
+		"emoji": msg.ConditionEmoji,
+
+		// localtime formats a time in the user's timezone, with a layout
+		// such as "Mon 15:04".
+		"localtime": func(t time.Time, layout string) string {
+			return t.In(loc).Format(layout)
+		},
+	}
+}
+
+func parseBody(body string, loc *time.Location, b *budget) (*template.Template, error) {
+	if len(body) > MaxBodyLength {
+		return nil, ErrBodyTooLong
+	}
+
+	funcs := newFuncs(loc, b)
+	t, err := template.New("message").Funcs(funcs).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Templates calling others could recurse, and run for a long time
+	// without rendering anything, so they're not allowed.
+	if len(t.Templates()) > 1 {
+		return nil, errors.New("templates can't define other templates")
+	}
+
+	if err := walk(t.Tree.Root, checkNode); err != nil {
+		return nil, err
+	}
+
+	bound, err := newRangeBound(funcs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := walk(t.Tree.Root, bound.apply); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// walk calls f with node and every node within it.
+func walk(node parse.Node, f func(parse.Node) error) error {
+	if err := f(node); err != nil {
+		return err
+	}
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+
+		for _, child := range n.Nodes {
+			if err := walk(child, f); err != nil {
+				return err
+			}
+		}
+	case *parse.IfNode:
+		return walkBranch(&n.BranchNode, f)
+	case *parse.RangeNode:
+		return walkBranch(&n.BranchNode, f)
+	case *parse.WithNode:
+		return walkBranch(&n.BranchNode, f)
+	}
+
+	return nil
+}
+
+func walkBranch(b *parse.BranchNode, f func(parse.Node) error) error {
+	if err := walk(b.List, f); err != nil {
+		return err
+	}
+
+	return walk(b.ElseList, f)
+}
+
+func checkNode(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.TemplateNode:
+		return errors.New("templates can't call other templates")
+	case *parse.RangeNode:
+		return checkRange(n)
+	}
+
+	return nil
+}
+
+// checkRange only lets templates range over their data, such as .Forecasts or
+// $a.Alerts, rather than over numbers or whatever functions return.
+func checkRange(n *parse.RangeNode) error {
+	err := errors.New("templates can only range over their data, such as .Forecasts")
+	if len(n.Pipe.Cmds) != 1 || len(n.Pipe.Cmds[0].Args) != 1 {
+		return err
+	}
+
+	switch n.Pipe.Cmds[0].Args[0].(type) {
+	case *parse.DotNode, *parse.FieldNode, *parse.VariableNode:
+		return nil
+	default:
+		return err
+	}
+}
+
+// Names of the functions ranges are bounded with. Templates could call them
+// too, but all they'd get is an empty string.
+const (
+	stepFunc      = "_step"
+	rangeableFunc = "_rangeable"
+)
+
+// rangeBound bounds the ranges of a template: what they range over is
+// checked to be a slice or a map, since fields such as .Time.Unix are
+// numbers, and every iteration counts against the budget of the execution.
+type rangeBound struct {
+	check *parse.CommandNode
+	step  parse.Node
+}
+
+func newRangeBound(funcs template.FuncMap) (*rangeBound, error) {
+	t, err := template.New("bound").Funcs(funcs).Parse("{{range . | " + rangeableFunc + "}}{{" + stepFunc + "}}{{end}}")
+	if err != nil {
+		return nil, fmt.Errorf("parse range bound: %w", err)
+	}
+
+	n := t.Tree.Root.Nodes[0].(*parse.RangeNode)
+	return &rangeBound{check: n.Pipe.Cmds[1], step: n.List.Nodes[0]}, nil
+}
+
+func (b *rangeBound) apply(node parse.Node) error {
+	n, ok := node.(*parse.RangeNode)
+	if !ok {
+		return nil
+	}
+
+	n.Pipe.Cmds = append(n.Pipe.Cmds, b.check.Copy().(*parse.CommandNode))
+	n.List.Nodes = append([]parse.Node{b.step.Copy()}, n.List.Nodes...)
+	return nil
+}
+
+func rangeable(v any) (any, error) {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Invalid, reflect.Slice, reflect.Array, reflect.Map:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("can't range over %T, only over lists such as .Forecasts", v)
+	}
+}
+
+// budget stops the execution of a template once it has looped too many times
+// or run out of time.
+type budget struct {
+	ctx   context.Context
+	steps int
+}
+
+func (b *budget) step() (string, error) {
+	if b.ctx.Err() != nil {
+		return "", ErrTimeout
+	}
+
+	b.steps++
+	if b.steps > MaxIterations {
+		return "", ErrTooManyIterations
+	}
+
+	return "", nil
+}
+
+// Validate checks that the body is a valid template of the kind, by
+// rendering it with sample data.
+func Validate(kind Kind, body string) error {
+	_, err := Execute(context.Background(), body, SampleData(kind))
+	return err
+}
+
+type executeOptions struct {
+	timeout time.Duration
+}
+
+type ExecuteOption func(*executeOptions)
+
+// WithTimeout sets how long the template can run for. Defaults to
+// DefaultTimeout.
+func WithTimeout(d time.Duration) ExecuteOption {
+	return func(o *executeOptions) {
+		o.timeout = d
+	}
+}
+
+// Execute renders the template body with data. Templates run with a timeout
+// and limits on how much they can loop and render, and can only call the
+// helpers:
+//
+//   - round, which rounds a number to the nearest integer.
+//   - emoji, which returns the emoji of a condition.
+//   - localtime, which formats a time in the user's timezone.
+func Execute(ctx context.Context, body string, data Data, opts ...ExecuteOption) (string, error) {
+	o := executeOptions{timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	loc, err := time.LoadLocation(data.User.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	b := budget{ctx: ctx}
+	t, err := parseBody(body, loc, &b)
+	if err != nil {
+		return "", fmt.Errorf("parse template: %w", err)
+	}
+
+	// Templates only loop in ranges, and only write so much, so checking the
+	// deadline on every iteration and write stops them in time.
+	w := limitedWriter{ctx: ctx, limit: MaxOutputLength}
+	if err := t.Execute(&w, data); err != nil {
+		if errors.Is(err, ErrTimeout) {
+			return "", ErrTimeout
+		}
+
+		return "", fmt.Errorf("execute template: %w", err)
+	}
+
+	if len(bytes.TrimSpace(w.buf.Bytes())) == 0 {
+		return "", ErrEmptyOutput
+	}
+
+	return w.buf.String(), nil
+}
+
+type limitedWriter struct {
+	ctx   context.Context
+	buf   bytes.Buffer
+	limit int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.ctx.Err() != nil {
+		return 0, ErrTimeout
+	}
+
+	if w.buf.Len()+len(p) > w.limit {
+		return 0, ErrOutputTooLong
+	}
+
+	return w.buf.Write(p)
+}
+
+// SampleData returns data like the one templates of the kind are executed
+// with, to try them out.
+func SampleData(kind Kind) Data {
+	now := time.Date(2022, time.August, 1, 7, 0, 0, 0, time.UTC)
+	forecast := Forecast{
+		Time:                     now.Add(5 * time.Hour),
+		Condition:                "rain",
+		Description:              "light rain",
+		MinTemperature:           17,
+		MaxTemperature:           21.5,
+		PrecipitationProbability: 0.8,
+		WindSpeed:                4.2,
+		Humidity:                 70,
+	}
+
+	data := Data{
+		User:      User{ID: 1, Timezone: "Europe/Madrid"},
+		Location:  Location{Name: "Madrid", Latitude: 40.4168, Longitude: -3.7038},
+		Units:     DefaultUnits,
+		Forecasts: []Forecast{forecast},
+	}
+
+	if kind == KindBrief {
+		data.Brief = &Brief{
+			Day:         now,
+			High:        21.5,
+			Low:         15,
+			MaxWind:     4.2,
+			RainWindows: []TimeWindow{{From: forecast.Time, To: forecast.Time.Add(3 * time.Hour)}},
+			Highlights:  []string{"☂️ Don't forget your umbrella."},
+		}
+
+		return data
+	}
+
+	data.Alerts = []Alert{{
+		Rule:       "rain",
+		Text:       "Heads up, it's going to be raining today at 14:00!",
+		Standalone: true,
+		Today:      true,
+		Forecast:   forecast,
+	}}
+
+	return data
+}
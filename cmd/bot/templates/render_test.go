@@ -0,0 +1,151 @@
+package templates_test
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/manzanit0/weathry/cmd/bot/templates"
+)
+
+func TestExecute(t *testing.T) {
+	alert := templates.SampleData(templates.KindAlert)
+	alert.Alerts = []templates.Alert{
+		{Text: "it's going to be pretty hot today with a max of 33.00ºC! 🔥"},
+		{Text: "next Tue temperatures are going to decrease the way to 9.00ºC! ❄️ "},
+		{Text: "📏 Your rule \"pop > 0.5\" matches today at 12:00.", Standalone: true},
+	}
+
+	brief := templates.SampleData(templates.KindBrief)
+	noRain := templates.SampleData(templates.KindBrief)
+	noRain.Brief.RainWindows = nil
+	noRain.Brief.Highlights = nil
+
+	testCases := []struct {
+		desc     string
+		body     string
+		data     templates.Data
+		expected string
+	}{
+		{
+			desc:     "default alert joins the alerts",
+			body:     templates.DefaultAlert,
+			data:     alert,
+			expected: "Hi! Just letting you know that it's going to be pretty hot today with a max of 33.00ºC! 🔥\nAlso, on a separate note, next Tue temperatures are going to decrease the way to 9.00ºC! ❄️ \n📏 Your rule \"pop > 0.5\" matches today at 12:00.",
+		},
+		{
+			desc:     "default brief",
+			body:     templates.DefaultBrief,
+			data:     brief,
+			expected: "☀️ Hi! Here's your Monday in Madrid:\n\n🌡 High of 22ºC and low of 15ºC\n🌧 Rain from 14:00 to 17:00\n💨 Wind up to 4 m/s\n\n☂️ Don't forget your umbrella.",
+		},
+		{
+			desc:     "default brief without rain nor highlights",
+			body:     templates.DefaultBrief,
+			data:     noRain,
+			expected: "☀️ Hi! Here's your Monday in Madrid:\n\n🌡 High of 22ºC and low of 15ºC\n🌂 No rain expected\n💨 Wind up to 4 m/s",
+		},
+		{
+			desc:     "helpers",
+			body:     `{{range .Alerts}}{{emoji .Forecast.Condition}} {{localtime .Forecast.Time "Mon 15:04"}} {{round .Forecast.MaxTemperature}}{{$.Units.Temperature}}{{end}}`,
+			data:     templates.SampleData(templates.KindAlert),
+			expected: "🌧 Mon 14:00 22ºC",
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got, err := templates.Execute(context.Background(), tC.body, tC.data)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			if got != tC.expected {
+				t.Errorf("got %q, expected %q", got, tC.expected)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		kind  templates.Kind
+		body  string
+		valid bool
+		err   error
+	}{
+		{desc: "default alert", kind: templates.KindAlert, body: templates.DefaultAlert, valid: true},
+		{desc: "default brief", kind: templates.KindBrief, body: templates.DefaultBrief, valid: true},
+		{desc: "invalid syntax", kind: templates.KindAlert, body: "{{range .Alerts}}"},
+		{desc: "unknown field", kind: templates.KindAlert, body: "{{.Temperature}}"},
+		{desc: "brief fields in an alert", kind: templates.KindAlert, body: "{{.Brief.High}}"},
+		{desc: "unknown helper", kind: templates.KindAlert, body: `{{exec "rm"}}`},
+		{desc: "defining templates", kind: templates.KindAlert, body: `{{define "a"}}{{template "a"}}{{end}}x`},
+		{desc: "calling templates", kind: templates.KindAlert, body: `{{if true}}{{template "message" .}}{{end}}`},
+		{desc: "empty output", kind: templates.KindAlert, body: "{{if false}}x{{end}}  ", err: templates.ErrEmptyOutput},
+		{desc: "too long", kind: templates.KindAlert, body: strings.Repeat("a", templates.MaxBodyLength+1), err: templates.ErrBodyTooLong},
+		{desc: "too much output", kind: templates.KindBrief, body: `{{printf "%20000s" "x"}}`, err: templates.ErrOutputTooLong},
+		{desc: "ranging over a number", kind: templates.KindAlert, body: `{{range 2000000000}}{{end}}x`},
+		{desc: "ranging over a function", kind: templates.KindAlert, body: `{{range len .Alerts}}{{end}}x`},
+		{desc: "ranging over a pipeline", kind: templates.KindAlert, body: `{{range .Alerts | len}}{{end}}x`},
+		{desc: "ranging over a numeric field", kind: templates.KindAlert, body: `{{range .Alerts}}{{range .Forecast.Time.Unix}}{{end}}{{end}}x`},
+		{desc: "ranging over a variable", kind: templates.KindAlert, body: `{{range $a := .Alerts}}{{range $f := $.Forecasts}}{{$f.Condition}}{{end}}{{end}}`, valid: true},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			err := templates.Validate(tC.kind, tC.body)
+
+			switch {
+			case tC.valid && err != nil:
+				t.Errorf("unexpected error: %s", err.Error())
+			case !tC.valid && err == nil:
+				t.Errorf("expected an error")
+			case tC.err != nil && !errors.Is(err, tC.err):
+				t.Errorf("got %v, expected %v", err, tC.err)
+			}
+		})
+	}
+}
+
+func TestExecuteTimeout(t *testing.T) {
+	data := templates.SampleData(templates.KindAlert)
+	for i := 0; i < 10; i++ {
+		data.Forecasts = append(data.Forecasts, data.Forecasts...)
+	}
+
+	// Ranging over a thousand forecasts a thousand times, without rendering
+	// anything, takes way longer than a microsecond.
+	body := `{{range .Forecasts}}{{range $.Forecasts}}{{end}}{{end}}x`
+
+	before := runtime.NumGoroutine()
+	_, err := templates.Execute(context.Background(), body, data, templates.WithTimeout(time.Microsecond))
+	if !errors.Is(err, templates.ErrTimeout) {
+		t.Errorf("got %v, expected %v", err, templates.ErrTimeout)
+	}
+
+	// The template has to be stopped rather than left running in the
+	// background.
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines left running", after-before)
+	}
+}
+
+func TestExecuteTooManyIterations(t *testing.T) {
+	data := templates.SampleData(templates.KindAlert)
+	for i := 0; i < 10; i++ {
+		data.Forecasts = append(data.Forecasts, data.Forecasts...)
+	}
+
+	body := `{{range .Forecasts}}{{range $.Forecasts}}{{end}}{{end}}x`
+
+	_, err := templates.Execute(context.Background(), body, data, templates.WithTimeout(time.Minute))
+	if !errors.Is(err, templates.ErrTooManyIterations) {
+		t.Errorf("got %v, expected %v", err, templates.ErrTooManyIterations)
+	}
+}
@@ -0,0 +1,160 @@
+// Package templates lets users shape their own alert and brief messages with
+// text/template templates, which are validated and run sandboxed.
+package templates
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Kind is the message a template renders.
+type Kind string
+
+const (
+	KindAlert Kind = "alert"
+	KindBrief Kind = "brief"
+)
+
+// ParseKind returns the kind called name, or false if there's none.
+func ParseKind(name string) (Kind, bool) {
+	switch k := Kind(strings.ToLower(name)); k {
+	case KindAlert, KindBrief:
+		return k, true
+	default:
+		return "", false
+	}
+}
+
+// Global is the user ID of the templates which apply to everyone who hasn't
+// got one of their own.
+const Global = 0
+
+type Template struct {
+	// UserID is the user the template belongs to, or Global.
+	UserID    int
+	Kind      Kind
+	Body      string
+	UpdatedAt time.Time
+}
+
+type Repository interface {
+	// GetTemplate returns the user's template of the kind, falling back to
+	// the global one. It returns nil if there are neither.
+	GetTemplate(ctx context.Context, userID int, kind Kind) (*Template, error)
+
+	// SetTemplate creates or replaces the user's template of the kind, or the
+	// global one if userID is Global.
+	SetTemplate(ctx context.Context, userID int, kind Kind, body string) error
+
+	// DeleteTemplate deletes the user's template of the kind, or the global
+	// one if userID is Global. It returns false if there was none.
+	DeleteTemplate(ctx context.Context, userID int, kind Kind) (bool, error)
+}
+
+type dbTemplate struct {
+	UserID    *string   `db:"user_id"`
+	Kind      string    `db:"kind"`
+	Body      string    `db:"body"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+func (t dbTemplate) Map() *Template {
+	template := Template{UserID: Global, Kind: Kind(t.Kind), Body: t.Body, UpdatedAt: t.UpdatedAt}
+	if t.UserID != nil {
+		template.UserID, _ = strconv.Atoi(*t.UserID)
+	}
+
+	return &template
+}
+
+type pgRepo struct {
+	db *sqlx.DB
+}
+
+var _ Repository = (*pgRepo)(nil)
+
+func NewPgRepository(db *sql.DB) *pgRepo {
+	return &pgRepo{db: sqlx.NewDb(db, "postgres")}
+}
+
+// dbUserID is how userID is stored: NULL for global templates.
+func dbUserID(userID int) *string {
+	if userID == Global {
+		return nil
+	}
+
+	id := fmt.Sprint(userID)
+	return &id
+}
+
+func (r *pgRepo) GetTemplate(ctx context.Context, userID int, kind Kind) (*Template, error) {
+	var t dbTemplate
+
+	// The user's own template sorts first, since NULLs sort last.
+	query := `
+	SELECT user_id, kind, body, updated_at
+	FROM message_templates
+	WHERE kind = $1 AND (user_id = $2 OR user_id IS NULL)
+	ORDER BY user_id NULLS LAST
+	LIMIT 1;`
+
+	err := r.db.GetContext(ctx, &t, query, string(kind), fmt.Sprint(userID))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("select template: %w", err)
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	return t.Map(), nil
+}
+
+func (r *pgRepo) SetTemplate(ctx context.Context, userID int, kind Kind, body string) error {
+	query := `
+	INSERT INTO message_templates (user_id, kind, body)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (user_id, kind) WHERE user_id IS NOT NULL DO UPDATE SET body = $3;`
+
+	if userID == Global {
+		query = `
+		INSERT INTO message_templates (user_id, kind, body)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (kind) WHERE user_id IS NULL DO UPDATE SET body = $3;`
+	}
+
+	_, err := r.db.ExecContext(ctx, query, dbUserID(userID), string(kind), body)
+	if err != nil {
+		return fmt.Errorf("upsert template: %w", err)
+	}
+
+	return nil
+}
+
+func (r *pgRepo) DeleteTemplate(ctx context.Context, userID int, kind Kind) (bool, error) {
+	query := `DELETE FROM message_templates WHERE user_id = $1 AND kind = $2`
+	args := []any{fmt.Sprint(userID), string(kind)}
+	if userID == Global {
+		query = `DELETE FROM message_templates WHERE user_id IS NULL AND kind = $1`
+		args = []any{string(kind)}
+	}
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, fmt.Errorf("delete template: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("rows affected: %w", err)
+	}
+
+	return n > 0, nil
+}
@@ -5,20 +5,123 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/manzanit0/weathry/pkg/middleware"
 )
 
+// DefaultTimezone is the timezone of users who haven't set one.
+const DefaultTimezone = "UTC"
+
+// DefaultView is how daily forecasts are shown to users who haven't picked
+// how.
+const DefaultView = "table"
+
+type User struct {
+	ID       int
+	Timezone string
+
+	// DefaultView is how the user wants daily forecasts shown, such as
+	// "table" or "compact".
+	DefaultView string
+
+	// QuietHours is when the user doesn't want to get notifications. Nil if
+	// they haven't set any.
+	QuietHours *QuietHours
+
+	// MutedUntil is when the user wants to start getting notifications again.
+	MutedUntil *time.Time
+}
+
+// QuietHours is a window of the day, in minutes since midnight in the user's
+// timezone. It wraps around midnight when Start is after End, such as 22:00 to
+// 07:00.
+type QuietHours struct {
+	Start int
+	End   int
+}
+
+func (q QuietHours) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", q.Start/60, q.Start%60, q.End/60, q.End%60)
+}
+
+// Location returns the user's timezone, falling back to UTC if it's invalid.
+func (u *User) Location() *time.Location {
+	loc, err := time.LoadLocation(u.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// IsMuted reports whether the user has muted notifications at now.
+func (u *User) IsMuted(now time.Time) bool {
+	return u.MutedUntil != nil && now.Before(*u.MutedUntil)
+}
+
+// QuietUntil returns the end of the user's quiet hours if now falls within
+// them, or the zero time otherwise.
+func (u *User) QuietUntil(now time.Time) time.Time {
+	if u.QuietHours == nil || u.QuietHours.Start == u.QuietHours.End {
+		return time.Time{}
+	}
+
+	local := now.In(u.Location())
+	minutes := local.Hour()*60 + local.Minute()
+	start, end := u.QuietHours.Start, u.QuietHours.End
+
+	var quiet bool
+	if start < end {
+		quiet = minutes >= start && minutes < end
+	} else {
+		quiet = minutes >= start || minutes < end
+	}
+
+	if !quiet {
+		return time.Time{}
+	}
+
+	until := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, local.Location())
+	if !until.After(local) {
+		until = time.Date(local.Year(), local.Month(), local.Day()+1, end/60, end%60, 0, 0, local.Location())
+	}
+
+	return until
+}
+
+type Repository interface {
+	middleware.UsersClient
+
+	GetUser(ctx context.Context, userID int) (*User, error)
+	SetTimezone(ctx context.Context, userID int, timezone string) error
+	SetQuietHours(ctx context.Context, userID int, q *QuietHours) error
+	SetMutedUntil(ctx context.Context, userID int, until *time.Time) error
+	SetDefaultView(ctx context.Context, userID int, view string) error
+	Deactivate(ctx context.Context, userID int) error
+
+	// CountActive returns how many users haven't blocked the bot.
+	CountActive(ctx context.Context) (int, error)
+}
+
 type repository struct {
 	dbx *sqlx.DB
 }
 
+var _ Repository = (*repository)(nil)
+
 func NewDBClient(db *sql.DB) middleware.UsersClient {
 	dbx := sqlx.NewDb(db, "postgres")
 	return &repository{dbx: dbx}
 }
 
+func NewPgRepository(db *sql.DB) *repository {
+	dbx := sqlx.NewDb(db, "postgres")
+	return &repository{dbx: dbx}
+}
+
 func (c *repository) CreateUser(ctx context.Context, req middleware.CreateUserPayload) error {
 	var u dbUser
 	err := c.dbx.GetContext(ctx, &u, `SELECT * FROM users WHERE chat_id = $1`, req.ID)
@@ -26,7 +129,16 @@ func (c *repository) CreateUser(ctx context.Context, req middleware.CreateUserPa
 		return fmt.Errorf("find user: %w", err)
 	}
 
+	// Users who blocked the bot are reactivated as soon as they talk to it
+	// again.
 	if err == nil {
+		if !u.Active {
+			_, err = c.dbx.ExecContext(ctx, `UPDATE users SET active = TRUE WHERE chat_id = $1`, req.ID)
+			if err != nil {
+				return fmt.Errorf("reactivate user: %w", err)
+			}
+		}
+
 		return nil
 	}
 
@@ -38,6 +150,86 @@ func (c *repository) CreateUser(ctx context.Context, req middleware.CreateUserPa
 	return nil
 }
 
+// GetUser returns the user, or nil if it doesn't exist.
+func (c *repository) GetUser(ctx context.Context, userID int) (*User, error) {
+	var u dbUser
+	err := c.dbx.GetContext(ctx, &u, `SELECT * FROM users WHERE chat_id = $1`, fmt.Sprint(userID))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("find user: %w", err)
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	return u.Map(), nil
+}
+
+func (c *repository) SetTimezone(ctx context.Context, userID int, timezone string) error {
+	_, err := c.dbx.ExecContext(ctx, `UPDATE users SET timezone = $1 WHERE chat_id = $2`, timezone, fmt.Sprint(userID))
+	if err != nil {
+		return fmt.Errorf("update timezone: %w", err)
+	}
+
+	return nil
+}
+
+// SetQuietHours sets the user's quiet hours, or clears them if q is nil.
+func (c *repository) SetQuietHours(ctx context.Context, userID int, q *QuietHours) error {
+	var start, end *int
+	if q != nil {
+		start, end = &q.Start, &q.End
+	}
+
+	_, err := c.dbx.ExecContext(ctx, `UPDATE users SET quiet_hours_start = $1, quiet_hours_end = $2 WHERE chat_id = $3`, start, end, fmt.Sprint(userID))
+	if err != nil {
+		return fmt.Errorf("update quiet hours: %w", err)
+	}
+
+	return nil
+}
+
+// SetMutedUntil mutes the user's notifications until the given time, or
+// unmutes them if until is nil.
+func (c *repository) SetMutedUntil(ctx context.Context, userID int, until *time.Time) error {
+	_, err := c.dbx.ExecContext(ctx, `UPDATE users SET muted_until = $1 WHERE chat_id = $2`, until, fmt.Sprint(userID))
+	if err != nil {
+		return fmt.Errorf("update muted until: %w", err)
+	}
+
+	return nil
+}
+
+func (c *repository) SetDefaultView(ctx context.Context, userID int, view string) error {
+	_, err := c.dbx.ExecContext(ctx, `UPDATE users SET default_view = $1 WHERE chat_id = $2`, view, fmt.Sprint(userID))
+	if err != nil {
+		return fmt.Errorf("update default view: %w", err)
+	}
+
+	return nil
+}
+
+// Deactivate stops the user from getting any more notifications, for
+// instance because they blocked the bot.
+func (c *repository) Deactivate(ctx context.Context, userID int) error {
+	_, err := c.dbx.ExecContext(ctx, `UPDATE users SET active = FALSE WHERE chat_id = $1`, fmt.Sprint(userID))
+	if err != nil {
+		return fmt.Errorf("deactivate user: %w", err)
+	}
+
+	return nil
+}
+
+func (c *repository) CountActive(ctx context.Context) (int, error) {
+	var n int
+	err := c.dbx.GetContext(ctx, &n, `SELECT COUNT(*) FROM users WHERE active`)
+	if err != nil {
+		return 0, fmt.Errorf("count active users: %w", err)
+	}
+
+	return n, nil
+}
+
 type dbUser struct {
 	TelegramChatID string  `db:"chat_id"`
 	Username       *string `db:"username"`
@@ -45,4 +237,21 @@ type dbUser struct {
 	LastName       *string `db:"last_name"`
 	LanguageCode   string  `db:"language_code"`
 	IsBot          string  `db:"is_bot"`
+	Timezone       string  `db:"timezone"`
+	DefaultView    string  `db:"default_view"`
+
+	QuietHoursStart *int       `db:"quiet_hours_start"`
+	QuietHoursEnd   *int       `db:"quiet_hours_end"`
+	MutedUntil      *time.Time `db:"muted_until"`
+	Active          bool       `db:"active"`
+}
+
+func (u dbUser) Map() *User {
+	uid, _ := strconv.Atoi(u.TelegramChatID)
+	user := User{ID: uid, Timezone: u.Timezone, DefaultView: u.DefaultView, MutedUntil: u.MutedUntil}
+	if u.QuietHoursStart != nil && u.QuietHoursEnd != nil {
+		user.QuietHours = &QuietHours{Start: *u.QuietHoursStart, End: *u.QuietHoursEnd}
+	}
+
+	return &user
 }
@@ -0,0 +1,72 @@
+package users_test
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/manzanit0/weathry/cmd/bot/users"
+)
+
+func TestQuietUntil(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("load location: %s", err.Error())
+	}
+
+	overnight := &users.QuietHours{Start: 22 * 60, End: 7 * 60}
+	afternoon := &users.QuietHours{Start: 14 * 60, End: 16*60 + 30}
+
+	testCases := []struct {
+		desc string
+		user users.User
+		now  time.Time
+		want time.Time
+	}{
+		{
+			desc: "when the user has no quiet hours, it should return the zero time",
+			user: users.User{Timezone: "UTC"},
+			now:  time.Date(2022, time.August, 1, 23, 0, 0, 0, time.UTC),
+			want: time.Time{},
+		},
+		{
+			desc: "when it's before midnight in an overnight window, it should return the next morning",
+			user: users.User{Timezone: "UTC", QuietHours: overnight},
+			now:  time.Date(2022, time.August, 1, 23, 0, 0, 0, time.UTC),
+			want: time.Date(2022, time.August, 2, 7, 0, 0, 0, time.UTC),
+		},
+		{
+			desc: "when it's after midnight in an overnight window, it should return the same morning",
+			user: users.User{Timezone: "UTC", QuietHours: overnight},
+			now:  time.Date(2022, time.August, 2, 3, 0, 0, 0, time.UTC),
+			want: time.Date(2022, time.August, 2, 7, 0, 0, 0, time.UTC),
+		},
+		{
+			desc: "when it's outside of the window, it should return the zero time",
+			user: users.User{Timezone: "UTC", QuietHours: overnight},
+			now:  time.Date(2022, time.August, 2, 7, 0, 0, 0, time.UTC),
+			want: time.Time{},
+		},
+		{
+			desc: "when the window is within the day, it should return its end",
+			user: users.User{Timezone: "UTC", QuietHours: afternoon},
+			now:  time.Date(2022, time.August, 2, 15, 0, 0, 0, time.UTC),
+			want: time.Date(2022, time.August, 2, 16, 30, 0, 0, time.UTC),
+		},
+		{
+			desc: "when the user has a timezone, the window should be in it",
+			user: users.User{Timezone: "America/New_York", QuietHours: overnight},
+			now:  time.Date(2022, time.August, 1, 8, 0, 0, 0, time.UTC), // 04:00 in New York
+			want: time.Date(2022, time.August, 1, 7, 0, 0, 0, newYork),
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got := tC.user.QuietUntil(tC.now)
+			if !got.Equal(tC.want) {
+				t.Errorf("got %s, expected %s", got, tC.want)
+			}
+		})
+	}
+}
@@ -3,38 +3,91 @@ package main
 import (
 	"context"
 	"database/sql"
+	"flag"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
+	_ "time/tzdata"
 
 	_ "github.com/jackc/pgx/v4/stdlib"
 
+	"github.com/manzanit0/weathry/cmd/bot/briefings"
+	"github.com/manzanit0/weathry/cmd/bot/callbacks"
 	"github.com/manzanit0/weathry/cmd/bot/location"
+	"github.com/manzanit0/weathry/cmd/bot/notifications"
+	"github.com/manzanit0/weathry/cmd/bot/rules"
+	"github.com/manzanit0/weathry/cmd/bot/schedules"
+	"github.com/manzanit0/weathry/cmd/bot/templates"
+	"github.com/manzanit0/weathry/cmd/bot/users"
+	"github.com/manzanit0/weathry/cmd/pinger/outbox"
 	"github.com/manzanit0/weathry/cmd/pinger/pings"
+	"github.com/manzanit0/weathry/cmd/pinger/scheduler"
+	"github.com/manzanit0/weathry/cmd/pinger/snapshots"
+	"github.com/manzanit0/weathry/pkg/cron"
 	"github.com/manzanit0/weathry/pkg/env"
 	"github.com/manzanit0/weathry/pkg/geocode"
 	"github.com/manzanit0/weathry/pkg/logger"
 	"github.com/manzanit0/weathry/pkg/middleware"
 	"github.com/manzanit0/weathry/pkg/tgram"
+	"github.com/manzanit0/weathry/pkg/tracing"
 	"github.com/manzanit0/weathry/pkg/weather"
 	"github.com/manzanit0/weathry/pkg/whttp"
 )
 
 const ServiceName = "pinger"
 
+// DefaultSchedule is when the homes of users without their own schedule are
+// checked, in UTC.
+const DefaultSchedule = "0 8,19 * * *"
+
 func init() {
 	logger.InitGlobalSlog(ServiceName)
 }
 
+type options struct {
+	once bool
+
+	dryRun bool
+	userID int
+	at     string
+	format string
+
+	metricsAddr    string
+	metricsPushURL string
+}
+
 func main() {
+	var opts options
+	flag.BoolVar(&opts.once, "once", false, "check the weather of all homes once and exit, instead of running the scheduler")
+	flag.BoolVar(&opts.dryRun, "dry-run", false, "print the messages a run would send instead of sending them, without writing to the database")
+	flag.IntVar(&opts.userID, "user", 0, "only check the weather of the user with this chat id")
+	flag.StringVar(&opts.at, "at", "", "simulate a dry run at this time, such as 2022-08-01T19:00:00Z")
+	flag.StringVar(&opts.format, "format", formatTable, "output format of dry runs: table or json")
+	flag.StringVar(&opts.metricsAddr, "metrics-addr", os.Getenv("METRICS_ADDR"), "serve Prometheus metrics at this address, such as :9090")
+	flag.StringVar(&opts.metricsPushURL, "metrics-push-url", os.Getenv("METRICS_PUSH_URL"), "push Prometheus metrics to the Pushgateway at this URL, such as http://localhost:9091")
+	flag.Parse()
+
+	if err := opts.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid flags: %s\n", err.Error())
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Keep stdout for the preview of dry runs.
+	if opts.dryRun {
+		handler := logger.NewContextJSONHandler(os.Stderr, nil)
+		slog.SetDefault(slog.New(handler).With("service", ServiceName))
+	}
+
 	slog.Info("starting pinger")
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	if err := run(ctx); err != nil {
+	if err := run(ctx, opts); err != nil {
 		slog.Error("pinger shutdown abruptly", "error", err.Error())
 		os.Exit(1)
 	}
@@ -42,7 +95,21 @@ func main() {
 	slog.Info("pinger shutdown gracefully")
 }
 
-func run(ctx context.Context) error {
+func run(ctx context.Context, opts options) error {
+	shutdownTracing, err := tracing.Init(ServiceName)
+	if err != nil {
+		return fmt.Errorf("init tracing: %w", err)
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("export pending spans", "error", err.Error())
+		}
+	}()
+
 	db, err := sql.Open("pgx", os.Getenv("DATABASE_URL"))
 	if err != nil {
 		return fmt.Errorf("open db connection: %w", err)
@@ -73,6 +140,22 @@ func run(ctx context.Context) error {
 		return fmt.Errorf("create geocoder: %w", err)
 	}
 
+	repositories := pings.Repositories{
+		Locations:     locations,
+		Rules:         rules.NewPgRepository(db),
+		Notifications: notifications.NewPgRepository(db),
+		Snapshots:     snapshots.NewPgRepository(db),
+		Schedules:     schedules.NewPgRepository(db),
+		Briefings:     briefings.NewPgRepository(db),
+		Users:         users.NewPgRepository(db),
+		Outbox:        outbox.NewPgRepository(db),
+		Templates:     templates.NewPgRepository(db),
+	}
+
+	if opts.dryRun {
+		return runPreview(ctx, opts, owmClient, geocoder, repositories)
+	}
+
 	tgramClient, err := newTelegramClient()
 	if err != nil {
 		return fmt.Errorf("create telegram client: %w", err)
@@ -94,12 +177,90 @@ func run(ctx context.Context) error {
 		}
 	}()
 
-	pinger := pings.NewBackgroundPinger(owmClient, geocoder, tgramClient, locations)
-	if err := pinger.MonitorWeather(ctx); err != nil {
-		return fmt.Errorf("monitor weather: %w", err)
+	callbackSecret, err := env.CallbackSecret()
+	if err != nil {
+		return fmt.Errorf("get callback secret: %w", err)
+	}
+
+	callbackStore := callbacks.NewPgRepository(db)
+	codec := tgram.NewCallbackCodec(callbackSecret, callbackStore)
+	pinger := pings.NewBackgroundPinger(owmClient, geocoder, tgramClient, codec, repositories, pings.WithOnlyUser(opts.userID))
+
+	if opts.metricsAddr != "" {
+		go serveMetrics(ctx, opts.metricsAddr)
+	}
+
+	if opts.once {
+		ctx, span := tracing.Start(ctx, "job ping-homes")
+		defer span.End()
+
+		if err := pinger.MonitorWeather(ctx); err != nil {
+			span.RecordError(err)
+			return fmt.Errorf("monitor weather: %w", err)
+		}
+
+		if opts.metricsPushURL != "" {
+			return pushMetrics(opts.metricsPushURL)(ctx)
+		}
+
+		return nil
+	}
+
+	schedule, err := cron.Parse(defaultSchedule())
+	if err != nil {
+		return fmt.Errorf("parse PINGER_SCHEDULE: %w", err)
+	}
+
+	jobs := []*scheduler.Job{
+		{Name: "ping-homes", Schedule: schedule, Jitter: time.Minute, Run: pinger.MonitorWeather},
+		{Name: "ping-scheduled-users", Schedule: cron.MustParse("* * * * *"), Run: pinger.PingScheduledUsers},
+		{Name: "send-briefings", Schedule: cron.MustParse("* * * * *"), Run: pinger.SendBriefings},
+		{Name: "dispatch-outbox", Schedule: cron.MustParse("* * * * *"), Run: pinger.DispatchOutbox},
+		{Name: "purge-callback-payloads", Schedule: cron.MustParse("30 3 * * *"), Run: purgeCallbackPayloads(callbackStore.DeleteExpired)},
+	}
+
+	if opts.metricsPushURL != "" {
+		jobs = append(jobs, &scheduler.Job{Name: "push-metrics", Schedule: cron.MustParse("* * * * *"), Run: pushMetrics(opts.metricsPushURL)})
+	}
+
+	elector := scheduler.NewPgElector(db, scheduler.PingerLockKey)
+
+	// Wait for the elector to release the lock before closing the database.
+	campaignDone := make(chan struct{})
+	defer func() { <-campaignDone }()
+
+	go func() {
+		defer close(campaignDone)
+		elector.Campaign(ctx)
+	}()
+
+	s := scheduler.New(elector, jobs, scheduler.WithPanicHandler(func(ctx context.Context, r any) {
+		middleware.HandleRecover(ctx, r, errorTgramClient, myTelegramChatID)
+	}))
+
+	return s.Run(ctx)
+}
+
+// purgeCallbackPayloads deletes the payloads of buttons which have expired, so
+// the table doesn't grow with every message sent.
+func purgeCallbackPayloads(deleteExpired func(context.Context) (int64, error)) func(context.Context) error {
+	return func(ctx context.Context) error {
+		n, err := deleteExpired(ctx)
+		if err != nil {
+			return err
+		}
+
+		slog.InfoContext(ctx, "purged expired callback payloads", "count", n)
+		return nil
+	}
+}
+
+func defaultSchedule() string {
+	if s := os.Getenv("PINGER_SCHEDULE"); s != "" {
+		return s
 	}
 
-	return nil
+	return DefaultSchedule
 }
 
 func newWeatherClient() (weather.Client, error) {
@@ -108,12 +269,12 @@ func newWeatherClient() (weather.Client, error) {
 		return nil, fmt.Errorf("missing OPENWEATHERMAP_API_KEY environment variable. Please check your environment.")
 	}
 
-	httpClient := whttp.NewLoggingClient()
-	return weather.NewOpenWeatherMapClient(httpClient, openWeatherMapAPIKey), nil
+	httpClient := whttp.NewClient(whttp.OpenWeatherMap)
+	return weather.NewCachedClient(weather.NewOpenWeatherMapClient(httpClient, openWeatherMapAPIKey), weather.DefaultCacheTTL), nil
 }
 
 func newGeocoder() (geocode.Client, error) {
-	return geocode.NewOpenstreetmapClient(), nil
+	return geocode.NewCachedClient(geocode.NewOpenstreetmapClient(whttp.NewClient(whttp.Nominatim)), geocode.DefaultCacheTTL), nil
 }
 
 func newTelegramClient() (tgram.Client, error) {
@@ -122,6 +283,6 @@ func newTelegramClient() (tgram.Client, error) {
 		return nil, fmt.Errorf("missing TELEGRAM_BOT_TOKEN environment variable. Please check your environment.")
 	}
 
-	httpClient := whttp.NewLoggingClient()
+	httpClient := whttp.NewClient(whttp.Telegram)
 	return tgram.NewClient(httpClient, telegramBotToken), nil
 }
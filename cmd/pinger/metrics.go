@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/metrics"
+)
+
+// serveMetrics serves the metrics at addr until ctx is done, for Prometheus to
+// scrape them.
+func serveMetrics(ctx context.Context, addr string) {
+	slog.Info("serving metrics", "addr", addr)
+	if err := metrics.Default.Serve(ctx, addr); err != nil {
+		slog.Error("serve metrics", "error", err.Error())
+	}
+}
+
+// pushMetrics returns a job which pushes the metrics to the Pushgateway at
+// url, for runs too short to be scraped, such as with -once.
+func pushMetrics(url string) func(context.Context) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return func(ctx context.Context) error {
+		if err := metrics.Default.Push(ctx, client, url, ServiceName); err != nil {
+			return fmt.Errorf("push metrics: %w", err)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,334 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+const (
+	// MaxAttempts is how many times a message is tried before giving up.
+	MaxAttempts = 8
+
+	// BaseBackoff is how long to wait before the first retry. It doubles with
+	// every attempt, up to MaxBackoff.
+	BaseBackoff = 30 * time.Second
+	MaxBackoff  = time.Hour
+)
+
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusSent    Status = "sent"
+	StatusFailed  Status = "failed"
+)
+
+// Message is a message waiting to be delivered to a user, or which was.
+type Message struct {
+	ID          int64
+	UserID      int
+	Text        string
+	ReplyMarkup *tgram.ReplyMarkup
+
+	// Rules are those of the alerts in the message, such as "rain" or
+	// "rule:12", so deliveries can be counted by rule.
+	Rules []string
+
+	// PartsSent is how many parts of the message were sent, when it's too
+	// long for a single one and only some went through.
+	PartsSent int
+
+	Status        Status
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	SentAt        *time.Time
+	CreatedAt     time.Time
+}
+
+// NewMessage returns a pending message for the request. It's delivered as soon
+// as possible, unless deliverAt is set.
+func NewMessage(req tgram.SendMessageRequest, deliverAt time.Time) *Message {
+	return &Message{
+		UserID:        int(req.ChatID),
+		Text:          req.Text,
+		ReplyMarkup:   req.ReplyMarkup,
+		Status:        StatusPending,
+		NextAttemptAt: deliverAt,
+	}
+}
+
+// Request returns the request to send the message to the user.
+func (m *Message) Request() tgram.SendMessageRequest {
+	return tgram.SendMessageRequest{ChatID: int64(m.UserID), Text: m.Text, ReplyMarkup: m.ReplyMarkup}
+}
+
+// Backoff returns how long to wait before retrying a message which has been
+// tried the given number of times.
+func Backoff(attempts int) time.Duration {
+	d := BaseBackoff
+	for i := 1; i < attempts && d < MaxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > MaxBackoff {
+		return MaxBackoff
+	}
+
+	return d
+}
+
+type dbMessage struct {
+	ID            int64      `db:"id"`
+	UserID        string     `db:"user_id"`
+	Text          string     `db:"text"`
+	ReplyMarkup   []byte     `db:"reply_markup"`
+	Rules         []byte     `db:"rules"`
+	PartsSent     int        `db:"parts_sent"`
+	Status        string     `db:"status"`
+	Attempts      int        `db:"attempts"`
+	NextAttemptAt time.Time  `db:"next_attempt_at"`
+	LastError     *string    `db:"last_error"`
+	SentAt        *time.Time `db:"sent_at"`
+	CreatedAt     time.Time  `db:"created_at"`
+}
+
+type Repository interface {
+	Enqueue(ctx context.Context, m *Message) error
+	ListDue(ctx context.Context, now time.Time, limit int) ([]*Message, error)
+	MarkSent(ctx context.Context, id int64, at time.Time) error
+	MarkRetry(ctx context.Context, id int64, next time.Time, cause error) error
+
+	// MarkPartsSent records how many parts of the message were sent, so
+	// retries don't send them again.
+	MarkPartsSent(ctx context.Context, id int64, n int) error
+	MarkFailed(ctx context.Context, id int64, cause error) error
+	DropPending(ctx context.Context, userID int, reason string) (int64, error)
+	ListMessages(ctx context.Context, userID int, limit int) ([]*Message, error)
+	CountByStatus(ctx context.Context) (map[Status]int, error)
+}
+
+type pgRepo struct {
+	db *sqlx.DB
+}
+
+var _ Repository = (*pgRepo)(nil)
+
+func NewPgRepository(db *sql.DB) *pgRepo {
+	return &pgRepo{db: sqlx.NewDb(db, "postgres")}
+}
+
+func (r *pgRepo) Enqueue(ctx context.Context, m *Message) error {
+	var markup []byte
+	if m.ReplyMarkup != nil {
+		var err error
+		markup, err = json.Marshal(m.ReplyMarkup)
+		if err != nil {
+			return fmt.Errorf("marshal reply markup: %w", err)
+		}
+	}
+
+	var rules []byte
+	if len(m.Rules) > 0 {
+		var err error
+		rules, err = json.Marshal(m.Rules)
+		if err != nil {
+			return fmt.Errorf("marshal rules: %w", err)
+		}
+	}
+
+	// Messages to be delivered as soon as possible are due from the moment
+	// they're inserted, as the database tells it.
+	var nextAttemptAt *time.Time
+	if !m.NextAttemptAt.IsZero() {
+		nextAttemptAt = &m.NextAttemptAt
+	}
+
+	query := `
+	INSERT INTO outbox (user_id, text, reply_markup, rules, next_attempt_at)
+	VALUES ($1, $2, $3, $4, COALESCE($5::timestamptz, now()))
+	RETURNING id, next_attempt_at, created_at;`
+
+	var row struct {
+		ID            int64     `db:"id"`
+		NextAttemptAt time.Time `db:"next_attempt_at"`
+		CreatedAt     time.Time `db:"created_at"`
+	}
+
+	err := r.db.GetContext(ctx, &row, query, fmt.Sprint(m.UserID), m.Text, markup, rules, nextAttemptAt)
+	if err != nil {
+		return fmt.Errorf("insert outbox message: %w", err)
+	}
+
+	m.ID = row.ID
+	m.Status = StatusPending
+	m.NextAttemptAt = row.NextAttemptAt
+	m.CreatedAt = row.CreatedAt
+	return nil
+}
+
+// ListDue returns the pending messages which are due by now, oldest first.
+func (r *pgRepo) ListDue(ctx context.Context, now time.Time, limit int) ([]*Message, error) {
+	query := `
+	SELECT id, user_id, text, reply_markup, rules, parts_sent, status, attempts, next_attempt_at, last_error, sent_at, created_at
+	FROM outbox
+	WHERE status = 'pending' AND next_attempt_at <= $1
+	ORDER BY next_attempt_at, id
+	LIMIT $2;`
+
+	return r.selectMessages(ctx, query, now, limit)
+}
+
+func (r *pgRepo) MarkSent(ctx context.Context, id int64, at time.Time) error {
+	query := `UPDATE outbox SET status = 'sent', attempts = attempts + 1, sent_at = $1 WHERE id = $2`
+	_, err := r.db.ExecContext(ctx, query, at, id)
+	if err != nil {
+		return fmt.Errorf("update outbox message: %w", err)
+	}
+
+	return nil
+}
+
+func (r *pgRepo) MarkRetry(ctx context.Context, id int64, next time.Time, cause error) error {
+	query := `UPDATE outbox SET attempts = attempts + 1, next_attempt_at = $1, last_error = $2 WHERE id = $3`
+	_, err := r.db.ExecContext(ctx, query, next, cause.Error(), id)
+	if err != nil {
+		return fmt.Errorf("update outbox message: %w", err)
+	}
+
+	return nil
+}
+
+func (r *pgRepo) MarkPartsSent(ctx context.Context, id int64, n int) error {
+	query := `UPDATE outbox SET parts_sent = $1 WHERE id = $2`
+	_, err := r.db.ExecContext(ctx, query, n, id)
+	if err != nil {
+		return fmt.Errorf("update outbox message: %w", err)
+	}
+
+	return nil
+}
+
+func (r *pgRepo) MarkFailed(ctx context.Context, id int64, cause error) error {
+	query := `UPDATE outbox SET status = 'failed', attempts = attempts + 1, last_error = $1 WHERE id = $2`
+	_, err := r.db.ExecContext(ctx, query, cause.Error(), id)
+	if err != nil {
+		return fmt.Errorf("update outbox message: %w", err)
+	}
+
+	return nil
+}
+
+// DropPending fails all the pending messages of the user without trying them,
+// for instance once they've blocked the bot.
+func (r *pgRepo) DropPending(ctx context.Context, userID int, reason string) (int64, error) {
+	query := `UPDATE outbox SET status = 'failed', last_error = $1 WHERE user_id = $2 AND status = 'pending'`
+	res, err := r.db.ExecContext(ctx, query, reason, fmt.Sprint(userID))
+	if err != nil {
+		return 0, fmt.Errorf("update outbox messages: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("rows affected: %w", err)
+	}
+
+	return n, nil
+}
+
+// ListMessages returns the latest messages for the user, newest first.
+func (r *pgRepo) ListMessages(ctx context.Context, userID int, limit int) ([]*Message, error) {
+	query := `
+	SELECT id, user_id, text, reply_markup, rules, parts_sent, status, attempts, next_attempt_at, last_error, sent_at, created_at
+	FROM outbox
+	WHERE user_id = $1
+	ORDER BY created_at DESC
+	LIMIT $2;`
+
+	return r.selectMessages(ctx, query, fmt.Sprint(userID), limit)
+}
+
+func (r *pgRepo) CountByStatus(ctx context.Context) (map[Status]int, error) {
+	var rows []struct {
+		Status string `db:"status"`
+		Count  int    `db:"count"`
+	}
+
+	err := r.db.SelectContext(ctx, &rows, `SELECT status, count(*) AS count FROM outbox GROUP BY status`)
+	if err != nil {
+		return nil, fmt.Errorf("count outbox messages: %w", err)
+	}
+
+	counts := make(map[Status]int, len(rows))
+	for _, row := range rows {
+		counts[Status(row.Status)] = row.Count
+	}
+
+	return counts, nil
+}
+
+func (r *pgRepo) selectMessages(ctx context.Context, query string, args ...any) ([]*Message, error) {
+	var mm []dbMessage
+	err := r.db.SelectContext(ctx, &mm, query, args...)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("select outbox messages: %w", err)
+	}
+
+	mmx := make([]*Message, 0, len(mm))
+	for i := range mm {
+		m, err := mm[i].Map()
+		if err != nil {
+			return nil, err
+		}
+
+		mmx = append(mmx, m)
+	}
+
+	return mmx, nil
+}
+
+func (m dbMessage) Map() (*Message, error) {
+	var markup *tgram.ReplyMarkup
+	if len(m.ReplyMarkup) > 0 {
+		markup = &tgram.ReplyMarkup{}
+		if err := json.Unmarshal(m.ReplyMarkup, markup); err != nil {
+			return nil, fmt.Errorf("unmarshal reply markup: %w", err)
+		}
+	}
+
+	var rules []string
+	if len(m.Rules) > 0 {
+		if err := json.Unmarshal(m.Rules, &rules); err != nil {
+			return nil, fmt.Errorf("unmarshal rules: %w", err)
+		}
+	}
+
+	var lastError string
+	if m.LastError != nil {
+		lastError = *m.LastError
+	}
+
+	uid, _ := strconv.Atoi(m.UserID)
+	return &Message{
+		ID:            m.ID,
+		UserID:        uid,
+		Text:          m.Text,
+		ReplyMarkup:   markup,
+		Rules:         rules,
+		PartsSent:     m.PartsSent,
+		Status:        Status(m.Status),
+		Attempts:      m.Attempts,
+		NextAttemptAt: m.NextAttemptAt,
+		LastError:     lastError,
+		SentAt:        m.SentAt,
+		CreatedAt:     m.CreatedAt,
+	}, nil
+}
@@ -0,0 +1,30 @@
+package outbox_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/manzanit0/weathry/cmd/pinger/outbox"
+)
+
+func TestBackoff(t *testing.T) {
+	testCases := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: 30 * time.Second},
+		{attempts: 1, want: 30 * time.Second},
+		{attempts: 2, want: time.Minute},
+		{attempts: 3, want: 2 * time.Minute},
+		{attempts: 7, want: 32 * time.Minute},
+		{attempts: 8, want: time.Hour},
+		{attempts: 100, want: time.Hour},
+	}
+
+	for _, tC := range testCases {
+		got := outbox.Backoff(tC.attempts)
+		if got != tC.want {
+			t.Errorf("got %s after %d attempts, expected %s", got, tC.attempts, tC.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package pings
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/manzanit0/weathry/cmd/bot/notifications"
+	"github.com/manzanit0/weathry/cmd/bot/rules"
+	"github.com/manzanit0/weathry/cmd/bot/templates"
+	"github.com/manzanit0/weathry/pkg/weather"
+)
+
+// alert is a single reason to notify a user about, such as rain or a user rule
+// matching.
+type alert struct {
+	rule        string
+	forecast    *weather.Forecast
+	temperature float64
+
+	// text is the alert, written so it can be appended to other alerts.
+	text string
+
+	// standalone alerts are full sentences. The rest are written to follow
+	// "Hi! Just letting you know that...".
+	standalone bool
+}
+
+func (a *alert) notification(userID int) *notifications.Notification {
+	target := time.Unix(int64(a.forecast.DateTimeTS), 0)
+	return notifications.New(userID, a.rule, target, a.temperature, a.text)
+}
+
+// findAlerts checks the forecasts for the built-in alerts: rain, high and low
+// temperatures.
+func findAlerts(forecasts []*weather.Forecast, now time.Time) []*alert {
+	var alerts []*alert
+
+	rainyForecast := FindNextRainyDay(forecasts, now)
+	if rainyForecast != nil {
+		a := alert{rule: notifications.RuleRain, forecast: rainyForecast, temperature: rainyForecast.MaximumTemperature, standalone: true}
+		if isToday(rainyForecast.DateTimeTS, now) {
+			a.text = fmt.Sprintf("Heads up, it's going to be raining today at %s!", rainyForecast.FormattedTime(now.Location()))
+		} else {
+			a.text = fmt.Sprintf("Hey 👋! I'm expecting rain next %s at around %s.",
+				rainyForecast.FormattedDate(now.Location()),
+				rainyForecast.FormattedTime(now.Location()))
+		}
+
+		alerts = append(alerts, &a)
+	}
+
+	highTempForecast := FindNextHighTemperature(forecasts)
+	if highTempForecast != nil {
+		a := alert{rule: notifications.RuleHighTemperature, forecast: highTempForecast, temperature: highTempForecast.MaximumTemperature}
+		if isToday(highTempForecast.DateTimeTS, now) {
+			a.text = fmt.Sprintf("it's going to be pretty hot today with a max of %.2fºC! 🔥",
+				highTempForecast.MaximumTemperature)
+		} else {
+			a.text = fmt.Sprintf("next %s temperatures are going to rise all the way to %.2fºC! 🔥",
+				highTempForecast.FormattedDateTime(now.Location()),
+				highTempForecast.MaximumTemperature)
+		}
+
+		alerts = append(alerts, &a)
+	}
+
+	lowTempForecast := FindNextLowTemperature(forecasts)
+	if lowTempForecast != nil {
+		a := alert{rule: notifications.RuleLowTemperature, forecast: lowTempForecast, temperature: lowTempForecast.MinimumTemperature}
+		if isToday(lowTempForecast.DateTimeTS, now) {
+			a.text = fmt.Sprintf("it's going to be pretty cold today with a min of %.2fºC! ❄️ ",
+				lowTempForecast.MinimumTemperature)
+		} else {
+			a.text = fmt.Sprintf("next %s temperatures are going to decrease the way to %.2fºC! ❄️ ",
+				lowTempForecast.FormattedDateTime(now.Location()),
+				lowTempForecast.MinimumTemperature)
+		}
+
+		alerts = append(alerts, &a)
+	}
+
+	return alerts
+}
+
+func newRuleAlert(r *rules.Rule, f *weather.Forecast, now time.Time) *alert {
+	a := alert{
+		rule:        notifications.UserRule(r.ID),
+		forecast:    f,
+		temperature: (f.MinimumTemperature + f.MaximumTemperature) / 2,
+		standalone:  true,
+	}
+
+	if isToday(f.DateTimeTS, now) {
+		a.text = fmt.Sprintf("📏 Your rule \"%s\" matches today at %s.", r.Expression, f.FormattedTime(now.Location()))
+	} else {
+		a.text = fmt.Sprintf("📏 Your rule \"%s\" matches next %s at %s.", r.Expression, f.FormattedDate(now.Location()), f.FormattedTime(now.Location()))
+	}
+
+	return &a
+}
+
+func newChangeAlert(c *Change) *alert {
+	return &alert{
+		rule:        notifications.ForecastChange(string(c.Kind)),
+		forecast:    c.Forecast,
+		temperature: c.Forecast.MaximumTemperature,
+		text:        c.Text,
+		standalone:  true,
+	}
+}
+
+// templateAlerts returns the alerts as templates get them.
+func templateAlerts(alerts []*alert, now time.Time) []templates.Alert {
+	ta := make([]templates.Alert, len(alerts))
+	for i, a := range alerts {
+		ta[i] = templates.Alert{
+			Rule:       a.rule,
+			Text:       a.text,
+			Standalone: a.standalone,
+			Today:      isToday(a.forecast.DateTimeTS, now),
+			Forecast:   templates.NewForecast(a.forecast),
+		}
+	}
+
+	return ta
+}
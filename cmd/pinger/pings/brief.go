@@ -0,0 +1,79 @@
+package pings
+
+import (
+	"math"
+	"time"
+
+	"github.com/manzanit0/weathry/cmd/bot/templates"
+	"github.com/manzanit0/weathry/pkg/weather"
+)
+
+// Thresholds for the clothing highlights of briefs.
+const (
+	BriefHotTemperature  = 28.0
+	BriefColdTemperature = 10.0
+	BriefWindySpeed      = 10.0
+)
+
+// forecastWindow is how long each hourly forecast covers.
+const forecastWindow = 3 * time.Hour
+
+// Brief is a summary of the weather for the rest of the day.
+type Brief = templates.Brief
+
+type TimeWindow = templates.TimeWindow
+
+// BuildBrief summarises the forecasts of the day now is in, in now's location.
+// It returns nil if there are no forecasts for the rest of the day.
+func BuildBrief(forecasts []*weather.Forecast, now time.Time) *Brief {
+	today := startOfDay(now)
+	tomorrow := today.AddDate(0, 0, 1)
+
+	b := Brief{Day: today, High: math.Inf(-1), Low: math.Inf(1)}
+
+	var found bool
+	var rain *TimeWindow
+	for _, f := range forecasts {
+		t := time.Unix(int64(f.DateTimeTS), 0).In(now.Location())
+		if t.Before(today) || !t.Before(tomorrow) {
+			continue
+		}
+
+		found = true
+		b.High = math.Max(b.High, f.MaximumTemperature)
+		b.Low = math.Min(b.Low, f.MinimumTemperature)
+		b.MaxWind = math.Max(b.MaxWind, f.WindSpeed)
+
+		switch {
+		case f.IsRainy() && rain != nil && !t.After(rain.To):
+			rain.To = t.Add(forecastWindow)
+		case f.IsRainy():
+			b.RainWindows = append(b.RainWindows, TimeWindow{From: t, To: t.Add(forecastWindow)})
+			rain = &b.RainWindows[len(b.RainWindows)-1]
+		default:
+			rain = nil
+		}
+	}
+
+	if !found {
+		return nil
+	}
+
+	if b.High >= BriefHotTemperature {
+		b.Highlights = append(b.Highlights, "👕 Light clothes and sunscreen, it's going to be hot.")
+	}
+
+	if b.Low < BriefColdTemperature {
+		b.Highlights = append(b.Highlights, "🧥 Wrap up, it's going to be chilly.")
+	}
+
+	if len(b.RainWindows) > 0 {
+		b.Highlights = append(b.Highlights, "☂️ Don't forget your umbrella.")
+	}
+
+	if b.MaxWind >= BriefWindySpeed {
+		b.Highlights = append(b.Highlights, "🧣 A windbreaker will come in handy.")
+	}
+
+	return &b
+}
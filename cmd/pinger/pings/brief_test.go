@@ -0,0 +1,66 @@
+package pings_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/manzanit0/weathry/cmd/pinger/pings"
+	"github.com/manzanit0/weathry/pkg/weather"
+)
+
+func TestBuildBrief(t *testing.T) {
+	now := time.Date(2022, time.August, 1, 7, 0, 0, 0, time.UTC)
+	at := func(hour int) int {
+		return int(time.Date(2022, time.August, 1, hour, 0, 0, 0, time.UTC).Unix())
+	}
+
+	forecasts := []*weather.Forecast{
+		{DateTimeTS: at(9), Condition: "clear", MinimumTemperature: 8, MaximumTemperature: 15, WindSpeed: 3},
+		{DateTimeTS: at(12), Condition: "rain", MinimumTemperature: 14, MaximumTemperature: 20, WindSpeed: 12},
+		{DateTimeTS: at(15), Condition: "storm", MinimumTemperature: 15, MaximumTemperature: 22, WindSpeed: 6},
+		{DateTimeTS: at(18), Condition: "clouds", MinimumTemperature: 13, MaximumTemperature: 17, WindSpeed: 4},
+		{DateTimeTS: at(21), Condition: "rain", MinimumTemperature: 11, MaximumTemperature: 12, WindSpeed: 2},
+		// Tomorrow's forecasts shouldn't be part of today's brief.
+		{DateTimeTS: at(24), Condition: "clear", MinimumTemperature: 2, MaximumTemperature: 35, WindSpeed: 20},
+	}
+
+	brief := pings.BuildBrief(forecasts, now)
+	if brief == nil {
+		t.Fatalf("got nil brief")
+	}
+
+	if brief.High != 22 || brief.Low != 8 || brief.MaxWind != 12 {
+		t.Errorf("got high %.0f, low %.0f and wind %.0f, expected 22, 8 and 12", brief.High, brief.Low, brief.MaxWind)
+	}
+
+	expectedWindows := []pings.TimeWindow{
+		{From: time.Unix(int64(at(12)), 0), To: time.Unix(int64(at(18)), 0)},
+		{From: time.Unix(int64(at(21)), 0), To: time.Unix(int64(at(24)), 0)},
+	}
+
+	if len(brief.RainWindows) != len(expectedWindows) {
+		t.Fatalf("got %d rain windows, expected %d", len(brief.RainWindows), len(expectedWindows))
+	}
+
+	for i, w := range brief.RainWindows {
+		if !w.From.Equal(expectedWindows[i].From) || !w.To.Equal(expectedWindows[i].To) {
+			t.Errorf("got rain window %s-%s, expected %s-%s", w.From, w.To, expectedWindows[i].From, expectedWindows[i].To)
+		}
+	}
+
+	// Chilly, rainy and windy, but not hot.
+	if len(brief.Highlights) != 3 {
+		t.Errorf("got highlights %q, expected 3", brief.Highlights)
+	}
+}
+
+func TestBuildBriefWithoutForecastsForToday(t *testing.T) {
+	now := time.Date(2022, time.August, 1, 23, 0, 0, 0, time.UTC)
+	forecasts := []*weather.Forecast{
+		{DateTimeTS: int(now.Add(3 * time.Hour).Unix()), Condition: "clear"},
+	}
+
+	if brief := pings.BuildBrief(forecasts, now); brief != nil {
+		t.Errorf("got %+v, expected nil", brief)
+	}
+}
@@ -0,0 +1,108 @@
+package pings
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/manzanit0/weathry/cmd/bot/briefings"
+	"github.com/manzanit0/weathry/cmd/bot/msg"
+	"github.com/manzanit0/weathry/cmd/bot/templates"
+	"github.com/manzanit0/weathry/cmd/pinger/outbox"
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+// SendBriefings sends the daily brief to the users whose brief is due.
+func (p *backgroundPinger) SendBriefings(ctx context.Context) error {
+	subscribed, err := p.briefings.ListActive(ctx)
+	if err != nil {
+		return fmt.Errorf("list briefings: %w", err)
+	}
+
+	now := p.clock.Now()
+
+	var summary Summary
+	for _, b := range subscribed {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if p.includes(b.UserID) && b.Due(now) {
+			summary.add(p.sendBriefing(ctx, b, now))
+		}
+	}
+
+	if summary.Processed == 0 {
+		return ctx.Err()
+	}
+
+	slog.Info("queued briefings", "summary", summary)
+	return p.DispatchOutbox(ctx)
+}
+
+func (p *backgroundPinger) sendBriefing(ctx context.Context, b *briefings.Briefing, now time.Time) homeOutcome {
+	logger := slog.Default().With("ctx.user_id", b.UserID)
+
+	home, err := p.locations.GetHome(ctx, b.UserID)
+	if err != nil {
+		logger.Error("error getting home", "error", err.Error())
+		return outcomeFailed
+	}
+
+	if home == nil {
+		p.markBriefingSent(ctx, logger, b, now)
+		return outcomeSkipped
+	}
+
+	forecasts, err := p.forecaster.GetHourlyForecast(ctx, home.Latitude, home.Longitude)
+	if err != nil {
+		logger.Error("error requesting upcoming weather", "error", err.Error())
+		return outcomeFailed
+	}
+
+	brief := BuildBrief(forecasts, now.In(b.Location()))
+	if brief == nil {
+		p.markBriefingSent(ctx, logger, b, now)
+		return outcomeSkipped
+	}
+
+	text, err := p.renderMessage(ctx, logger, templates.KindBrief, templates.Data{
+		User:      templates.User{ID: b.UserID, Timezone: b.Location().String()},
+		Location:  templates.Location{Name: home.Name, Latitude: home.Latitude, Longitude: home.Longitude},
+		Units:     templates.DefaultUnits,
+		Forecasts: templates.NewForecasts(forecasts),
+		Brief:     brief,
+	})
+	if err != nil {
+		logger.Error("error rendering brief", "error", err.Error())
+		return outcomeFailed
+	}
+
+	keyboard, err := msg.NewBriefKeyboard(ctx, p.codec, false)
+	if err != nil {
+		logger.Error("error creating brief buttons", "error", err.Error())
+		return outcomeFailed
+	}
+
+	req := tgram.SendMessageRequest{
+		ChatID:      int64(b.UserID),
+		Text:        text,
+		ReplyMarkup: keyboard,
+	}
+
+	err = p.outbox.Enqueue(ctx, outbox.NewMessage(req, time.Time{}))
+	if err != nil {
+		logger.Error("failed to queue brief", "error", err.Error())
+		return outcomeFailed
+	}
+
+	p.markBriefingSent(ctx, logger, b, now)
+	return outcomeQueued
+}
+
+func (p *backgroundPinger) markBriefingSent(ctx context.Context, logger *slog.Logger, b *briefings.Briefing, now time.Time) {
+	if err := p.briefings.MarkSent(ctx, b.UserID, now); err != nil {
+		logger.Error("error marking brief as sent", "error", err.Error())
+	}
+}
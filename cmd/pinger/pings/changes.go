@@ -0,0 +1,166 @@
+package pings
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/manzanit0/weathry/cmd/pinger/snapshots"
+	"github.com/manzanit0/weathry/pkg/weather"
+)
+
+// ChangeTemperatureThreshold is how many degrees, in ºC, the maximum
+// temperature of a day has to change between snapshots to be notified.
+const ChangeTemperatureThreshold = 5.0
+
+type ChangeKind string
+
+const (
+	ChangeRainCancelled ChangeKind = "rain_cancelled"
+	ChangeWarmer        ChangeKind = "warmer"
+	ChangeColder        ChangeKind = "colder"
+)
+
+// Change is a significant difference between the forecast a user got
+// previously and the current one.
+type Change struct {
+	Kind ChangeKind
+
+	// Forecast is the first forecast of the day which changed.
+	Forecast *weather.Forecast
+
+	// Delta is the difference in the maximum temperature of the day.
+	Delta float64
+	Text  string
+}
+
+type dayComparison struct {
+	first                *weather.Forecast
+	prevRainy, currRainy bool
+	prevMax, currMax     float64
+}
+
+// DetectChanges compares the forecasts of the previous snapshot with the
+// current ones, day by day. Only the forecast windows present in both are
+// compared, so a day which was only partially covered by the previous snapshot
+// isn't mistaken for a change. Days are calculated in now's location.
+func DetectChanges(prev *snapshots.Snapshot, curr []*weather.Forecast, now time.Time) []*Change {
+	if prev == nil {
+		return nil
+	}
+
+	prevByTime := make(map[int]*weather.Forecast, len(prev.Forecasts))
+	for _, f := range prev.Forecasts {
+		prevByTime[f.DateTimeTS] = f
+	}
+
+	days := map[time.Time]*dayComparison{}
+	for _, c := range curr {
+		p, ok := prevByTime[c.DateTimeTS]
+		if !ok {
+			continue
+		}
+
+		day := startOfDay(time.Unix(int64(c.DateTimeTS), 0).In(now.Location()))
+		d, ok := days[day]
+		if !ok {
+			d = &dayComparison{first: c, prevMax: math.Inf(-1), currMax: math.Inf(-1)}
+			days[day] = d
+		}
+
+		d.prevRainy = d.prevRainy || p.IsRainy()
+		d.currRainy = d.currRainy || c.IsRainy()
+		d.prevMax = math.Max(d.prevMax, p.MaximumTemperature)
+		d.currMax = math.Max(d.currMax, c.MaximumTemperature)
+	}
+
+	sortedDays := make([]time.Time, 0, len(days))
+	for day := range days {
+		sortedDays = append(sortedDays, day)
+	}
+
+	sort.Slice(sortedDays, func(i, j int) bool { return sortedDays[i].Before(sortedDays[j]) })
+
+	since := sinceLabel(prev.CreatedAt.In(now.Location()), now)
+
+	var changes []*Change
+	for _, day := range sortedDays {
+		d := days[day]
+
+		if d.prevRainy && !d.currRainy {
+			changes = append(changes, &Change{
+				Kind:     ChangeRainCancelled,
+				Forecast: d.first,
+				Text:     fmt.Sprintf("☀️ Good news, the rain expected %s is now cancelled.", dayLabel(day, now)),
+			})
+		}
+
+		delta := d.currMax - d.prevMax
+		switch {
+		case delta >= ChangeTemperatureThreshold:
+			changes = append(changes, &Change{
+				Kind:     ChangeWarmer,
+				Forecast: d.first,
+				Delta:    delta,
+				Text:     fmt.Sprintf("🌡 %s is now %.0fº warmer than forecast %s.", capitalise(dayLabel(day, now)), delta, since),
+			})
+
+		case delta <= -ChangeTemperatureThreshold:
+			changes = append(changes, &Change{
+				Kind:     ChangeColder,
+				Forecast: d.first,
+				Delta:    delta,
+				Text:     fmt.Sprintf("🌡 %s is now %.0fº colder than forecast %s.", capitalise(dayLabel(day, now)), -delta, since),
+			})
+		}
+	}
+
+	return changes
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// dayLabel describes the day relative to now, such as "tomorrow" or "on
+// Saturday".
+func dayLabel(day, now time.Time) string {
+	today := startOfDay(now)
+
+	switch {
+	case day.Equal(today):
+		return "today"
+	case day.Equal(today.AddDate(0, 0, 1)):
+		return "tomorrow"
+	default:
+		return "on " + day.Weekday().String()
+	}
+}
+
+// sinceLabel describes when the snapshot was taken relative to now.
+func sinceLabel(taken, now time.Time) string {
+	today := startOfDay(now)
+
+	switch day := startOfDay(taken); {
+	case day.Equal(today):
+		return "earlier today"
+	case day.Equal(today.AddDate(0, 0, -1)):
+		return "yesterday"
+	default:
+		return "on " + taken.Format("Mon 02")
+	}
+}
+
+// capitalise turns day labels into the subject of a sentence: "tomorrow"
+// becomes "Tomorrow", and "on Saturday" becomes "Saturday".
+func capitalise(label string) string {
+	switch label {
+	case "today":
+		return "Today"
+	case "tomorrow":
+		return "Tomorrow"
+	default:
+		return label[len("on "):]
+	}
+}
@@ -0,0 +1,110 @@
+package pings_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/manzanit0/weathry/cmd/pinger/pings"
+	"github.com/manzanit0/weathry/cmd/pinger/snapshots"
+	"github.com/manzanit0/weathry/pkg/weather"
+)
+
+func TestDetectChanges(t *testing.T) {
+	// Thursday, 4th of August 2022 at 08:00 UTC.
+	now := time.Date(2022, time.August, 4, 8, 0, 0, 0, time.UTC)
+	yesterday := now.Add(-13 * time.Hour)
+	tomorrow := int(now.Add(24 * time.Hour).Unix())
+	saturday := int(now.Add(48 * time.Hour).Unix())
+
+	testCases := []struct {
+		desc  string
+		prev  *snapshots.Snapshot
+		curr  []*weather.Forecast
+		kinds []pings.ChangeKind
+		texts []string
+	}{
+		{
+			desc: "when there's no previous snapshot, it should return nothing",
+			prev: nil,
+			curr: []*weather.Forecast{{DateTimeTS: tomorrow, Condition: "clear"}},
+		},
+		{
+			desc: "when the forecast didn't change, it should return nothing",
+			prev: &snapshots.Snapshot{CreatedAt: yesterday, Forecasts: []*weather.Forecast{{DateTimeTS: tomorrow, Condition: "rain", MaximumTemperature: 20}}},
+			curr: []*weather.Forecast{{DateTimeTS: tomorrow, Condition: "rain", MaximumTemperature: 21}},
+		},
+		{
+			desc:  "when the rain expected tomorrow is gone, it should be cancelled",
+			prev:  &snapshots.Snapshot{CreatedAt: yesterday, Forecasts: []*weather.Forecast{{DateTimeTS: tomorrow, Condition: "rain", MaximumTemperature: 20}}},
+			curr:  []*weather.Forecast{{DateTimeTS: tomorrow, Condition: "clouds", MaximumTemperature: 20}},
+			kinds: []pings.ChangeKind{pings.ChangeRainCancelled},
+			texts: []string{"☀️ Good news, the rain expected tomorrow is now cancelled."},
+		},
+		{
+			desc: "when it's still raining later in the day, it should not be cancelled",
+			prev: &snapshots.Snapshot{CreatedAt: yesterday, Forecasts: []*weather.Forecast{
+				{DateTimeTS: tomorrow, Condition: "rain"},
+				{DateTimeTS: tomorrow + 3*60*60, Condition: "clouds"},
+			}},
+			curr: []*weather.Forecast{
+				{DateTimeTS: tomorrow, Condition: "clouds"},
+				{DateTimeTS: tomorrow + 3*60*60, Condition: "rain"},
+			},
+		},
+		{
+			desc:  "when saturday is much colder, it should be notified",
+			prev:  &snapshots.Snapshot{CreatedAt: yesterday, Forecasts: []*weather.Forecast{{DateTimeTS: saturday, Condition: "clear", MaximumTemperature: 26}}},
+			curr:  []*weather.Forecast{{DateTimeTS: saturday, Condition: "clear", MaximumTemperature: 20}},
+			kinds: []pings.ChangeKind{pings.ChangeColder},
+			texts: []string{"🌡 Saturday is now 6º colder than forecast yesterday."},
+		},
+		{
+			desc:  "when tomorrow is much warmer than this morning's forecast, it should be notified",
+			prev:  &snapshots.Snapshot{CreatedAt: now.Add(-1 * time.Hour), Forecasts: []*weather.Forecast{{DateTimeTS: tomorrow, Condition: "clear", MaximumTemperature: 20}}},
+			curr:  []*weather.Forecast{{DateTimeTS: tomorrow, Condition: "clear", MaximumTemperature: 25}},
+			kinds: []pings.ChangeKind{pings.ChangeWarmer},
+			texts: []string{"🌡 Tomorrow is now 5º warmer than forecast earlier today."},
+		},
+		{
+			desc: "when the previous snapshot didn't cover the whole day, only the overlapping windows should be compared",
+			prev: &snapshots.Snapshot{CreatedAt: yesterday, Forecasts: []*weather.Forecast{
+				{DateTimeTS: saturday + 6*60*60, Condition: "clear", MaximumTemperature: 20},
+			}},
+			curr: []*weather.Forecast{
+				{DateTimeTS: saturday, Condition: "rain", MaximumTemperature: 30},
+				{DateTimeTS: saturday + 6*60*60, Condition: "clear", MaximumTemperature: 20},
+			},
+		},
+		{
+			desc: "when several days change, they should be returned in order",
+			prev: &snapshots.Snapshot{CreatedAt: yesterday, Forecasts: []*weather.Forecast{
+				{DateTimeTS: saturday, Condition: "clear", MaximumTemperature: 20},
+				{DateTimeTS: tomorrow, Condition: "rain", MaximumTemperature: 20},
+			}},
+			curr: []*weather.Forecast{
+				{DateTimeTS: tomorrow, Condition: "clear", MaximumTemperature: 14},
+				{DateTimeTS: saturday, Condition: "clear", MaximumTemperature: 27},
+			},
+			kinds: []pings.ChangeKind{pings.ChangeRainCancelled, pings.ChangeColder, pings.ChangeWarmer},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got := pings.DetectChanges(tC.prev, tC.curr, now)
+			if len(got) != len(tC.kinds) {
+				t.Fatalf("got %d changes, expected %d", len(got), len(tC.kinds))
+			}
+
+			for i := range got {
+				if got[i].Kind != tC.kinds[i] {
+					t.Errorf("got change %q, expected %q", got[i].Kind, tC.kinds[i])
+				}
+
+				if i < len(tC.texts) && got[i].Text != tC.texts[i] {
+					t.Errorf("got text %q, expected %q", got[i].Text, tC.texts[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package pings
+
+import (
+	"strings"
+
+	"github.com/manzanit0/weathry/cmd/pinger/outbox"
+	"github.com/manzanit0/weathry/pkg/metrics"
+)
+
+var (
+	notificationsTotal = metrics.NewCounter(
+		"weathry_notifications_total",
+		"Alerts delivered to users (sent), or which never will be (failed), by rule. User rules are all labelled \"rule\".",
+		"rule", "result",
+	)
+
+	deliveriesTotal = metrics.NewCounter(
+		"weathry_outbox_deliveries_total",
+		"Attempts to deliver outbox messages, by outcome.",
+		"outcome",
+	)
+)
+
+// ruleLabel returns the label of the rule for metrics. User rules are labelled
+// together, since there's one per rule users create.
+func ruleLabel(rule string) string {
+	if strings.HasPrefix(rule, "rule:") {
+		return "rule"
+	}
+
+	return rule
+}
+
+// countNotifications counts the alerts of the message as sent or failed.
+func countNotifications(m *outbox.Message, result string) {
+	for _, rule := range m.Rules {
+		notificationsTotal.Inc(ruleLabel(rule), result)
+	}
+}
+
+func (o deliveryOutcome) String() string {
+	switch o {
+	case deliveryDelivered:
+		return "delivered"
+	case deliveryRetried:
+		return "retried"
+	case deliveryFailed:
+		return "failed"
+	case deliveryDropped:
+		return "dropped"
+	case deliveryThrottled:
+		return "throttled"
+	default:
+		return "unknown"
+	}
+}
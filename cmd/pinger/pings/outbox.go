@@ -0,0 +1,248 @@
+package pings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/manzanit0/weathry/cmd/pinger/outbox"
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+const (
+	// dispatchBatchSize is how many messages are loaded from the outbox at
+	// once.
+	dispatchBatchSize = 500
+
+	// maxDispatchBatches bounds how long a single dispatch runs. Whatever is
+	// left is picked up by the next one.
+	maxDispatchBatches = 10
+)
+
+var (
+	errMuted       = errors.New("user muted notifications")
+	errLimiterWait = errors.New("waiting for the send limiter")
+)
+
+// DispatchSummary is the outcome of delivering the messages in the outbox.
+type DispatchSummary struct {
+	Delivered int
+	Retried   int
+	Failed    int
+
+	// Dropped is the number of messages not delivered on purpose, i.e. because
+	// the user muted notifications.
+	Dropped int
+}
+
+func (s DispatchSummary) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("delivered", s.Delivered),
+		slog.Int("retried", s.Retried),
+		slog.Int("failed", s.Failed),
+		slog.Int("dropped", s.Dropped),
+	)
+}
+
+type deliveryOutcome int
+
+const (
+	deliveryDelivered deliveryOutcome = iota
+	deliveryRetried
+	deliveryFailed
+	deliveryDropped
+
+	// deliveryThrottled means Telegram asked us to slow down, so the rest of
+	// the batch should wait for the next dispatch.
+	deliveryThrottled
+)
+
+func (s *DispatchSummary) add(o deliveryOutcome) {
+	switch o {
+	case deliveryDelivered:
+		s.Delivered++
+	case deliveryRetried, deliveryThrottled:
+		s.Retried++
+	case deliveryFailed:
+		s.Failed++
+	case deliveryDropped:
+		s.Dropped++
+	}
+}
+
+// DispatchOutbox delivers the messages in the outbox which are due, within
+// Telegram's rate limit. Messages which fail with temporary errors are retried
+// with exponential backoff, and the ones that fail with permanent errors are
+// marked as failed.
+//
+// Only one dispatch runs at a time. If there's one running already, this one
+// returns straight away and leaves the messages to it or the next one.
+func (p *backgroundPinger) DispatchOutbox(ctx context.Context) error {
+	if !p.dispatching.TryLock() {
+		return nil
+	}
+
+	defer p.dispatching.Unlock()
+
+	var summary DispatchSummary
+
+	defer func() {
+		if summary == (DispatchSummary{}) {
+			return
+		}
+
+		counts, err := p.outbox.CountByStatus(ctx)
+		if err != nil {
+			slog.Error("failed to count outbox messages", "error", err.Error())
+		}
+
+		slog.Info("dispatched outbox", "summary", summary, "pending", counts[outbox.StatusPending], "failed", counts[outbox.StatusFailed])
+	}()
+
+	for i := 0; i < maxDispatchBatches; i++ {
+		due, err := p.outbox.ListDue(ctx, p.clock.Now(), dispatchBatchSize)
+		if err != nil {
+			return fmt.Errorf("list due messages: %w", err)
+		}
+
+		for _, m := range due {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			o := p.deliver(ctx, m)
+			summary.add(o)
+			deliveriesTotal.Inc(o.String())
+
+			switch o {
+			case deliveryDelivered:
+				countNotifications(m, "sent")
+			case deliveryFailed, deliveryDropped:
+				countNotifications(m, "failed")
+			}
+
+			if o == deliveryThrottled {
+				return nil
+			}
+		}
+
+		if len(due) < dispatchBatchSize {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (p *backgroundPinger) deliver(ctx context.Context, m *outbox.Message) deliveryOutcome {
+	logger := slog.Default().With("ctx.user_id", m.UserID).With("outbox_message_id", m.ID)
+	now := p.clock.Now()
+
+	user, err := p.users.GetUser(ctx, m.UserID)
+	if err != nil {
+		logger.Error("error getting user", "error", err.Error())
+	}
+
+	// Users who muted notifications since the message was queued don't get
+	// it at all.
+	if user != nil && user.IsMuted(now) {
+		p.markFailed(ctx, logger, m, errMuted)
+		return deliveryDropped
+	}
+
+	err = p.sendParts(ctx, logger, m)
+	if errors.Is(err, errLimiterWait) {
+		return deliveryThrottled
+	}
+
+	if err == nil {
+		if err := p.outbox.MarkSent(ctx, m.ID, p.clock.Now()); err != nil {
+			logger.Error("failed to mark outbox message as sent", "error", err.Error())
+		}
+
+		return deliveryDelivered
+	}
+
+	var apiErr *tgram.APIError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.Blocked():
+		logger.Info("user blocked the bot, deactivating them", "error", err.Error())
+		p.markFailed(ctx, logger, m, err)
+
+		if err := p.users.Deactivate(ctx, m.UserID); err != nil {
+			logger.Error("failed to deactivate user", "error", err.Error())
+		}
+
+		if _, err := p.outbox.DropPending(ctx, m.UserID, "user blocked the bot"); err != nil {
+			logger.Error("failed to drop pending outbox messages", "error", err.Error())
+		}
+
+		return deliveryFailed
+
+	case errors.As(err, &apiErr) && !apiErr.Temporary():
+		logger.Error("failed to deliver outbox message", "error", err.Error(), "attempts", m.Attempts+1)
+		p.markFailed(ctx, logger, m, err)
+		return deliveryFailed
+
+	case m.Attempts+1 >= outbox.MaxAttempts:
+		logger.Error("giving up delivering outbox message", "error", err.Error(), "attempts", m.Attempts+1)
+		p.markFailed(ctx, logger, m, err)
+		return deliveryFailed
+	}
+
+	delay := outbox.Backoff(m.Attempts + 1)
+	throttled := apiErr != nil && apiErr.RetryAfter > 0
+	if throttled && apiErr.RetryAfter > delay {
+		delay = apiErr.RetryAfter
+	}
+
+	logger.Warn("failed to deliver outbox message, retrying later", "error", err.Error(), "attempts", m.Attempts+1, "retry_in", delay.String())
+	if err := p.outbox.MarkRetry(ctx, m.ID, now.Add(delay), err); err != nil {
+		logger.Error("failed to schedule outbox message retry", "error", err.Error())
+	}
+
+	if throttled {
+		return deliveryThrottled
+	}
+
+	return deliveryRetried
+}
+
+// sendParts sends the parts of the message the user hasn't got yet, each
+// within the rate limit, since Telegram counts them as separate messages. When
+// only some go through, how many is recorded so that retries resume from the
+// next one instead of sending them again.
+func (p *backgroundPinger) sendParts(ctx context.Context, logger *slog.Logger, m *outbox.Message) error {
+	parts := m.Request().Parts()
+	sent := m.PartsSent
+
+	var err error
+	for ; sent < len(parts); sent++ {
+		if werr := p.sendLimiter.Wait(ctx); werr != nil {
+			err = fmt.Errorf("%w: %w", errLimiterWait, werr)
+			break
+		}
+
+		if err = p.telegram.SendMessage(ctx, parts[sent]); err != nil {
+			break
+		}
+	}
+
+	if err != nil && sent > m.PartsSent {
+		logger.Warn("delivered outbox message partially", "parts_sent", sent, "parts", len(parts))
+		if err := p.outbox.MarkPartsSent(ctx, m.ID, sent); err != nil {
+			logger.Error("failed to record outbox message parts sent", "error", err.Error())
+		}
+
+		m.PartsSent = sent
+	}
+
+	return err
+}
+
+func (p *backgroundPinger) markFailed(ctx context.Context, logger *slog.Logger, m *outbox.Message, cause error) {
+	if err := p.outbox.MarkFailed(ctx, m.ID, cause); err != nil {
+		logger.Error("failed to mark outbox message as failed", "error", err.Error())
+	}
+}
@@ -0,0 +1,121 @@
+package pings_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manzanit0/weathry/cmd/bot/users"
+	"github.com/manzanit0/weathry/cmd/pinger/outbox"
+	"github.com/manzanit0/weathry/cmd/pinger/pings"
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+type manualClock struct{ now time.Time }
+
+func (c *manualClock) Now() time.Time { return c.now }
+
+type noUsers struct{ users.Repository }
+
+func (noUsers) GetUser(context.Context, int) (*users.User, error) { return nil, nil }
+
+// memOutbox keeps a single message.
+type memOutbox struct {
+	outbox.Repository
+	m *outbox.Message
+}
+
+func (o *memOutbox) ListDue(_ context.Context, now time.Time, _ int) ([]*outbox.Message, error) {
+	if o.m.Status != outbox.StatusPending || o.m.NextAttemptAt.After(now) {
+		return nil, nil
+	}
+
+	m := *o.m
+	return []*outbox.Message{&m}, nil
+}
+
+func (o *memOutbox) MarkSent(_ context.Context, _ int64, at time.Time) error {
+	o.m.Status = outbox.StatusSent
+	o.m.Attempts++
+	o.m.SentAt = &at
+	return nil
+}
+
+func (o *memOutbox) MarkRetry(_ context.Context, _ int64, next time.Time, cause error) error {
+	o.m.Attempts++
+	o.m.NextAttemptAt = next
+	o.m.LastError = cause.Error()
+	return nil
+}
+
+func (o *memOutbox) MarkPartsSent(_ context.Context, _ int64, n int) error {
+	o.m.PartsSent = n
+	return nil
+}
+
+func (o *memOutbox) CountByStatus(context.Context) (map[outbox.Status]int, error) {
+	return map[outbox.Status]int{o.m.Status: 1}, nil
+}
+
+// flakyTelegram rate limits the second message it's asked to send.
+type flakyTelegram struct {
+	tgram.Client
+	calls int
+	sent  []string
+}
+
+func (t *flakyTelegram) SendMessage(_ context.Context, m tgram.SendMessageRequest) error {
+	t.calls++
+	if t.calls == 2 {
+		return &tgram.APIError{StatusCode: 429, Description: "Too Many Requests: retry after 5", RetryAfter: 5 * time.Second}
+	}
+
+	t.sent = append(t.sent, m.Text)
+	return nil
+}
+
+func TestDispatchOutboxResumesPartiallyDeliveredMessages(t *testing.T) {
+	clk := &manualClock{now: time.Date(2022, time.August, 1, 7, 0, 0, 0, time.UTC)}
+
+	line := strings.Repeat("a", 99) + "\n"
+	req := tgram.SendMessageRequest{ChatID: 42, Text: strings.Repeat(line, 90)}
+	parts := req.Parts()
+	if len(parts) != 3 {
+		t.Fatalf("got %d parts, expected 3", len(parts))
+	}
+
+	m := outbox.NewMessage(req, clk.now)
+	m.ID = 1
+	ob := &memOutbox{m: m}
+	tg := &flakyTelegram{}
+
+	p := pings.NewBackgroundPinger(nil, nil, tg, nil, pings.Repositories{Users: noUsers{}, Outbox: ob}, pings.WithClock(clk))
+
+	if err := p.DispatchOutbox(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if m.Status != outbox.StatusPending || m.PartsSent != 1 {
+		t.Fatalf("got message %s with %d parts sent, expected it pending with 1", m.Status, m.PartsSent)
+	}
+
+	clk.now = m.NextAttemptAt
+	if err := p.DispatchOutbox(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if m.Status != outbox.StatusSent {
+		t.Errorf("got message %s, expected it sent", m.Status)
+	}
+
+	if len(tg.sent) != len(parts) {
+		t.Fatalf("got %d parts delivered, expected each of the %d once", len(tg.sent), len(parts))
+	}
+
+	for i, text := range tg.sent {
+		if text != parts[i].Text {
+			t.Errorf("got part %d of %d characters, expected %d", i+1, len(text), len(parts[i].Text))
+		}
+	}
+}
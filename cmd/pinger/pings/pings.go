@@ -3,128 +3,272 @@ package pings
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"log/slog"
 
+	"github.com/manzanit0/weathry/cmd/bot/briefings"
 	"github.com/manzanit0/weathry/cmd/bot/location"
+	"github.com/manzanit0/weathry/cmd/bot/notifications"
+	"github.com/manzanit0/weathry/cmd/bot/rules"
+	"github.com/manzanit0/weathry/cmd/bot/schedules"
+	"github.com/manzanit0/weathry/cmd/bot/templates"
+	"github.com/manzanit0/weathry/cmd/bot/users"
+	"github.com/manzanit0/weathry/cmd/pinger/outbox"
+	"github.com/manzanit0/weathry/cmd/pinger/snapshots"
+	"github.com/manzanit0/weathry/pkg/clock"
+	"github.com/manzanit0/weathry/pkg/cron"
+	"github.com/manzanit0/weathry/pkg/expr"
 	"github.com/manzanit0/weathry/pkg/geocode"
+	"github.com/manzanit0/weathry/pkg/ratelimit"
 	"github.com/manzanit0/weathry/pkg/tgram"
 	"github.com/manzanit0/weathry/pkg/weather"
 )
 
 type Pinger interface {
 	MonitorWeather(context.Context) error
+	PingScheduledUsers(context.Context) error
+	SendBriefings(context.Context) error
+	DispatchOutbox(context.Context) error
 }
 
-func NewBackgroundPinger(f weather.Client, g geocode.Client, t tgram.Client, l location.Repository) *backgroundPinger {
-	return &backgroundPinger{forecaster: f, geocoder: g, telegram: t, locations: l}
+type Repositories struct {
+	Locations     location.Repository
+	Rules         rules.Repository
+	Notifications notifications.Repository
+	Snapshots     snapshots.Repository
+	Schedules     schedules.Repository
+	Briefings     briefings.Repository
+	Users         users.Repository
+	Outbox        outbox.Repository
+	Templates     templates.Repository
+}
+
+func NewBackgroundPinger(f weather.Client, g geocode.Client, t tgram.Client, codec *tgram.CallbackCodec, r Repositories, opts ...Option) *backgroundPinger {
+	p := backgroundPinger{
+		forecaster:    f,
+		geocoder:      g,
+		telegram:      t,
+		codec:         codec,
+		locations:     r.Locations,
+		rules:         r.Rules,
+		notifications: r.Notifications,
+		snapshots:     r.Snapshots,
+		schedules:     r.Schedules,
+		briefings:     r.Briefings,
+		users:         r.Users,
+		outbox:        r.Outbox,
+		templates:     r.Templates,
+		workers:       DefaultWorkers,
+		sendLimiter:   ratelimit.New(TelegramMessagesPerSecond, 1),
+		clock:         clock.System{},
+	}
+
+	for _, o := range opts {
+		o(&p)
+	}
+
+	return &p
 }
 
 type backgroundPinger struct {
 	forecaster weather.Client
 	geocoder   geocode.Client
 	telegram   tgram.Client
+	codec      *tgram.CallbackCodec
 	locations  location.Repository
+	rules      rules.Repository
+
+	notifications notifications.Repository
+	snapshots     snapshots.Repository
+	schedules     schedules.Repository
+	briefings     briefings.Repository
+	users         users.Repository
+	outbox        outbox.Repository
+	templates     templates.Repository
+
+	workers int
+
+	// sendLimiter is shared by all dispatches, since Telegram's limit is
+	// global to the bot.
+	sendLimiter *ratelimit.Limiter
+
+	// dispatching stops several jobs from dispatching the outbox at once,
+	// which would deliver the same messages twice.
+	dispatching sync.Mutex
+
+	clock clock.Clock
+
+	// onlyUserID restricts runs to a single user when set.
+	onlyUserID int
+}
+
+type Option func(*backgroundPinger)
+
+// WithClock sets the clock the pinger tells the time with, so runs can be
+// simulated at any given time.
+func WithClock(c clock.Clock) Option {
+	return func(p *backgroundPinger) {
+		p.clock = c
+	}
+}
+
+// WithOnlyUser restricts runs to the given user, ignoring everyone else.
+func WithOnlyUser(userID int) Option {
+	return func(p *backgroundPinger) {
+		p.onlyUserID = userID
+	}
+}
+
+func (p *backgroundPinger) includes(userID int) bool {
+	return p.onlyUserID == 0 || p.onlyUserID == userID
 }
 
 func (p *backgroundPinger) MonitorWeather(ctx context.Context) error {
-	return p.PingRainyForecasts()
+	return p.PingRainyForecasts(ctx)
 }
 
-func (p *backgroundPinger) PingRainyForecasts() error {
-	homes, err := p.locations.ListHomes(context.Background())
+// PingRainyForecasts checks the forecast of the homes of all users which
+// haven't set up their own schedule.
+func (p *backgroundPinger) PingRainyForecasts(ctx context.Context) error {
+	homes, err := p.locations.ListHomes(ctx)
 	if err != nil {
 		return fmt.Errorf("list homes: %w", err)
 	}
 
+	scheduled, err := p.schedules.ListSchedules(ctx)
+	if err != nil {
+		return fmt.Errorf("list schedules: %w", err)
+	}
+
+	hasOwnSchedule := make(map[int]bool, len(scheduled))
+	for _, s := range scheduled {
+		hasOwnSchedule[s.UserID] = true
+	}
+
+	var pending []*location.HomeLocation
 	for _, home := range homes {
-		logger := slog.
-			Default().
-			With("ctx.user_id", home.UserID).
-			With("ctx.home", home.Name)
+		if !hasOwnSchedule[home.UserID] && p.includes(home.UserID) {
+			pending = append(pending, home)
+		}
+	}
+
+	summary := p.pingHomes(ctx, pending)
+	slog.Info("checked the weather of homes", "summary", summary)
+
+	return p.DispatchOutbox(ctx)
+}
+
+// PingScheduledUsers checks the forecast of the homes of the users whose
+// schedule is due.
+func (p *backgroundPinger) PingScheduledUsers(ctx context.Context) error {
+	scheduled, err := p.schedules.ListSchedules(ctx)
+	if err != nil {
+		return fmt.Errorf("list schedules: %w", err)
+	}
+
+	now := p.clock.Now()
 
-		var message string
+	var due []*schedules.Schedule
+	var homes []*location.HomeLocation
+	for _, s := range scheduled {
+		if !p.includes(s.UserID) || !IsScheduleDue(s, now) {
+			continue
+		}
 
-		forecasts, err := p.forecaster.GetHourlyForecast(home.Latitude, home.Longitude)
+		home, err := p.locations.GetHome(ctx, s.UserID)
 		if err != nil {
-			logger.Error("error requesting upcoming weather", "error", err.Error())
+			slog.Error("error getting home", "error", err.Error(), "ctx.user_id", s.UserID)
 			continue
 		}
 
-		rainyForecast := FindNextRainyDay(forecasts)
-		if rainyForecast != nil {
-			if isToday(rainyForecast.DateTimeTS) {
-				message = fmt.Sprintf("Heads up, it's going to be raining today at %s!", rainyForecast.FormattedTime())
-			} else {
-				message = fmt.Sprintf("Hey 👋! I'm expecting rain next %s at around %s.",
-					rainyForecast.FormattedDate(),
-					rainyForecast.FormattedTime())
-			}
+		due = append(due, s)
+		if home != nil {
+			homes = append(homes, home)
 		}
+	}
 
-		highTempForecast := FindNextHighTemperature(forecasts)
-		if highTempForecast != nil {
-			if len(message) > 0 {
-				message += "\nAlso, on a separate note, "
-			} else {
-				message = "Hi! Just letting you know that "
-			}
+	if len(due) == 0 {
+		return nil
+	}
 
-			if isToday(highTempForecast.DateTimeTS) {
-				message += fmt.Sprintf("it's going to be pretty hot today with a max of %.2fºC! 🔥",
-					highTempForecast.MaximumTemperature)
-			} else {
-				message += fmt.Sprintf("next %s temperatures are going to rise all the way to %.2fºC! 🔥",
-					highTempForecast.FormattedDateTime(),
-					highTempForecast.MaximumTemperature)
-			}
+	summary := p.pingHomes(ctx, homes)
+	slog.Info("checked the weather of scheduled homes", "summary", summary)
+
+	for _, s := range due {
+		if err := p.schedules.MarkRun(ctx, s.UserID, now); err != nil {
+			slog.Error("error marking schedule as run", "error", err.Error(), "ctx.user_id", s.UserID)
 		}
+	}
 
-		lowTempForecast := FindNextLowTemperature(forecasts)
-		if lowTempForecast != nil {
-			if len(message) > 0 {
-				message += "\nAlso, on a separate note, "
-			} else {
-				message = "Hi! Just letting you know that "
-			}
+	return p.DispatchOutbox(ctx)
+}
 
-			if isToday(lowTempForecast.DateTimeTS) {
-				message += fmt.Sprintf("it's going to be pretty cold today with a min of %.2fºC! ❄️ ",
-					lowTempForecast.MinimumTemperature)
-			} else {
-				message += fmt.Sprintf("next %s temperatures are going to decrease the way to %.2fºC! ❄️ ",
-					lowTempForecast.FormattedDateTime(),
-					lowTempForecast.MinimumTemperature)
-			}
-		}
+// IsScheduleDue reports whether the schedule should have run between the last
+// time it ran and now.
+func IsScheduleDue(s *schedules.Schedule, now time.Time) bool {
+	sched, err := cron.Parse(s.CronExpression)
+	if err != nil {
+		slog.Error("invalid user schedule", "error", err.Error(), "ctx.user_id", s.UserID)
+		return false
+	}
 
-		if message == "" {
-			continue
-		}
+	last := s.CreatedAt
+	if s.LastRunAt != nil {
+		last = *s.LastRunAt
+	}
+
+	next := sched.Next(last.In(s.Location()))
+	return !next.IsZero() && !next.After(now)
+}
 
-		res := tgram.SendMessageRequest{Text: message, ChatID: int64(home.UserID)}
-		res.AddKeyboardElementRow([]tgram.InlineKeyboardElement{
-			{Text: "⏰ Check hourly forecast", CallbackData: fmt.Sprintf("hourly:%f,%f", home.Latitude, home.Longitude)},
-			{Text: "📆 Check daily forecast", CallbackData: fmt.Sprintf("daily:%f,%f", home.Latitude, home.Longitude)},
-		})
+// detectChanges compares the forecasts with the ones from the previous run and
+// stores them for the next one.
+func (p *backgroundPinger) detectChanges(ctx context.Context, logger *slog.Logger, home *location.HomeLocation, forecasts []*weather.Forecast, now time.Time) []*Change {
+	prev, err := p.snapshots.GetLatest(ctx, home.UserID, home.Name)
+	if err != nil {
+		logger.Error("failed to get latest forecast snapshot", "error", err.Error())
+	}
 
-		err = p.telegram.SendMessage(res)
+	err = p.snapshots.SaveSnapshot(ctx, &snapshots.Snapshot{UserID: home.UserID, LocationName: home.Name, Forecasts: forecasts})
+	if err != nil {
+		logger.Error("failed to save forecast snapshot", "error", err.Error())
+	}
+
+	return DetectChanges(prev, forecasts, now)
+}
+
+// suppressDuplicates filters out the alerts which the user has already been
+// notified about, unless the forecast has materially changed since.
+func (p *backgroundPinger) suppressDuplicates(ctx context.Context, logger *slog.Logger, userID int, alerts []*alert, now time.Time) []*alert {
+	var filtered []*alert
+	for _, a := range alerts {
+		prev, err := p.notifications.GetLatest(ctx, userID, a.rule, now.Add(-notifications.SuppressionWindow), now)
 		if err != nil {
-			logger.Error("failed to send rainy update to telegram", "error", err.Error())
+			// Better to notify twice than not at all.
+			logger.Error("failed to get latest notification", "error", err.Error(), "rule", a.rule)
+			filtered = append(filtered, a)
+			continue
+		}
+
+		if !a.notification(userID).Supersedes(prev) {
+			logger.Info("suppressed duplicate notification", "rule", a.rule, "previous_notification_id", prev.ID)
 			continue
 		}
+
+		filtered = append(filtered, a)
 	}
 
-	return nil
+	return filtered
 }
 
-func FindNextRainyDay(forecasts []*weather.Forecast) *weather.Forecast {
+func FindNextRainyDay(forecasts []*weather.Forecast, now time.Time) *weather.Forecast {
 	for _, f := range forecasts {
 		if f.IsRainy() {
 			// We only want to get today if it's early morning. If we're
 			// checking after lunch, might as well check upcoming days.
-			if isToday(f.DateTimeTS) && isNowPastLunchTime() {
+			if isToday(f.DateTimeTS, now) && isPastLunchTime(now) {
 				continue
 			}
 
@@ -135,39 +279,49 @@ func FindNextRainyDay(forecasts []*weather.Forecast) *weather.Forecast {
 	return nil
 }
 
-func FindNextHighTemperature(forecasts []*weather.Forecast) *weather.Forecast {
-	if len(forecasts) == 0 {
-		return nil
+// FindFirstMatchingForecast returns the first forecast which matches the user
+// rule, or nil if none do. Fields such as the hour are evaluated in loc.
+func FindFirstMatchingForecast(r *rules.Rule, forecasts []*weather.Forecast, loc *time.Location) (*weather.Forecast, error) {
+	compiled, err := expr.Compile(r.Expression)
+	if err != nil {
+		return nil, fmt.Errorf("compile rule: %w", err)
 	}
 
-	for _, f := range forecasts[:len(forecasts)-1] {
-		if f.MaximumTemperature > 32 {
-			return f
+	return compiled.FindFirst(forecasts, loc)
+}
+
+// FindNextHighTemperature returns the first forecast above 32ºC which is
+// warmer than the one before it, so users are warned when the heat arrives
+// rather than for as long as it lasts.
+func FindNextHighTemperature(forecasts []*weather.Forecast) *weather.Forecast {
+	for i := 1; i < len(forecasts); i++ {
+		if forecasts[i].MaximumTemperature > 32 && forecasts[i].MaximumTemperature > forecasts[i-1].MaximumTemperature {
+			return forecasts[i]
 		}
 	}
 
 	return nil
 }
 
+// FindNextLowTemperature returns the first forecast below 10ºC which is colder
+// than the one before it.
 func FindNextLowTemperature(forecasts []*weather.Forecast) *weather.Forecast {
-	if len(forecasts) == 0 {
-		return nil
-	}
-
-	for _, f := range forecasts[:len(forecasts)-1] {
-		if f.MinimumTemperature < 10 {
-			return f
+	for i := 1; i < len(forecasts); i++ {
+		if forecasts[i].MinimumTemperature < 10 && forecasts[i].MinimumTemperature < forecasts[i-1].MinimumTemperature {
+			return forecasts[i]
 		}
 	}
 
 	return nil
 }
 
-func isToday(unix int) bool {
-	t := time.Unix(int64(unix), 0)
-	return t.Day() == time.Now().Day()
+// isToday reports whether the timestamp falls on the same day as now, in now's
+// location.
+func isToday(unix int, now time.Time) bool {
+	t := time.Unix(int64(unix), 0).In(now.Location())
+	return t.Year() == now.Year() && t.YearDay() == now.YearDay()
 }
 
-func isNowPastLunchTime() bool {
-	return time.Now().Hour() > 15
+func isPastLunchTime(now time.Time) bool {
+	return now.Hour() > 15
 }
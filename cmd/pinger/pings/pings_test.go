@@ -3,12 +3,15 @@ package pings_test
 import (
 	"testing"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/manzanit0/weathry/cmd/pinger/pings"
 	"github.com/manzanit0/weathry/pkg/weather"
 )
 
 func TestFindNextHighTemperature(t *testing.T) {
+	now := time.Date(2022, time.August, 1, 8, 0, 0, 0, time.UTC)
+
 	testCases := []struct {
 		desc      string
 		forecasts []*weather.Forecast
@@ -28,37 +31,37 @@ func TestFindNextHighTemperature(t *testing.T) {
 			desc: "when the first forecast is above 32, it should be ignored",
 			want: nil,
 			forecasts: []*weather.Forecast{
-				{MaximumTemperature: 35, DateTimeTS: int(time.Now().Unix())},
+				{MaximumTemperature: 35, DateTimeTS: int(now.Unix())},
 			},
 		},
 		{
 			desc: "when the temperature increases from the previous day and is above 32, it should be returned",
-			want: &weather.Forecast{MaximumTemperature: 33, MinimumTemperature: 23, DateTimeTS: int(time.Now().Add(48 * time.Hour).Unix())},
+			want: &weather.Forecast{MaximumTemperature: 33, MinimumTemperature: 23, DateTimeTS: int(now.Add(48 * time.Hour).Unix())},
 			forecasts: []*weather.Forecast{
-				{MaximumTemperature: 12, DateTimeTS: int(time.Now().Unix())},
-				{MaximumTemperature: 13, DateTimeTS: int(time.Now().Add(24 * time.Hour).Unix())},
-				{MaximumTemperature: 33, DateTimeTS: int(time.Now().Add(48 * time.Hour).Unix())},
-				{MaximumTemperature: 34, DateTimeTS: int(time.Now().Add(72 * time.Hour).Unix())},
+				{MaximumTemperature: 12, DateTimeTS: int(now.Unix())},
+				{MaximumTemperature: 13, DateTimeTS: int(now.Add(24 * time.Hour).Unix())},
+				{MaximumTemperature: 33, DateTimeTS: int(now.Add(48 * time.Hour).Unix())},
+				{MaximumTemperature: 34, DateTimeTS: int(now.Add(72 * time.Hour).Unix())},
 			},
 		},
 		{
 			desc: "when the temperature decreases from the previous day and is above 32, it should not be returned",
 			want: nil,
 			forecasts: []*weather.Forecast{
-				{MaximumTemperature: 35, DateTimeTS: int(time.Now().Unix())},
-				{MaximumTemperature: 34, DateTimeTS: int(time.Now().Add(24 * time.Hour).Unix())},
-				{MaximumTemperature: 33, DateTimeTS: int(time.Now().Add(48 * time.Hour).Unix())},
-				{MaximumTemperature: 32, DateTimeTS: int(time.Now().Add(72 * time.Hour).Unix())},
+				{MaximumTemperature: 35, DateTimeTS: int(now.Unix())},
+				{MaximumTemperature: 34, DateTimeTS: int(now.Add(24 * time.Hour).Unix())},
+				{MaximumTemperature: 33, DateTimeTS: int(now.Add(48 * time.Hour).Unix())},
+				{MaximumTemperature: 32, DateTimeTS: int(now.Add(72 * time.Hour).Unix())},
 			},
 		},
 		{
 			desc: "when the temperature increases and is above 32 immediately after the first forecast, it should be returned",
-			want: &weather.Forecast{MaximumTemperature: 36, DateTimeTS: int(time.Now().Add(24 * time.Hour).Unix())},
+			want: &weather.Forecast{MaximumTemperature: 36, DateTimeTS: int(now.Add(24 * time.Hour).Unix())},
 			forecasts: []*weather.Forecast{
-				{MaximumTemperature: 35, DateTimeTS: int(time.Now().Unix())},
-				{MaximumTemperature: 36, DateTimeTS: int(time.Now().Add(24 * time.Hour).Unix())},
-				{MaximumTemperature: 37, DateTimeTS: int(time.Now().Add(48 * time.Hour).Unix())},
-				{MaximumTemperature: 38, DateTimeTS: int(time.Now().Add(72 * time.Hour).Unix())},
+				{MaximumTemperature: 35, DateTimeTS: int(now.Unix())},
+				{MaximumTemperature: 36, DateTimeTS: int(now.Add(24 * time.Hour).Unix())},
+				{MaximumTemperature: 37, DateTimeTS: int(now.Add(48 * time.Hour).Unix())},
+				{MaximumTemperature: 38, DateTimeTS: int(now.Add(72 * time.Hour).Unix())},
 			},
 		},
 	}
@@ -75,3 +78,162 @@ func TestFindNextHighTemperature(t *testing.T) {
 		})
 	}
 }
+
+func TestFindNextLowTemperature(t *testing.T) {
+	now := time.Date(2022, time.December, 1, 8, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		desc      string
+		forecasts []*weather.Forecast
+		want      *weather.Forecast
+	}{
+		{
+			desc:      "when nil forecasts are passed, it should return nil",
+			want:      nil,
+			forecasts: nil,
+		},
+		{
+			desc: "when the first forecast is below 10, it should be ignored",
+			want: nil,
+			forecasts: []*weather.Forecast{
+				{MinimumTemperature: 5, DateTimeTS: int(now.Unix())},
+			},
+		},
+		{
+			desc: "when the temperature decreases from the previous day and is below 10, it should be returned",
+			want: &weather.Forecast{MinimumTemperature: 8, DateTimeTS: int(now.Add(48 * time.Hour).Unix())},
+			forecasts: []*weather.Forecast{
+				{MinimumTemperature: 15, DateTimeTS: int(now.Unix())},
+				{MinimumTemperature: 12, DateTimeTS: int(now.Add(24 * time.Hour).Unix())},
+				{MinimumTemperature: 8, DateTimeTS: int(now.Add(48 * time.Hour).Unix())},
+				{MinimumTemperature: 6, DateTimeTS: int(now.Add(72 * time.Hour).Unix())},
+			},
+		},
+		{
+			desc: "when the temperature increases from the previous day and is below 10, it should not be returned",
+			want: nil,
+			forecasts: []*weather.Forecast{
+				{MinimumTemperature: 4, DateTimeTS: int(now.Unix())},
+				{MinimumTemperature: 5, DateTimeTS: int(now.Add(24 * time.Hour).Unix())},
+				{MinimumTemperature: 7, DateTimeTS: int(now.Add(48 * time.Hour).Unix())},
+				{MinimumTemperature: 9, DateTimeTS: int(now.Add(72 * time.Hour).Unix())},
+			},
+		},
+		{
+			desc: "when the temperature stays the same below 10, it should not be returned",
+			want: nil,
+			forecasts: []*weather.Forecast{
+				{MinimumTemperature: 6, DateTimeTS: int(now.Unix())},
+				{MinimumTemperature: 6, DateTimeTS: int(now.Add(24 * time.Hour).Unix())},
+			},
+		},
+		{
+			desc: "when the temperature drops but stays at 10 or above, it should not be returned",
+			want: nil,
+			forecasts: []*weather.Forecast{
+				{MinimumTemperature: 14, DateTimeTS: int(now.Unix())},
+				{MinimumTemperature: 10, DateTimeTS: int(now.Add(24 * time.Hour).Unix())},
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got := pings.FindNextLowTemperature(tC.forecasts)
+			if tC.want == nil && got != nil {
+				t.Errorf("expected nil, got %v", got)
+			}
+
+			if tC.want != nil && (got == nil || got.DateTimeTS != tC.want.DateTimeTS) {
+				t.Errorf("got %v, expected %v", got, tC.want)
+			}
+		})
+	}
+}
+
+func TestFindNextRainyDay(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("load location: %s", err.Error())
+	}
+
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Fatalf("load location: %s", err.Error())
+	}
+
+	rainAt := func(t time.Time) *weather.Forecast {
+		return &weather.Forecast{Condition: "rain", DateTimeTS: int(t.Unix())}
+	}
+
+	testCases := []struct {
+		desc      string
+		now       time.Time
+		forecasts []*weather.Forecast
+		want      time.Time
+	}{
+		{
+			desc: "when it's going to rain today and it's the morning, it should return today's rain",
+			now:  time.Date(2022, time.August, 1, 9, 0, 0, 0, time.UTC),
+			forecasts: []*weather.Forecast{
+				rainAt(time.Date(2022, time.August, 1, 18, 0, 0, 0, time.UTC)),
+				rainAt(time.Date(2022, time.August, 2, 12, 0, 0, 0, time.UTC)),
+			},
+			want: time.Date(2022, time.August, 1, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			desc: "when it's going to rain today but it's past lunch time, it should return the next day's rain",
+			now:  time.Date(2022, time.August, 1, 17, 0, 0, 0, time.UTC),
+			forecasts: []*weather.Forecast{
+				rainAt(time.Date(2022, time.August, 1, 21, 0, 0, 0, time.UTC)),
+				rainAt(time.Date(2022, time.August, 2, 12, 0, 0, 0, time.UTC)),
+			},
+			want: time.Date(2022, time.August, 2, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			desc: "when it's past lunch time in UTC but not in the user's timezone, it should return today's rain",
+			now:  time.Date(2022, time.August, 1, 16, 0, 0, 0, time.UTC).In(newYork), // 12:00 in New York
+			forecasts: []*weather.Forecast{
+				rainAt(time.Date(2022, time.August, 1, 22, 30, 0, 0, time.UTC)),
+				rainAt(time.Date(2022, time.August, 2, 12, 0, 0, 0, time.UTC)),
+			},
+			want: time.Date(2022, time.August, 1, 22, 30, 0, 0, time.UTC),
+		},
+		{
+			desc: "when the rain is after midnight in the user's timezone, it shouldn't count as today",
+			now:  time.Date(2022, time.August, 1, 18, 0, 0, 0, madrid),
+			forecasts: []*weather.Forecast{
+				rainAt(time.Date(2022, time.August, 2, 0, 30, 0, 0, madrid)), // still the 1st in UTC
+			},
+			want: time.Date(2022, time.August, 2, 0, 30, 0, 0, madrid),
+		},
+		{
+			desc: "when clocks go back and the day is 25 hours long, the end of the day should still be today",
+			now:  time.Date(2022, time.October, 30, 16, 30, 0, 0, madrid),
+			forecasts: []*weather.Forecast{
+				rainAt(time.Date(2022, time.October, 30, 23, 30, 0, 0, madrid)),
+				rainAt(time.Date(2022, time.October, 31, 0, 30, 0, 0, madrid)),
+			},
+			want: time.Date(2022, time.October, 31, 0, 30, 0, 0, madrid),
+		},
+		{
+			desc:      "when it isn't going to rain, it should return nil",
+			now:       time.Date(2022, time.August, 1, 9, 0, 0, 0, time.UTC),
+			forecasts: []*weather.Forecast{{Condition: "clear", DateTimeTS: int(time.Date(2022, time.August, 1, 12, 0, 0, 0, time.UTC).Unix())}},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got := pings.FindNextRainyDay(tC.forecasts, tC.now)
+
+			switch {
+			case tC.want.IsZero() && got != nil:
+				t.Errorf("expected nil, got %s", got.Time())
+			case !tC.want.IsZero() && got == nil:
+				t.Errorf("expected %s, got nil", tC.want)
+			case !tC.want.IsZero() && !got.Time().Equal(tC.want):
+				t.Errorf("got %s, expected %s", got.Time(), tC.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+package pings
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/manzanit0/weathry/cmd/bot/location"
+	"github.com/manzanit0/weathry/cmd/bot/msg"
+	"github.com/manzanit0/weathry/cmd/bot/templates"
+	"github.com/manzanit0/weathry/cmd/pinger/outbox"
+	"github.com/manzanit0/weathry/pkg/tgram"
+	"github.com/manzanit0/weathry/pkg/weather"
+)
+
+const (
+	// DefaultWorkers is how many forecasts are fetched concurrently.
+	DefaultWorkers = 8
+
+	// TelegramMessagesPerSecond is the global limit of messages Telegram lets
+	// bots send.
+	TelegramMessagesPerSecond = 30
+
+	// CoordinatePrecision is the number of decimals homes' coordinates are
+	// rounded to before grouping them. Two decimals are roughly a kilometre,
+	// which is well within the resolution of the forecasts.
+	CoordinatePrecision = 2
+
+	// sendQueueSize bounds how many messages can be waiting to be sent before
+	// the workers stop fetching forecasts.
+	sendQueueSize = 100
+)
+
+// Summary is the outcome of checking the weather of a batch of homes.
+type Summary struct {
+	// Processed is the number of homes checked. It's always the sum of
+	// Queued, Deferred, Failed and Skipped.
+	Processed int
+
+	// Queued is the number of notifications added to the outbox to be
+	// delivered straight away.
+	Queued int
+
+	// Deferred is the number of notifications held back until the end of the
+	// user's quiet hours.
+	Deferred int
+	Failed   int
+
+	// Skipped is the number of homes which didn't need a notification, or
+	// whose user muted them.
+	Skipped int
+}
+
+func (s Summary) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("processed", s.Processed),
+		slog.Int("queued", s.Queued),
+		slog.Int("deferred", s.Deferred),
+		slog.Int("failed", s.Failed),
+		slog.Int("skipped", s.Skipped),
+	)
+}
+
+func (s *Summary) add(outcome homeOutcome) {
+	s.Processed++
+	switch outcome {
+	case outcomeQueued:
+		s.Queued++
+	case outcomeDeferred:
+		s.Deferred++
+	case outcomeFailed:
+		s.Failed++
+	case outcomeSkipped:
+		s.Skipped++
+	}
+}
+
+type homeOutcome int
+
+const (
+	outcomeQueued homeOutcome = iota
+	outcomeDeferred
+	outcomeFailed
+	outcomeSkipped
+)
+
+// HomeGroup is a set of homes close enough to share a forecast.
+type HomeGroup struct {
+	Latitude  float64
+	Longitude float64
+	Homes     []*location.HomeLocation
+}
+
+// GroupHomes groups homes by their coordinates rounded to CoordinatePrecision
+// decimals, so the forecast for each group is fetched once. Groups keep the
+// order in which their first home appears.
+func GroupHomes(homes []*location.HomeLocation) []*HomeGroup {
+	factor := math.Pow(10, CoordinatePrecision)
+	round := func(f float64) float64 { return math.Round(f*factor) / factor }
+
+	var groups []*HomeGroup
+	byKey := map[string]*HomeGroup{}
+	for _, h := range homes {
+		lat, lon := round(h.Latitude), round(h.Longitude)
+		key := fmt.Sprintf("%.*f,%.*f", CoordinatePrecision, lat, CoordinatePrecision, lon)
+
+		g, ok := byKey[key]
+		if !ok {
+			g = &HomeGroup{Latitude: lat, Longitude: lon}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+
+		g.Homes = append(g.Homes, h)
+	}
+
+	return groups
+}
+
+type outgoingMessage struct {
+	home    *location.HomeLocation
+	request tgram.SendMessageRequest
+	alerts  []*alert
+
+	// deliverAt is set when the message has to wait for the end of the user's
+	// quiet hours.
+	deliverAt time.Time
+}
+
+// pingHomes checks the weather of the homes with a pool of workers which fetch
+// each group's forecast and evaluate it for each home in the group. Messages
+// are then added to the outbox one at a time.
+func (p *backgroundPinger) pingHomes(ctx context.Context, homes []*location.HomeLocation) Summary {
+	groups := make(chan *HomeGroup)
+	outgoing := make(chan *outgoingMessage, sendQueueSize)
+	outcomes := make(chan homeOutcome)
+
+	var workers sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for g := range groups {
+				p.pingGroup(ctx, g, outgoing, outcomes)
+			}
+		}()
+	}
+
+	var sender sync.WaitGroup
+	sender.Add(1)
+	go func() {
+		defer sender.Done()
+		for m := range outgoing {
+			outcomes <- p.send(ctx, m)
+		}
+	}()
+
+	go func() {
+		defer close(groups)
+		for _, g := range GroupHomes(homes) {
+			select {
+			case groups <- g:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		workers.Wait()
+		close(outgoing)
+		sender.Wait()
+		close(outcomes)
+	}()
+
+	var summary Summary
+	for o := range outcomes {
+		summary.add(o)
+	}
+
+	return summary
+}
+
+func (p *backgroundPinger) pingGroup(ctx context.Context, g *HomeGroup, outgoing chan<- *outgoingMessage, outcomes chan<- homeOutcome) {
+	forecasts, err := p.forecaster.GetHourlyForecast(ctx, g.Latitude, g.Longitude)
+	if err != nil {
+		slog.Error("error requesting upcoming weather", "error", err.Error(), "latitude", g.Latitude, "longitude", g.Longitude, "homes", len(g.Homes))
+		for range g.Homes {
+			outcomes <- outcomeFailed
+		}
+
+		return
+	}
+
+	for _, home := range g.Homes {
+		m := p.evaluateHome(ctx, home, forecasts)
+		if m == nil {
+			outcomes <- outcomeSkipped
+			continue
+		}
+
+		outgoing <- m
+	}
+}
+
+// evaluateHome returns the message the user should get about the forecasts of
+// their home, or nil if there's nothing worth notifying about.
+func (p *backgroundPinger) evaluateHome(ctx context.Context, home *location.HomeLocation, forecasts []*weather.Forecast) *outgoingMessage {
+	logger := homeLogger(home)
+
+	// Users without a profile are notified anyway, like before they could
+	// configure any of this.
+	user, err := p.users.GetUser(ctx, home.UserID)
+	if err != nil {
+		logger.Error("error getting user", "error", err.Error())
+	}
+
+	// Alerts talk about days and times in the user's timezone.
+	loc := time.UTC
+	if user != nil {
+		loc = user.Location()
+	}
+
+	now := p.clock.Now().In(loc)
+	alerts := findAlerts(forecasts, now)
+
+	userRules, err := p.rules.ListRules(ctx, home.UserID)
+	if err != nil {
+		logger.Error("error listing user rules", "error", err.Error())
+	}
+
+	for _, r := range userRules {
+		matching, err := FindFirstMatchingForecast(r, forecasts, loc)
+		if err != nil {
+			logger.Error("error evaluating user rule", "error", err.Error(), "rule_id", r.ID)
+			continue
+		}
+
+		if matching != nil {
+			alerts = append(alerts, newRuleAlert(r, matching, now))
+		}
+	}
+
+	for _, c := range p.detectChanges(ctx, logger, home, forecasts, now) {
+		alerts = append(alerts, newChangeAlert(c))
+	}
+
+	alerts = p.suppressDuplicates(ctx, logger, home.UserID, alerts, now)
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	if user != nil && user.IsMuted(now) {
+		logger.Info("user muted notifications", "muted_until", user.MutedUntil.Format(time.RFC3339))
+		return nil
+	}
+
+	forecastRow, err := msg.NewForecastKeyboardRow(ctx, p.codec, home.Latitude, home.Longitude)
+	if err != nil {
+		logger.Error("error creating forecast buttons", "error", err.Error())
+		return nil
+	}
+
+	snoozeRow, err := msg.NewSnoozeKeyboardRow(ctx, p.codec)
+	if err != nil {
+		logger.Error("error creating snooze buttons", "error", err.Error())
+		return nil
+	}
+
+	text, err := p.renderMessage(ctx, logger, templates.KindAlert, templates.Data{
+		User:      templates.User{ID: home.UserID, Timezone: loc.String()},
+		Location:  templates.Location{Name: home.Name, Latitude: home.Latitude, Longitude: home.Longitude},
+		Units:     templates.DefaultUnits,
+		Forecasts: templates.NewForecasts(forecasts),
+		Alerts:    templateAlerts(alerts, now),
+	})
+	if err != nil {
+		logger.Error("error rendering alerts", "error", err.Error())
+		return nil
+	}
+
+	req := tgram.SendMessageRequest{Text: text, ChatID: int64(home.UserID)}
+	req.ReplyMarkup = &tgram.ReplyMarkup{
+		InlineKeyboard: [][]tgram.InlineKeyboardElement{forecastRow, snoozeRow},
+	}
+
+	m := outgoingMessage{home: home, request: req, alerts: alerts}
+	if user != nil {
+		m.deliverAt = user.QuietUntil(now)
+	}
+
+	return &m
+}
+
+func (p *backgroundPinger) send(ctx context.Context, m *outgoingMessage) homeOutcome {
+	logger := homeLogger(m.home)
+
+	om := outbox.NewMessage(m.request, m.deliverAt)
+	for _, a := range m.alerts {
+		om.Rules = append(om.Rules, a.rule)
+	}
+
+	err := p.outbox.Enqueue(ctx, om)
+	if err != nil {
+		logger.Error("failed to queue rainy update", "error", err.Error())
+		countNotifications(om, "failed")
+		return outcomeFailed
+	}
+
+	p.recordNotifications(ctx, logger, m, om.ID)
+
+	if !m.deliverAt.IsZero() {
+		return outcomeDeferred
+	}
+
+	return outcomeQueued
+}
+
+// recordNotifications records the alerts of the message so the user isn't
+// notified about them again. They are recorded as soon as they are queued, so
+// later runs don't queue the same alerts while they wait in the outbox, and
+// linked to the outbox message so they count as sent only once it is.
+func (p *backgroundPinger) recordNotifications(ctx context.Context, logger *slog.Logger, m *outgoingMessage, outboxMessageID int64) {
+	for _, a := range m.alerts {
+		n := a.notification(m.home.UserID)
+		n.OutboxMessageID = outboxMessageID
+
+		err := p.notifications.CreateNotification(ctx, n)
+		if err != nil {
+			logger.Error("failed to record notification", "error", err.Error(), "rule", a.rule)
+		}
+	}
+}
+
+func homeLogger(home *location.HomeLocation) *slog.Logger {
+	return slog.
+		Default().
+		With("ctx.user_id", home.UserID).
+		With("ctx.home", home.Name)
+}
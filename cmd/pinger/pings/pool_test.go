@@ -0,0 +1,56 @@
+package pings_test
+
+import (
+	"testing"
+
+	"github.com/manzanit0/weathry/cmd/bot/location"
+	"github.com/manzanit0/weathry/cmd/pinger/pings"
+)
+
+func TestGroupHomes(t *testing.T) {
+	home := func(userID int, lat, lon float64) *location.HomeLocation {
+		return &location.HomeLocation{UserID: userID, Location: location.Location{Latitude: lat, Longitude: lon}}
+	}
+
+	homes := []*location.HomeLocation{
+		home(1, 40.41678, -3.70379),
+		home(2, 41.38506, 2.17340),
+		home(3, 40.41501, -3.70412),
+		home(4, 40.43000, -3.70379),
+	}
+
+	groups := pings.GroupHomes(homes)
+	if len(groups) != 3 {
+		t.Fatalf("got %d groups, expected 3", len(groups))
+	}
+
+	testCases := []struct {
+		desc    string
+		group   *pings.HomeGroup
+		lat     float64
+		lon     float64
+		userIDs []int
+	}{
+		{desc: "homes within the same rounded coordinates are grouped", group: groups[0], lat: 40.42, lon: -3.70, userIDs: []int{1, 3}},
+		{desc: "homes in other cities get their own group", group: groups[1], lat: 41.39, lon: 2.17, userIDs: []int{2}},
+		{desc: "homes over a kilometre away get their own group", group: groups[2], lat: 40.43, lon: -3.70, userIDs: []int{4}},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if tC.group.Latitude != tC.lat || tC.group.Longitude != tC.lon {
+				t.Errorf("got coordinates %f,%f, expected %f,%f", tC.group.Latitude, tC.group.Longitude, tC.lat, tC.lon)
+			}
+
+			if len(tC.group.Homes) != len(tC.userIDs) {
+				t.Fatalf("got %d homes, expected %d", len(tC.group.Homes), len(tC.userIDs))
+			}
+
+			for i, h := range tC.group.Homes {
+				if h.UserID != tC.userIDs[i] {
+					t.Errorf("got user %d at position %d, expected %d", h.UserID, i, tC.userIDs[i])
+				}
+			}
+		})
+	}
+}
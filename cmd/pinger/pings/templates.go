@@ -0,0 +1,29 @@
+package pings
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/manzanit0/weathry/cmd/bot/templates"
+)
+
+// renderMessage renders the message of the kind with the user's template, or
+// the global one if the user hasn't got any. Should the template fail, the
+// default one is used instead so the user still gets the message.
+func (p *backgroundPinger) renderMessage(ctx context.Context, logger *slog.Logger, kind templates.Kind, data templates.Data) (string, error) {
+	t, err := p.templates.GetTemplate(ctx, data.User.ID, kind)
+	if err != nil {
+		logger.Error("error getting template", "error", err.Error(), "kind", string(kind))
+	}
+
+	if t != nil {
+		text, err := templates.Execute(ctx, t.Body, data)
+		if err == nil {
+			return text, nil
+		}
+
+		logger.Warn("template failed, falling back to the default one", "error", err.Error(), "kind", string(kind), "template_user_id", t.UserID)
+	}
+
+	return templates.Execute(ctx, templates.Default(kind), data)
+}
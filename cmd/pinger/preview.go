@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/manzanit0/weathry/cmd/pinger/pings"
+	"github.com/manzanit0/weathry/cmd/pinger/preview"
+	"github.com/manzanit0/weathry/pkg/clock"
+	"github.com/manzanit0/weathry/pkg/geocode"
+	"github.com/manzanit0/weathry/pkg/tgram"
+	"github.com/manzanit0/weathry/pkg/weather"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+func (o options) validate() error {
+	if o.format != formatTable && o.format != formatJSON {
+		return fmt.Errorf("unknown format %q, it must be table or json", o.format)
+	}
+
+	if o.at != "" && !o.dryRun {
+		return errors.New("-at can only be used with -dry-run")
+	}
+
+	if o.at != "" {
+		if _, err := o.simulatedNow(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// simulatedNow returns the time set with -at, or the zero time if it wasn't.
+func (o options) simulatedNow() (time.Time, error) {
+	if o.at == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04"} {
+		if t, err := time.Parse(layout, o.at); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q, expected something like 2022-08-01T19:00:00Z", o.at)
+}
+
+// runPreview runs every job once as if it was the time set with -at, and
+// prints the messages which would be sent.
+func runPreview(ctx context.Context, opts options, w weather.Client, g geocode.Client, r pings.Repositories) error {
+	var recorder preview.Recorder
+
+	pingerOpts := []pings.Option{pings.WithOnlyUser(opts.userID)}
+
+	now, _ := opts.simulatedNow()
+	if !now.IsZero() {
+		pingerOpts = append(pingerOpts, pings.WithClock(clock.Fixed(now)))
+	}
+
+	// Nobody presses the buttons of the preview, so they don't need the real
+	// secret nor to be stored.
+	codec := tgram.NewCallbackCodec([]byte("preview"), tgram.NewMemoryCallbackStore())
+
+	pinger := pings.NewBackgroundPinger(w, g, preview.Telegram{}, codec, preview.Repositories(r, &recorder), pingerOpts...)
+
+	if err := pinger.MonitorWeather(ctx); err != nil {
+		return fmt.Errorf("monitor weather: %w", err)
+	}
+
+	if err := pinger.PingScheduledUsers(ctx); err != nil {
+		return fmt.Errorf("ping scheduled users: %w", err)
+	}
+
+	if err := pinger.SendBriefings(ctx); err != nil {
+		return fmt.Errorf("send briefings: %w", err)
+	}
+
+	return printPreview(os.Stdout, opts.format, recorder.Messages())
+}
+
+func printPreview(w io.Writer, format string, messages []*preview.Message) error {
+	if format == formatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+
+		if messages == nil {
+			messages = []*preview.Message{}
+		}
+
+		return enc.Encode(messages)
+	}
+
+	if len(messages) == 0 {
+		_, err := fmt.Fprintln(w, "No messages would be sent.")
+		return err
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Chat ID", "Deliver at", "Text", "Keyboard"})
+	table.SetAutoWrapText(false)
+	table.SetRowLine(true)
+
+	for _, m := range messages {
+		deliverAt := "now"
+		if m.DeliverAt != nil {
+			deliverAt = m.DeliverAt.Format(time.RFC3339)
+		}
+
+		var keyboard []string
+		if m.ReplyMarkup != nil {
+			for _, row := range m.ReplyMarkup.InlineKeyboard {
+				buttons := make([]string, len(row))
+				for i, b := range row {
+					buttons[i] = fmt.Sprintf("[%s → %s]", b.Text, b.CallbackData)
+				}
+
+				keyboard = append(keyboard, strings.Join(buttons, " "))
+			}
+		}
+
+		table.Append([]string{fmt.Sprint(m.ChatID), deliverAt, m.Text, strings.Join(keyboard, "\n")})
+	}
+
+	table.Render()
+	return nil
+}
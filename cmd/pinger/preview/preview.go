@@ -0,0 +1,161 @@
+// package preview wraps the pinger's dependencies so runs can be simulated
+// without messaging anybody or writing to the database. Reads still go to the
+// database, so the messages are exactly the ones a real run would send.
+package preview
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/manzanit0/weathry/cmd/bot/briefings"
+	"github.com/manzanit0/weathry/cmd/bot/location"
+	"github.com/manzanit0/weathry/cmd/bot/notifications"
+	"github.com/manzanit0/weathry/cmd/bot/rules"
+	"github.com/manzanit0/weathry/cmd/bot/schedules"
+	"github.com/manzanit0/weathry/cmd/bot/users"
+	"github.com/manzanit0/weathry/cmd/pinger/outbox"
+	"github.com/manzanit0/weathry/cmd/pinger/pings"
+	"github.com/manzanit0/weathry/cmd/pinger/snapshots"
+	"github.com/manzanit0/weathry/pkg/middleware"
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+// Message is a message a run would have sent.
+type Message struct {
+	ChatID      int64              `json:"chat_id"`
+	Text        string             `json:"text"`
+	ReplyMarkup *tgram.ReplyMarkup `json:"reply_markup,omitempty"`
+
+	// DeliverAt is set when the message would be held back until the end of
+	// the user's quiet hours.
+	DeliverAt *time.Time `json:"deliver_at,omitempty"`
+}
+
+// Recorder is an outbox which records the messages queued into it instead of
+// storing them, and never has any messages to deliver.
+type Recorder struct {
+	mu       sync.Mutex
+	messages []*Message
+}
+
+var _ outbox.Repository = (*Recorder)(nil)
+
+// Messages returns the messages recorded so far, in the order they were
+// queued.
+func (r *Recorder) Messages() []*Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Message(nil), r.messages...)
+}
+
+func (r *Recorder) Enqueue(_ context.Context, m *outbox.Message) error {
+	pm := Message{ChatID: int64(m.UserID), Text: m.Text, ReplyMarkup: m.ReplyMarkup}
+	if !m.NextAttemptAt.IsZero() {
+		deliverAt := m.NextAttemptAt
+		pm.DeliverAt = &deliverAt
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, &pm)
+	return nil
+}
+
+func (r *Recorder) ListDue(context.Context, time.Time, int) ([]*outbox.Message, error) {
+	return nil, nil
+}
+
+func (r *Recorder) MarkSent(context.Context, int64, time.Time) error         { return nil }
+func (r *Recorder) MarkRetry(context.Context, int64, time.Time, error) error { return nil }
+func (r *Recorder) MarkPartsSent(context.Context, int64, int) error          { return nil }
+func (r *Recorder) MarkFailed(context.Context, int64, error) error           { return nil }
+func (r *Recorder) DropPending(context.Context, int, string) (int64, error)  { return 0, nil }
+func (r *Recorder) ListMessages(context.Context, int, int) ([]*outbox.Message, error) {
+	return nil, nil
+}
+
+func (r *Recorder) CountByStatus(context.Context) (map[outbox.Status]int, error) {
+	return map[outbox.Status]int{}, nil
+}
+
+// Telegram is a Telegram client which refuses to send anything. Messages are
+// recorded by the Recorder before they would reach it, so it's only a safety
+// net.
+type Telegram struct{}
+
+var _ tgram.Client = Telegram{}
+
+func (Telegram) SendMessage(context.Context, tgram.SendMessageRequest) error {
+	return errors.New("preview: refusing to send a message to telegram")
+}
+
+func (Telegram) SendPhoto(context.Context, tgram.SendPhotoRequest) error {
+	return errors.New("preview: refusing to send a photo to telegram")
+}
+
+// Repositories returns the repositories with all their writes turned into
+// no-ops, and the outbox replaced by rec.
+func Repositories(r pings.Repositories, rec *Recorder) pings.Repositories {
+	return pings.Repositories{
+		Locations:     readOnlyLocations{r.Locations},
+		Rules:         readOnlyRules{r.Rules},
+		Notifications: readOnlyNotifications{r.Notifications},
+		Snapshots:     readOnlySnapshots{r.Snapshots},
+		Schedules:     readOnlySchedules{r.Schedules},
+		Briefings:     readOnlyBriefings{r.Briefings},
+		Users:         readOnlyUsers{r.Users},
+		Outbox:        rec,
+		Templates:     r.Templates,
+	}
+}
+
+type readOnlyLocations struct{ location.Repository }
+
+func (r readOnlyLocations) CreateLocation(_ context.Context, name string) (*location.Location, error) {
+	return &location.Location{Name: name}, nil
+}
+
+func (r readOnlyLocations) UpdateLocation(context.Context, *location.Location) error { return nil }
+func (r readOnlyLocations) SetHome(context.Context, int, *location.Location) error   { return nil }
+
+type readOnlyRules struct{ rules.Repository }
+
+func (r readOnlyRules) CreateRule(_ context.Context, userID int, expression string) (*rules.Rule, error) {
+	return &rules.Rule{UserID: userID, Expression: expression}, nil
+}
+
+func (r readOnlyRules) DeleteRule(context.Context, int, int64) (bool, error) { return false, nil }
+
+type readOnlyNotifications struct{ notifications.Repository }
+
+func (r readOnlyNotifications) CreateNotification(context.Context, *notifications.Notification) error {
+	return nil
+}
+
+type readOnlySnapshots struct{ snapshots.Repository }
+
+func (r readOnlySnapshots) SaveSnapshot(context.Context, *snapshots.Snapshot) error { return nil }
+
+type readOnlySchedules struct{ schedules.Repository }
+
+func (r readOnlySchedules) SetSchedule(context.Context, int, string) error    { return nil }
+func (r readOnlySchedules) DeleteSchedule(context.Context, int) (bool, error) { return false, nil }
+func (r readOnlySchedules) MarkRun(context.Context, int, time.Time) error     { return nil }
+
+type readOnlyBriefings struct{ briefings.Repository }
+
+func (r readOnlyBriefings) Subscribe(context.Context, int, int, int) error     { return nil }
+func (r readOnlyBriefings) SetPaused(context.Context, int, bool) (bool, error) { return false, nil }
+func (r readOnlyBriefings) Unsubscribe(context.Context, int) (bool, error)     { return false, nil }
+func (r readOnlyBriefings) MarkSent(context.Context, int, time.Time) error     { return nil }
+
+type readOnlyUsers struct{ users.Repository }
+
+func (r readOnlyUsers) CreateUser(context.Context, middleware.CreateUserPayload) error { return nil }
+func (r readOnlyUsers) SetTimezone(context.Context, int, string) error                 { return nil }
+func (r readOnlyUsers) SetQuietHours(context.Context, int, *users.QuietHours) error    { return nil }
+func (r readOnlyUsers) SetMutedUntil(context.Context, int, *time.Time) error           { return nil }
+func (r readOnlyUsers) SetDefaultView(context.Context, int, string) error              { return nil }
+func (r readOnlyUsers) Deactivate(context.Context, int) error                          { return nil }
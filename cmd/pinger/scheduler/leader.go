@@ -0,0 +1,123 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// PingerLockKey is the advisory lock replicas of the pinger compete for.
+const PingerLockKey int64 = 0x7765617468727901
+
+// DefaultCampaignInterval is how often a follower tries to become the leader,
+// and how often the leader checks it still holds the lock.
+const DefaultCampaignInterval = 15 * time.Second
+
+// PgElector elects a leader amongst replicas via a Postgres session-level
+// advisory lock. The lock is held for as long as the connection which
+// acquired it is alive, so if the leader dies, Postgres releases it and
+// another replica takes over.
+type PgElector struct {
+	db       *sql.DB
+	key      int64
+	interval time.Duration
+
+	mu     sync.RWMutex
+	conn   *sql.Conn
+	leader bool
+}
+
+var _ Elector = (*PgElector)(nil)
+
+func NewPgElector(db *sql.DB, key int64) *PgElector {
+	return &PgElector{db: db, key: key, interval: DefaultCampaignInterval}
+}
+
+func (e *PgElector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leader
+}
+
+// Campaign blocks trying to become the leader until ctx is done, at which
+// point it releases the lock if it holds it.
+func (e *PgElector) Campaign(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.tick(ctx); err != nil {
+			slog.Error("leader election", "error", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			e.resign()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *PgElector) tick(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.leader {
+		if err := e.conn.PingContext(ctx); err != nil {
+			slog.Warn("lost leadership, the connection holding the lock is gone", "error", err.Error())
+			e.leader = false
+			_ = e.conn.Close()
+			e.conn = nil
+		}
+
+		return nil
+	}
+
+	conn, err := e.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("get connection: %w", err)
+	}
+
+	var acquired bool
+	err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, e.key).Scan(&acquired)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("try advisory lock: %w", err)
+	}
+
+	if !acquired {
+		_ = conn.Close()
+		return nil
+	}
+
+	slog.Info("became the leader")
+	e.conn = conn
+	e.leader = true
+	return nil
+}
+
+func (e *PgElector) resign() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.leader {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := e.conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, e.key)
+	if err != nil {
+		slog.Error("release advisory lock", "error", err.Error())
+	}
+
+	_ = e.conn.Close()
+	e.conn = nil
+	e.leader = false
+	slog.Info("resigned leadership")
+}
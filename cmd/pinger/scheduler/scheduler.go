@@ -0,0 +1,184 @@
+// package scheduler runs jobs on cron schedules for as long as the process is
+// alive. When several replicas run at once, only the leader runs jobs.
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/clock"
+	"github.com/manzanit0/weathry/pkg/cron"
+	"github.com/manzanit0/weathry/pkg/tracing"
+)
+
+// DefaultShutdownTimeout is how long jobs which are running when the scheduler
+// is asked to stop are given to finish.
+const DefaultShutdownTimeout = 30 * time.Second
+
+type Job struct {
+	Name     string
+	Schedule *cron.Schedule
+
+	// Jitter delays each run by a random duration up to Jitter, so replicas
+	// and upstream APIs don't get hit all at the same second.
+	Jitter time.Duration
+	Run    func(context.Context) error
+}
+
+// Elector decides whether this replica should run jobs.
+type Elector interface {
+	IsLeader() bool
+}
+
+// AlwaysLeader is an Elector for when there's a single replica.
+type AlwaysLeader struct{}
+
+func (AlwaysLeader) IsLeader() bool { return true }
+
+type Scheduler struct {
+	jobs            []*Job
+	elector         Elector
+	location        *time.Location
+	clock           clock.Clock
+	shutdownTimeout time.Duration
+	onPanic         func(ctx context.Context, r any)
+}
+
+type Option func(*Scheduler)
+
+// WithLocation sets the location cron schedules are evaluated in. Defaults to
+// UTC.
+func WithLocation(loc *time.Location) Option {
+	return func(s *Scheduler) {
+		s.location = loc
+	}
+}
+
+// WithClock sets the clock the next run of every job is worked out from.
+// Defaults to the system clock.
+func WithClock(c clock.Clock) Option {
+	return func(s *Scheduler) {
+		s.clock = c
+	}
+}
+
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(s *Scheduler) {
+		s.shutdownTimeout = d
+	}
+}
+
+// WithPanicHandler sets a function which is called when a job panics. The
+// scheduler keeps running afterwards.
+func WithPanicHandler(f func(ctx context.Context, r any)) Option {
+	return func(s *Scheduler) {
+		s.onPanic = f
+	}
+}
+
+func New(e Elector, jobs []*Job, opts ...Option) *Scheduler {
+	s := Scheduler{
+		jobs:            jobs,
+		elector:         e,
+		location:        time.UTC,
+		clock:           clock.System{},
+		shutdownTimeout: DefaultShutdownTimeout,
+	}
+
+	for _, o := range opts {
+		o(&s)
+	}
+
+	return &s
+}
+
+// Run blocks running jobs until ctx is done. Then, it waits for the jobs which
+// are running to finish, for up to the shutdown timeout.
+func (s *Scheduler) Run(ctx context.Context) error {
+	// Jobs get their own context so they aren't interrupted as soon as we are
+	// asked to stop, but only once the shutdown timeout expires.
+	jobsCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+
+	stop := context.AfterFunc(ctx, func() {
+		slog.Info("scheduler stopping, waiting for running jobs to finish", "timeout", s.shutdownTimeout.String())
+		time.AfterFunc(s.shutdownTimeout, cancel)
+	})
+	defer stop()
+
+	var wg sync.WaitGroup
+	for _, j := range s.jobs {
+		wg.Add(1)
+		go func(j *Job) {
+			defer wg.Done()
+			s.loop(ctx, jobsCtx, j)
+		}(j)
+	}
+
+	wg.Wait()
+	slog.Info("scheduler stopped")
+	return nil
+}
+
+func (s *Scheduler) loop(ctx, jobsCtx context.Context, j *Job) {
+	logger := slog.Default().With("job", j.Name)
+
+	for {
+		now := s.clock.Now().In(s.location)
+		next := j.Schedule.Next(now)
+		if next.IsZero() {
+			logger.Error("job will never run again", "schedule", j.Schedule.String())
+			return
+		}
+
+		delay := next.Sub(now)
+		if j.Jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(j.Jitter)))
+		}
+
+		logger.Debug("job scheduled", "next_run", next.Format(time.RFC3339), "delay", delay.String())
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if !s.elector.IsLeader() {
+			logger.Info("skipping job run, this replica isn't the leader")
+			continue
+		}
+
+		s.run(jobsCtx, logger, j)
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, logger *slog.Logger, j *Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("job panicked", "panic", r)
+			if s.onPanic != nil {
+				s.onPanic(ctx, r)
+			}
+		}
+	}()
+
+	ctx, span := tracing.Start(ctx, "job "+j.Name)
+	defer span.End()
+
+	t0 := time.Now()
+	logger.InfoContext(ctx, "job started")
+
+	if err := j.Run(ctx); err != nil {
+		span.RecordError(err)
+		logger.ErrorContext(ctx, "job failed", "error", err.Error(), "duration_ms", time.Since(t0).Milliseconds())
+		return
+	}
+
+	logger.InfoContext(ctx, "job finished", "duration_ms", time.Since(t0).Milliseconds())
+}
@@ -0,0 +1,124 @@
+package snapshots
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/manzanit0/weathry/pkg/weather"
+)
+
+// Retention is how long snapshots are kept. We only ever diff against the
+// latest one, so there's no point in keeping them for long.
+const Retention = 7 * 24 * time.Hour
+
+// Snapshot is the forecast for a user's home as it was at a given time.
+type Snapshot struct {
+	ID           int64
+	UserID       int
+	LocationName string
+	Forecasts    []*weather.Forecast
+	CreatedAt    time.Time
+}
+
+type dbSnapshot struct {
+	ID           int64     `db:"id"`
+	UserID       string    `db:"user_id"`
+	LocationName string    `db:"location_name"`
+	Forecasts    []byte    `db:"forecasts"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+type Repository interface {
+	SaveSnapshot(ctx context.Context, s *Snapshot) error
+	GetLatest(ctx context.Context, userID int, locationName string) (*Snapshot, error)
+}
+
+type pgRepo struct {
+	db *sqlx.DB
+}
+
+var _ Repository = (*pgRepo)(nil)
+
+func NewPgRepository(db *sql.DB) *pgRepo {
+	return &pgRepo{db: sqlx.NewDb(db, "postgres")}
+}
+
+// SaveSnapshot stores the snapshot and deletes the user's snapshots which are
+// older than the retention period by the time it was taken.
+func (r *pgRepo) SaveSnapshot(ctx context.Context, s *Snapshot) error {
+	b, err := json.Marshal(s.Forecasts)
+	if err != nil {
+		return fmt.Errorf("marshal forecasts: %w", err)
+	}
+
+	query := `
+	INSERT INTO forecast_snapshots (user_id, location_name, forecasts)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at;`
+
+	var row struct {
+		ID        int64     `db:"id"`
+		CreatedAt time.Time `db:"created_at"`
+	}
+
+	err = r.db.GetContext(ctx, &row, query, fmt.Sprint(s.UserID), s.LocationName, b)
+	if err != nil {
+		return fmt.Errorf("insert snapshot: %w", err)
+	}
+
+	s.ID = row.ID
+	s.CreatedAt = row.CreatedAt
+
+	query = `DELETE FROM forecast_snapshots WHERE user_id = $1 AND created_at < $2`
+	_, err = r.db.ExecContext(ctx, query, fmt.Sprint(s.UserID), s.CreatedAt.Add(-Retention))
+	if err != nil {
+		return fmt.Errorf("delete old snapshots: %w", err)
+	}
+
+	return nil
+}
+
+func (r *pgRepo) GetLatest(ctx context.Context, userID int, locationName string) (*Snapshot, error) {
+	var s dbSnapshot
+
+	query := `
+	SELECT id, user_id, location_name, forecasts, created_at
+	FROM forecast_snapshots
+	WHERE user_id = $1 AND location_name = $2
+	ORDER BY created_at DESC
+	LIMIT 1;`
+
+	err := r.db.GetContext(ctx, &s, query, fmt.Sprint(userID), locationName)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("select snapshot: %w", err)
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	return s.Map()
+}
+
+func (s dbSnapshot) Map() (*Snapshot, error) {
+	var forecasts []*weather.Forecast
+	err := json.Unmarshal(s.Forecasts, &forecasts)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal forecasts: %w", err)
+	}
+
+	uid, _ := strconv.Atoi(s.UserID)
+	return &Snapshot{
+		ID:           s.ID,
+		UserID:       uid,
+		LocationName: s.LocationName,
+		Forecasts:    forecasts,
+		CreatedAt:    s.CreatedAt,
+	}, nil
+}
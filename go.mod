@@ -4,7 +4,6 @@ module github.com/manzanit0/weathry
 go 1.21
 
 require (
-	github.com/codingsince1985/geo-golang v1.8.3
 	github.com/gin-gonic/gin v1.7.7
 	github.com/jackc/pgx/v4 v4.16.1
 	github.com/jmoiron/sqlx v1.3.5
@@ -19,7 +18,6 @@ require (
 	github.com/jackc/pgproto3/v2 v2.3.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20200714003250-2b9c44734f2b // indirect
 	github.com/jackc/pgtype v1.11.0 // indirect
-	github.com/segmentio/ksuid v1.0.4 // indirect
 )
 
 require (
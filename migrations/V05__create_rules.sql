@@ -0,0 +1,22 @@
+BEGIN;
+
+CREATE TABLE rules (
+    id BIGSERIAL NOT NULL,
+    user_id TEXT NOT NULL,
+    expression TEXT NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (id),
+    FOREIGN KEY (user_id) REFERENCES users (chat_id)
+);
+
+CREATE INDEX rules_user_id ON rules (user_id);
+
+CREATE TRIGGER rules
+BEFORE UPDATE ON rules
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+
+COMMIT;
@@ -0,0 +1,24 @@
+BEGIN;
+
+CREATE TABLE notifications (
+    id BIGSERIAL NOT NULL,
+    user_id TEXT NOT NULL,
+
+    -- rule is what triggered the notification, for instance "rain" or
+    -- "rule:12" for user-defined rules.
+    rule TEXT NOT NULL,
+    target_time TIMESTAMPTZ NOT NULL,
+    temperature DOUBLE PRECISION NOT NULL,
+    hash TEXT NOT NULL,
+    message TEXT NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (id),
+    FOREIGN KEY (user_id) REFERENCES users (chat_id)
+);
+
+CREATE INDEX notifications_user_id_rule_created_at
+ON notifications (user_id, rule, created_at DESC);
+
+COMMIT;
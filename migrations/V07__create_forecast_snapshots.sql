@@ -0,0 +1,18 @@
+BEGIN;
+
+CREATE TABLE forecast_snapshots (
+    id BIGSERIAL NOT NULL,
+    user_id TEXT NOT NULL,
+    location_name CITEXT NOT NULL,
+    forecasts JSONB NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (id),
+    FOREIGN KEY (user_id) REFERENCES users (chat_id)
+);
+
+CREATE INDEX forecast_snapshots_user_id_created_at
+ON forecast_snapshots (user_id, created_at DESC);
+
+COMMIT;
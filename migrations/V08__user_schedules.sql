@@ -0,0 +1,26 @@
+BEGIN;
+
+ALTER TABLE users
+ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC';
+
+CREATE TABLE user_schedules (
+    user_id TEXT NOT NULL,
+
+    -- cron_expression is a 5-field cron expression evaluated in the user's
+    -- timezone.
+    cron_expression TEXT NOT NULL,
+    last_run_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (user_id),
+    FOREIGN KEY (user_id) REFERENCES users (chat_id)
+);
+
+CREATE TRIGGER user_schedules
+BEFORE UPDATE ON user_schedules
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+
+COMMIT;
@@ -0,0 +1,25 @@
+BEGIN;
+
+CREATE TABLE briefings (
+    user_id TEXT NOT NULL,
+
+    -- hour and minute are the time of the day the brief is sent at, in the
+    -- user's timezone.
+    hour SMALLINT NOT NULL CHECK (hour BETWEEN 0 AND 23),
+    minute SMALLINT NOT NULL CHECK (minute BETWEEN 0 AND 59),
+    paused BOOLEAN NOT NULL DEFAULT FALSE,
+    last_sent_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (user_id),
+    FOREIGN KEY (user_id) REFERENCES users (chat_id)
+);
+
+CREATE TRIGGER briefings
+BEFORE UPDATE ON briefings
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+
+COMMIT;
@@ -0,0 +1,24 @@
+BEGIN;
+
+-- quiet_hours_start and quiet_hours_end are minutes since midnight in the
+-- user's timezone. The window wraps around midnight when the start is after
+-- the end.
+ALTER TABLE users
+ADD COLUMN quiet_hours_start SMALLINT CHECK (quiet_hours_start BETWEEN 0 AND 1439),
+ADD COLUMN quiet_hours_end SMALLINT CHECK (quiet_hours_end BETWEEN 0 AND 1439),
+ADD COLUMN muted_until TIMESTAMPTZ;
+
+CREATE TABLE deferred_messages (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    text TEXT NOT NULL,
+    reply_markup JSONB,
+    deliver_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    FOREIGN KEY (user_id) REFERENCES users (chat_id)
+);
+
+CREATE INDEX deferred_messages_deliver_at_idx ON deferred_messages (deliver_at);
+
+COMMIT;
@@ -0,0 +1,49 @@
+-- The outbox replaces deferred_messages, which V10 created to hold back alerts
+-- during quiet hours. Once every message was to be queued and retried, rather
+-- than only those held back, one table for all of them made the other
+-- redundant: held back messages are pending messages which aren't due yet.
+-- Databases which ran V10 may have messages held back in it, so they're moved
+-- over at the end of this migration.
+
+BEGIN;
+
+ALTER TABLE users
+ADD COLUMN active BOOLEAN NOT NULL DEFAULT TRUE;
+
+CREATE TABLE outbox (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    text TEXT NOT NULL,
+    reply_markup JSONB,
+
+    -- status is one of pending, sent or failed. Failed messages won't be
+    -- retried.
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error TEXT,
+    sent_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    FOREIGN KEY (user_id) REFERENCES users (chat_id)
+);
+
+CREATE INDEX outbox_pending_idx ON outbox (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX outbox_user_id_idx ON outbox (user_id, created_at DESC);
+
+CREATE TRIGGER outbox
+BEFORE UPDATE ON outbox
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+
+-- Messages held back during quiet hours are now pending messages in the
+-- outbox which aren't due yet.
+INSERT INTO outbox (user_id, text, reply_markup, next_attempt_at, created_at)
+SELECT user_id, text, reply_markup, deliver_at, created_at
+FROM deferred_messages;
+
+DROP TABLE deferred_messages;
+
+COMMIT;
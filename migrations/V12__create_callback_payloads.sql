@@ -0,0 +1,20 @@
+BEGIN;
+
+-- callback_payloads keeps the payloads of inline buttons which don't fit in
+-- the 64 bytes Telegram allows for callback data. Buttons only carry the key.
+CREATE TABLE callback_payloads (
+    key TEXT NOT NULL,
+    payload TEXT NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (key)
+);
+
+CREATE TRIGGER callback_payloads
+BEFORE UPDATE ON callback_payloads
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+
+COMMIT;
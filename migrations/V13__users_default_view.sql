@@ -0,0 +1,8 @@
+BEGIN;
+
+-- default_view is how daily forecasts are shown to the user: a table with a
+-- row per day, or a line per day.
+ALTER TABLE users
+ADD COLUMN default_view TEXT NOT NULL DEFAULT 'table' CHECK (default_view IN ('table', 'compact'));
+
+COMMIT;
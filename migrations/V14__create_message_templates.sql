@@ -0,0 +1,26 @@
+BEGIN;
+
+-- message_templates keeps the templates users shape their alerts and briefs
+-- with. Templates without a user_id are global: they apply to everyone who
+-- hasn't got one of their own.
+CREATE TABLE message_templates (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT,
+    kind TEXT NOT NULL CHECK (kind IN ('alert', 'brief')),
+    body TEXT NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    FOREIGN KEY (user_id) REFERENCES users (chat_id)
+);
+
+CREATE UNIQUE INDEX message_templates_user_id_kind_idx ON message_templates (user_id, kind) WHERE user_id IS NOT NULL;
+CREATE UNIQUE INDEX message_templates_global_kind_idx ON message_templates (kind) WHERE user_id IS NULL;
+
+CREATE TRIGGER message_templates
+BEFORE UPDATE ON message_templates
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+
+COMMIT;
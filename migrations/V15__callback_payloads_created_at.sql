@@ -0,0 +1,6 @@
+BEGIN;
+
+-- Payloads older than the retention period are purged every night.
+CREATE INDEX callback_payloads_created_at_idx ON callback_payloads (created_at);
+
+COMMIT;
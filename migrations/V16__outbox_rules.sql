@@ -0,0 +1,8 @@
+BEGIN;
+
+-- rules are those of the alerts in the message, as a JSON array such as
+-- ["rain", "rule:12"], so deliveries can be counted by rule. Briefs have none.
+ALTER TABLE outbox
+ADD COLUMN rules JSONB;
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+-- outbox_message_id is the message the notification was queued in. Alerts are
+-- recorded as soon as they are queued, so they're only considered sent, i.e.
+-- listed in /history, once their message is, and they no longer suppress
+-- equivalent alerts if it fails. Notifications recorded before the outbox have
+-- none.
+ALTER TABLE notifications
+ADD COLUMN outbox_message_id BIGINT REFERENCES outbox (id);
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+-- parts_sent is how many parts of a message too long for a single Telegram
+-- message the user already got, so retries resume from the next one.
+ALTER TABLE outbox
+ADD COLUMN parts_sent INTEGER NOT NULL DEFAULT 0;
+
+COMMIT;
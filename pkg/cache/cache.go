@@ -0,0 +1,125 @@
+// Package cache keeps values in memory for a while, so the same questions
+// aren't asked of upstream APIs over and over. Lookups are counted in the
+// weathry_cache_requests_total metric.
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/clock"
+	"github.com/manzanit0/weathry/pkg/metrics"
+)
+
+var requestsTotal = metrics.NewCounter(
+	"weathry_cache_requests_total",
+	"Cache lookups, by cache and whether the value was there (hit) or not (miss).",
+	"cache", "result",
+)
+
+// DefaultSize is how many values a cache keeps unless told otherwise.
+const DefaultSize = 1000
+
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// Cache keeps up to a number of values, each for as long as its TTL. It is
+// safe for concurrent use.
+type Cache[V any] struct {
+	name  string
+	ttl   time.Duration
+	size  int
+	clock clock.Clock
+
+	mu      sync.Mutex
+	entries map[string]entry[V]
+}
+
+type Option func(*options)
+
+type options struct {
+	size  int
+	clock clock.Clock
+}
+
+// WithSize sets how many values the cache keeps. Defaults to DefaultSize.
+func WithSize(n int) Option {
+	return func(o *options) {
+		o.size = n
+	}
+}
+
+// WithClock sets the clock values expire by. Defaults to the system clock.
+func WithClock(c clock.Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// New returns an empty cache, which is labelled name in metrics.
+func New[V any](name string, ttl time.Duration, opts ...Option) *Cache[V] {
+	o := options{size: DefaultSize, clock: clock.System{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return &Cache[V]{
+		name:    name,
+		ttl:     ttl,
+		size:    o.size,
+		clock:   o.clock,
+		entries: map[string]entry[V]{},
+	}
+}
+
+// Get returns the value of key, if it's there and hasn't expired.
+func (c *Cache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if ok && !c.clock.Now().Before(e.expiresAt) {
+		delete(c.entries, key)
+		ok = false
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		requestsTotal.Inc(c.name, "miss")
+		var zero V
+		return zero, false
+	}
+
+	requestsTotal.Inc(c.name, "hit")
+	return e.value, true
+}
+
+// Set keeps the value of key for the TTL of the cache. When the cache is full,
+// expired values are dropped, or any one if none has.
+func (c *Cache[V]) Set(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.size {
+		c.evict(now)
+	}
+
+	c.entries[key] = entry[V]{value: value, expiresAt: now.Add(c.ttl)}
+}
+
+func (c *Cache[V]) evict(now time.Time) {
+	for k, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+
+	for k := range c.entries {
+		if len(c.entries) < c.size {
+			return
+		}
+
+		delete(c.entries, k)
+	}
+}
@@ -0,0 +1,90 @@
+package cache_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/cache"
+	"github.com/manzanit0/weathry/pkg/metrics"
+)
+
+type manualClock struct{ now time.Time }
+
+func (c *manualClock) Now() time.Time { return c.now }
+
+func TestGetReturnsValuesUntilTheyExpire(t *testing.T) {
+	clk := &manualClock{now: time.Date(2022, time.August, 1, 7, 0, 0, 0, time.UTC)}
+	c := cache.New[int]("expiry", time.Minute, cache.WithClock(clk))
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatalf("expected an empty cache")
+	}
+
+	c.Set("a", 1)
+	clk.now = clk.now.Add(59 * time.Second)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("got %d, %t, expected 1, true", v, ok)
+	}
+
+	clk.now = clk.now.Add(time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("expected the value to have expired")
+	}
+}
+
+func TestSetEvictsWhenFull(t *testing.T) {
+	clk := &manualClock{now: time.Date(2022, time.August, 1, 7, 0, 0, 0, time.UTC)}
+	c := cache.New[int]("eviction", time.Minute, cache.WithClock(clk), cache.WithSize(2))
+
+	c.Set("a", 1)
+	clk.now = clk.now.Add(30 * time.Second)
+	c.Set("b", 2)
+	clk.now = clk.now.Add(30 * time.Second)
+
+	// "a" has expired by now, so it makes room for "c".
+	c.Set("c", 3)
+	if _, ok := c.Get("b"); !ok {
+		t.Errorf("expected b to be kept")
+	}
+
+	if _, ok := c.Get("c"); !ok {
+		t.Errorf("expected c to be kept")
+	}
+
+	// Nothing has expired now, so either "b" or "c" makes room for "d".
+	c.Set("d", 4)
+	kept := 0
+	for _, k := range []string{"b", "c", "d"} {
+		if _, ok := c.Get(k); ok {
+			kept++
+		}
+	}
+
+	if kept != 2 {
+		t.Errorf("got %d values, expected 2", kept)
+	}
+}
+
+func TestLookupsAreCounted(t *testing.T) {
+	c := cache.New[string]("counted", time.Minute)
+	c.Get("a")
+	c.Set("a", "x")
+	c.Get("a")
+	c.Get("a")
+
+	var b bytes.Buffer
+	if err := metrics.Default.Write(&b); err != nil {
+		t.Fatalf("write metrics: %s", err.Error())
+	}
+
+	for _, want := range []string{
+		`weathry_cache_requests_total{cache="counted",result="hit"} 2`,
+		`weathry_cache_requests_total{cache="counted",result="miss"} 1`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,194 @@
+// Package chart draws forecasts as PNG images, for where the text tables
+// don't fit, such as phones.
+//
+// It only relies on the standard library and a tiny built-in font, so it runs
+// headless and without cgo.
+package chart
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/weather"
+)
+
+// Size of the images, in pixels.
+const (
+	Width  = 800
+	Height = 480
+)
+
+// Layout of the images: the plot with the temperatures and chances of
+// precipitation, and below it a row of icons and another of labels.
+const (
+	marginLeft   = 80
+	marginRight  = 80
+	marginTop    = 30
+	plotBottom   = Height - 110
+	iconRow      = plotBottom + 35
+	labelRow     = plotBottom + 80
+	textScale    = 3
+	lineWidth    = 4
+	pointRadius  = 5
+	maxTempTicks = 6
+)
+
+var (
+	colorBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	colorGrid       = color.RGBA{0xe5, 0xe7, 0xeb, 0xff}
+	colorAxis       = color.RGBA{0x9c, 0xa3, 0xaf, 0xff}
+	colorText       = color.RGBA{0x37, 0x41, 0x51, 0xff}
+	colorBars       = color.RGBA{0xbf, 0xdb, 0xfe, 0xff}
+	colorMaximum    = color.RGBA{0xef, 0x44, 0x44, 0xff}
+	colorMinimum    = color.RGBA{0x3b, 0x82, 0xf6, 0xff}
+)
+
+var ErrNoForecasts = errors.New("no forecasts to draw")
+
+type options struct {
+	location *time.Location
+	daily    bool
+}
+
+type Option func(*options)
+
+// InLocation labels the forecasts with their time in the given location.
+// Defaults to UTC.
+func InLocation(loc *time.Location) Option {
+	return func(o *options) {
+		if loc != nil {
+			o.location = loc
+		}
+	}
+}
+
+// Daily draws the forecasts as daily ones: labelled by date and with both the
+// minimum and maximum temperatures. Otherwise they're drawn as hourly ones,
+// labelled by time and with a single temperature.
+func Daily() Option {
+	return func(o *options) {
+		o.daily = true
+	}
+}
+
+// Render draws the forecasts and writes them to w as a PNG.
+func Render(w io.Writer, forecasts []*weather.Forecast, opts ...Option) error {
+	img, err := Draw(forecasts, opts...)
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(w, img); err != nil {
+		return fmt.Errorf("encode png: %w", err)
+	}
+
+	return nil
+}
+
+// Draw draws the temperatures of the forecasts as lines, their chances of
+// precipitation as bars and their conditions as icons along the x-axis.
+func Draw(forecasts []*weather.Forecast, opts ...Option) (*image.RGBA, error) {
+	if len(forecasts) == 0 {
+		return nil, ErrNoForecasts
+	}
+
+	o := options{location: time.UTC}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	fillRect(img, 0, 0, Width, Height, colorBackground)
+
+	slot := float64(Width-marginLeft-marginRight) / float64(len(forecasts))
+	x := func(i int) int { return marginLeft + int(slot*(float64(i)+0.5)) }
+
+	lo, hi, step := temperatureScale(forecasts, o.daily)
+	y := func(temp float64) int {
+		return plotBottom - int(math.Round((temp-lo)/(hi-lo)*float64(plotBottom-marginTop)))
+	}
+
+	for t := lo; t <= hi; t += step {
+		fillRect(img, marginLeft, y(t), Width-marginLeft-marginRight, 1, colorGrid)
+		drawText(img, marginLeft-12, y(t), fmt.Sprintf("%.0f°", t), textScale, alignRight, colorText)
+	}
+
+	for _, p := range []float64{0, 0.5, 1} {
+		py := plotBottom - int(p*float64(plotBottom-marginTop))
+		drawText(img, Width-marginRight+12, py, fmt.Sprintf("%.0f%%", p*100), textScale, alignLeft, colorRain)
+	}
+
+	barWidth := max(2, int(slot*0.6))
+	for i, f := range forecasts {
+		h := int(math.Round(f.PrecipitationProbability * float64(plotBottom-marginTop)))
+		fillRect(img, x(i)-barWidth/2, plotBottom-h, barWidth, h, colorBars)
+	}
+
+	fillRect(img, marginLeft, plotBottom, Width-marginLeft-marginRight, 2, colorAxis)
+
+	if o.daily {
+		drawSeries(img, forecasts, x, y, func(f *weather.Forecast) float64 { return f.MinimumTemperature }, colorMinimum)
+		drawSeries(img, forecasts, x, y, func(f *weather.Forecast) float64 { return f.MaximumTemperature }, colorMaximum)
+	} else {
+		drawSeries(img, forecasts, x, y, func(f *weather.Forecast) float64 { return f.MinimumTemperature }, colorMaximum)
+	}
+
+	layout := "15:04"
+	if o.daily {
+		layout = "02/01"
+	}
+
+	// Icons and labels are skipped evenly when they don't fit in their slot.
+	every := int(math.Ceil(float64(max(textWidth(layout, textScale), iconSize)+12) / slot))
+	for i, f := range forecasts {
+		if i%every != 0 {
+			continue
+		}
+
+		drawIcon(img, x(i), iconRow, f.Condition)
+		drawText(img, x(i), labelRow, f.Time().In(o.location).Format(layout), textScale, alignCenter, colorText)
+	}
+
+	return img, nil
+}
+
+func drawSeries(img *image.RGBA, forecasts []*weather.Forecast, x func(int) int, y func(float64) int, temp func(*weather.Forecast) float64, c color.Color) {
+	for i := 1; i < len(forecasts); i++ {
+		drawLine(img, x(i-1), y(temp(forecasts[i-1])), x(i), y(temp(forecasts[i])), lineWidth, c)
+	}
+
+	for i, f := range forecasts {
+		fillCircle(img, x(i), y(temp(f)), pointRadius, c)
+	}
+}
+
+// temperatureScale returns the range of temperatures of the y-axis, rounded
+// to a step which leaves no more than maxTempTicks ticks.
+func temperatureScale(forecasts []*weather.Forecast, daily bool) (lo, hi, step float64) {
+	lo, hi = math.Inf(1), math.Inf(-1)
+	for _, f := range forecasts {
+		lo, hi = min(lo, f.MinimumTemperature), max(hi, f.MinimumTemperature)
+		if daily {
+			hi = max(hi, f.MaximumTemperature)
+		}
+	}
+
+	for _, step = range []float64{1, 2, 5, 10, 20} {
+		if math.Ceil(hi/step)-math.Floor(lo/step) <= maxTempTicks-1 {
+			break
+		}
+	}
+
+	lo, hi = math.Floor(lo/step)*step, math.Ceil(hi/step)*step
+	if lo == hi {
+		hi += step
+	}
+
+	return lo, hi, step
+}
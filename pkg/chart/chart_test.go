@@ -0,0 +1,144 @@
+package chart_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/manzanit0/weathry/pkg/chart"
+	"github.com/manzanit0/weathry/pkg/weather"
+)
+
+// Run with -update to regenerate the golden hashes, along with the images
+// they belong to so they can be looked at.
+var update = flag.Bool("update", false, "update the golden hashes and images in testdata")
+
+func hourlyForecasts() []*weather.Forecast {
+	conditions := []string{"clear", "clear", "clouds", "clouds", "rain", "rain", "thunderstorm", "drizzle", "clouds", "atmosphere", "clear", "clear", "clouds", "snow", "snow", "clear"}
+	start := time.Date(2022, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+	var forecasts []*weather.Forecast
+	for i, c := range conditions {
+		temp := 18 + 6*float64(i%8)/7 - float64(i/8)*3
+		forecasts = append(forecasts, &weather.Forecast{
+			Condition:                c,
+			MinimumTemperature:       temp,
+			MaximumTemperature:       temp + 1,
+			DateTimeTS:               int(start.Add(time.Duration(i) * 3 * time.Hour).Unix()),
+			PrecipitationProbability: float64(i%5) / 4,
+		})
+	}
+
+	return forecasts
+}
+
+func dailyForecasts() []*weather.Forecast {
+	conditions := []string{"clear", "clouds", "rain", "thunderstorm", "snow", "atmosphere", "drizzle"}
+	start := time.Date(2022, time.December, 28, 12, 0, 0, 0, time.UTC)
+
+	var forecasts []*weather.Forecast
+	for i, c := range conditions {
+		forecasts = append(forecasts, &weather.Forecast{
+			Condition:                c,
+			MinimumTemperature:       -4 + float64(i),
+			MaximumTemperature:       3 + float64(i*i)/3,
+			DateTimeTS:               int(start.AddDate(0, 0, i).Unix()),
+			PrecipitationProbability: float64(i) / 6,
+		})
+	}
+
+	return forecasts
+}
+
+func TestDraw(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Fatalf("load location: %s", err.Error())
+	}
+
+	testCases := []struct {
+		desc      string
+		golden    string
+		forecasts []*weather.Forecast
+		opts      []chart.Option
+	}{
+		{desc: "hourly", golden: "hourly", forecasts: hourlyForecasts()},
+		{desc: "hourly in another timezone", golden: "hourly_madrid", forecasts: hourlyForecasts(), opts: []chart.Option{chart.InLocation(madrid)}},
+		{desc: "daily", golden: "daily", forecasts: dailyForecasts(), opts: []chart.Option{chart.Daily()}},
+		{desc: "single forecast", golden: "single", forecasts: hourlyForecasts()[:1]},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			img, err := chart.Draw(tC.forecasts, tC.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			if img.Bounds().Dx() != chart.Width || img.Bounds().Dy() != chart.Height {
+				t.Errorf("got a %s image, expected %dx%d", img.Bounds().Size(), chart.Width, chart.Height)
+			}
+
+			// Pixels are hashed rather than the PNG, since the encoder might
+			// compress differently across Go versions.
+			sum := sha256.Sum256(img.Pix)
+			got := hex.EncodeToString(sum[:])
+			path := filepath.Join("testdata", tC.golden+".sha256")
+
+			if *update {
+				var b bytes.Buffer
+				if err := png.Encode(&b, img); err != nil {
+					t.Fatalf("encode png: %s", err.Error())
+				}
+
+				if err := os.WriteFile(filepath.Join("testdata", tC.golden+".png"), b.Bytes(), 0o644); err != nil {
+					t.Fatalf("write image: %s", err.Error())
+				}
+
+				if err := os.WriteFile(path, []byte(got+"\n"), 0o644); err != nil {
+					t.Fatalf("write hash: %s", err.Error())
+				}
+			}
+
+			expected, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read golden hash: %s", err.Error())
+			}
+
+			if got != strings.TrimSpace(string(expected)) {
+				t.Errorf("got hash %s, expected %s. Run the tests with -update and check the images in testdata if the change is intended", got, strings.TrimSpace(string(expected)))
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	var b bytes.Buffer
+	if err := chart.Render(&b, dailyForecasts(), chart.Daily()); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	img, err := png.Decode(&b)
+	if err != nil {
+		t.Fatalf("invalid png: %s", err.Error())
+	}
+
+	if img.Bounds().Dx() != chart.Width || img.Bounds().Dy() != chart.Height {
+		t.Errorf("got a %s image, expected %dx%d", img.Bounds().Size(), chart.Width, chart.Height)
+	}
+}
+
+func TestDrawWithoutForecasts(t *testing.T) {
+	if _, err := chart.Draw(nil); !errors.Is(err, chart.ErrNoForecasts) {
+		t.Errorf("got %v, expected %v", err, chart.ErrNoForecasts)
+	}
+}
@@ -0,0 +1,69 @@
+package chart
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// The primitives below don't anti-alias, so the same forecasts always draw
+// the very same pixels.
+
+func fillRect(img *image.RGBA, x, y, w, h int, c color.Color) {
+	r := image.Rect(x, y, x+w, y+h).Intersect(img.Bounds())
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func fillCircle(img *image.RGBA, cx, cy, radius int, c color.Color) {
+	for y := -radius; y <= radius; y++ {
+		for x := -radius; x <= radius; x++ {
+			if x*x+y*y <= radius*radius {
+				img.Set(cx+x, cy+y, c)
+			}
+		}
+	}
+}
+
+// drawLine draws a line width pixels thick, with Bresenham's algorithm.
+func drawLine(img *image.RGBA, x0, y0, x1, y1, width int, c color.Color) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := sign(x1-x0), sign(y1-y0)
+	err := dx + dy
+
+	for {
+		fillRect(img, x0-width/2, y0-width/2, width, width, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	default:
+		return 0
+	}
+}
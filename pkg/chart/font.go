@@ -0,0 +1,76 @@
+package chart
+
+import (
+	"image"
+	"image/color"
+)
+
+// glyphs is a 3x5 pixel font with just what the labels of the charts need.
+// Runes it doesn't have are drawn as blanks.
+var glyphs = map[rune][glyphHeight]string{
+	'0': {"###", "#.#", "#.#", "#.#", "###"},
+	'1': {".#.", "##.", ".#.", ".#.", "###"},
+	'2': {"###", "..#", "###", "#..", "###"},
+	'3': {"###", "..#", "###", "..#", "###"},
+	'4': {"#.#", "#.#", "###", "..#", "..#"},
+	'5': {"###", "#..", "###", "..#", "###"},
+	'6': {"###", "#..", "###", "#.#", "###"},
+	'7': {"###", "..#", "..#", ".#.", ".#."},
+	'8': {"###", "#.#", "###", "#.#", "###"},
+	'9': {"###", "#.#", "###", "..#", "###"},
+	'-': {"...", "...", "###", "...", "..."},
+	':': {"...", ".#.", "...", ".#.", "..."},
+	'/': {"..#", "..#", ".#.", "#..", "#.."},
+	'%': {"#.#", "..#", ".#.", "#..", "#.#"},
+	'°': {"###", "#.#", "###", "...", "..."},
+	'.': {"...", "...", "...", "...", ".#."},
+}
+
+const (
+	glyphWidth  = 3
+	glyphHeight = 5
+)
+
+type align int
+
+const (
+	alignLeft align = iota
+	alignCenter
+	alignRight
+)
+
+// textWidth is how many pixels wide s is at the given scale, with a column of
+// space between glyphs.
+func textWidth(s string, scale int) int {
+	n := len([]rune(s))
+	if n == 0 {
+		return 0
+	}
+
+	return (n*(glyphWidth+1) - 1) * scale
+}
+
+// drawText draws s with its vertical middle at y. x is the left edge, the
+// middle or the right edge depending on a.
+func drawText(img *image.RGBA, x, y int, s string, scale int, a align, c color.Color) {
+	switch a {
+	case alignCenter:
+		x -= textWidth(s, scale) / 2
+	case alignRight:
+		x -= textWidth(s, scale)
+	}
+
+	y -= glyphHeight * scale / 2
+
+	for _, r := range s {
+		for row, line := range glyphs[r] {
+			for col, px := range line {
+				if px == '#' {
+					fillRect(img, x+col*scale, y+row*scale, scale, scale, c)
+				}
+			}
+		}
+
+		x += (glyphWidth + 1) * scale
+	}
+}
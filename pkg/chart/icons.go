@@ -0,0 +1,60 @@
+package chart
+
+import (
+	"image"
+	"image/color"
+)
+
+var (
+	colorSun       = color.RGBA{0xfa, 0xcc, 0x15, 0xff}
+	colorCloud     = color.RGBA{0x9c, 0xa3, 0xaf, 0xff}
+	colorDarkCloud = color.RGBA{0x4b, 0x55, 0x63, 0xff}
+	colorRain      = color.RGBA{0x25, 0x63, 0xeb, 0xff}
+	colorSnow      = color.RGBA{0x7d, 0xd3, 0xfc, 0xff}
+)
+
+// iconSize is roughly how many pixels wide and tall icons are.
+const iconSize = 28
+
+// drawIcon draws the icon of the condition centred at x and y. Conditions are
+// those of weather.Forecast.
+func drawIcon(img *image.RGBA, x, y int, condition string) {
+	switch condition {
+	case "clear":
+		fillCircle(img, x, y, 9, colorSun)
+
+	case "rain", "drizzle":
+		drawCloud(img, x, y-4, colorCloud)
+		for _, dx := range []int{-6, 0, 6} {
+			drawLine(img, x+dx, y+7, x+dx-2, y+12, 2, colorRain)
+		}
+
+	case "thunderstorm":
+		drawCloud(img, x, y-4, colorDarkCloud)
+		drawLine(img, x+1, y+5, x-3, y+10, 2, colorSun)
+		drawLine(img, x-3, y+10, x+3, y+10, 2, colorSun)
+		drawLine(img, x+3, y+10, x-1, y+14, 2, colorSun)
+
+	case "snow":
+		drawCloud(img, x, y-4, colorCloud)
+		for _, dx := range []int{-6, 0, 6} {
+			fillCircle(img, x+dx, y+10, 2, colorSnow)
+		}
+
+	case "atmosphere":
+		for _, dy := range []int{-6, 0, 6} {
+			drawLine(img, x-11, y+dy, x+11, y+dy, 3, colorCloud)
+		}
+
+	default:
+		drawCloud(img, x, y, colorCloud)
+	}
+}
+
+// drawCloud draws a cloud with its middle at x and y.
+func drawCloud(img *image.RGBA, x, y int, c color.Color) {
+	fillCircle(img, x-6, y+1, 6, c)
+	fillCircle(img, x+1, y-3, 8, c)
+	fillCircle(img, x+8, y+2, 5, c)
+	fillRect(img, x-6, y+1, 14, 7, c)
+}
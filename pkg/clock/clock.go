@@ -0,0 +1,23 @@
+// Package clock abstracts telling the time, so logic which depends on it can
+// be tested at any given time rather than whenever the tests happen to run.
+package clock
+
+import "time"
+
+type Clock interface {
+	Now() time.Time
+}
+
+// System is the clock of the machine.
+type System struct{}
+
+func (System) Now() time.Time {
+	return time.Now()
+}
+
+// Fixed is a clock which is always at the same time.
+type Fixed time.Time
+
+func (f Fixed) Now() time.Time {
+	return time.Time(f)
+}
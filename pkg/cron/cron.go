@@ -0,0 +1,263 @@
+// package cron parses standard 5-field cron expressions, such as "0 8,19 * * *",
+// and calculates when they are due next.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLookahead bounds how far in the future Next searches. Expressions such as
+// "0 0 30 2 *" never match.
+const maxLookahead = 5 * 366 * 24 * time.Hour
+
+type fieldSpec struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteSpec = fieldSpec{name: "minute", min: 0, max: 59}
+	hourSpec   = fieldSpec{name: "hour", min: 0, max: 23}
+	domSpec    = fieldSpec{name: "day of month", min: 1, max: 31}
+	monthSpec  = fieldSpec{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Both 0 and 7 are Sunday.
+	dowSpec = fieldSpec{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var descriptors = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+	"@yearly":  "0 0 1 1 *",
+}
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	expr string
+
+	minute, hour, dom, month, dow uint64
+
+	// domStar and dowStar track whether the day fields were "*". When both
+	// day fields are restricted, a day matches if either of them do.
+	domStar, dowStar bool
+}
+
+// Parse parses a cron expression with the fields: minute, hour, day of month,
+// month and day of week. Fields support "*", lists ("1,2"), ranges ("1-5"),
+// steps ("*/15", "8-18/2") and, for months and days of the week, names ("jan",
+// "mon-fri"). The descriptors @hourly, @daily, @weekly, @monthly and @yearly
+// are supported too.
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+
+	normalised := expr
+	if d, ok := descriptors[strings.ToLower(expr)]; ok {
+		normalised = d
+	}
+
+	fields := strings.Fields(normalised)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields (minute, hour, day of month, month, day of week), got %d", len(fields))
+	}
+
+	s := Schedule{expr: expr}
+
+	var err error
+	if s.minute, err = parseField(fields[0], minuteSpec); err != nil {
+		return nil, err
+	}
+
+	if s.hour, err = parseField(fields[1], hourSpec); err != nil {
+		return nil, err
+	}
+
+	if s.dom, err = parseField(fields[2], domSpec); err != nil {
+		return nil, err
+	}
+
+	if s.month, err = parseField(fields[3], monthSpec); err != nil {
+		return nil, err
+	}
+
+	if s.dow, err = parseField(fields[4], dowSpec); err != nil {
+		return nil, err
+	}
+
+	// Sunday can be either 0 or 7.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+
+	s.domStar = strings.HasPrefix(fields[2], "*")
+	s.dowStar = strings.HasPrefix(fields[4], "*")
+
+	return &s, nil
+}
+
+// MustParse is like Parse but panics if the expression is invalid. It's meant
+// for expressions known at compile time.
+func MustParse(expr string) *Schedule {
+	s, err := Parse(expr)
+	if err != nil {
+		panic(fmt.Sprintf("cron: parse %q: %s", expr, err.Error()))
+	}
+
+	return s
+}
+
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// Next returns the first time after t which matches the schedule, in t's
+// location. It returns the zero time if there's no such time within the next
+// five years.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	limit := t.Add(maxLookahead)
+
+	// Start at the beginning of the next minute.
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+
+			// When clocks go back, the next hour in the wall clock might be
+			// the same instant we are already at.
+			if !next.After(t) {
+				next = t.Add(time.Hour).Truncate(time.Minute)
+			}
+
+			t = next
+			continue
+		}
+
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s *Schedule) matchesDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+
+	return dom || dow
+}
+
+func parseField(field string, spec fieldSpec) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		b, err := parsePart(part, spec)
+		if err != nil {
+			return 0, err
+		}
+
+		bits |= b
+	}
+
+	return bits, nil
+}
+
+func parsePart(part string, spec fieldSpec) (uint64, error) {
+	rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+	step := 1
+	if hasStep {
+		var err error
+		step, err = strconv.Atoi(stepPart)
+		if err != nil || step <= 0 {
+			return 0, fmt.Errorf("invalid step %q in %s field", stepPart, spec.name)
+		}
+	}
+
+	var low, high int
+	switch {
+	case rangePart == "*":
+		low, high = spec.min, spec.max
+
+	case strings.Contains(rangePart, "-"):
+		lowPart, highPart, _ := strings.Cut(rangePart, "-")
+
+		var err error
+		if low, err = parseValue(lowPart, spec); err != nil {
+			return 0, err
+		}
+
+		if high, err = parseValue(highPart, spec); err != nil {
+			return 0, err
+		}
+
+		if low > high {
+			return 0, fmt.Errorf("invalid range %q in %s field, the start is after the end", rangePart, spec.name)
+		}
+
+	default:
+		var err error
+		if low, err = parseValue(rangePart, spec); err != nil {
+			return 0, err
+		}
+
+		high = low
+
+		// "5/15" means from 5 to the end in steps of 15.
+		if hasStep {
+			high = spec.max
+		}
+	}
+
+	var bits uint64
+	for i := low; i <= high; i += step {
+		bits |= 1 << uint(i)
+	}
+
+	return bits, nil
+}
+
+func parseValue(s string, spec fieldSpec) (int, error) {
+	if v, ok := spec.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q in %s field", s, spec.name)
+	}
+
+	if v < spec.min || v > spec.max {
+		return 0, fmt.Errorf("value %d out of range in %s field, it must be between %d and %d", v, spec.name, spec.min, spec.max)
+	}
+
+	return v, nil
+}
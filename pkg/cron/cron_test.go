@@ -0,0 +1,154 @@
+package cron_test
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/manzanit0/weathry/pkg/cron"
+)
+
+func TestNext(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Fatalf("load location: %s", err.Error())
+	}
+
+	testCases := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{
+			expr: "0 8,19 * * *",
+			from: time.Date(2022, time.August, 1, 7, 59, 0, 0, time.UTC),
+			want: time.Date(2022, time.August, 1, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			expr: "0 8,19 * * *",
+			from: time.Date(2022, time.August, 1, 8, 0, 0, 0, time.UTC),
+			want: time.Date(2022, time.August, 1, 19, 0, 0, 0, time.UTC),
+		},
+		{
+			expr: "0 8,19 * * *",
+			from: time.Date(2022, time.August, 1, 19, 30, 15, 0, time.UTC),
+			want: time.Date(2022, time.August, 2, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			expr: "*/15 * * * *",
+			from: time.Date(2022, time.August, 1, 10, 16, 0, 0, time.UTC),
+			want: time.Date(2022, time.August, 1, 10, 30, 0, 0, time.UTC),
+		},
+		{
+			expr: "30 7 * * mon-fri",
+			from: time.Date(2022, time.August, 5, 8, 0, 0, 0, time.UTC),  // Friday
+			want: time.Date(2022, time.August, 8, 7, 30, 0, 0, time.UTC), // Monday
+		},
+		{
+			expr: "0 9 * * 7",
+			from: time.Date(2022, time.August, 1, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2022, time.August, 7, 9, 0, 0, 0, time.UTC), // Sunday
+		},
+		{
+			expr: "0 0 1 jan *",
+			from: time.Date(2022, time.August, 1, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			expr: "0 0 29 2 *",
+			from: time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			// Either the 13th or a Friday, since both day fields are restricted.
+			expr: "0 12 13 * 5",
+			from: time.Date(2022, time.August, 6, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2022, time.August, 12, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			expr: "@daily",
+			from: time.Date(2022, time.August, 1, 10, 0, 0, 0, time.UTC),
+			want: time.Date(2022, time.August, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			expr: "5-10/5 8-18/10 * * *",
+			from: time.Date(2022, time.August, 1, 8, 10, 0, 0, time.UTC),
+			want: time.Date(2022, time.August, 1, 18, 5, 0, 0, time.UTC),
+		},
+		{
+			// Local times are kept across DST: 07:30 in Madrid is 05:30 UTC in
+			// summer and 06:30 UTC in winter.
+			expr: "30 7 * * *",
+			from: time.Date(2022, time.October, 29, 8, 0, 0, 0, madrid),
+			want: time.Date(2022, time.October, 30, 7, 30, 0, 0, madrid),
+		},
+		{
+			// 02:30 doesn't exist in Madrid on the 27th of March 2022.
+			expr: "30 2 * * *",
+			from: time.Date(2022, time.March, 27, 0, 0, 0, 0, madrid),
+			want: time.Date(2022, time.March, 28, 2, 30, 0, 0, madrid),
+		},
+		{
+			expr: "0 0 30 2 *",
+			from: time.Date(2022, time.August, 1, 0, 0, 0, 0, time.UTC),
+			want: time.Time{},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.expr, func(t *testing.T) {
+			s, err := cron.Parse(tC.expr)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			got := s.Next(tC.from)
+			if !got.Equal(tC.want) {
+				t.Errorf("got %s, expected %s", got, tC.want)
+			}
+		})
+	}
+}
+
+func TestNextAcrossDSTKeepsWallClock(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Fatalf("load location: %s", err.Error())
+	}
+
+	s := cron.MustParse("30 7 * * *")
+
+	summer := s.Next(time.Date(2022, time.October, 29, 0, 0, 0, 0, madrid))
+	winter := s.Next(summer)
+
+	if summer.UTC().Hour() != 5 || winter.UTC().Hour() != 6 {
+		t.Errorf("got %s and %s in UTC, expected 05:30 and 06:30", summer.UTC(), winter.UTC())
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"10-5 * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"@never",
+	}
+
+	for _, expr := range testCases {
+		t.Run(expr, func(t *testing.T) {
+			_, err := cron.Parse(expr)
+			if err == nil {
+				t.Errorf("expected error, got nil")
+			}
+		})
+	}
+}
@@ -22,7 +22,7 @@ func NewErroryTgramClient() (tgram.Client, error) {
 		return nil, fmt.Errorf("missing ERRORY_BOT_TOKEN environment variable. Please check your environment.")
 	}
 
-	httpClient := whttp.NewLoggingClient()
+	httpClient := whttp.NewClient(whttp.Telegram)
 	return tgram.NewClient(httpClient, telegramBotToken), nil
 }
 
@@ -40,3 +40,14 @@ func MyTelegramChatID() (int64, error) {
 
 	return chatIDint, nil
 }
+
+// CallbackSecret is the secret inline buttons are signed with. The bot and the
+// pinger must share it, since the pinger creates buttons the bot handles.
+func CallbackSecret() ([]byte, error) {
+	secret := os.Getenv("TELEGRAM_CALLBACK_SECRET")
+	if secret == "" {
+		return nil, fmt.Errorf("missing TELEGRAM_CALLBACK_SECRET environment variable. Please check your environment.")
+	}
+
+	return []byte(secret), nil
+}
@@ -0,0 +1,201 @@
+package expr
+
+import "fmt"
+
+type kind int
+
+const (
+	kindNumber kind = iota
+	kindString
+	kindBool
+)
+
+func (k kind) String() string {
+	switch k {
+	case kindNumber:
+		return "number"
+	case kindString:
+		return "string"
+	default:
+		return "condition"
+	}
+}
+
+// node is a type-checked node of the syntax tree. eval returns a float64,
+// string or bool depending on kind.
+type node interface {
+	kind() kind
+	eval(*scope) (any, error)
+}
+
+type literalNode struct {
+	value any
+	k     kind
+}
+
+func (n *literalNode) kind() kind { return n.k }
+
+func (n *literalNode) eval(*scope) (any, error) { return n.value, nil }
+
+type fieldNode struct {
+	name  string
+	field field
+}
+
+func (n *fieldNode) kind() kind { return n.field.kind }
+
+func (n *fieldNode) eval(s *scope) (any, error) { return n.field.get(s), nil }
+
+type logicalNode struct {
+	op          string
+	left, right node
+}
+
+func (n *logicalNode) kind() kind { return kindBool }
+
+func (n *logicalNode) eval(s *scope) (any, error) {
+	l, err := n.left.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short-circuit just like Go does.
+	if n.op == "&&" && !l.(bool) {
+		return false, nil
+	}
+
+	if n.op == "||" && l.(bool) {
+		return true, nil
+	}
+
+	return n.right.eval(s)
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) kind() kind { return kindBool }
+
+func (n *notNode) eval(s *scope) (any, error) {
+	v, err := n.operand.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return !v.(bool), nil
+}
+
+type comparisonNode struct {
+	op          string
+	left, right node
+}
+
+func (n *comparisonNode) kind() kind { return kindBool }
+
+func (n *comparisonNode) eval(s *scope) (any, error) {
+	l, err := n.left.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := n.right.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return l == r, nil
+	case "!=":
+		return l != r, nil
+	case "<":
+		return l.(float64) < r.(float64), nil
+	case "<=":
+		return l.(float64) <= r.(float64), nil
+	case ">":
+		return l.(float64) > r.(float64), nil
+	case ">=":
+		return l.(float64) >= r.(float64), nil
+	default:
+		return nil, fmt.Errorf("unknown comparison operator %q", n.op)
+	}
+}
+
+type betweenNode struct {
+	value, low, high node
+}
+
+func (n *betweenNode) kind() kind { return kindBool }
+
+// eval checks whether the value is within the bounds, both inclusive.
+func (n *betweenNode) eval(s *scope) (any, error) {
+	v, err := n.value.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	low, err := n.low.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	high, err := n.high.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(float64) >= low.(float64) && v.(float64) <= high.(float64), nil
+}
+
+type arithmeticNode struct {
+	op          string
+	pos         int
+	left, right node
+}
+
+func (n *arithmeticNode) kind() kind { return kindNumber }
+
+func (n *arithmeticNode) eval(s *scope) (any, error) {
+	l, err := n.left.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := n.right.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "+":
+		return l.(float64) + r.(float64), nil
+	case "-":
+		return l.(float64) - r.(float64), nil
+	case "*":
+		return l.(float64) * r.(float64), nil
+	case "/":
+		if r.(float64) == 0 {
+			return nil, &Error{Pos: n.pos, Msg: "division by zero"}
+		}
+
+		return l.(float64) / r.(float64), nil
+	default:
+		return nil, fmt.Errorf("unknown arithmetic operator %q", n.op)
+	}
+}
+
+type negateNode struct {
+	operand node
+}
+
+func (n *negateNode) kind() kind { return kindNumber }
+
+func (n *negateNode) eval(s *scope) (any, error) {
+	v, err := n.operand.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return -v.(float64), nil
+}
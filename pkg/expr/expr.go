@@ -0,0 +1,183 @@
+// package expr implements a small, side-effect free expression language to
+// write notification rules over weather forecasts, such as:
+//
+//	pop > 0.6 && hour between 7 and 9 && weekday
+//
+// Rules are type-checked when compiled, so a compiled rule can always be
+// evaluated against any forecast.
+package expr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/weather"
+)
+
+const (
+	// MaxLength is the maximum amount of characters a rule can have.
+	MaxLength = 256
+
+	// maxDepth is the maximum amount of nested sub-expressions a rule can have.
+	maxDepth = 32
+)
+
+// Error is a compilation error. Pos is the 1-based column of the rule where
+// the error was found.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Pos, e.Msg)
+}
+
+// Rule is a compiled expression which evaluates to a boolean.
+type Rule struct {
+	src  string
+	root node
+}
+
+// Compile parses and type-checks src.
+func Compile(src string) (*Rule, error) {
+	src = strings.TrimSpace(src)
+	if src == "" {
+		return nil, &Error{Pos: 1, Msg: "rule is empty"}
+	}
+
+	if len([]rune(src)) > MaxLength {
+		return nil, &Error{Pos: MaxLength + 1, Msg: fmt.Sprintf("rule is longer than %d characters", MaxLength)}
+	}
+
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser{tokens: tokens}
+	root, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	if root.kind() != kindBool {
+		return nil, &Error{Pos: 1, Msg: fmt.Sprintf("rule must be a condition, but it is a %s", root.kind())}
+	}
+
+	return &Rule{src: src, root: root}, nil
+}
+
+// String returns the source of the rule.
+func (r *Rule) String() string {
+	return r.src
+}
+
+// Match evaluates the rule against the forecast. Time related fields, such as
+// hour or weekday, are calculated in the given location.
+func (r *Rule) Match(f *weather.Forecast, loc *time.Location) (bool, error) {
+	s := &scope{forecast: f, time: time.Unix(int64(f.DateTimeTS), 0).In(loc)}
+
+	v, err := r.root.eval(s)
+	if err != nil {
+		return false, err
+	}
+
+	return v.(bool), nil
+}
+
+// FindFirst returns the first forecast which matches the rule, or nil if none
+// do.
+func (r *Rule) FindFirst(forecasts []*weather.Forecast, loc *time.Location) (*weather.Forecast, error) {
+	for _, f := range forecasts {
+		ok, err := r.Match(f, loc)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			return f, nil
+		}
+	}
+
+	return nil, nil
+}
+
+type scope struct {
+	forecast *weather.Forecast
+	time     time.Time
+}
+
+type field struct {
+	kind kind
+	doc  string
+	get  func(*scope) any
+}
+
+var fields = map[string]field{
+	"temp": {kindNumber, "average temperature in ºC", func(s *scope) any {
+		return (s.forecast.MinimumTemperature + s.forecast.MaximumTemperature) / 2
+	}},
+	"min_temp": {kindNumber, "minimum temperature in ºC", func(s *scope) any {
+		return s.forecast.MinimumTemperature
+	}},
+	"max_temp": {kindNumber, "maximum temperature in ºC", func(s *scope) any {
+		return s.forecast.MaximumTemperature
+	}},
+	"humidity": {kindNumber, "humidity, from 0 to 100", func(s *scope) any {
+		return float64(s.forecast.Humidity)
+	}},
+	"wind": {kindNumber, "wind speed in m/s", func(s *scope) any {
+		return s.forecast.WindSpeed
+	}},
+	"pop": {kindNumber, "probability of precipitation, from 0 to 1", func(s *scope) any {
+		return s.forecast.PrecipitationProbability
+	}},
+	"hour": {kindNumber, "hour of the day, from 0 to 23", func(s *scope) any {
+		return float64(s.time.Hour())
+	}},
+	"day": {kindString, "day of the week, such as \"mon\" or \"sat\"", func(s *scope) any {
+		return strings.ToLower(s.time.Weekday().String()[:3])
+	}},
+	"weekday": {kindBool, "true from Monday to Friday", func(s *scope) any {
+		return s.time.Weekday() != time.Saturday && s.time.Weekday() != time.Sunday
+	}},
+	"weekend": {kindBool, "true on Saturday and Sunday", func(s *scope) any {
+		return s.time.Weekday() == time.Saturday || s.time.Weekday() == time.Sunday
+	}},
+	"condition": {kindString, "condition, such as \"rain\", \"snow\" or \"clear\"", func(s *scope) any {
+		return s.forecast.Condition
+	}},
+	"rainy": {kindBool, "true when it is going to rain", func(s *scope) any {
+		return s.forecast.IsRainy()
+	}},
+}
+
+// Field describes a value of the forecast which can be used within a rule.
+type Field struct {
+	Name string
+	Doc  string
+}
+
+// Fields lists all the fields which can be used in rules, sorted by name.
+func Fields() []Field {
+	ff := make([]Field, 0, len(fields))
+	for name, f := range fields {
+		ff = append(ff, Field{Name: name, Doc: f.doc})
+	}
+
+	sort.Slice(ff, func(i, j int) bool { return ff[i].Name < ff[j].Name })
+	return ff
+}
+
+func fieldNames() string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
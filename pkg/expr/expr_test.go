@@ -0,0 +1,220 @@
+package expr_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/expr"
+	"github.com/manzanit0/weathry/pkg/weather"
+)
+
+// monday8am is Monday, 1st of August 2022 at 08:00 UTC.
+var monday8am = int(time.Date(2022, time.August, 1, 8, 0, 0, 0, time.UTC).Unix())
+
+func TestMatch(t *testing.T) {
+	forecast := &weather.Forecast{
+		Condition:                "rain",
+		MinimumTemperature:       14,
+		MaximumTemperature:       18,
+		Humidity:                 80,
+		WindSpeed:                5.5,
+		DateTimeTS:               monday8am,
+		PrecipitationProbability: 0.7,
+	}
+
+	testCases := []struct {
+		rule string
+		want bool
+	}{
+		{rule: "pop > 0.6 && hour between 7 and 9 && weekday", want: true},
+		{rule: "pop > 0.6 and hour between 7 and 9 and weekday", want: true},
+		{rule: "pop > 0.8 && hour between 7 and 9 && weekday", want: false},
+		{rule: "hour between 8 and 8", want: true},
+		{rule: "hour between 9 and 12", want: false},
+		{rule: "weekend", want: false},
+		{rule: "!weekend", want: true},
+		{rule: "not weekend", want: true},
+		{rule: "!!weekday", want: true},
+		{rule: "day == \"mon\"", want: true},
+		{rule: "day == 'sat' || day == 'sun'", want: false},
+		{rule: "day != \"mon\"", want: false},
+		{rule: "condition == \"rain\"", want: true},
+		{rule: "rainy", want: true},
+		{rule: "temp == 16", want: true},
+		{rule: "min_temp < 15 && max_temp >= 18", want: true},
+		{rule: "max_temp - min_temp > 3", want: true},
+		{rule: "max_temp - min_temp > 4", want: false},
+		{rule: "max_temp / 2 == 9", want: true},
+		{rule: "min_temp * 2 + 1 == 29", want: true},
+		{rule: "1 + 2 * 3 == 7", want: true},
+		{rule: "(1 + 2) * 3 == 9", want: true},
+		{rule: "-min_temp < 0", want: true},
+		{rule: "- -1 == 1", want: true},
+		{rule: "humidity >= 80", want: true},
+		{rule: "humidity > 80", want: false},
+		{rule: "wind <= 5.5", want: true},
+		{rule: "wind < .5", want: false},
+		{rule: "true", want: true},
+		{rule: "false || true", want: true},
+		{rule: "true && false", want: false},
+		{rule: "false || false || true", want: true},
+		{rule: "true || false && false", want: true},
+		{rule: "(true || false) && false", want: false},
+		{rule: "weekend == false", want: true},
+		{rule: "  rainy  ", want: true},
+		{rule: "Rainy AND NOT weekend", want: true},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.rule, func(t *testing.T) {
+			r, err := expr.Compile(tC.rule)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			got, err := r.Match(forecast, time.UTC)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			if got != tC.want {
+				t.Errorf("got %t, expected %t", got, tC.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	testCases := []struct {
+		rule    string
+		wantPos int
+		wantMsg string
+	}{
+		{rule: "", wantPos: 1, wantMsg: "rule is empty"},
+		{rule: "pop", wantPos: 1, wantMsg: "rule must be a condition, but it is a number"},
+		{rule: "day", wantPos: 1, wantMsg: "rule must be a condition, but it is a string"},
+		{rule: "pip > 0.5", wantPos: 1, wantMsg: "unknown field \"pip\""},
+		{rule: "pop = 0.5", wantPos: 5, wantMsg: "use \"==\" to compare values"},
+		{rule: "pop > 0.5 & weekday", wantPos: 11, wantMsg: "use \"&&\""},
+		{rule: "pop > 0.5 | weekday", wantPos: 11, wantMsg: "use \"||\""},
+		{rule: "pop > 0.5 &&", wantPos: 13, wantMsg: "unexpected end of rule"},
+		{rule: "pop >", wantPos: 6, wantMsg: "unexpected end of rule"},
+		{rule: "pop > 0.5)", wantPos: 10, wantMsg: "unexpected \")\""},
+		{rule: "(pop > 0.5", wantPos: 11, wantMsg: "expected \")\", got end of rule"},
+		{rule: "pop > \"high\"", wantPos: 5, wantMsg: "\">\" expects a number, got a string"},
+		{rule: "day == 1", wantPos: 5, wantMsg: "cannot compare a string with a number"},
+		{rule: "pop && weekday", wantPos: 5, wantMsg: "\"&&\" expects a condition, got a number"},
+		{rule: "weekday || 1", wantPos: 9, wantMsg: "\"||\" expects a condition, got a number"},
+		{rule: "!pop", wantPos: 1, wantMsg: "\"!\" expects a condition, got a number"},
+		{rule: "-weekday", wantPos: 1, wantMsg: "\"-\" expects a number, got a condition"},
+		{rule: "weekday + 1 > 0", wantPos: 9, wantMsg: "\"+\" expects a number, got a condition"},
+		{rule: "hour between 7 or 9", wantPos: 16, wantMsg: "expected \"and\" after the lower bound of \"between\", got \"or\""},
+		{rule: "hour between 7", wantPos: 15, wantMsg: "expected \"and\""},
+		{rule: "day between 1 and 2", wantPos: 5, wantMsg: "\"between\" expects a number, got a string"},
+		{rule: "1 < 2 < 3", wantPos: 7, wantMsg: "unexpected \"<\""},
+		{rule: "pop > 0.5 weekday", wantPos: 11, wantMsg: "unexpected \"weekday\""},
+		{rule: "day == \"mon", wantPos: 8, wantMsg: "unterminated string"},
+		{rule: "pop > 0.5 ; drop table", wantPos: 11, wantMsg: "unexpected character \";\""},
+		{rule: "and", wantPos: 1, wantMsg: "unexpected \"and\""},
+		{rule: "between > 1", wantPos: 1, wantMsg: "unexpected \"between\""},
+		{rule: strings.Repeat("(", 40) + "true" + strings.Repeat(")", 40), wantPos: 33, wantMsg: "nested too deeply"},
+		{rule: strings.Repeat("!", 40) + "true", wantPos: 33, wantMsg: "nested too deeply"},
+		{rule: "pop > " + strings.Repeat("1", expr.MaxLength), wantPos: expr.MaxLength + 1, wantMsg: "longer than"},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.rule, func(t *testing.T) {
+			_, err := expr.Compile(tC.rule)
+			if err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+
+			var exprErr *expr.Error
+			if !errors.As(err, &exprErr) {
+				t.Fatalf("expected *expr.Error, got %T", err)
+			}
+
+			if exprErr.Pos != tC.wantPos {
+				t.Errorf("got position %d, expected %d (%s)", exprErr.Pos, tC.wantPos, exprErr.Msg)
+			}
+
+			if !strings.Contains(exprErr.Msg, tC.wantMsg) {
+				t.Errorf("got message %q, expected it to contain %q", exprErr.Msg, tC.wantMsg)
+			}
+		})
+	}
+}
+
+func TestMatchDivisionByZero(t *testing.T) {
+	r, err := expr.Compile("pop / (hour - 8) > 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	_, err = r.Match(&weather.Forecast{DateTimeTS: monday8am}, time.UTC)
+	if err == nil || !strings.Contains(err.Error(), "division by zero") {
+		t.Errorf("expected division by zero error, got %v", err)
+	}
+}
+
+func TestMatchUsesLocation(t *testing.T) {
+	r, err := expr.Compile("hour == 10")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	madrid := time.FixedZone("CEST", 2*60*60)
+	forecast := &weather.Forecast{DateTimeTS: monday8am}
+
+	if ok, _ := r.Match(forecast, time.UTC); ok {
+		t.Errorf("expected 08:00 UTC not to match hour == 10")
+	}
+
+	if ok, _ := r.Match(forecast, madrid); !ok {
+		t.Errorf("expected 10:00 CEST to match hour == 10")
+	}
+}
+
+func TestFindFirst(t *testing.T) {
+	r, err := expr.Compile("pop > 0.5 && weekday")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	forecasts := []*weather.Forecast{
+		{PrecipitationProbability: 0.1, DateTimeTS: monday8am},
+		{PrecipitationProbability: 0.6, DateTimeTS: monday8am + 3*60*60},
+		{PrecipitationProbability: 0.9, DateTimeTS: monday8am + 6*60*60},
+	}
+
+	got, err := r.FindFirst(forecasts, time.UTC)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if got != forecasts[1] {
+		t.Errorf("got %v, expected %v", got, forecasts[1])
+	}
+
+	got, err = r.FindFirst(forecasts[:1], time.UTC)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if got != nil {
+		t.Errorf("expected nil, got %v", got)
+	}
+}
+
+func TestString(t *testing.T) {
+	r, err := expr.Compile("  pop > 0.6  ")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if r.String() != "pop > 0.6" {
+		t.Errorf("got %q, expected %q", r.String(), "pop > 0.6")
+	}
+}
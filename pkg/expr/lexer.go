@@ -0,0 +1,124 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+
+	// pos is the 1-based column where the token starts.
+	pos int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of rule"
+	}
+
+	return fmt.Sprintf("%q", t.text)
+}
+
+// is reports whether the token is the given operator or keyword. Keywords are
+// matched case-insensitively.
+func (t token) is(s string) bool {
+	switch t.kind {
+	case tokenOperator:
+		return t.text == s
+	case tokenIdent:
+		return strings.EqualFold(t.text, s)
+	default:
+		return false
+	}
+}
+
+var operators = []string{"&&", "||", "<=", ">=", "==", "!=", "<", ">", "!", "(", ")", "+", "-", "*", "/"}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			seenDot := false
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || (runes[i] == '.' && !seenDot)) {
+				if runes[i] == '.' {
+					seenDot = true
+				}
+				i++
+			}
+
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[start:i]), pos: pos})
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[start:i]), pos: pos})
+
+		case r == '"' || r == '\'':
+			quote := r
+			i++
+			start := i
+			for i < len(runes) && runes[i] != quote {
+				i++
+			}
+
+			if i == len(runes) {
+				return nil, &Error{Pos: pos, Msg: "unterminated string"}
+			}
+
+			tokens = append(tokens, token{kind: tokenString, text: string(runes[start:i]), pos: pos})
+			i++
+
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					tokens = append(tokens, token{kind: tokenOperator, text: op, pos: pos})
+					i += len([]rune(op))
+					matched = true
+					break
+				}
+			}
+
+			if !matched {
+				if r == '=' {
+					return nil, &Error{Pos: pos, Msg: "unexpected \"=\", use \"==\" to compare values"}
+				}
+
+				if r == '&' || r == '|' {
+					return nil, &Error{Pos: pos, Msg: fmt.Sprintf("unexpected %q, use %q", string(r), strings.Repeat(string(r), 2))}
+				}
+
+				return nil, &Error{Pos: pos, Msg: fmt.Sprintf("unexpected character %q", string(r))}
+			}
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokenEOF, pos: len(runes) + 1})
+	return tokens, nil
+}
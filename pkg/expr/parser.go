@@ -0,0 +1,341 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The grammar, from lowest to highest precedence:
+//
+//	or         = and { ( "||" | "or" ) and }
+//	and        = not { ( "&&" | "and" ) not }
+//	not        = ( "!" | "not" ) not | comparison
+//	comparison = sum [ ( "<" | "<=" | ">" | ">=" | "==" | "!=" ) sum
+//	                 | "between" sum "and" sum ]
+//	sum        = product { ( "+" | "-" ) product }
+//	product    = unary { ( "*" | "/" ) unary }
+//	unary      = "-" unary | primary
+//	primary    = number | string | "true" | "false" | field | "(" or ")"
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+func (p *parser) parse() (node, error) {
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("unexpected %s", t)}
+	}
+
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *parser) enter(t token) error {
+	p.depth++
+	if p.depth > maxDepth {
+		return &Error{Pos: t.pos, Msg: "rule is nested too deeply"}
+	}
+
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for t := p.peek(); t.is("||") || t.is("or"); t = p.peek() {
+		p.next()
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := expectKind(t, "||", kindBool, left, right); err != nil {
+			return nil, err
+		}
+
+		left = &logicalNode{op: "||", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for t := p.peek(); t.is("&&") || t.is("and"); t = p.peek() {
+		p.next()
+
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := expectKind(t, "&&", kindBool, left, right); err != nil {
+			return nil, err
+		}
+
+		left = &logicalNode{op: "&&", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	t := p.peek()
+	if !t.is("!") && !t.is("not") {
+		return p.parseComparison()
+	}
+
+	p.next()
+	if err := p.enter(t); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	operand, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	if operand.kind() != kindBool {
+		return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("%q expects a condition, got a %s", t.text, operand.kind())}
+	}
+
+	return &notNode{operand: operand}, nil
+}
+
+var comparisonOperators = map[string]bool{"<": true, "<=": true, ">": true, ">=": true, "==": true, "!=": true}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	switch {
+	case t.kind == tokenOperator && comparisonOperators[t.text]:
+		p.next()
+
+		right, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+
+		if t.text == "==" || t.text == "!=" {
+			if left.kind() != right.kind() {
+				return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("cannot compare a %s with a %s", left.kind(), right.kind())}
+			}
+		} else if err := expectKind(t, t.text, kindNumber, left, right); err != nil {
+			return nil, err
+		}
+
+		return &comparisonNode{op: t.text, left: left, right: right}, nil
+
+	case t.is("between"):
+		p.next()
+
+		low, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+
+		if and := p.next(); !and.is("and") {
+			return nil, &Error{Pos: and.pos, Msg: fmt.Sprintf("expected \"and\" after the lower bound of \"between\", got %s", and)}
+		}
+
+		high, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := expectKind(t, "between", kindNumber, left, low, high); err != nil {
+			return nil, err
+		}
+
+		return &betweenNode{value: left, low: low, high: high}, nil
+
+	default:
+		return left, nil
+	}
+}
+
+func (p *parser) parseSum() (node, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+
+	for t := p.peek(); t.is("+") || t.is("-"); t = p.peek() {
+		p.next()
+
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := expectKind(t, t.text, kindNumber, left, right); err != nil {
+			return nil, err
+		}
+
+		left = &arithmeticNode{op: t.text, pos: t.pos, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseProduct() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for t := p.peek(); t.is("*") || t.is("/"); t = p.peek() {
+		p.next()
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := expectKind(t, t.text, kindNumber, left, right); err != nil {
+			return nil, err
+		}
+
+		left = &arithmeticNode{op: t.text, pos: t.pos, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t := p.peek()
+	if !t.is("-") {
+		return p.parsePrimary()
+	}
+
+	p.next()
+	if err := p.enter(t); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := expectKind(t, "-", kindNumber, operand); err != nil {
+		return nil, err
+	}
+
+	return &negateNode{operand: operand}, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+
+	switch {
+	case t.kind == tokenNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("invalid number %s", t)}
+		}
+
+		return &literalNode{value: n, k: kindNumber}, nil
+
+	case t.kind == tokenString:
+		return &literalNode{value: t.text, k: kindString}, nil
+
+	case t.is("true"):
+		return &literalNode{value: true, k: kindBool}, nil
+
+	case t.is("false"):
+		return &literalNode{value: false, k: kindBool}, nil
+
+	case t.is("("):
+		if err := p.enter(t); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if closing := p.next(); !closing.is(")") {
+			return nil, &Error{Pos: closing.pos, Msg: fmt.Sprintf("expected \")\", got %s", closing)}
+		}
+
+		return n, nil
+
+	case t.kind == tokenIdent:
+		if isKeyword(t.text) {
+			return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("unexpected %s", t)}
+		}
+
+		f, ok := fields[strings.ToLower(t.text)]
+		if !ok {
+			return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("unknown field %s, available fields are: %s", t, fieldNames())}
+		}
+
+		return &fieldNode{name: t.text, field: f}, nil
+
+	case t.kind == tokenEOF:
+		return nil, &Error{Pos: t.pos, Msg: "unexpected end of rule, expected a value"}
+
+	default:
+		return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("unexpected %s, expected a value", t)}
+	}
+}
+
+func isKeyword(s string) bool {
+	for _, k := range []string{"and", "or", "not", "between"} {
+		if strings.EqualFold(s, k) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func expectKind(t token, op string, want kind, operands ...node) error {
+	for _, o := range operands {
+		if o.kind() != want {
+			return &Error{Pos: t.pos, Msg: fmt.Sprintf("%q expects a %s, got a %s", op, want, o.kind())}
+		}
+	}
+
+	return nil
+}
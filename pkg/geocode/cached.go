@@ -0,0 +1,56 @@
+package geocode
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/cache"
+)
+
+// DefaultCacheTTL is how long locations are cached for.
+const DefaultCacheTTL = 24 * time.Hour
+
+// NewCachedClient returns a client which keeps the locations c finds for ttl.
+// Places don't move, and geocoding APIs such as Nominatim only allow a request
+// per second, so users asking about the same places can do without them.
+func NewCachedClient(c Client, ttl time.Duration) Client {
+	return &cachedClient{proxied: c, locations: cache.New[Location]("geocode", ttl)}
+}
+
+type cachedClient struct {
+	proxied   Client
+	locations *cache.Cache[Location]
+}
+
+var _ Client = (*cachedClient)(nil)
+
+func (c *cachedClient) Geocode(ctx context.Context, query string) (*Location, error) {
+	return c.cached("geocode:"+strings.ToLower(strings.TrimSpace(query)), func() (*Location, error) {
+		return c.proxied.Geocode(ctx, query)
+	})
+}
+
+func (c *cachedClient) ReverseGeocode(ctx context.Context, lat, long float64) (*Location, error) {
+	return c.cached(fmt.Sprintf("reverse:%f,%f", lat, long), func() (*Location, error) {
+		return c.proxied.ReverseGeocode(ctx, lat, long)
+	})
+}
+
+func (c *cachedClient) cached(key string, fetch func() (*Location, error)) (*Location, error) {
+	if l, ok := c.locations.Get(key); ok {
+		return &l, nil
+	}
+
+	l, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	if l != nil {
+		c.locations.Set(key, *l)
+	}
+
+	return l, nil
+}
@@ -0,0 +1,72 @@
+package geocode_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/geocode"
+)
+
+type countingClient struct {
+	calls int
+	err   error
+}
+
+func (c *countingClient) Geocode(_ context.Context, query string) (*geocode.Location, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return &geocode.Location{Name: query}, nil
+}
+
+func (c *countingClient) ReverseGeocode(_ context.Context, lat, long float64) (*geocode.Location, error) {
+	c.calls++
+	return &geocode.Location{Latitude: lat, Longitude: long}, nil
+}
+
+func TestCachedClientGeocodesPlacesOnce(t *testing.T) {
+	proxied := &countingClient{}
+	c := geocode.NewCachedClient(proxied, time.Hour)
+
+	for _, q := range []string{"Lisbon", " lisbon", "LISBON"} {
+		l, err := c.Geocode(context.Background(), q)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+
+		if l.Name != "Lisbon" {
+			t.Errorf("got %q, expected the location found the first time", l.Name)
+		}
+	}
+
+	if _, err := c.ReverseGeocode(context.Background(), 38.7, -9.1); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if _, err := c.ReverseGeocode(context.Background(), 38.7, -9.1); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if proxied.calls != 2 {
+		t.Errorf("got %d calls, expected 2", proxied.calls)
+	}
+}
+
+func TestCachedClientDoesNotCacheErrors(t *testing.T) {
+	proxied := &countingClient{err: errors.New("boom")}
+	c := geocode.NewCachedClient(proxied, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Geocode(context.Background(), "Lisbon"); err == nil {
+			t.Fatalf("expected an error")
+		}
+	}
+
+	if proxied.calls != 2 {
+		t.Errorf("got %d calls, expected 2", proxied.calls)
+	}
+}
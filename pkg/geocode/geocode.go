@@ -1,8 +1,10 @@
 package geocode
 
+import "context"
+
 type Client interface {
-	Geocode(query string) (*Location, error)
-	ReverseGeocode(lat, long float64) (*Location, error)
+	Geocode(ctx context.Context, query string) (*Location, error)
+	ReverseGeocode(ctx context.Context, lat, long float64) (*Location, error)
 }
 
 type Location struct {
@@ -1,62 +1,162 @@
 package geocode
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
-
-	"github.com/codingsince1985/geo-golang"
-	"github.com/codingsince1985/geo-golang/openstreetmap"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 )
 
-func NewOpenstreetmapClient() *oc {
-	geocoder := openstreetmap.Geocoder()
-	return &oc{geocoder: geocoder}
+const nominatimHost = "https://nominatim.openstreetmap.org"
+
+// userAgent identifies us to Nominatim, as its usage policy asks, rather than
+// Go's default, which gets requests blocked.
+//
+// @see https://operations.osmfoundation.org/policies/nominatim/
+const userAgent = "weathry (+https://github.com/manzanit0/weathry)"
+
+// NewOpenstreetmapClient returns a client of Nominatim, OpenStreetMap's
+// geocoder, which makes its requests with h.
+func NewOpenstreetmapClient(h *http.Client) *oc {
+	return &oc{h: h}
 }
 
 type oc struct {
-	geocoder geo.Geocoder
+	h *http.Client
 }
 
 var _ Client = (*oc)(nil)
 
-func (c *oc) Geocode(query string) (*Location, error) {
-	location, err := c.geocoder.Geocode(query)
+type nominatimPlace struct {
+	Lat     string           `json:"lat"`
+	Lon     string           `json:"lon"`
+	Error   string           `json:"error"`
+	Address nominatimAddress `json:"address"`
+}
+
+type nominatimAddress struct {
+	City        string `json:"city"`
+	Town        string `json:"town"`
+	Village     string `json:"village"`
+	Hamlet      string `json:"hamlet"`
+	Country     string `json:"country"`
+	CountryCode string `json:"country_code"`
+}
+
+// locality returns the most specific of the places the address is in, such as
+// its city or, in the countryside, its village.
+func (a nominatimAddress) locality() string {
+	for _, l := range []string{a.City, a.Town, a.Village, a.Hamlet} {
+		if l != "" {
+			return l
+		}
+	}
+
+	return ""
+}
+
+func (c *oc) get(ctx context.Context, path string, q url.Values, v any) error {
+	q.Set("format", "json")
+	url := fmt.Sprintf("%s/%s?%s", nominatimHost, path, q.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
+		return err
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+
+	res, err := c.h.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func (c *oc) Geocode(ctx context.Context, query string) (*Location, error) {
+	q := url.Values{}
+	q.Set("q", query)
+	q.Set("limit", "1")
+
+	var places []nominatimPlace
+	if err := c.get(ctx, "search", q, &places); err != nil {
 		return nil, err
 	}
 
-	if location == nil {
+	if len(places) == 0 {
 		return nil, fmt.Errorf("unable to geocode address")
 	}
 
-	address, err := c.geocoder.ReverseGeocode(location.Lat, location.Lng)
+	lat, err := strconv.ParseFloat(places[0].Lat, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse latitude: %w", err)
+	}
+
+	lon, err := strconv.ParseFloat(places[0].Lon, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse longitude: %w", err)
+	}
+
+	// Searches don't say which country places are in, so that's asked for
+	// separately.
+	address, err := c.reverseGeocode(ctx, lat, lon)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Location{
-		Latitude:    location.Lat,
-		Longitude:   location.Lng,
+		Latitude:    lat,
+		Longitude:   lon,
 		Name:        query,
 		Country:     address.Country,
-		CountryCode: address.CountryCode,
+		CountryCode: strings.ToUpper(address.CountryCode),
 	}, nil
 }
 
-func (c *oc) ReverseGeocode(lat, lon float64) (*Location, error) {
-	address, err := c.geocoder.ReverseGeocode(lat, lon)
+func (c *oc) ReverseGeocode(ctx context.Context, lat, lon float64) (*Location, error) {
+	address, err := c.reverseGeocode(ctx, lat, lon)
 	if err != nil {
 		return nil, err
 	}
 
-	if address == nil {
-		return nil, fmt.Errorf("unable to reverse geocode location")
-	}
-
 	return &Location{
 		Latitude:    lat,
 		Longitude:   lon,
-		Name:        fmt.Sprintf("%s, %s", address.City, address.Country),
+		Name:        fmt.Sprintf("%s, %s", address.locality(), address.Country),
 		Country:     address.Country,
-		CountryCode: address.CountryCode,
+		CountryCode: strings.ToUpper(address.CountryCode),
 	}, nil
 }
+
+func (c *oc) reverseGeocode(ctx context.Context, lat, lon float64) (*nominatimAddress, error) {
+	q := url.Values{}
+	q.Set("lat", strconv.FormatFloat(lat, 'f', 6, 64))
+	q.Set("lon", strconv.FormatFloat(lon, 'f', 6, 64))
+
+	var place nominatimPlace
+	if err := c.get(ctx, "reverse", q, &place); err != nil {
+		return nil, err
+	}
+
+	if place.Error != "" {
+		return nil, fmt.Errorf("unable to reverse geocode location: %s", place.Error)
+	}
+
+	return &place.Address, nil
+}
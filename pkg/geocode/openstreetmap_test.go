@@ -0,0 +1,49 @@
+package geocode_test
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/manzanit0/weathry/pkg/geocode"
+	"github.com/manzanit0/weathry/pkg/whttp"
+)
+
+// newOpenstreetmapClient returns a client which replays the cassette in
+// testdata. To record it against the real API instead, set WHTTP_RECORD. The
+// cassettes have the User-Agent Nominatim asks for, so requests without it
+// aren't replayed.
+func newOpenstreetmapClient(t *testing.T, cassette string) geocode.Client {
+	t.Helper()
+
+	rec := whttp.NewTestRecorder(t, filepath.Join("testdata", cassette+".json"))
+	return geocode.NewOpenstreetmapClient(&http.Client{Transport: rec})
+}
+
+func TestOpenstreetmapGeocode(t *testing.T) {
+	c := newOpenstreetmapClient(t, "openstreetmap_geocode")
+
+	l, err := c.Geocode(context.Background(), "Lisbon")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := geocode.Location{Latitude: 38.7077507, Longitude: -9.1365919, Name: "Lisbon", Country: "Portugal", CountryCode: "PT"}
+	if *l != expected {
+		t.Errorf("got %+v, expected %+v", *l, expected)
+	}
+}
+
+func TestOpenstreetmapReverseGeocode(t *testing.T) {
+	c := newOpenstreetmapClient(t, "openstreetmap_reverse_geocode")
+
+	l, err := c.ReverseGeocode(context.Background(), 38.7078, -9.1365)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if l.Name != "Lisboa, Portugal" || l.CountryCode != "PT" {
+		t.Errorf("unexpected location %+v", l)
+	}
+}
@@ -1,11 +1,14 @@
 package geocode
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+
+	"github.com/manzanit0/weathry/pkg/redact"
 )
 
 const (
@@ -30,13 +33,13 @@ func (c *psc) queryWithDefaults() url.Values {
 	return v
 }
 
-func (c *psc) Geocode(query string) (*Location, error) {
+func (c *psc) Geocode(ctx context.Context, query string) (*Location, error) {
 	q := c.queryWithDefaults()
 	q.Set("query", query)
 	q.Set("limit", "1")
 	url := fmt.Sprintf("%s/v1/forward?%s", host, q.Encode())
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -45,7 +48,7 @@ func (c *psc) Geocode(query string) (*Location, error) {
 
 	res, err := c.h.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, redact.Default.URLError(err)
 	}
 
 	data, err := io.ReadAll(res.Body)
@@ -80,16 +83,21 @@ func (c *psc) Geocode(query string) (*Location, error) {
 	}, nil
 }
 
-func (c *psc) ReverseGeocode(lat, lon float64) (*Location, error) {
+func (c *psc) ReverseGeocode(ctx context.Context, lat, lon float64) (*Location, error) {
 	q := c.queryWithDefaults()
 	q.Set("query", fmt.Sprintf("%f,%f", lat, lon))
 	url := fmt.Sprintf("http://api.positionstack.com/v1/reverse?%s", q.Encode())
 
-	res, err := c.h.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
 
+	res, err := c.h.Do(req)
+	if err != nil {
+		return nil, redact.Default.URLError(err)
+	}
+
 	data, err := io.ReadAll(res.Body)
 	if err != nil {
 		return nil, err
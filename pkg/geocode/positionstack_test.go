@@ -0,0 +1,78 @@
+package geocode_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/manzanit0/weathry/pkg/geocode"
+	"github.com/manzanit0/weathry/pkg/whttp"
+)
+
+// newPositionStackClient returns a client which replays the cassette in
+// testdata. To record it against the real API instead, set WHTTP_RECORD and
+// POSITIONSTACK_API_KEY.
+func newPositionStackClient(t *testing.T, cassette string) geocode.Client {
+	t.Helper()
+
+	apiKey := "secret"
+	if whttp.Recording() {
+		apiKey = os.Getenv("POSITIONSTACK_API_KEY")
+	}
+
+	rec := whttp.NewTestRecorder(t, filepath.Join("testdata", cassette+".json"))
+	return geocode.NewPositionStackClient(&http.Client{Transport: rec}, apiKey)
+}
+
+func TestPositionStackGeocode(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		cassette string
+		query    string
+		err      string
+		name     string
+	}{
+		{desc: "found", cassette: "positionstack_forward", query: "Lisbon", name: "Lisbon"},
+		{desc: "nothing found", cassette: "positionstack_no_results", query: "Xyzzyland", err: "no location data returned by API"},
+		{desc: "invalid access key", cassette: "positionstack_invalid_key", query: "Lisbon", err: "You have not supplied a valid API Access Key."},
+		{desc: "invalid query", cassette: "positionstack_validation_error", query: "", err: "Request failed with validation error"},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			c := newPositionStackClient(t, tC.cassette)
+
+			l, err := c.Geocode(context.Background(), tC.query)
+			if tC.err != "" {
+				if err == nil || err.Error() != tC.err {
+					t.Fatalf("got error %v, expected %q", err, tC.err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			if l.Name != tC.name || l.Country != "Portugal" || l.Latitude != 38.7077507 || l.Longitude != -9.1365919 {
+				t.Errorf("unexpected location %+v", l)
+			}
+		})
+	}
+}
+
+func TestPositionStackReverseGeocode(t *testing.T) {
+	c := newPositionStackClient(t, "positionstack_reverse")
+
+	l, err := c.ReverseGeocode(context.Background(), 38.7223, -9.1393)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if l.Name != "Rossio" || l.Country != "Portugal" {
+		t.Errorf("unexpected location %+v", l)
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"log/slog"
 	"os"
 
-	"github.com/manzanit0/weathry/pkg/middleware"
+	"github.com/manzanit0/weathry/pkg/tracing"
 )
 
 func InitGlobalSlog(service string) {
@@ -37,9 +37,8 @@ func (h *ContextJSONHandler) WithGroup(name string) slog.Handler {
 }
 
 func (h *ContextJSONHandler) Handle(ctx context.Context, r slog.Record) error {
-	trace_id := ctx.Value(middleware.CtxKeyTraceID)
-	if str, ok := trace_id.(string); ok {
-		r.AddAttrs(slog.String(string(middleware.CtxKeyTraceID), str))
+	if sc, ok := tracing.SpanContextFromContext(ctx); ok {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID.String()), slog.String("span_id", sc.SpanID.String()))
 	}
 
 	return h.jsonHandler.Handle(ctx, r)
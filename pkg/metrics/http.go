@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ContentType is the content type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Handler serves the metrics of the registry to be scraped.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		var buf bytes.Buffer
+		if err := r.Write(&buf); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", ContentType)
+		_, _ = w.Write(buf.Bytes())
+	})
+}
+
+// Push replaces the metrics of the job in a Prometheus Pushgateway at url,
+// such as http://localhost:9091, with those of the registry.
+func (r *Registry) Push(ctx context.Context, client *http.Client, url, job string) error {
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		return fmt.Errorf("write metrics: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, fmt.Sprintf("%s/metrics/job/%s", url, job), &buf)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", ContentType)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("push metrics: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("push metrics: unexpected status %d: %s", res.StatusCode, body)
+	}
+
+	return nil
+}
+
+// Serve serves the metrics of the registry at addr, under /metrics, until ctx
+// is done.
+func (r *Registry) Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r.Handler())
+
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("shutdown metrics server", "error", err.Error())
+		}
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("serve metrics: %w", err)
+	}
+
+	return nil
+}
+
+// Since returns the seconds elapsed since t0, as histograms of latencies
+// observe them.
+func Since(t0 time.Time) float64 {
+	return time.Since(t0).Seconds()
+}
@@ -0,0 +1,276 @@
+// Package metrics keeps counters, gauges and histograms and writes them in the
+// Prometheus text exposition format, so they can be scraped or pushed.
+//
+// @see https://prometheus.io/docs/instrumenting/exposition_formats/
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the default histogram buckets, in seconds, which suit the
+// latencies of HTTP requests.
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Default is the registry the package level functions register metrics in.
+var Default = NewRegistry()
+
+// Registry holds metrics so they can be written together.
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+	names    map[string]bool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{names: map[string]bool{}}
+}
+
+func (r *Registry) register(f *family) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.names[f.name] {
+		panic(fmt.Sprintf("metrics: %s is already registered", f.name))
+	}
+
+	r.names[f.name] = true
+	r.families = append(r.families, f)
+	return f
+}
+
+// NewCounter registers a counter, a value which only goes up, such as the
+// number of requests.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{r.register(newFamily(name, help, "counter", labels))}
+}
+
+// NewGauge registers a gauge, a value which goes up and down, such as the
+// number of messages waiting to be sent.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r.register(newFamily(name, help, "gauge", labels))}
+}
+
+// NewGaugeFunc registers a gauge whose value is read with fn every time the
+// metrics are written, such as when they're scraped.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	f := newFamily(name, help, "gauge", nil)
+	f.fn = fn
+	r.register(f)
+}
+
+// NewHistogram registers a histogram, which counts observations, such as the
+// latencies of requests, in buckets with the given upper bounds.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	f := newFamily(name, help, "histogram", labels)
+	f.buckets = append([]float64(nil), buckets...)
+	sort.Float64s(f.buckets)
+	return &Histogram{r.register(f)}
+}
+
+// Write writes the metrics in the text exposition format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	families := append([]*family(nil), r.families...)
+	r.mu.Unlock()
+
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	var sb strings.Builder
+	for _, f := range families {
+		f.write(&sb)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	return Default.NewCounter(name, help, labels...)
+}
+
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return Default.NewGauge(name, help, labels...)
+}
+
+func NewGaugeFunc(name, help string, fn func() float64) {
+	Default.NewGaugeFunc(name, help, fn)
+}
+
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return Default.NewHistogram(name, help, buckets, labels...)
+}
+
+type Counter struct{ f *family }
+
+// Inc adds one to the counter with the label values, given in the order the
+// labels were registered in.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v, which mustn't be negative, to the counter with the label
+// values.
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic("metrics: counters can't go down")
+	}
+
+	c.f.update(values, func(s *series) { s.value += v })
+}
+
+type Gauge struct{ f *family }
+
+func (g *Gauge) Set(v float64, values ...string) {
+	g.f.update(values, func(s *series) { s.value = v })
+}
+
+func (g *Gauge) Add(v float64, values ...string) {
+	g.f.update(values, func(s *series) { s.value += v })
+}
+
+type Histogram struct{ f *family }
+
+// Observe counts v in the histogram with the label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.f.update(values, func(s *series) {
+		if s.counts == nil {
+			s.counts = make([]uint64, len(h.f.buckets))
+		}
+
+		for i, le := range h.f.buckets {
+			if v <= le {
+				s.counts[i]++
+			}
+		}
+
+		s.sum += v
+		s.count++
+	})
+}
+
+type family struct {
+	name    string
+	help    string
+	typ     string
+	labels  []string
+	buckets []float64
+	fn      func() float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// series is the value of a family for some label values. Histograms keep the
+// cumulative count of each bucket besides their sum and count.
+type series struct {
+	values []string
+	value  float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newFamily(name, help, typ string, labels []string) *family {
+	return &family{name: name, help: help, typ: typ, labels: labels, series: map[string]*series{}}
+}
+
+func (f *family) update(values []string, fn func(*series)) {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels but got %d values", f.name, len(f.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: append([]string(nil), values...)}
+		f.series[key] = s
+	}
+
+	fn(s)
+}
+
+func (f *family) write(sb *strings.Builder) {
+	fmt.Fprintf(sb, "# HELP %s %s\n", f.name, helpEscaper.Replace(f.help))
+	fmt.Fprintf(sb, "# TYPE %s %s\n", f.name, f.typ)
+
+	if f.fn != nil {
+		writeSample(sb, f.name, nil, nil, f.fn())
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := f.series[k]
+		if f.typ != "histogram" {
+			writeSample(sb, f.name, f.labels, s.values, s.value)
+			continue
+		}
+
+		labels := append(append([]string(nil), f.labels...), "le")
+		for i, le := range f.buckets {
+			writeSample(sb, f.name+"_bucket", labels, append(append([]string(nil), s.values...), formatFloat(le)), float64(s.counts[i]))
+		}
+
+		writeSample(sb, f.name+"_bucket", labels, append(append([]string(nil), s.values...), "+Inf"), float64(s.count))
+		writeSample(sb, f.name+"_sum", f.labels, s.values, s.sum)
+		writeSample(sb, f.name+"_count", f.labels, s.values, float64(s.count))
+	}
+}
+
+func writeSample(sb *strings.Builder, name string, labels, values []string, v float64) {
+	sb.WriteString(name)
+	if len(labels) > 0 {
+		sb.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+
+			fmt.Fprintf(sb, `%s="%s"`, l, labelEscaper.Replace(values[i]))
+		}
+
+		sb.WriteByte('}')
+	}
+
+	sb.WriteByte(' ')
+	sb.WriteString(formatFloat(v))
+	sb.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
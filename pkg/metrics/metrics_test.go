@@ -0,0 +1,120 @@
+package metrics_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/manzanit0/weathry/pkg/metrics"
+)
+
+func TestWrite(t *testing.T) {
+	r := metrics.NewRegistry()
+
+	requests := r.NewCounter("requests_total", "Requests by command.", "command")
+	requests.Inc("/daily")
+	requests.Inc("/daily")
+	requests.Add(3, `say "hi"`)
+
+	queued := r.NewGauge("queued", "Queued messages.\nAll of them.")
+	queued.Set(4)
+	queued.Add(-1)
+
+	r.NewGaugeFunc("users", "Active users.", func() float64 { return 7 })
+
+	latency := r.NewHistogram("latency_seconds", "Latency.", []float64{1, 0.1}, "host")
+	latency.Observe(0.05, "api.telegram.org")
+	latency.Observe(0.5, "api.telegram.org")
+	latency.Observe(5, "api.telegram.org")
+
+	var sb strings.Builder
+	if err := r.Write(&sb); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{host="api.telegram.org",le="0.1"} 1
+latency_seconds_bucket{host="api.telegram.org",le="1"} 2
+latency_seconds_bucket{host="api.telegram.org",le="+Inf"} 3
+latency_seconds_sum{host="api.telegram.org"} 5.55
+latency_seconds_count{host="api.telegram.org"} 3
+# HELP queued Queued messages.\nAll of them.
+# TYPE queued gauge
+queued 3
+# HELP requests_total Requests by command.
+# TYPE requests_total counter
+requests_total{command="/daily"} 2
+requests_total{command="say \"hi\""} 3
+# HELP users Active users.
+# TYPE users gauge
+users 7
+`
+
+	if got := sb.String(); got != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, expected)
+	}
+}
+
+func TestUpdatePanics(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		update func(r *metrics.Registry)
+	}{
+		{
+			desc:   "wrong number of label values",
+			update: func(r *metrics.Registry) { r.NewCounter("c", "", "a", "b").Inc("a") },
+		},
+		{
+			desc:   "counters going down",
+			update: func(r *metrics.Registry) { r.NewCounter("c", "").Add(-1) },
+		},
+		{
+			desc: "registering twice",
+			update: func(r *metrics.Registry) {
+				r.NewCounter("c", "")
+				r.NewGauge("c", "")
+			},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected a panic")
+				}
+			}()
+
+			tC.update(metrics.NewRegistry())
+		})
+	}
+}
+
+func TestPush(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.NewCounter("pings_total", "Pings.").Inc()
+
+	var method, path, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		method, path, body = req.Method, req.URL.Path, string(b)
+	}))
+	defer srv.Close()
+
+	err := r.Push(context.Background(), srv.Client(), srv.URL, "pinger")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if method != http.MethodPut || path != "/metrics/job/pinger" {
+		t.Errorf("got %s %s, expected PUT /metrics/job/pinger", method, path)
+	}
+
+	if !strings.Contains(body, "pings_total 1\n") {
+		t.Errorf("expected the metrics to be pushed, got:\n%s", body)
+	}
+}
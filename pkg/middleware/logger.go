@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/manzanit0/weathry/pkg/redact"
 )
 
 type responseBodyWriter struct {
@@ -18,7 +19,28 @@ func (r responseBodyWriter) Write(b []byte) (int, error) {
 	return r.ResponseWriter.Write(b)
 }
 
-func Logger(debug bool) gin.HandlerFunc {
+type loggerOptions struct {
+	policy redact.Policy
+}
+
+type LoggerOption func(*loggerOptions)
+
+// WithRedaction sets what's masked out of the logs. Defaults to
+// redact.Default.
+func WithRedaction(p redact.Policy) LoggerOption {
+	return func(o *loggerOptions) {
+		o.policy = p
+	}
+}
+
+// Logger logs every request, along with the response body when debugging.
+// Secrets are masked out of the query params, the headers and the body.
+func Logger(debug bool, opts ...LoggerOption) gin.HandlerFunc {
+	o := loggerOptions{policy: redact.Default}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	return func(c *gin.Context) {
 		w := &responseBodyWriter{body: &bytes.Buffer{}, ResponseWriter: c.Writer}
 		c.Writer = w
@@ -27,15 +49,9 @@ func Logger(debug bool) gin.HandlerFunc {
 
 		c.Next()
 
-		// Ofuscate access_key if it exists.
-		q := c.Request.URL.Query()
-		if q.Has("access_key") {
-			q.Set("access_key", "*****")
-		}
-
 		body := "<redacted>"
 		if debug {
-			body = w.body.String()
+			body = string(o.policy.JSON(w.body.Bytes()))
 		}
 
 		logFields := []any{
@@ -44,12 +60,12 @@ func Logger(debug bool) gin.HandlerFunc {
 					"duration_ms", time.Since(t0).Milliseconds(),
 					"method", c.Request.Method,
 					"content_length", c.Request.ContentLength,
-					"headers", c.Request.Header,
+					"headers", o.policy.Header(c.Request.Header),
 					slog.Group("url",
 						"scheme", c.Request.URL.Scheme,
 						"host", c.Request.URL.Host,
-						"path", c.Request.URL.Path,
-						"query_params", q,
+						"path", o.policy.String(c.Request.URL.Path),
+						"query_params", o.policy.Query(c.Request.URL.Query()),
 					),
 				),
 				slog.Group("response",
@@ -0,0 +1,43 @@
+package middleware_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/manzanit0/weathry/pkg/middleware"
+	"github.com/manzanit0/weathry/pkg/redact"
+)
+
+func TestLoggerRedactsSecrets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	r := gin.New()
+	r.Use(middleware.Logger(true, middleware.WithRedaction(redact.Default.Merge(redact.Policy{BodyFields: []string{"chat_id"}}))))
+	r.POST("/telegram/webhook", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"method": "sendMessage", "chat_id": 4242424242, "text": "token=123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw"})
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/telegram/webhook?token=s3cr3t", nil)
+	req.Header.Set("X-Telegram-Bot-Api-Secret-Token", "s3cr3t")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	out := logs.String()
+	if !strings.Contains(out, "inbound request") {
+		t.Fatalf("expected the request to be logged, got %s", out)
+	}
+
+	for _, secret := range []string{"s3cr3t", "AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw", "4242424242"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("expected %s to be masked, got %s", secret, out)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/manzanit0/weathry/pkg/metrics"
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+var (
+	webhookRequests = metrics.NewCounter(
+		"weathry_webhook_requests_total",
+		"Telegram webhook requests by command and response status.",
+		"command", "status",
+	)
+
+	webhookDuration = metrics.NewHistogram(
+		"weathry_webhook_request_duration_seconds",
+		"Time taken to handle Telegram webhook requests, by command.",
+		metrics.DefBuckets,
+		"command",
+	)
+)
+
+// Metrics records the count and latency of webhook requests by the command
+// they carry. Commands other than the given ones are labelled "other", so
+// users can't make up labels. It has to run before TelegramAuth, which parses
+// the payload the command is read from.
+func Metrics(commands ...string) gin.HandlerFunc {
+	known := map[string]bool{}
+	for _, c := range commands {
+		known[c] = true
+	}
+
+	return func(c *gin.Context) {
+		t0 := time.Now()
+
+		c.Next()
+
+		command := "invalid"
+		if i, ok := c.Get(CtxKeyPayload); ok {
+			command = commandOf(i.(*tgram.WebhookRequest), known)
+		}
+
+		webhookRequests.Inc(command, statusClass(c.Writer.Status()))
+		webhookDuration.Observe(metrics.Since(t0), command)
+	}
+}
+
+func commandOf(p *tgram.WebhookRequest, known map[string]bool) string {
+	switch {
+	case p.IsCallbackQuery():
+		return "callback"
+	case p.Message == nil:
+		return "unsupported"
+	case !strings.HasPrefix(p.Message.Text, "/"):
+		return "message"
+	}
+
+	command, _, _ := strings.Cut(strings.Fields(p.Message.Text)[0], "@")
+	if !known[command] {
+		return "other"
+	}
+
+	return command
+}
+
+// statusClass returns the class of the status, such as 2xx, to keep the
+// labels few.
+func statusClass(status int) string {
+	return string(rune('0'+status/100)) + "xx"
+}
@@ -30,11 +30,14 @@ func HandleRecover(ctx context.Context, r any, t tgram.Client, reportChat int64)
 	slog.ErrorContext(ctx, "recovered from panic", "callstack", callstack)
 
 	if t != nil {
-		_ = t.SendMessage(tgram.SendMessageRequest{
+		_ = t.SendMessage(ctx, tgram.SendMessageRequest{
 			ParseMode: tgram.ParseModeHTML,
 			ChatID:    reportChat,
-			Text: fmt.Sprintf(`<b>Recovered from panic: %v</b>
-<code>%s</code>`, r, callstack),
+			Text: tgram.NewRichText().
+				Bold(fmt.Sprintf("Recovered from panic: %v", r)).
+				Plain("\n").
+				Code(callstack).
+				HTML(),
 		})
 	}
 
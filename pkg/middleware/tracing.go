@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/manzanit0/weathry/pkg/tgram"
+	"github.com/manzanit0/weathry/pkg/tracing"
+)
+
+// Tracing starts a span for every request, continuing the trace of the
+// caller's traceparent header if there's one.
+func Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := tracing.Extract(c.Request.Context(), c.Request.Header)
+		ctx, span := tracing.Start(ctx, fmt.Sprintf("%s %s", c.Request.Method, c.FullPath()),
+			tracing.WithKind(tracing.KindServer),
+			tracing.WithAttributes(
+				slog.String("http.request.method", c.Request.Method),
+				slog.String("http.route", c.FullPath()),
+			))
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		span.SetAttributes(slog.Int("http.response.status_code", c.Writer.Status()))
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			span.RecordError(fmt.Errorf("responded with status %d", c.Writer.Status()))
+		}
+	}
+}
+
+// CommandSpan starts a span named after the command of the webhook request,
+// such as "command /daily", so the calls made for it are grouped under it.
+// Commands other than the given ones are named "other". It has to run after
+// TelegramAuth, which parses the payload the command is read from.
+func CommandSpan(commands ...string) gin.HandlerFunc {
+	known := map[string]bool{}
+	for _, c := range commands {
+		known[c] = true
+	}
+
+	return func(c *gin.Context) {
+		i, ok := c.Get(CtxKeyPayload)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		p := i.(*tgram.WebhookRequest)
+		ctx, span := tracing.Start(c.Request.Context(), "command "+commandOf(p, known),
+			tracing.WithAttributes(slog.Int("user.id", p.GetFromID())))
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
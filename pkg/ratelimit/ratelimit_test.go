@@ -0,0 +1,52 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/ratelimit"
+)
+
+func TestAllowRespectsBurst(t *testing.T) {
+	l := ratelimit.New(1, 3)
+
+	for i := 0; i < 3; i++ {
+		if !l.Allow() {
+			t.Fatalf("expected event %d to be allowed", i)
+		}
+	}
+
+	if l.Allow() {
+		t.Errorf("expected event to be rate limited once the burst is spent")
+	}
+}
+
+func TestWaitSpacesOutEvents(t *testing.T) {
+	l := ratelimit.New(100, 1)
+
+	t0 := time.Now()
+	for i := 0; i < 6; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+	}
+
+	// The first event goes through straight away and the other 5 wait 10ms
+	// each.
+	if elapsed := time.Since(t0); elapsed < 45*time.Millisecond {
+		t.Errorf("6 events took %s, expected at least 50ms", elapsed)
+	}
+}
+
+func TestWaitStopsWhenContextIsDone(t *testing.T) {
+	l := ratelimit.New(0.1, 1)
+	l.Allow()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := l.Wait(ctx); err == nil {
+		t.Errorf("expected an error, got nil")
+	}
+}
@@ -0,0 +1,227 @@
+// Package redact masks secrets, such as API keys and bot tokens, out of what
+// is logged about HTTP requests.
+package redact
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Mask is what secrets are replaced with.
+const Mask = "*****"
+
+// Policy is what's considered a secret. Names are matched regardless of case.
+type Policy struct {
+	// QueryKeys are the query params whose values are masked, such as appid.
+	QueryKeys []string
+
+	// Headers are the headers whose values are masked, such as Authorization.
+	Headers []string
+
+	// BodyFields are the fields of JSON bodies whose values are masked, at
+	// any depth.
+	BodyFields []string
+
+	// Patterns are the shapes of secrets which are masked wherever they show
+	// up, such as in paths or error messages.
+	Patterns []*regexp.Regexp
+}
+
+// Default masks the secrets of the APIs we talk to: OpenWeatherMap's appid,
+// PositionStack's access_key and Telegram's bot tokens, besides the usual
+// credentials.
+var Default = Policy{
+	QueryKeys: []string{"appid", "access_key", "api_key", "apikey", "key", "token", "secret", "password"},
+	Headers: []string{
+		"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key",
+		"X-Telegram-Bot-Api-Secret-Token",
+	},
+	BodyFields: []string{"token", "secret", "password", "appid", "access_key", "api_key", "apikey"},
+	Patterns: []*regexp.Regexp{
+		// Telegram bot tokens, such as 123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw.
+		regexp.MustCompile(`\d{5,}:[A-Za-z0-9_-]{30,}`),
+	},
+}
+
+// Merge returns a policy with the secrets of both policies.
+func (p Policy) Merge(other Policy) Policy {
+	return Policy{
+		QueryKeys:  append(append([]string(nil), p.QueryKeys...), other.QueryKeys...),
+		Headers:    append(append([]string(nil), p.Headers...), other.Headers...),
+		BodyFields: append(append([]string(nil), p.BodyFields...), other.BodyFields...),
+		Patterns:   append(append([]*regexp.Regexp(nil), p.Patterns...), other.Patterns...),
+	}
+}
+
+// String masks the secrets in s which match the patterns.
+func (p Policy) String(s string) string {
+	for _, re := range p.Patterns {
+		s = re.ReplaceAllString(s, Mask)
+	}
+
+	return s
+}
+
+// Query returns a copy of q with the values of the query keys masked.
+func (p Policy) Query(q url.Values) url.Values {
+	out := make(url.Values, len(q))
+	for k, vv := range q {
+		secret := contains(p.QueryKeys, k)
+		for _, v := range vv {
+			if secret {
+				v = Mask
+			}
+
+			out[k] = append(out[k], p.String(v))
+		}
+	}
+
+	return out
+}
+
+// URL returns u as a string, with its secrets masked. Masks aren't escaped,
+// so they're easy to spot.
+func (p Policy) URL(u *url.URL) string {
+	var sb strings.Builder
+	if u.Scheme != "" {
+		sb.WriteString(u.Scheme + "://")
+	}
+
+	sb.WriteString(u.Host)
+	sb.WriteString(p.String(u.Path))
+
+	q := p.Query(u.Query())
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for i, k := range keys {
+		for j, v := range q[k] {
+			if i == 0 && j == 0 {
+				sb.WriteByte('?')
+			} else {
+				sb.WriteByte('&')
+			}
+
+			if v != Mask {
+				v = url.QueryEscape(v)
+			}
+
+			sb.WriteString(url.QueryEscape(k) + "=" + v)
+		}
+	}
+
+	return sb.String()
+}
+
+// Header returns a copy of h with the values of the headers masked.
+func (p Policy) Header(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for k, vv := range h {
+		secret := contains(p.Headers, k)
+		for _, v := range vv {
+			if secret {
+				v = Mask
+			}
+
+			out[k] = append(out[k], p.String(v))
+		}
+	}
+
+	return out
+}
+
+// JSON masks the values of the body fields in body. Bodies which aren't JSON
+// only have the patterns masked.
+func (p Policy) JSON(body []byte) []byte {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return []byte(p.String(string(body)))
+	}
+
+	b, err := json.Marshal(p.value(v))
+	if err != nil {
+		return []byte(p.String(string(body)))
+	}
+
+	return b
+}
+
+func (p Policy) value(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, field := range v {
+			if contains(p.BodyFields, k) {
+				v[k] = Mask
+				continue
+			}
+
+			v[k] = p.value(field)
+		}
+
+		return v
+	case []any:
+		for i := range v {
+			v[i] = p.value(v[i])
+		}
+
+		return v
+	case string:
+		return p.String(v)
+	default:
+		return v
+	}
+}
+
+// Error returns the message of err with its secrets masked. The URLs of
+// failed requests, which carry query params, are masked like with URL.
+func (p Policy) Error(err error) string {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return p.String(err.Error())
+	}
+
+	msg := err.Error()
+	if u, parseErr := url.Parse(urlErr.URL); parseErr == nil {
+		msg = strings.ReplaceAll(msg, urlErr.URL, p.URL(u))
+	}
+
+	return p.String(msg)
+}
+
+// URLError masks the URL of err if it's a *url.Error, which is what
+// http.Client returns, so that clients can return errors safe to log. Other
+// errors are returned as they are. It has to be called before wrapping err,
+// since wrapped errors keep the message they were created with.
+func (p Policy) URLError(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+
+	if u, parseErr := url.Parse(urlErr.URL); parseErr == nil {
+		urlErr.URL = p.URL(u)
+	} else {
+		urlErr.URL = p.String(urlErr.URL)
+	}
+
+	return err
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,148 @@
+package redact_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/manzanit0/weathry/pkg/redact"
+)
+
+const botToken = "123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw"
+
+func TestURL(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		url      string
+		expected string
+	}{
+		{
+			desc:     "bot token in the path",
+			url:      "https://api.telegram.org/bot" + botToken + "/sendMessage",
+			expected: "https://api.telegram.org/bot*****/sendMessage",
+		},
+		{
+			desc:     "api key in the query",
+			url:      "https://api.openweathermap.org/data/3.0/onecall?lon=2.17&appid=s3cr3t&lat=41.38",
+			expected: "https://api.openweathermap.org/data/3.0/onecall?appid=*****&lat=41.38&lon=2.17",
+		},
+		{
+			desc:     "keys regardless of case",
+			url:      "http://api.positionstack.com/v1/forward?ACCESS_KEY=s3cr3t&query=Barcelona%2C+Spain",
+			expected: "http://api.positionstack.com/v1/forward?ACCESS_KEY=*****&query=Barcelona%2C+Spain",
+		},
+		{
+			desc:     "nothing to mask",
+			url:      "https://nominatim.openstreetmap.org/search?q=Madrid",
+			expected: "https://nominatim.openstreetmap.org/search?q=Madrid",
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			u, err := url.Parse(tC.url)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			if got := redact.Default.URL(u); got != tC.expected {
+				t.Errorf("got %s, expected %s", got, tC.expected)
+			}
+		})
+	}
+}
+
+func TestHeader(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Bearer s3cr3t")
+	h.Set("X-Telegram-Bot-Api-Secret-Token", "s3cr3t")
+	h.Set("Content-Type", "application/json")
+
+	got := redact.Default.Header(h)
+	if got.Get("Authorization") != redact.Mask || got.Get("X-Telegram-Bot-Api-Secret-Token") != redact.Mask {
+		t.Errorf("expected the credentials to be masked, got %v", got)
+	}
+
+	if got.Get("Content-Type") != "application/json" {
+		t.Errorf("expected other headers to be kept, got %v", got)
+	}
+
+	if h.Get("Authorization") != "Bearer s3cr3t" {
+		t.Errorf("expected the original headers to be left alone")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		body     string
+		expected string
+	}{
+		{
+			desc:     "nested fields",
+			body:     `{"chat_id":42,"auth":{"Token":"s3cr3t"},"items":[{"password":"hunter2"}]}`,
+			expected: `{"auth":{"Token":"*****"},"chat_id":42,"items":[{"password":"*****"}]}`,
+		},
+		{
+			desc:     "patterns in values",
+			body:     `{"text":"my token is ` + botToken + `"}`,
+			expected: `{"text":"my token is *****"}`,
+		},
+		{
+			desc:     "not JSON",
+			body:     "token=" + botToken,
+			expected: "token=*****",
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if got := string(redact.Default.JSON([]byte(tC.body))); got != tC.expected {
+				t.Errorf("got %s, expected %s", got, tC.expected)
+			}
+		})
+	}
+}
+
+func TestURLError(t *testing.T) {
+	err := fmt.Errorf("unable to do request: %w", &url.Error{
+		Op:  "Post",
+		URL: "https://api.telegram.org/bot" + botToken + "/sendMessage?appid=s3cr3t",
+		Err: errors.New("connection refused"),
+	})
+
+	if msg := redact.Default.Error(err); strings.Contains(msg, botToken) || strings.Contains(msg, "s3cr3t") {
+		t.Errorf("expected the secrets to be masked, got %s", msg)
+	}
+
+	err = fmt.Errorf("unable to do request: %w", redact.Default.URLError(errors.Unwrap(err)))
+	expected := `unable to do request: Post "https://api.telegram.org/bot*****/sendMessage?appid=*****": connection refused`
+	if err.Error() != expected {
+		t.Errorf("got %s, expected %s", err.Error(), expected)
+	}
+
+	plain := errors.New("boom")
+	if redact.Default.URLError(plain) != plain {
+		t.Errorf("expected other errors to be returned as they are")
+	}
+
+	if redact.Default.URLError(nil) != nil {
+		t.Errorf("expected nil errors to stay nil")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	p := redact.Default.Merge(redact.Policy{QueryKeys: []string{"lat", "lon"}})
+
+	got := p.Query(url.Values{"lat": {"41.38"}, "appid": {"s3cr3t"}, "units": {"metric"}})
+	if got.Get("lat") != redact.Mask || got.Get("appid") != redact.Mask || got.Get("units") != "metric" {
+		t.Errorf("unexpected query %v", got)
+	}
+
+	if len(redact.Default.QueryKeys) == len(p.QueryKeys) {
+		t.Errorf("expected the default policy to be left alone")
+	}
+}
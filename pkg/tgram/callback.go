@@ -0,0 +1,287 @@
+package tgram
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MaxCallbackDataLength is the size limit Telegram puts on the callback data
+// of inline buttons, in bytes.
+//
+// @see https://core.telegram.org/bots/api#inlinekeyboardbutton
+const MaxCallbackDataLength = 64
+
+const (
+	callbackSeparator = ":"
+
+	// callbackMACLength is how many base64 characters of the HMAC are kept:
+	// 60 bits, which is plenty given nobody gets to try more than a few per
+	// second.
+	callbackMACLength = 10
+
+	// storedCallbackAction marks callback data which only carries the key of
+	// the actual payload in the CallbackStore.
+	storedCallbackAction = "~"
+)
+
+var (
+	ErrInvalidCallbackData   = errors.New("invalid callback data")
+	ErrCallbackDataSignature = errors.New("callback data signature mismatch")
+	ErrCallbackDataNotFound  = errors.New("callback data not found")
+)
+
+// CallbackData is the payload of an inline button: the action it triggers,
+// the version of the action's fields and the fields themselves.
+type CallbackData struct {
+	Action  string
+	Version int
+	Fields  []string
+}
+
+// NewCallbackData builds callback data out of strings, ints, floats and
+// bools. Floats are written as short as possible to save space.
+func NewCallbackData(action string, version int, fields ...any) CallbackData {
+	d := CallbackData{Action: action, Version: version, Fields: make([]string, len(fields))}
+	for i, f := range fields {
+		switch v := f.(type) {
+		case string:
+			d.Fields[i] = v
+		case int:
+			d.Fields[i] = strconv.Itoa(v)
+		case float64:
+			d.Fields[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			d.Fields[i] = "0"
+			if v {
+				d.Fields[i] = "1"
+			}
+		default:
+			d.Fields[i] = fmt.Sprint(v)
+		}
+	}
+
+	return d
+}
+
+func (d CallbackData) field(i int) (string, error) {
+	if i < 0 || i >= len(d.Fields) {
+		return "", fmt.Errorf("%w: %s v%d has no field %d", ErrInvalidCallbackData, d.Action, d.Version, i)
+	}
+
+	return d.Fields[i], nil
+}
+
+func (d CallbackData) String(i int) (string, error) {
+	return d.field(i)
+}
+
+func (d CallbackData) Int(i int) (int, error) {
+	s, err := d.field(i)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: field %d isn't an int: %s", ErrInvalidCallbackData, i, err.Error())
+	}
+
+	return n, nil
+}
+
+func (d CallbackData) Float(i int) (float64, error) {
+	s, err := d.field(i)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: field %d isn't a float: %s", ErrInvalidCallbackData, i, err.Error())
+	}
+
+	return f, nil
+}
+
+func (d CallbackData) Bool(i int) (bool, error) {
+	s, err := d.field(i)
+	if err != nil {
+		return false, err
+	}
+
+	switch s {
+	case "1":
+		return true, nil
+	case "0":
+		return false, nil
+	default:
+		return false, fmt.Errorf("%w: field %d isn't a bool", ErrInvalidCallbackData, i)
+	}
+}
+
+func (d CallbackData) encode() (string, error) {
+	if d.Action == "" || d.Action == storedCallbackAction || strings.Contains(d.Action, callbackSeparator) {
+		return "", fmt.Errorf("%w: invalid action %q", ErrInvalidCallbackData, d.Action)
+	}
+
+	for i, f := range d.Fields {
+		if strings.Contains(f, callbackSeparator) {
+			return "", fmt.Errorf("%w: field %d contains %q", ErrInvalidCallbackData, i, callbackSeparator)
+		}
+	}
+
+	parts := append([]string{d.Action, strconv.Itoa(d.Version)}, d.Fields...)
+	return strings.Join(parts, callbackSeparator), nil
+}
+
+func decodeCallbackData(payload string) (CallbackData, error) {
+	parts := strings.Split(payload, callbackSeparator)
+	if len(parts) < 2 || parts[0] == "" {
+		return CallbackData{}, fmt.Errorf("%w: missing action or version", ErrInvalidCallbackData)
+	}
+
+	version, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return CallbackData{}, fmt.Errorf("%w: invalid version %q", ErrInvalidCallbackData, parts[1])
+	}
+
+	return CallbackData{Action: parts[0], Version: version, Fields: parts[2:]}, nil
+}
+
+// CallbackStore keeps the payloads which don't fit in the callback data.
+type CallbackStore interface {
+	SaveCallbackData(ctx context.Context, key, payload string) error
+
+	// GetCallbackData returns ErrCallbackDataNotFound when there's no payload
+	// for the key.
+	GetCallbackData(ctx context.Context, key string) (string, error)
+}
+
+// CallbackCodec signs callback data, so the bot only acts on buttons it
+// created itself. Payloads which don't fit in Telegram's limit once signed
+// are kept in the store, and the button only carries their key.
+type CallbackCodec struct {
+	secret []byte
+	store  CallbackStore
+}
+
+// NewCallbackCodec creates a codec signing with secret. The store is
+// optional, without it payloads which are too long fail to encode.
+func NewCallbackCodec(secret []byte, store CallbackStore) *CallbackCodec {
+	return &CallbackCodec{secret: secret, store: store}
+}
+
+func (c *CallbackCodec) Encode(ctx context.Context, d CallbackData) (string, error) {
+	payload, err := d.encode()
+	if err != nil {
+		return "", err
+	}
+
+	if len(payload)+len(callbackSeparator)+callbackMACLength <= MaxCallbackDataLength {
+		return c.sign(payload), nil
+	}
+
+	if c.store == nil {
+		return "", fmt.Errorf("%w: %d bytes don't fit in a button and there's no store", ErrInvalidCallbackData, len(payload))
+	}
+
+	key, err := newCallbackKey()
+	if err != nil {
+		return "", fmt.Errorf("generate key: %w", err)
+	}
+
+	err = c.store.SaveCallbackData(ctx, key, payload)
+	if err != nil {
+		return "", fmt.Errorf("save callback data: %w", err)
+	}
+
+	return c.sign(storedCallbackAction + callbackSeparator + key), nil
+}
+
+func (c *CallbackCodec) Decode(ctx context.Context, data string) (CallbackData, error) {
+	i := strings.LastIndex(data, callbackSeparator)
+	if i < 0 {
+		return CallbackData{}, fmt.Errorf("%w: missing signature", ErrInvalidCallbackData)
+	}
+
+	payload, mac := data[:i], data[i+1:]
+	if !hmac.Equal([]byte(mac), []byte(c.mac(payload))) {
+		return CallbackData{}, ErrCallbackDataSignature
+	}
+
+	key, stored := strings.CutPrefix(payload, storedCallbackAction+callbackSeparator)
+	if !stored {
+		return decodeCallbackData(payload)
+	}
+
+	if c.store == nil {
+		return CallbackData{}, fmt.Errorf("%w: no store to look up %q", ErrCallbackDataNotFound, key)
+	}
+
+	payload, err := c.store.GetCallbackData(ctx, key)
+	if err != nil {
+		return CallbackData{}, fmt.Errorf("get callback data: %w", err)
+	}
+
+	return decodeCallbackData(payload)
+}
+
+func (c *CallbackCodec) sign(payload string) string {
+	return payload + callbackSeparator + c.mac(payload)
+}
+
+func (c *CallbackCodec) mac(payload string) string {
+	h := hmac.New(sha256.New, c.secret)
+	h.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))[:callbackMACLength]
+}
+
+func newCallbackKey() (string, error) {
+	b := make([]byte, 9)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// MemoryCallbackStore is a CallbackStore for a single process, such as tests
+// or dry runs.
+type MemoryCallbackStore struct {
+	mu       sync.Mutex
+	payloads map[string]string
+}
+
+var _ CallbackStore = (*MemoryCallbackStore)(nil)
+
+func NewMemoryCallbackStore() *MemoryCallbackStore {
+	return &MemoryCallbackStore{payloads: map[string]string{}}
+}
+
+func (s *MemoryCallbackStore) SaveCallbackData(_ context.Context, key, payload string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.payloads[key] = payload
+	return nil
+}
+
+func (s *MemoryCallbackStore) GetCallbackData(_ context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payload, ok := s.payloads[key]
+	if !ok {
+		return "", ErrCallbackDataNotFound
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,126 @@
+package tgram_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+func TestCallbackCodec(t *testing.T) {
+	ctx := context.Background()
+	codec := tgram.NewCallbackCodec([]byte("secret"), nil)
+
+	t.Run("round trips typed fields", func(t *testing.T) {
+		data, err := codec.Encode(ctx, tgram.NewCallbackData("fc", 1, "h", 2, true, 40.4168, -3.7038))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+
+		if len(data) > tgram.MaxCallbackDataLength {
+			t.Errorf("encoded data is %d bytes long", len(data))
+		}
+
+		d, err := codec.Decode(ctx, data)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+
+		if d.Action != "fc" || d.Version != 1 {
+			t.Errorf("got action %q and version %d", d.Action, d.Version)
+		}
+
+		view, _ := d.String(0)
+		page, _ := d.Int(1)
+		inPlace, _ := d.Bool(2)
+		lat, _ := d.Float(3)
+		lon, _ := d.Float(4)
+		if view != "h" || page != 2 || !inPlace || lat != 40.4168 || lon != -3.7038 {
+			t.Errorf("got fields %q %d %t %f %f", view, page, inPlace, lat, lon)
+		}
+
+		if _, err := d.Int(5); !errors.Is(err, tgram.ErrInvalidCallbackData) {
+			t.Errorf("expected a missing field to be invalid, got %v", err)
+		}
+
+		if _, err := d.Int(0); !errors.Is(err, tgram.ErrInvalidCallbackData) {
+			t.Errorf("expected a mistyped field to be invalid, got %v", err)
+		}
+	})
+
+	t.Run("rejects forged data", func(t *testing.T) {
+		data, err := codec.Encode(ctx, tgram.NewCallbackData("sn", 1, "1d"))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+
+		forged := strings.Replace(data, "1d", "90d", 1)
+		if _, err := codec.Decode(ctx, forged); !errors.Is(err, tgram.ErrCallbackDataSignature) {
+			t.Errorf("expected a signature mismatch, got %v", err)
+		}
+
+		other := tgram.NewCallbackCodec([]byte("another secret"), nil)
+		if _, err := other.Decode(ctx, data); !errors.Is(err, tgram.ErrCallbackDataSignature) {
+			t.Errorf("expected a signature mismatch with another secret, got %v", err)
+		}
+	})
+
+	t.Run("rejects legacy unsigned data", func(t *testing.T) {
+		for _, data := range []string{"hourly:40.416800,-3.703800", "snooze:1d", "brief", ""} {
+			if _, err := codec.Decode(ctx, data); err == nil {
+				t.Errorf("expected %q to be rejected", data)
+			}
+		}
+	})
+
+	t.Run("rejects fields with the separator", func(t *testing.T) {
+		if _, err := codec.Encode(ctx, tgram.NewCallbackData("fc", 1, "a:b")); !errors.Is(err, tgram.ErrInvalidCallbackData) {
+			t.Errorf("expected invalid data, got %v", err)
+		}
+	})
+
+	t.Run("fails on long payloads without a store", func(t *testing.T) {
+		if _, err := codec.Encode(ctx, tgram.NewCallbackData("fc", 1, strings.Repeat("x", 64))); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestCallbackCodecStore(t *testing.T) {
+	ctx := context.Background()
+	store := tgram.NewMemoryCallbackStore()
+	codec := tgram.NewCallbackCodec([]byte("secret"), store)
+
+	long := strings.Repeat("x", 80)
+
+	data, err := codec.Encode(ctx, tgram.NewCallbackData("fc", 1, long, 3))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if len(data) > tgram.MaxCallbackDataLength {
+		t.Errorf("encoded data is %d bytes long", len(data))
+	}
+
+	d, err := codec.Decode(ctx, data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if s, _ := d.String(0); s != long {
+		t.Errorf("got field %q", s)
+	}
+
+	if n, _ := d.Int(1); n != 3 {
+		t.Errorf("got field %d", n)
+	}
+
+	t.Run("unknown keys aren't found", func(t *testing.T) {
+		other := tgram.NewCallbackCodec([]byte("secret"), tgram.NewMemoryCallbackStore())
+		if _, err := other.Decode(ctx, data); !errors.Is(err, tgram.ErrCallbackDataNotFound) {
+			t.Errorf("expected not found, got %v", err)
+		}
+	})
+}
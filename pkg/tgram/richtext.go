@@ -0,0 +1,213 @@
+package tgram
+
+import (
+	"strings"
+)
+
+type entity int
+
+const (
+	entityPlain entity = iota
+	entityBold
+	entityItalic
+	entityCode
+	entityPre
+	entityLink
+	entitySpoiler
+)
+
+type span struct {
+	entity entity
+	text   string
+
+	// language of pre blocks, or URL of links.
+	attr string
+}
+
+// RichText builds messages out of plain text and formatting entities, which
+// render to either MarkdownV2 or HTML with whatever escaping each one needs.
+// The zero value is an empty text ready to use.
+//
+// Entities can't be nested, and empty ones are left out since Telegram
+// rejects them.
+//
+// @see https://core.telegram.org/bots/api#formatting-options
+type RichText struct {
+	spans []span
+}
+
+func NewRichText() *RichText {
+	return &RichText{}
+}
+
+func (t *RichText) add(e entity, text, attr string) *RichText {
+	if text == "" {
+		return t
+	}
+
+	// Adjacent entities of the same kind are merged, since the delimiters of
+	// one right after the other's would be ambiguous, as "__" is in
+	// MarkdownV2.
+	if n := len(t.spans); n > 0 && e != entityLink {
+		if last := &t.spans[n-1]; last.entity == e && last.attr == attr {
+			last.text += text
+			return t
+		}
+	}
+
+	t.spans = append(t.spans, span{entity: e, text: text, attr: attr})
+	return t
+}
+
+// Plain adds text without any formatting.
+func (t *RichText) Plain(text string) *RichText {
+	return t.add(entityPlain, text, "")
+}
+
+func (t *RichText) Bold(text string) *RichText {
+	return t.add(entityBold, text, "")
+}
+
+func (t *RichText) Italic(text string) *RichText {
+	return t.add(entityItalic, text, "")
+}
+
+// Code adds inline monospace text, such as a command or an expression.
+func (t *RichText) Code(text string) *RichText {
+	return t.add(entityCode, text, "")
+}
+
+// Pre adds a block of monospace text, such as a table. The language is
+// optional, and left out unless it's made of letters, digits, "_", "+" or "-".
+func (t *RichText) Pre(text, language string) *RichText {
+	if !validLanguage(language) {
+		language = ""
+	}
+
+	return t.add(entityPre, text, language)
+}
+
+// Link adds text pointing at url. Without an url, the text is added as plain.
+func (t *RichText) Link(text, url string) *RichText {
+	if url == "" {
+		return t.Plain(text)
+	}
+
+	return t.add(entityLink, text, url)
+}
+
+// Spoiler adds text which is hidden until tapped.
+func (t *RichText) Spoiler(text string) *RichText {
+	return t.add(entitySpoiler, text, "")
+}
+
+// Append adds all of other to the end of t.
+func (t *RichText) Append(other *RichText) *RichText {
+	for _, s := range other.spans {
+		t.add(s.entity, s.text, s.attr)
+	}
+
+	return t
+}
+
+// PlainText is the text without any formatting.
+func (t *RichText) PlainText() string {
+	var sb strings.Builder
+	for _, s := range t.spans {
+		sb.WriteString(s.text)
+	}
+
+	return sb.String()
+}
+
+// Render renders the text for the given parse mode. Texts can't be rendered
+// in the legacy Markdown mode, which can't escape everything, so they're
+// rendered as plain text instead.
+func (t *RichText) Render(mode ParseMode) string {
+	switch mode {
+	case ParseModeMarkdownV2:
+		return t.MarkdownV2()
+	case ParseModeHTML:
+		return t.HTML()
+	default:
+		return t.PlainText()
+	}
+}
+
+// @see https://core.telegram.org/bots/api#markdownv2-style
+func (t *RichText) MarkdownV2() string {
+	var sb strings.Builder
+	for _, s := range t.spans {
+		switch s.entity {
+		case entityPlain:
+			sb.WriteString(EscapeMarkdownV2(s.text))
+		case entityBold:
+			sb.WriteString("*" + EscapeMarkdownV2(s.text) + "*")
+		case entityItalic:
+			sb.WriteString("_" + EscapeMarkdownV2(s.text) + "_")
+		case entityCode:
+			sb.WriteString("`" + EscapeMarkdownV2Code(s.text) + "`")
+		case entityPre:
+			sb.WriteString("```" + s.attr + "\n" + EscapeMarkdownV2Code(s.text) + "\n```")
+		case entityLink:
+			sb.WriteString("[" + EscapeMarkdownV2(s.text) + "](" + markdownV2URLEscaper.Replace(s.attr) + ")")
+		case entitySpoiler:
+			sb.WriteString("||" + EscapeMarkdownV2(s.text) + "||")
+		}
+	}
+
+	return sb.String()
+}
+
+// @see https://core.telegram.org/bots/api#html-style
+func (t *RichText) HTML() string {
+	var sb strings.Builder
+	for _, s := range t.spans {
+		text := EscapeHTML(s.text)
+
+		switch s.entity {
+		case entityPlain:
+			sb.WriteString(text)
+		case entityBold:
+			sb.WriteString("<b>" + text + "</b>")
+		case entityItalic:
+			sb.WriteString("<i>" + text + "</i>")
+		case entityCode:
+			sb.WriteString("<code>" + text + "</code>")
+		case entityPre:
+			if s.attr == "" {
+				sb.WriteString("<pre>" + text + "</pre>")
+			} else {
+				sb.WriteString(`<pre><code class="language-` + s.attr + `">` + text + "</code></pre>")
+			}
+		case entityLink:
+			sb.WriteString(`<a href="` + EscapeHTML(s.attr) + `">` + text + "</a>")
+		case entitySpoiler:
+			sb.WriteString("<tg-spoiler>" + text + "</tg-spoiler>")
+		}
+	}
+
+	return sb.String()
+}
+
+var markdownV2URLEscaper = strings.NewReplacer("\\", "\\\\", ")", "\\)")
+
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// EscapeHTML escapes text so it can be safely interpolated in an HTML message,
+// including attributes.
+func EscapeHTML(text string) string {
+	return htmlEscaper.Replace(text)
+}
+
+func validLanguage(language string) bool {
+	for _, r := range language {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '+', r == '-':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
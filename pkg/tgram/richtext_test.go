@@ -0,0 +1,405 @@
+package tgram_test
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+func TestRichText(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		text       *tgram.RichText
+		markdownV2 string
+		html       string
+	}{
+		{
+			desc:       "plain text with reserved characters",
+			text:       tgram.NewRichText().Plain("Successfully set St. John's (NL) as your home!"),
+			markdownV2: `Successfully set St\. John's \(NL\) as your home\!`,
+			html:       "Successfully set St. John's (NL) as your home!",
+		},
+		{
+			desc:       "bold and italic",
+			text:       tgram.NewRichText().Bold("1.").Plain(" ").Italic("a_b"),
+			markdownV2: `*1\.* _a\_b_`,
+			html:       "<b>1.</b> <i>a_b</i>",
+		},
+		{
+			desc:       "code only escapes backticks and backslashes",
+			text:       tgram.NewRichText().Code("pop > 0.6 && `x` \\"),
+			markdownV2: "`pop > 0.6 && \\`x\\` \\\\`",
+			html:       "<code>pop &gt; 0.6 &amp;&amp; `x` \\</code>",
+		},
+		{
+			desc:       "pre with a language",
+			text:       tgram.NewRichText().Pre("a < b", "go"),
+			markdownV2: "```go\na < b\n```",
+			html:       `<pre><code class="language-go">a &lt; b</code></pre>`,
+		},
+		{
+			desc:       "pre with an invalid language",
+			text:       tgram.NewRichText().Pre("x", "a b"),
+			markdownV2: "```\nx\n```",
+			html:       "<pre>x</pre>",
+		},
+		{
+			desc:       "link",
+			text:       tgram.NewRichText().Link("docs (v2)", `https://example.com/a_(b)?q="c"`),
+			markdownV2: `[docs \(v2\)](https://example.com/a_(b\)?q="c")`,
+			html:       `<a href="https://example.com/a_(b)?q=&quot;c&quot;">docs (v2)</a>`,
+		},
+		{
+			desc:       "link without url",
+			text:       tgram.NewRichText().Link("docs", ""),
+			markdownV2: "docs",
+			html:       "docs",
+		},
+		{
+			desc:       "spoiler",
+			text:       tgram.NewRichText().Spoiler("it rains|"),
+			markdownV2: `||it rains\|||`,
+			html:       "<tg-spoiler>it rains|</tg-spoiler>",
+		},
+		{
+			desc:       "adjacent entities of the same kind are merged",
+			text:       tgram.NewRichText().Italic("a").Italic("b").Bold("").Plain("c"),
+			markdownV2: "_ab_c",
+			html:       "<i>ab</i>c",
+		},
+		{
+			desc:       "append",
+			text:       tgram.NewRichText().Plain("a.").Append(tgram.NewRichText().Plain("b.").Bold("c")),
+			markdownV2: `a\.b\.*c*`,
+			html:       "a.b.<b>c</b>",
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if got := tC.text.MarkdownV2(); got != tC.markdownV2 {
+				t.Errorf("got MarkdownV2 %q, expected %q", got, tC.markdownV2)
+			}
+
+			if got := tC.text.HTML(); got != tC.html {
+				t.Errorf("got HTML %q, expected %q", got, tC.html)
+			}
+		})
+	}
+}
+
+// segment is a run of text with a single kind of formatting, as Telegram
+// would parse it.
+type segment struct {
+	kind string
+	text string
+	attr string
+}
+
+// build adds the text to rt with the formatting picked by kind, and returns
+// the segment it's expected to be parsed as.
+func build(rt *tgram.RichText, kind byte, text, attr string) segment {
+	switch kind % 7 {
+	case 0:
+		rt.Plain(text)
+		return segment{kind: "plain", text: text}
+	case 1:
+		rt.Bold(text)
+		return segment{kind: "bold", text: text}
+	case 2:
+		rt.Italic(text)
+		return segment{kind: "italic", text: text}
+	case 3:
+		rt.Code(text)
+		return segment{kind: "code", text: text}
+	case 4:
+		rt.Pre(text, "go")
+		return segment{kind: "pre", text: text, attr: "go"}
+	case 5:
+		if attr == "" {
+			rt.Plain(text)
+			return segment{kind: "plain", text: text}
+		}
+
+		rt.Link(text, attr)
+		return segment{kind: "link", text: text, attr: attr}
+	default:
+		rt.Spoiler(text)
+		return segment{kind: "spoiler", text: text}
+	}
+}
+
+// merge drops empty segments and merges adjacent ones of the same kind, as
+// both the builder and Telegram's parsers do.
+func merge(ss []segment) []segment {
+	var merged []segment
+	for _, s := range ss {
+		if s.text == "" {
+			continue
+		}
+
+		if n := len(merged); n > 0 && s.kind != "link" && merged[n-1].kind == s.kind && merged[n-1].attr == s.attr {
+			merged[n-1].text += s.text
+			continue
+		}
+
+		merged = append(merged, s)
+	}
+
+	return merged
+}
+
+const markdownV2Reserved = "_*[]()~`>#+-=|{}.!\\"
+
+// parseMarkdownV2 follows Telegram's rules for MarkdownV2: reserved characters
+// must be escaped outside entities, only "`" and "\" inside code and pre, and
+// only ")" and "\" inside link URLs.
+func parseMarkdownV2(s string) ([]segment, error) {
+	var segments []segment
+	var current strings.Builder
+	kind, attr := "plain", ""
+
+	flush := func(next string) {
+		segments = append(segments, segment{kind: kind, text: current.String(), attr: attr})
+		current.Reset()
+		kind, attr = next, ""
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if kind == "code" || kind == "pre" {
+			switch {
+			case c == '\\':
+				if i+1 >= len(s) {
+					return nil, fmt.Errorf("dangling escape at %d", i)
+				}
+
+				i++
+				current.WriteByte(s[i])
+			case kind == "pre" && strings.HasPrefix(s[i:], "\n```"):
+				flush("plain")
+				i += 3
+			case kind == "code" && c == '`':
+				flush("plain")
+			case c == '`':
+				return nil, fmt.Errorf("unescaped backtick in %s at %d", kind, i)
+			default:
+				current.WriteByte(c)
+			}
+
+			continue
+		}
+
+		switch {
+		case c == '\\':
+			if i+1 >= len(s) || s[i+1] < 1 || s[i+1] > 126 {
+				return nil, fmt.Errorf("invalid escape at %d", i)
+			}
+
+			i++
+			current.WriteByte(s[i])
+
+		case strings.HasPrefix(s[i:], "```"):
+			if kind != "plain" {
+				return nil, fmt.Errorf("pre inside %s at %d", kind, i)
+			}
+
+			end := strings.IndexByte(s[i:], '\n')
+			if end < 0 {
+				return nil, fmt.Errorf("pre without a newline at %d", i)
+			}
+
+			flush("pre")
+			attr = s[i+3 : i+end]
+			i += end
+
+		case c == '`':
+			if kind != "plain" {
+				return nil, fmt.Errorf("code inside %s at %d", kind, i)
+			}
+
+			flush("code")
+
+		case c == '*', c == '_':
+			entity := map[byte]string{'*': "bold", '_': "italic"}[c]
+			switch kind {
+			case entity:
+				flush("plain")
+			case "plain":
+				flush(entity)
+			default:
+				return nil, fmt.Errorf("%s inside %s at %d", entity, kind, i)
+			}
+
+		case strings.HasPrefix(s[i:], "||"):
+			switch kind {
+			case "spoiler":
+				flush("plain")
+			case "plain":
+				flush("spoiler")
+			default:
+				return nil, fmt.Errorf("spoiler inside %s at %d", kind, i)
+			}
+
+			i++
+
+		case c == '[':
+			if kind != "plain" {
+				return nil, fmt.Errorf("link inside %s at %d", kind, i)
+			}
+
+			flush("link")
+
+		case c == ']' && kind == "link":
+			if !strings.HasPrefix(s[i:], "](") {
+				return nil, fmt.Errorf("link without url at %d", i)
+			}
+
+			var url strings.Builder
+			j := i + 2
+			for ; j < len(s) && s[j] != ')'; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+
+				url.WriteByte(s[j])
+			}
+
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated link url at %d", i)
+			}
+
+			attr = url.String()
+			flush("plain")
+			i = j
+
+		case strings.IndexByte(markdownV2Reserved, c) >= 0:
+			return nil, fmt.Errorf("unescaped %q at %d", c, i)
+
+		default:
+			current.WriteByte(c)
+		}
+	}
+
+	if kind != "plain" {
+		return nil, fmt.Errorf("unterminated %s", kind)
+	}
+
+	flush("plain")
+	return merge(segments), nil
+}
+
+var htmlTag = regexp.MustCompile(`<(/?)(b|i|code|pre|tg-spoiler|a)( href="([^"]*)"| class="language-([^"]*)")?>`)
+
+// parseHTML checks there's no unescaped markup in the text, and returns the
+// segments of text between the tags Telegram supports.
+func parseHTML(s string) ([]segment, error) {
+	var segments []segment
+	kind, attr := "plain", ""
+	inPreCode := false
+
+	names := map[string]string{"b": "bold", "i": "italic", "code": "code", "pre": "pre", "tg-spoiler": "spoiler", "a": "link"}
+
+	for s != "" {
+		loc := htmlTag.FindStringSubmatchIndex(s)
+		text := s
+		if loc != nil {
+			text = s[:loc[0]]
+		}
+
+		if strings.ContainsAny(text, `<>"`) {
+			return nil, fmt.Errorf("unescaped markup in %q", text)
+		}
+
+		// Telegram only knows a few named entities.
+		for rest, i := text, strings.IndexByte(text, '&'); i >= 0; i = strings.IndexByte(rest, '&') {
+			rest = rest[i:]
+			if !strings.HasPrefix(rest, "&amp;") && !strings.HasPrefix(rest, "&lt;") && !strings.HasPrefix(rest, "&gt;") && !strings.HasPrefix(rest, "&quot;") {
+				return nil, fmt.Errorf("invalid entity in %q", text)
+			}
+
+			rest = rest[1:]
+		}
+
+		segments = append(segments, segment{kind: kind, text: html.UnescapeString(text), attr: attr})
+		if loc == nil {
+			break
+		}
+
+		closing, name := s[loc[2]:loc[3]] == "/", names[s[loc[4]:loc[5]]]
+		switch {
+		case name == "code" && kind == "pre":
+			inPreCode = !closing
+			if !closing && loc[10] >= 0 {
+				attr = s[loc[10]:loc[11]]
+			}
+		case closing && (kind != name || inPreCode):
+			return nil, fmt.Errorf("unexpected closing tag %q", s[loc[0]:loc[1]])
+		case closing:
+			kind, attr = "plain", ""
+		case kind != "plain":
+			return nil, fmt.Errorf("nested tag %q", s[loc[0]:loc[1]])
+		default:
+			kind, attr = name, ""
+			if loc[8] >= 0 {
+				attr = html.UnescapeString(s[loc[8]:loc[9]])
+			}
+		}
+
+		s = s[loc[1]:]
+	}
+
+	if kind != "plain" {
+		return nil, fmt.Errorf("unterminated %s", kind)
+	}
+
+	return merge(segments), nil
+}
+
+func FuzzRichText(f *testing.F) {
+	f.Add("St. John's", byte(0), "*bold*", byte(1), "a_(b)", byte(5), "https://t.me/x_(y)")
+	f.Add("`code`", byte(3), "\\", byte(4), "||", byte(6), "")
+	f.Add("_", byte(2), "_", byte(2), "__", byte(0), "")
+	f.Add("<b>&amp;</b>", byte(0), "\"", byte(5), "]", byte(1), "a\"b&c")
+	f.Add("a\n```", byte(4), "```", byte(3), "\n", byte(4), "")
+
+	f.Fuzz(func(t *testing.T, a string, ka byte, b string, kb byte, c string, kc byte, url string) {
+		for _, s := range []string{a, b, c, url} {
+			// Telegram only takes UTF-8 without NULs.
+			if !utf8.ValidString(s) || strings.ContainsRune(s, 0) {
+				t.Skip()
+			}
+		}
+
+		rt := tgram.NewRichText()
+		expected := merge([]segment{build(rt, ka, a, url), build(rt, kb, b, url), build(rt, kc, c, url)})
+
+		md, err := parseMarkdownV2(rt.MarkdownV2())
+		if err != nil {
+			t.Fatalf("invalid MarkdownV2 %q: %s", rt.MarkdownV2(), err.Error())
+		}
+
+		if fmt.Sprint(md) != fmt.Sprint(expected) {
+			t.Errorf("MarkdownV2 %q parsed as %q, expected %q", rt.MarkdownV2(), md, expected)
+		}
+
+		h, err := parseHTML(rt.HTML())
+		if err != nil {
+			t.Fatalf("invalid HTML %q: %s", rt.HTML(), err.Error())
+		}
+
+		if fmt.Sprint(h) != fmt.Sprint(expected) {
+			t.Errorf("HTML %q parsed as %q, expected %q", rt.HTML(), h, expected)
+		}
+
+		if rt.PlainText() != a+b+c {
+			t.Errorf("got plain text %q, expected %q", rt.PlainText(), a+b+c)
+		}
+	})
+}
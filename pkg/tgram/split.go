@@ -0,0 +1,387 @@
+package tgram
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxMessageLength is the most characters Telegram takes in a message.
+//
+// @see https://core.telegram.org/bots/api#sendmessage
+const MaxMessageLength = 4096
+
+type cutKind uint8
+
+const (
+	// cutNone is somewhere the text can't be cut, such as within an entity
+	// or in the middle of an escape sequence.
+	cutNone cutKind = iota
+
+	// cutPlain is somewhere the text can be cut as is.
+	cutPlain
+
+	// cutPre is within a pre block, which has to be closed before the cut
+	// and opened again after it.
+	cutPre
+)
+
+type cut struct {
+	kind cutKind
+
+	// pre is the index of the pre block cutPre cuts are within.
+	pre int
+}
+
+type preBlock struct {
+	opener, closer string
+}
+
+// SplitMessage splits text into parts of at most limit characters, so that
+// each can be sent on its own in the given parse mode.
+//
+// Parts are split on line boundaries, and never within an entity. Pre blocks,
+// such as tables, are moved whole to the next part when they don't fit in the
+// current one. Only blocks and lines too long for a part of their own are cut
+// in several: blocks are closed and opened again around the cut, and lines
+// are cut wherever they can be.
+//
+// Characters are counted as Telegram does, in UTF-16 code units, but
+// including the markup, so parts might come out somewhat shorter than needed.
+func SplitMessage(text string, mode ParseMode, limit int) []string {
+	units := utf16Offsets(text)
+	if units[len(text)] <= limit {
+		return []string{text}
+	}
+
+	var cuts []cut
+	var blocks []preBlock
+	switch mode {
+	case ParseModeMarkdownV2:
+		cuts, blocks = scanMarkdownV2(text)
+	case ParseModeHTML:
+		cuts, blocks = scanHTML(text)
+	default:
+		cuts = scanPlain(text)
+	}
+
+	var parts []string
+	add := func(part string) {
+		// Telegram rejects messages with nothing but whitespace.
+		if strings.TrimSpace(part) != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	start, opener := 0, ""
+	for {
+		budget := limit - utf16Length(opener)
+		if units[len(text)]-units[start] <= budget {
+			add(opener + text[start:])
+			return parts
+		}
+
+		line, preLine, hard, fallback := -1, -1, -1, -1
+		for p := start + 1; p < len(text) && units[p]-units[start] <= budget; p++ {
+			if !utf8.RuneStart(text[p]) {
+				continue
+			}
+
+			fallback = p
+
+			c := cuts[p]
+			size := units[p] - units[start]
+			if c.kind == cutPre {
+				size += utf16Length(blocks[c.pre].closer)
+			}
+
+			if c.kind == cutNone || size > budget {
+				continue
+			}
+
+			hard = p
+			if text[p] == '\n' {
+				if c.kind == cutPlain {
+					line = p
+				} else {
+					preLine = p
+				}
+			}
+		}
+
+		end, next := -1, -1
+		switch {
+		case line >= 0:
+			end, next = line, line+1
+		case preLine >= 0:
+			end, next = preLine, preLine+1
+		case hard >= 0:
+			end, next = hard, hard
+		case fallback >= 0:
+			end, next = fallback, fallback
+		default:
+			// Not even a single character fits, so it's sent on its own.
+			_, size := utf8.DecodeRuneInString(text[start:])
+			end, next = start+size, start+size
+		}
+
+		part := opener + text[start:end]
+		opener = ""
+		if c := cuts[end]; c.kind == cutPre {
+			part += blocks[c.pre].closer
+			opener = blocks[c.pre].opener
+		}
+
+		add(part)
+		start = next
+	}
+}
+
+// utf16Offsets returns how many UTF-16 code units there are in text up to
+// each of its bytes, and up to its end.
+func utf16Offsets(text string) []int {
+	units := make([]int, len(text)+1)
+	n := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		for j := 0; j < size; j++ {
+			units[i+j] = n
+		}
+
+		n++
+		if r >= 0x10000 {
+			n++
+		}
+
+		i += size
+	}
+
+	units[len(text)] = n
+	return units
+}
+
+func utf16Length(text string) int {
+	return utf16Offsets(text)[len(text)]
+}
+
+func scanPlain(text string) []cut {
+	cuts := make([]cut, len(text)+1)
+	for i := range cuts {
+		cuts[i] = cut{kind: cutPlain}
+	}
+
+	return cuts
+}
+
+// scanMarkdownV2 finds where text can be cut without breaking its entities.
+//
+// @see https://core.telegram.org/bots/api#markdownv2-style
+func scanMarkdownV2(text string) ([]cut, []preBlock) {
+	cuts := make([]cut, len(text)+1)
+	var blocks []preBlock
+
+	open := map[string]bool{}
+	toggle := func(delim string) {
+		open[delim] = !open[delim]
+		if !open[delim] {
+			delete(open, delim)
+		}
+	}
+
+	code, link, pre := false, false, -1
+
+	// Cutting right after the start of a block would leave an empty one in
+	// the part before the cut.
+	noCut := -1
+
+	for i := 0; i < len(text); {
+		switch {
+		case i == noCut:
+		case pre >= 0:
+			cuts[i] = cut{kind: cutPre, pre: pre}
+		case len(open) == 0 && !code && !link:
+			cuts[i] = cut{kind: cutPlain}
+		}
+
+		switch {
+		case text[i] == '\\':
+			i += 2
+
+		case pre >= 0:
+			if !strings.HasPrefix(text[i:], "```") {
+				i++
+				continue
+			}
+
+			// Likewise, cutting right before the end of a block would leave
+			// an empty one in the part after the cut.
+			cuts[i] = cut{}
+			if i > 0 && text[i-1] == '\n' {
+				cuts[i-1] = cut{}
+			}
+
+			pre = -1
+			i += 3
+
+		case code:
+			code = text[i] != '`'
+			i++
+
+		case strings.HasPrefix(text[i:], "```"):
+			opener := "```"
+			if nl := strings.IndexByte(text[i:], '\n'); nl >= 0 && !strings.Contains(text[i+3:i+nl], "`") {
+				opener = text[i : i+nl+1]
+			}
+
+			blocks = append(blocks, preBlock{opener: opener, closer: "\n```"})
+			pre = len(blocks) - 1
+			i += len(opener)
+			noCut = i
+
+		case text[i] == '`':
+			code = true
+			i++
+
+		case link:
+			if strings.HasPrefix(text[i:], "](") {
+				end := i + 2
+				for end < len(text) && text[end] != ')' {
+					if text[end] == '\\' {
+						end++
+					}
+					end++
+				}
+
+				link = false
+				i = end + 1
+				continue
+			}
+
+			i++
+
+		case text[i] == '[':
+			link = true
+			i++
+
+		case strings.HasPrefix(text[i:], "__"), strings.HasPrefix(text[i:], "||"):
+			toggle(text[i : i+2])
+			i += 2
+
+		case text[i] == '*', text[i] == '_', text[i] == '~':
+			toggle(text[i : i+1])
+			i++
+
+		default:
+			i++
+		}
+	}
+
+	if pre < 0 && len(open) == 0 && !code && !link {
+		cuts[len(text)] = cut{kind: cutPlain}
+	}
+
+	return cuts, blocks
+}
+
+// scanHTML finds where text can be cut without breaking its tags, entities
+// or character references.
+//
+// @see https://core.telegram.org/bots/api#html-style
+func scanHTML(text string) ([]cut, []preBlock) {
+	cuts := make([]cut, len(text)+1)
+	var blocks []preBlock
+
+	depth, pre := 0, -1
+
+	// Cutting right after the start of a block would leave an empty one in
+	// the part before the cut.
+	noCut := -1
+
+	for i := 0; i < len(text); {
+		switch {
+		case i == noCut:
+		case pre >= 0 && depth == 0:
+			cuts[i] = cut{kind: cutPre, pre: pre}
+		case pre < 0 && depth == 0:
+			cuts[i] = cut{kind: cutPlain}
+		}
+
+		switch text[i] {
+		case '&':
+			end := strings.IndexByte(text[i:], ';')
+			if end < 0 {
+				end = 0
+			}
+
+			i += end + 1
+
+		case '<':
+			end := strings.IndexByte(text[i:], '>')
+			if end < 0 {
+				end = len(text) - i - 1
+			}
+
+			tag := text[i : i+end+1]
+			name := tagName(tag)
+
+			switch {
+			case pre >= 0 && (name == "/pre" || name == "/code" && strings.HasSuffix(blocks[pre].closer, "</code></pre>")):
+				// Likewise, cutting right before the end of a block would
+				// leave an empty one in the part after the cut.
+				cuts[i] = cut{}
+				if i > 0 && text[i-1] == '\n' {
+					cuts[i-1] = cut{}
+				}
+
+				if name == "/pre" {
+					pre = -1
+				}
+
+			case name == "pre":
+				opener, closer := tag, "</pre>"
+				if next := text[i+len(tag):]; tagName(next) == "code" {
+					opener += next[:strings.IndexByte(next, '>')+1]
+					closer = "</code></pre>"
+				}
+
+				blocks = append(blocks, preBlock{opener: opener, closer: closer})
+				pre = len(blocks) - 1
+				tag = opener
+
+			case strings.HasPrefix(name, "/"):
+				depth--
+
+			default:
+				depth++
+			}
+
+			i += len(tag)
+			if name == "pre" {
+				noCut = i
+			}
+
+		default:
+			i++
+		}
+	}
+
+	if pre < 0 && depth == 0 {
+		cuts[len(text)] = cut{kind: cutPlain}
+	}
+
+	return cuts, blocks
+}
+
+// tagName returns the lowercase name of the tag text starts with, prefixed
+// with "/" for closing tags, or "" when it doesn't start with a tag.
+func tagName(text string) string {
+	if !strings.HasPrefix(text, "<") {
+		return ""
+	}
+
+	end := strings.IndexAny(text, " \t\n>")
+	if end < 0 {
+		return ""
+	}
+
+	return strings.ToLower(text[1:end])
+}
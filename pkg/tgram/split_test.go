@@ -0,0 +1,175 @@
+package tgram_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/manzanit0/weathry/pkg/tgram"
+)
+
+func TestSplitMessage(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		text     string
+		mode     tgram.ParseMode
+		limit    int
+		expected []string
+	}{
+		{
+			desc:     "short texts are left as they are",
+			text:     "one\ntwo",
+			mode:     tgram.ParseModeMarkdownV2,
+			limit:    10,
+			expected: []string{"one\ntwo"},
+		},
+		{
+			desc:     "texts are split on line boundaries",
+			text:     "one\ntwo\nthree\nfour",
+			mode:     tgram.ParseModeMarkdownV2,
+			limit:    10,
+			expected: []string{"one\ntwo", "three\nfour"},
+		},
+		{
+			desc:     "characters are counted in UTF-16 code units",
+			text:     "🌧🌧\n🌧🌧",
+			mode:     tgram.ParseModeMarkdownV2,
+			limit:    5,
+			expected: []string{"🌧🌧", "🌧🌧"},
+		},
+		{
+			desc:     "pre blocks which don't fit are moved whole to the next part",
+			text:     "Madrid\n```\n| 10 |\n| 12 |\n```\nBye",
+			mode:     tgram.ParseModeMarkdownV2,
+			limit:    24,
+			expected: []string{"Madrid", "```\n| 10 |\n| 12 |\n```", "Bye"},
+		},
+		{
+			desc:     "pre blocks too long for a part are closed and opened again",
+			text:     "```go\n1111\n2222\n3333\n```",
+			mode:     tgram.ParseModeMarkdownV2,
+			limit:    20,
+			expected: []string{"```go\n1111\n2222\n```", "```go\n3333\n```"},
+		},
+		{
+			desc:     "entities spanning lines aren't broken",
+			text:     "*one\ntwo* three\nfour",
+			mode:     tgram.ParseModeMarkdownV2,
+			limit:    16,
+			expected: []string{"*one\ntwo* three", "four"},
+		},
+		{
+			desc:     "long lines aren't cut within escape sequences",
+			text:     `aaaa\.bbbb`,
+			mode:     tgram.ParseModeMarkdownV2,
+			limit:    5,
+			expected: []string{"aaaa", `\.bbb`, "b"},
+		},
+		{
+			desc:     "HTML pre blocks too long for a part are closed and opened again",
+			text:     `<pre><code class="language-go">1111` + "\n" + `2222</code></pre>`,
+			mode:     tgram.ParseModeHTML,
+			limit:    48,
+			expected: []string{`<pre><code class="language-go">1111</code></pre>`, `<pre><code class="language-go">2222</code></pre>`},
+		},
+		{
+			desc:     "HTML character references aren't cut",
+			text:     "a &amp; b &lt; c",
+			mode:     tgram.ParseModeHTML,
+			limit:    8,
+			expected: []string{"a &amp; ", "b &lt; c"},
+		},
+		{
+			desc:     "plain texts are cut anywhere",
+			text:     "*aaaa*",
+			mode:     tgram.ParseModeMarkdownV1,
+			limit:    4,
+			expected: []string{"*aaa", "a*"},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got := tgram.SplitMessage(tC.text, tC.mode, tC.limit)
+			if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tC.expected) {
+				t.Errorf("got %q, expected %q", got, tC.expected)
+			}
+		})
+	}
+}
+
+// withoutSpace drops all the whitespace in s, since cuts might drop or add
+// line breaks.
+func withoutSpace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+
+		return r
+	}, s)
+}
+
+func FuzzSplitMessage(f *testing.F) {
+	f.Add("St. John's", byte(0), "a\nb\nc", byte(4), "*x*", byte(1), "https://t.me", byte(0))
+	f.Add("1111\n2222\n3333", byte(4), "\\`", byte(3), "||", byte(6), "", byte(20))
+	f.Add(strings.Repeat("a.", 60), byte(0), "_", byte(2), "```", byte(4), "", byte(63))
+
+	f.Fuzz(func(t *testing.T, a string, ka byte, b string, kb byte, c string, kc byte, url string, n byte) {
+		for _, s := range []string{a, b, c, url} {
+			if !utf8.ValidString(s) || strings.ContainsRune(s, 0) {
+				t.Skip()
+			}
+		}
+
+		// Only pre blocks and plain text can be cut when they don't fit in a
+		// part, so the other entities are kept short enough to always fit.
+		for _, s := range []struct {
+			text string
+			kind byte
+		}{{a, ka}, {b, kb}, {c, kc}} {
+			if k := s.kind % 7; k != 0 && k != 4 && (utf8.RuneCountInString(s.text) > 4 || len(url) > 4) {
+				t.Skip()
+			}
+		}
+
+		limit := 64 + int(n%64)
+
+		rt := tgram.NewRichText()
+		build(rt, ka, a, url)
+		rt.Plain("\n")
+		build(rt, kb, b, url)
+		rt.Plain("\n")
+		build(rt, kc, c, url)
+
+		for _, mode := range []tgram.ParseMode{tgram.ParseModeMarkdownV2, tgram.ParseModeHTML} {
+			parse := parseMarkdownV2
+			if mode == tgram.ParseModeHTML {
+				parse = parseHTML
+			}
+
+			var text strings.Builder
+			for _, part := range tgram.SplitMessage(rt.Render(mode), mode, limit) {
+				if l := len(utf16.Encode([]rune(part))); l > limit {
+					t.Errorf("got a %s part of %d characters, expected at most %d: %q", mode, l, limit, part)
+				}
+
+				segments, err := parse(part)
+				if err != nil {
+					t.Fatalf("invalid %s part %q: %s", mode, part, err.Error())
+				}
+
+				for _, s := range segments {
+					text.WriteString(s.text)
+				}
+			}
+
+			if withoutSpace(text.String()) != withoutSpace(rt.PlainText()) {
+				t.Errorf("%s parts have text %q, expected %q", mode, text.String(), rt.PlainText())
+			}
+		}
+	})
+}
@@ -2,10 +2,17 @@ package tgram
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/redact"
 )
 
 type WebhookRequest struct {
@@ -138,7 +145,8 @@ func NewMessage(chatID, text string) Message {
 }
 
 type Client interface {
-	SendMessage(SendMessageRequest) error
+	SendMessage(context.Context, SendMessageRequest) error
+	SendPhoto(context.Context, SendPhotoRequest) error
 }
 
 type client struct {
@@ -180,26 +188,141 @@ const (
 	ParseModeHTML       ParseMode = "HTML"
 )
 
-func (c *client) SendMessage(m SendMessageRequest) error {
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", c.botToken)
+// Parts splits the message in those which fit in a single Telegram message
+// each. Only the last one keeps the reply markup so that buttons stay at the
+// bottom.
+func (m SendMessageRequest) Parts() []SendMessageRequest {
+	texts := SplitMessage(m.Text, m.ParseMode, MaxMessageLength)
+	parts := make([]SendMessageRequest, len(texts))
+	for i, text := range texts {
+		parts[i] = m
+		parts[i].Text = text
+		if i < len(texts)-1 {
+			parts[i].ReplyMarkup = nil
+		}
+	}
+
+	return parts
+}
+
+// PartialSendError is returned when a message split in several parts fails
+// part way, so callers know how many parts the user already got.
+type PartialSendError struct {
+	// Sent is how many parts were sent before the one that failed.
+	Sent  int
+	Parts int
+	Err   error
+}
+
+func (e *PartialSendError) Error() string {
+	return fmt.Sprintf("unable to send part %d of %d: %s", e.Sent+1, e.Parts, e.Err.Error())
+}
+
+func (e *PartialSendError) Unwrap() error {
+	return e.Err
+}
+
+// SendMessage sends the message, split in several when it's too long for a
+// single one. The parts are sent in order, and when one fails the rest aren't
+// sent and a PartialSendError says how many were.
+func (c *client) SendMessage(ctx context.Context, m SendMessageRequest) error {
+	parts := m.Parts()
+	if len(parts) == 1 {
+		return c.sendMessage(ctx, parts[0])
+	}
+
+	for i, part := range parts {
+		if err := c.sendMessage(ctx, part); err != nil {
+			return &PartialSendError{Sent: i, Parts: len(parts), Err: err}
+		}
+	}
+
+	return nil
+}
 
+func (c *client) sendMessage(ctx context.Context, m SendMessageRequest) error {
 	b, err := json.Marshal(m)
 	if err != nil {
 		return fmt.Errorf("unable to marshal payload: %w", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(b))
+	return c.post(ctx, "sendMessage", "application/json", bytes.NewBuffer(b))
+}
+
+// SendPhotoRequest uploads a photo, such as a chart, along with its caption.
+//
+// @see https://core.telegram.org/bots/api#sendphoto
+type SendPhotoRequest struct {
+	ChatID      int64
+	Photo       []byte
+	Caption     string
+	ParseMode   ParseMode
+	ReplyMarkup *ReplyMarkup
+}
+
+func (c *client) SendPhoto(ctx context.Context, m SendPhotoRequest) error {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+
+	fields := map[string]string{
+		"chat_id":    strconv.FormatInt(m.ChatID, 10),
+		"caption":    m.Caption,
+		"parse_mode": string(m.ParseMode),
+	}
+
+	if m.ReplyMarkup != nil {
+		markup, err := json.Marshal(m.ReplyMarkup)
+		if err != nil {
+			return fmt.Errorf("unable to marshal reply markup: %w", err)
+		}
+
+		fields["reply_markup"] = string(markup)
+	}
+
+	for name, value := range fields {
+		if value == "" {
+			continue
+		}
+
+		if err := w.WriteField(name, value); err != nil {
+			return fmt.Errorf("unable to write %s: %w", name, err)
+		}
+	}
+
+	photo, err := w.CreateFormFile("photo", "photo.png")
+	if err != nil {
+		return fmt.Errorf("unable to create photo part: %w", err)
+	}
+
+	if _, err := photo.Write(m.Photo); err != nil {
+		return fmt.Errorf("unable to write photo: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("unable to close multipart body: %w", err)
+	}
+
+	return c.post(ctx, "sendPhoto", w.FormDataContentType(), &b)
+}
+
+func (c *client) post(ctx context.Context, method, contentType string, body io.Reader) error {
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/%s", c.botToken, method)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
 	if err != nil {
 		return fmt.Errorf("unable to create http req: %w", err)
 	}
 
-	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Content-Type", contentType)
 
 	res, err := c.h.Do(req)
 	if err != nil {
-		return fmt.Errorf("unable to do request: %w", err)
+		// The URL has the bot token, so it's masked out of errors.
+		return fmt.Errorf("unable to do request: %w", redact.Default.URLError(err))
 	}
 
+	defer res.Body.Close()
+
 	if res.StatusCode >= 200 && res.StatusCode < 300 {
 		return nil
 	}
@@ -209,5 +332,47 @@ func (c *client) SendMessage(m SendMessageRequest) error {
 		return fmt.Errorf("request failed with status %d, but unable to read body: %w", res.StatusCode, err)
 	}
 
-	return fmt.Errorf("request failed with status %d and body %s", res.StatusCode, string(data))
+	return newAPIError(res.StatusCode, data)
+}
+
+// APIError is a request the Telegram Bot API rejected.
+type APIError struct {
+	StatusCode  int
+	Description string
+
+	// RetryAfter is how long to wait before retrying when rate limited.
+	RetryAfter time.Duration
+}
+
+func newAPIError(statusCode int, body []byte) *APIError {
+	var payload struct {
+		Description string `json:"description"`
+		Parameters  struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
+	}
+
+	// Errors from proxies and such might not be JSON, so keep the raw body.
+	e := APIError{StatusCode: statusCode, Description: string(body)}
+	if err := json.Unmarshal(body, &payload); err == nil {
+		e.Description = payload.Description
+		e.RetryAfter = time.Duration(payload.Parameters.RetryAfter) * time.Second
+	}
+
+	return &e
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("request failed with status %d: %s", e.StatusCode, e.Description)
+}
+
+// Blocked reports whether the user blocked the bot or deleted their account,
+// in which case there's no point in messaging them again.
+func (e *APIError) Blocked() bool {
+	return e.StatusCode == http.StatusForbidden
+}
+
+// Temporary reports whether the request might succeed if retried later.
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
 }
@@ -0,0 +1,287 @@
+package tgram_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/tgram"
+	"github.com/manzanit0/weathry/pkg/whttp"
+)
+
+// newCassetteClient returns a client which replays the cassette in testdata,
+// along with the chat to send messages to. To record it against the real API
+// instead, set WHTTP_RECORD, TELEGRAM_BOT_TOKEN and TELEGRAM_CHAT_ID.
+func newCassetteClient(t *testing.T, cassette string) (tgram.Client, int64) {
+	t.Helper()
+
+	token, chatID := "123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw", int64(12345678)
+	if whttp.Recording() {
+		token = os.Getenv("TELEGRAM_BOT_TOKEN")
+		chatID, _ = strconv.ParseInt(os.Getenv("TELEGRAM_CHAT_ID"), 10, 64)
+	}
+
+	rec := whttp.NewTestRecorder(t, filepath.Join("testdata", cassette+".json"))
+	return tgram.NewClient(&http.Client{Transport: rec}, token), chatID
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func respondWith(status int, body string) *http.Client {
+	return &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body)), Header: http.Header{}}, nil
+	})}
+}
+
+func TestSendMessageErrors(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		status     int
+		body       string
+		blocked    bool
+		temporary  bool
+		retryAfter time.Duration
+	}{
+		{
+			desc:      "when the user blocked the bot, it should be a blocked error",
+			status:    http.StatusForbidden,
+			body:      `{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`,
+			blocked:   true,
+			temporary: false,
+		},
+		{
+			desc:       "when rate limited, it should be temporary and have a retry after",
+			status:     http.StatusTooManyRequests,
+			body:       `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 5","parameters":{"retry_after":5}}`,
+			temporary:  true,
+			retryAfter: 5 * time.Second,
+		},
+		{
+			desc:      "when the request is wrong, it should be permanent",
+			status:    http.StatusBadRequest,
+			body:      `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`,
+			temporary: false,
+		},
+		{
+			desc:      "when the body isn't JSON, it should still be an error",
+			status:    http.StatusBadGateway,
+			body:      `<html>Bad Gateway</html>`,
+			temporary: true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			c := tgram.NewClient(respondWith(tC.status, tC.body), "token")
+			err := c.SendMessage(context.Background(), tgram.SendMessageRequest{ChatID: 1, Text: "hi"})
+
+			var apiErr *tgram.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("got %v, expected an *tgram.APIError", err)
+			}
+
+			if apiErr.Blocked() != tC.blocked || apiErr.Temporary() != tC.temporary || apiErr.RetryAfter != tC.retryAfter {
+				t.Errorf("got blocked %t, temporary %t and retry after %s, expected %t, %t and %s",
+					apiErr.Blocked(), apiErr.Temporary(), apiErr.RetryAfter, tC.blocked, tC.temporary, tC.retryAfter)
+			}
+		})
+	}
+}
+
+func TestSendMessageCassettes(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		cassette   string
+		parseMode  tgram.ParseMode
+		text       string
+		status     int
+		blocked    bool
+		temporary  bool
+		retryAfter time.Duration
+	}{
+		{desc: "sent", cassette: "send_message", text: "☀️ Lisbon: 16–24°, sky is clear"},
+		{desc: "blocked", cassette: "send_message_blocked", text: "☀️ Lisbon: 16–24°, sky is clear", status: 403, blocked: true},
+		{desc: "rate limited", cassette: "send_message_rate_limited", text: "☀️ Lisbon: 16–24°, sky is clear", status: 429, temporary: true, retryAfter: 17 * time.Second},
+		{desc: "unescaped markdown", cassette: "send_message_bad_markdown", parseMode: tgram.ParseModeMarkdownV2, text: "16-24°", status: 400},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			c, chatID := newCassetteClient(t, tC.cassette)
+
+			err := c.SendMessage(context.Background(), tgram.SendMessageRequest{ChatID: chatID, Text: tC.text, ParseMode: tC.parseMode})
+			if tC.status == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err.Error())
+				}
+
+				return
+			}
+
+			var apiErr *tgram.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("got %v, expected an *tgram.APIError", err)
+			}
+
+			if apiErr.StatusCode != tC.status || apiErr.Blocked() != tC.blocked || apiErr.Temporary() != tC.temporary || apiErr.RetryAfter != tC.retryAfter {
+				t.Errorf("got status %d, blocked %t, temporary %t and retry after %s, expected %d, %t, %t and %s",
+					apiErr.StatusCode, apiErr.Blocked(), apiErr.Temporary(), apiErr.RetryAfter, tC.status, tC.blocked, tC.temporary, tC.retryAfter)
+			}
+		})
+	}
+}
+
+func TestSendPhotoCassette(t *testing.T) {
+	c, chatID := newCassetteClient(t, "send_photo")
+
+	err := c.SendPhoto(context.Background(), tgram.SendPhotoRequest{ChatID: chatID, Photo: []byte("\x89PNG"), Caption: "Lisbon, next 5 days"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+}
+
+func TestSendPhoto(t *testing.T) {
+	var got *http.Request
+	h := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("invalid multipart body: %s", err.Error())
+		}
+
+		got = r
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"ok":true}`)), Header: http.Header{}}, nil
+	})}
+
+	c := tgram.NewClient(h, "token")
+	err := c.SendPhoto(context.Background(), tgram.SendPhotoRequest{
+		ChatID:      42,
+		Photo:       []byte("png"),
+		Caption:     "Madrid",
+		ParseMode:   tgram.ParseModeMarkdownV2,
+		ReplyMarkup: &tgram.ReplyMarkup{InlineKeyboard: [][]tgram.InlineKeyboardElement{{{Text: "a", CallbackData: "b"}}}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if got.URL.Path != "/bottoken/sendPhoto" {
+		t.Errorf("got path %s, expected /bottoken/sendPhoto", got.URL.Path)
+	}
+
+	fields := map[string]string{
+		"chat_id":      "42",
+		"caption":      "Madrid",
+		"parse_mode":   "MarkdownV2",
+		"reply_markup": `{"inline_keyboard":[[{"text":"a","callback_data":"b"}]]}`,
+	}
+
+	for name, expected := range fields {
+		if v := got.FormValue(name); v != expected {
+			t.Errorf("got %s %q, expected %q", name, v, expected)
+		}
+	}
+
+	f, _, err := got.FormFile("photo")
+	if err != nil {
+		t.Fatalf("missing photo: %s", err.Error())
+	}
+
+	photo, _ := io.ReadAll(f)
+	if string(photo) != "png" {
+		t.Errorf("got photo %q, expected %q", photo, "png")
+	}
+}
+
+func TestSendMessageSplitsLongTexts(t *testing.T) {
+	var got []tgram.SendMessageRequest
+	h := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var m tgram.SendMessageRequest
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			t.Fatalf("invalid body: %s", err.Error())
+		}
+
+		got = append(got, m)
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"ok":true}`)), Header: http.Header{}}, nil
+	})}
+
+	line := strings.Repeat("a", 99) + "\n"
+	markup := &tgram.ReplyMarkup{InlineKeyboard: [][]tgram.InlineKeyboardElement{{{Text: "a", CallbackData: "b"}}}}
+
+	c := tgram.NewClient(h, "token")
+	err := c.SendMessage(context.Background(), tgram.SendMessageRequest{
+		ChatID:      42,
+		Text:        strings.Repeat(line, 50),
+		ParseMode:   tgram.ParseModeMarkdownV2,
+		ReplyMarkup: markup,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := []string{strings.Repeat(line, 40)[:4000-1], strings.Repeat(line, 10)}
+	if len(got) != len(expected) {
+		t.Fatalf("got %d messages, expected %d", len(got), len(expected))
+	}
+
+	for i, m := range got {
+		if m.Text != expected[i] {
+			t.Errorf("got part %d of %d characters, expected %d", i+1, len(m.Text), len(expected[i]))
+		}
+
+		if m.ChatID != 42 || m.ParseMode != tgram.ParseModeMarkdownV2 {
+			t.Errorf("got part %d to chat %d in %s, expected chat 42 in MarkdownV2", i+1, m.ChatID, m.ParseMode)
+		}
+
+		if last := i == len(got)-1; (m.ReplyMarkup != nil) != last {
+			t.Errorf("got part %d with markup %v, expected it only on the last part", i+1, m.ReplyMarkup)
+		}
+	}
+}
+
+func TestSendMessageReportsPartialDelivery(t *testing.T) {
+	var calls int
+	h := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		if calls == 2 {
+			body := `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 5","parameters":{"retry_after":5}}`
+			return &http.Response{StatusCode: http.StatusTooManyRequests, Body: io.NopCloser(strings.NewReader(body)), Header: http.Header{}}, nil
+		}
+
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"ok":true}`)), Header: http.Header{}}, nil
+	})}
+
+	line := strings.Repeat("a", 99) + "\n"
+	m := tgram.SendMessageRequest{ChatID: 42, Text: strings.Repeat(line, 90)}
+	if n := len(m.Parts()); n != 3 {
+		t.Fatalf("got %d parts, expected 3", n)
+	}
+
+	err := tgram.NewClient(h, "token").SendMessage(context.Background(), m)
+
+	var partial *tgram.PartialSendError
+	if !errors.As(err, &partial) {
+		t.Fatalf("got %v, expected a *tgram.PartialSendError", err)
+	}
+
+	if partial.Sent != 1 || partial.Parts != 3 {
+		t.Errorf("got %d of %d parts sent, expected 1 of 3", partial.Sent, partial.Parts)
+	}
+
+	var apiErr *tgram.APIError
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != 5*time.Second {
+		t.Errorf("got %v, expected an *tgram.APIError to retry after 5s", err)
+	}
+
+	if calls != 2 {
+		t.Errorf("got %d requests, expected the third part not to be sent", calls)
+	}
+}
@@ -10,3 +10,25 @@ func ExtractCommandQuery(text string) string {
 
 	return strings.Join(strs[1:], " ")
 }
+
+// @see https://core.telegram.org/bots/api#markdownv2-style
+var markdownV2Escaper = strings.NewReplacer(
+	"\\", "\\\\", "_", "\\_", "*", "\\*", "[", "\\[", "]", "\\]", "(", "\\(",
+	")", "\\)", "~", "\\~", "`", "\\`", ">", "\\>", "#", "\\#", "+", "\\+",
+	"-", "\\-", "=", "\\=", "|", "\\|", "{", "\\{", "}", "\\}", ".", "\\.",
+	"!", "\\!",
+)
+
+// EscapeMarkdownV2 escapes text so it can be safely interpolated in a
+// MarkdownV2 message.
+func EscapeMarkdownV2(text string) string {
+	return markdownV2Escaper.Replace(text)
+}
+
+var markdownV2CodeEscaper = strings.NewReplacer("\\", "\\\\", "`", "\\`")
+
+// EscapeMarkdownV2Code escapes text so it can be safely interpolated inside
+// a MarkdownV2 code or pre entity.
+func EscapeMarkdownV2Code(text string) string {
+	return markdownV2CodeEscaper.Replace(text)
+}
@@ -0,0 +1,388 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exporter sends ended spans somewhere they can be looked at.
+type Exporter interface {
+	ExportSpan(s *Span)
+
+	// Shutdown exports whatever spans are pending.
+	Shutdown(ctx context.Context) error
+}
+
+var (
+	exporterMu sync.RWMutex
+	exporter   Exporter = noopExporter{}
+)
+
+// SetExporter sets where spans are exported to. Spans aren't exported
+// anywhere until it's called, nor after it's called with nil.
+func SetExporter(e Exporter) {
+	if e == nil {
+		e = noopExporter{}
+	}
+
+	exporterMu.Lock()
+	defer exporterMu.Unlock()
+	exporter = e
+}
+
+func getExporter() Exporter {
+	exporterMu.RLock()
+	defer exporterMu.RUnlock()
+	return exporter
+}
+
+// Init sets the exporter from the environment: OTEL_TRACES_EXPORTER is either
+// stdout, otlp or none, which is the default. The otlp exporter sends spans to
+// the collector at OTEL_EXPORTER_OTLP_ENDPOINT, http://localhost:4318 by
+// default. The returned function exports the pending spans, and should be
+// called before exiting.
+func Init(service string) (func(context.Context) error, error) {
+	var e Exporter
+
+	switch kind := os.Getenv("OTEL_TRACES_EXPORTER"); kind {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		e = NewStdoutExporter(os.Stdout)
+	case "otlp":
+		endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if endpoint == "" {
+			endpoint = "http://localhost:4318"
+		}
+
+		e = NewOTLPExporter(endpoint, service)
+	default:
+		return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q, it must be stdout, otlp or none", kind)
+	}
+
+	SetExporter(e)
+	return e.Shutdown, nil
+}
+
+type noopExporter struct{}
+
+func (noopExporter) ExportSpan(*Span)               {}
+func (noopExporter) Shutdown(context.Context) error { return nil }
+
+// StdoutExporter writes spans as JSON, a line each.
+type StdoutExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewStdoutExporter(w io.Writer) *StdoutExporter {
+	return &StdoutExporter{w: w}
+}
+
+type stdoutSpan struct {
+	Name         string         `json:"name"`
+	Kind         string         `json:"kind"`
+	TraceID      string         `json:"trace_id"`
+	SpanID       string         `json:"span_id"`
+	ParentSpanID string         `json:"parent_span_id,omitempty"`
+	Start        time.Time      `json:"start"`
+	End          time.Time      `json:"end"`
+	DurationMs   float64        `json:"duration_ms"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	Error        string         `json:"error,omitempty"`
+}
+
+func (e *StdoutExporter) ExportSpan(s *Span) {
+	out := stdoutSpan{
+		Name:       s.Name,
+		Kind:       s.Kind.String(),
+		TraceID:    s.TraceID.String(),
+		SpanID:     s.SpanID.String(),
+		Start:      s.Start,
+		End:        s.EndTime(),
+		DurationMs: float64(s.EndTime().Sub(s.Start).Microseconds()) / 1000,
+	}
+
+	if s.Parent.IsValid() {
+		out.ParentSpanID = s.Parent.String()
+	}
+
+	if err := s.Err(); err != nil {
+		out.Error = err.Error()
+	}
+
+	if attrs := s.Attributes(); len(attrs) > 0 {
+		out.Attributes = map[string]any{}
+		for _, a := range attrs {
+			out.Attributes[a.Key] = a.Value.Any()
+		}
+	}
+
+	b, err := json.Marshal(map[string]any{"span": out})
+	if err != nil {
+		slog.Error("marshal span", "error", err.Error())
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, _ = e.w.Write(append(b, '\n'))
+}
+
+func (e *StdoutExporter) Shutdown(context.Context) error { return nil }
+
+const (
+	// otlpBatchSize is how many spans are sent to the collector at once.
+	otlpBatchSize = 256
+
+	// otlpFlushInterval is how often spans are sent to the collector when
+	// there aren't enough for a batch.
+	otlpFlushInterval = 5 * time.Second
+
+	// otlpQueueSize bounds how many spans can wait to be sent. Spans ended
+	// while the queue is full are dropped rather than holding up requests.
+	otlpQueueSize = 2048
+)
+
+// OTLPExporter sends spans in batches to an OpenTelemetry collector, with
+// OTLP over HTTP encoded as JSON.
+//
+// @see https://opentelemetry.io/docs/specs/otlp/#otlphttp
+type OTLPExporter struct {
+	url     string
+	service string
+
+	// h isn't instrumented, or exporting spans would create more spans.
+	h *http.Client
+
+	spans    chan *Span
+	flush    chan chan struct{}
+	shutdown sync.Once
+	done     chan struct{}
+}
+
+// NewOTLPExporter returns an exporter which sends spans to the collector at
+// endpoint, such as http://localhost:4318, as those of the service.
+func NewOTLPExporter(endpoint, service string) *OTLPExporter {
+	e := &OTLPExporter{
+		url:     strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		service: service,
+		h:       &http.Client{Timeout: 10 * time.Second},
+		spans:   make(chan *Span, otlpQueueSize),
+		flush:   make(chan chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go e.run()
+	return e
+}
+
+func (e *OTLPExporter) ExportSpan(s *Span) {
+	select {
+	case e.spans <- s:
+	case <-e.done:
+	default:
+		slog.Warn("dropped span, the export queue is full", "span", s.Name)
+	}
+}
+
+// Shutdown sends the pending spans and stops the exporter.
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	flushed := make(chan struct{})
+
+	var err error
+	e.shutdown.Do(func() {
+		select {
+		case e.flush <- flushed:
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		}
+
+		select {
+		case <-flushed:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	})
+
+	return err
+}
+
+func (e *OTLPExporter) run() {
+	ticker := time.NewTicker(otlpFlushInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		if err := e.send(batch); err != nil {
+			slog.Error("export spans", "error", err.Error(), "spans", len(batch))
+		}
+
+		batch = nil
+	}
+
+	for {
+		select {
+		case s := <-e.spans:
+			batch = append(batch, s)
+			if len(batch) >= otlpBatchSize {
+				send()
+			}
+
+		case <-ticker.C:
+			send()
+
+		case flushed := <-e.flush:
+			close(e.done)
+			for len(e.spans) > 0 {
+				batch = append(batch, <-e.spans)
+			}
+
+			send()
+			close(flushed)
+			return
+		}
+	}
+}
+
+func (e *OTLPExporter) send(spans []*Span) error {
+	body, err := json.Marshal(newOTLPRequest(e.service, spans))
+	if err != nil {
+		return fmt.Errorf("marshal spans: %w", err)
+	}
+
+	res, err := e.h.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("post spans: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		b, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", res.StatusCode, b)
+	}
+
+	return nil
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	// Code is 0 when unset and 2 for errors.
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func newOTLPRequest(service string, spans []*Span) otlpRequest {
+	out := make([]otlpSpan, len(spans))
+	for i, s := range spans {
+		out[i] = otlpSpan{
+			TraceID:           s.TraceID.String(),
+			SpanID:            s.SpanID.String(),
+			Name:              s.Name,
+			Kind:              int(s.Kind),
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.EndTime().UnixNano(), 10),
+		}
+
+		if s.Parent.IsValid() {
+			out[i].ParentSpanID = s.Parent.String()
+		}
+
+		for _, a := range s.Attributes() {
+			out[i].Attributes = append(out[i].Attributes, newOTLPAttribute(a))
+		}
+
+		if err := s.Err(); err != nil {
+			out[i].Status = otlpStatus{Code: 2, Message: err.Error()}
+		}
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpAttribute{newOTLPAttribute(slog.String("service.name", service))}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "github.com/manzanit0/weathry/pkg/tracing"}, Spans: out}},
+	}}}
+}
+
+func newOTLPAttribute(a slog.Attr) otlpAttribute {
+	var v otlpValue
+	switch a.Value.Kind() {
+	case slog.KindBool:
+		b := a.Value.Bool()
+		v.BoolValue = &b
+	case slog.KindInt64:
+		i := strconv.FormatInt(a.Value.Int64(), 10)
+		v.IntValue = &i
+	case slog.KindUint64:
+		i := strconv.FormatUint(a.Value.Uint64(), 10)
+		v.IntValue = &i
+	case slog.KindFloat64:
+		f := a.Value.Float64()
+		v.DoubleValue = &f
+	default:
+		str := a.Value.String()
+		v.StringValue = &str
+	}
+
+	return otlpAttribute{Key: a.Key, Value: v}
+}
@@ -0,0 +1,277 @@
+// Package tracing records spans of the work done for a request, across
+// services, propagating them with W3C Trace Context headers and exporting
+// them to stdout or an OTLP collector.
+//
+// @see https://www.w3.org/TR/trace-context/
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceparentHeader is the header spans are propagated with.
+const TraceparentHeader = "traceparent"
+
+type TraceID [16]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id TraceID) IsValid() bool  { return id != TraceID{} }
+
+type SpanID [8]byte
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) IsValid() bool  { return id != SpanID{} }
+
+// SpanContext is what identifies a span across services.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+
+	// Sampled tells whether the span is exported.
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent returns the value of the traceparent header of the span, such
+// as 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent parses the value of a traceparent header. It returns false
+// if it isn't valid.
+func ParseTraceparent(h string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}, false
+	}
+
+	// Future versions may add fields, but version 00 has exactly four.
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	if !decodeHex(sc.TraceID[:], parts[1]) || !decodeHex(sc.SpanID[:], parts[2]) || !sc.IsValid() {
+		return SpanContext{}, false
+	}
+
+	var flags [1]byte
+	if !decodeHex(flags[:], parts[3]) {
+		return SpanContext{}, false
+	}
+
+	sc.Sampled = flags[0]&1 == 1
+	return sc, true
+}
+
+func decodeHex(dst []byte, s string) bool {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return false
+	}
+
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+type SpanKind int
+
+// Kinds of spans, numbered like OTLP's.
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case KindServer:
+		return "server"
+	case KindClient:
+		return "client"
+	default:
+		return "internal"
+	}
+}
+
+// Span is a piece of work, such as handling a command or calling an API.
+type Span struct {
+	Name string
+	Kind SpanKind
+	SpanContext
+	Parent SpanID
+	Start  time.Time
+
+	mu         sync.Mutex
+	end        time.Time
+	attributes []slog.Attr
+	err        error
+}
+
+// EndTime returns when the span ended, or the zero time if it hasn't.
+func (s *Span) EndTime() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.end
+}
+
+// Attributes returns the attributes set on the span.
+func (s *Span) Attributes() []slog.Attr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]slog.Attr(nil), s.attributes...)
+}
+
+// Err returns the error recorded on the span, if any.
+func (s *Span) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// SetAttributes adds attributes to the span, such as the status code of a
+// request. It does nothing on nil spans, so callers needn't check whether
+// there's a span.
+func (s *Span) SetAttributes(attrs ...slog.Attr) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes = append(s.attributes, attrs...)
+}
+
+// RecordError marks the span as failed with err.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// End ends the span and exports it, if it's sampled. Only the first call has
+// any effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	if s.Sampled {
+		getExporter().ExportSpan(s)
+	}
+}
+
+type ctxKey int
+
+const (
+	ctxKeySpan ctxKey = iota
+	ctxKeyRemote
+)
+
+// SpanFromContext returns the span in ctx, or nil if there's none.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(ctxKeySpan).(*Span)
+	return s
+}
+
+// SpanContextFromContext returns the span context of the span in ctx, or of
+// the remote span ctx was extracted from.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	if s := SpanFromContext(ctx); s != nil {
+		return s.SpanContext, true
+	}
+
+	sc, ok := ctx.Value(ctxKeyRemote).(SpanContext)
+	return sc, ok
+}
+
+type startOptions struct {
+	kind       SpanKind
+	attributes []slog.Attr
+}
+
+type StartOption func(*startOptions)
+
+func WithKind(k SpanKind) StartOption {
+	return func(o *startOptions) {
+		o.kind = k
+	}
+}
+
+func WithAttributes(attrs ...slog.Attr) StartOption {
+	return func(o *startOptions) {
+		o.attributes = append(o.attributes, attrs...)
+	}
+}
+
+// Start starts a span which is a child of the one in ctx, or of the remote
+// one ctx was extracted from, or the root of a new trace if there's none. The
+// span has to be ended with End.
+func Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	o := startOptions{kind: KindInternal}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	s := &Span{Name: name, Kind: o.kind, Start: time.Now(), attributes: o.attributes}
+	if parent, ok := SpanContextFromContext(ctx); ok {
+		s.TraceID = parent.TraceID
+		s.Parent = parent.SpanID
+		s.Sampled = parent.Sampled
+	} else {
+		_, _ = rand.Read(s.TraceID[:])
+		s.Sampled = true
+	}
+
+	_, _ = rand.Read(s.SpanID[:])
+
+	return context.WithValue(ctx, ctxKeySpan, s), s
+}
+
+// Extract returns ctx with the remote span of the traceparent header in h, so
+// spans started with it continue the caller's trace.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	sc, ok := ParseTraceparent(h.Get(TraceparentHeader))
+	if !ok {
+		return ctx
+	}
+
+	return context.WithValue(ctx, ctxKeyRemote, sc)
+}
+
+// Inject sets the traceparent header of the span in ctx in h, so the callee
+// continues the trace.
+func Inject(ctx context.Context, h http.Header) {
+	if sc, ok := SpanContextFromContext(ctx); ok {
+		h.Set(TraceparentHeader, sc.Traceparent())
+	}
+}
@@ -0,0 +1,179 @@
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/manzanit0/weathry/pkg/tracing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		header  string
+		valid   bool
+		sampled bool
+	}{
+		{desc: "sampled", header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", valid: true, sampled: true},
+		{desc: "not sampled", header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", valid: true},
+		{desc: "future version with more fields", header: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", valid: true, sampled: true},
+		{desc: "version 00 with more fields", header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+		{desc: "invalid version", header: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{desc: "zero trace id", header: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{desc: "zero span id", header: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		{desc: "uppercase", header: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+		{desc: "short trace id", header: "00-4bf92f3577b34da6-00f067aa0ba902b7-01"},
+		{desc: "empty", header: ""},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			sc, ok := tracing.ParseTraceparent(tC.header)
+			if ok != tC.valid {
+				t.Fatalf("got valid %t, expected %t", ok, tC.valid)
+			}
+
+			if ok && sc.Sampled != tC.sampled {
+				t.Errorf("got sampled %t, expected %t", sc.Sampled, tC.sampled)
+			}
+
+			if ok && tC.header[:2] == "00" && sc.Traceparent() != tC.header {
+				t.Errorf("got %s, expected %s", sc.Traceparent(), tC.header)
+			}
+		})
+	}
+}
+
+type recorder struct {
+	mu    sync.Mutex
+	spans []*tracing.Span
+}
+
+func (r *recorder) ExportSpan(s *tracing.Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+func (r *recorder) Shutdown(context.Context) error { return nil }
+
+func record(t *testing.T) *recorder {
+	r := &recorder{}
+	tracing.SetExporter(r)
+	t.Cleanup(func() { tracing.SetExporter(nil) })
+	return r
+}
+
+func TestStartContinuesTheTrace(t *testing.T) {
+	r := record(t)
+
+	h := http.Header{}
+	h.Set(tracing.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := tracing.Extract(context.Background(), h)
+
+	ctx, server := tracing.Start(ctx, "POST /telegram/webhook", tracing.WithKind(tracing.KindServer))
+	_, client := tracing.Start(ctx, "GET api.openweathermap.org", tracing.WithKind(tracing.KindClient))
+	client.RecordError(errors.New("boom"))
+	client.End()
+	server.End()
+	server.End()
+
+	if len(r.spans) != 2 {
+		t.Fatalf("got %d spans, expected 2", len(r.spans))
+	}
+
+	if server.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.Parent.String() != "00f067aa0ba902b7" {
+		t.Errorf("expected the server span to continue the caller's trace, got trace %s and parent %s", server.TraceID, server.Parent)
+	}
+
+	if client.TraceID != server.TraceID || client.Parent != server.SpanID {
+		t.Errorf("expected the client span to be a child of the server span")
+	}
+
+	out := http.Header{}
+	tracing.Inject(ctx, out)
+	if got, expected := out.Get(tracing.TraceparentHeader), server.Traceparent(); got != expected {
+		t.Errorf("got traceparent %s, expected %s", got, expected)
+	}
+}
+
+func TestUnsampledSpansAreNotExported(t *testing.T) {
+	r := record(t)
+
+	h := http.Header{}
+	h.Set(tracing.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+
+	_, span := tracing.Start(tracing.Extract(context.Background(), h), "POST /telegram/webhook")
+	span.End()
+
+	if len(r.spans) != 0 {
+		t.Errorf("got %d spans, expected none", len(r.spans))
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body []byte
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ = io.ReadAll(req.Body)
+		path = req.URL.Path
+	}))
+	defer srv.Close()
+
+	e := tracing.NewOTLPExporter(srv.URL, "bot")
+	tracing.SetExporter(e)
+	t.Cleanup(func() { tracing.SetExporter(nil) })
+
+	_, span := tracing.Start(context.Background(), "command /daily", tracing.WithAttributes(slog.Int("user.id", 42)))
+	span.End()
+
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if path != "/v1/traces" {
+		t.Errorf("got path %s, expected /v1/traces", path)
+	}
+
+	var req struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID    string `json:"traceId"`
+					Name       string `json:"name"`
+					Attributes []struct {
+						Key   string `json:"key"`
+						Value struct {
+							IntValue string `json:"intValue"`
+						} `json:"value"`
+					} `json:"attributes"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+
+	if err := json.Unmarshal(body, &req); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !strings.Contains(string(body), `"service.name"`) {
+		t.Errorf("expected the service name, got %s", body)
+	}
+
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 || spans[0].Name != "command /daily" || spans[0].TraceID != span.TraceID.String() {
+		t.Fatalf("unexpected spans: %s", body)
+	}
+
+	if a := spans[0].Attributes; len(a) != 1 || a[0].Key != "user.id" || a[0].Value.IntValue != "42" {
+		t.Errorf("unexpected attributes: %+v", a)
+	}
+}
@@ -0,0 +1,69 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/cache"
+)
+
+// DefaultCacheTTL is how long forecasts are cached for. OpenWeatherMap updates
+// them every few hours, at best.
+const DefaultCacheTTL = 10 * time.Minute
+
+// NewCachedClient returns a client which keeps the forecasts c returns for
+// ttl, so paging through a forecast or homes checked one after the other
+// don't fetch it again. Current conditions aren't cached.
+func NewCachedClient(c Client, ttl time.Duration) Client {
+	return &cachedClient{Client: c, forecasts: cache.New[[]*Forecast]("forecast", ttl)}
+}
+
+type cachedClient struct {
+	Client
+	forecasts *cache.Cache[[]*Forecast]
+}
+
+func (c *cachedClient) GetUpcomingWeather(ctx context.Context, lat, lon float64) ([]*Forecast, error) {
+	return c.cached(fmt.Sprintf("upcoming:%f,%f", lat, lon), func() ([]*Forecast, error) {
+		return c.Client.GetUpcomingWeather(ctx, lat, lon)
+	})
+}
+
+func (c *cachedClient) GetDailyForecast(ctx context.Context, lat, lon float64, days int) ([]*Forecast, error) {
+	return c.cached(fmt.Sprintf("daily:%d:%f,%f", days, lat, lon), func() ([]*Forecast, error) {
+		return c.Client.GetDailyForecast(ctx, lat, lon, days)
+	})
+}
+
+func (c *cachedClient) GetHourlyForecast(ctx context.Context, lat, lon float64) ([]*Forecast, error) {
+	return c.cached(fmt.Sprintf("hourly:%f,%f", lat, lon), func() ([]*Forecast, error) {
+		return c.Client.GetHourlyForecast(ctx, lat, lon)
+	})
+}
+
+func (c *cachedClient) cached(key string, fetch func() ([]*Forecast, error)) ([]*Forecast, error) {
+	if ff, ok := c.forecasts.Get(key); ok {
+		return copyForecasts(ff), nil
+	}
+
+	ff, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	c.forecasts.Set(key, copyForecasts(ff))
+	return ff, nil
+}
+
+// copyForecasts copies the forecasts, so callers can't change those which are
+// cached.
+func copyForecasts(ff []*Forecast) []*Forecast {
+	out := make([]*Forecast, len(ff))
+	for i, f := range ff {
+		c := *f
+		out[i] = &c
+	}
+
+	return out
+}
@@ -0,0 +1,49 @@
+package weather_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/weather"
+)
+
+type countingClient struct {
+	weather.Client
+	calls int
+}
+
+func (c *countingClient) GetHourlyForecast(_ context.Context, lat, lon float64) ([]*weather.Forecast, error) {
+	c.calls++
+	return []*weather.Forecast{{Coordinates: weather.Coordinates{Latitude: lat, Longitude: lon}, MaximumTemperature: 20}}, nil
+}
+
+func TestCachedClientFetchesForecastsOnce(t *testing.T) {
+	proxied := &countingClient{}
+	c := weather.NewCachedClient(proxied, time.Hour)
+
+	first, err := c.GetHourlyForecast(context.Background(), 38.7, -9.1)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	// Callers changing the forecasts they get don't change those cached.
+	first[0].MaximumTemperature = 30
+
+	second, err := c.GetHourlyForecast(context.Background(), 38.7, -9.1)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if second[0].MaximumTemperature != 20 {
+		t.Errorf("got %.0f, expected the cached forecast to be untouched", second[0].MaximumTemperature)
+	}
+
+	if _, err := c.GetHourlyForecast(context.Background(), 40.4, -3.7); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if proxied.calls != 2 {
+		t.Errorf("got %d calls, expected 2", proxied.calls)
+	}
+}
@@ -1,18 +1,22 @@
 package weather
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"time"
+
+	"github.com/manzanit0/weathry/pkg/redact"
 )
 
 type Client interface {
-	GetCurrentWeather(lat, lon float64) (*Forecast, error)
-	GetUpcomingWeather(lat, lon float64) ([]*Forecast, error)
-	GetHourlyForecast(lat, lon float64) ([]*Forecast, error)
+	GetCurrentWeather(ctx context.Context, lat, lon float64) (*Observation, error)
+	GetUpcomingWeather(ctx context.Context, lat, lon float64) ([]*Forecast, error)
+	GetDailyForecast(ctx context.Context, lat, lon float64, days int) ([]*Forecast, error)
+	GetHourlyForecast(ctx context.Context, lat, lon float64) ([]*Forecast, error)
 }
 
 type Coordinates struct {
@@ -30,22 +34,51 @@ type Forecast struct {
 	Humidity           int
 	WindSpeed          float64
 	DateTimeTS         int
+
+	// PrecipitationProbability is the probability of precipitation, between 0
+	// and 1.
+	PrecipitationProbability float64
+}
+
+// Observation is the weather measured at a station at a given time, as
+// opposed to a Forecast which is a prediction.
+type Observation struct {
+	Coordinates   Coordinates
+	Location      string
+	Condition     string
+	Description   string
+	Temperature   float64
+	FeelsLike     float64
+	Humidity      int
+	WindSpeed     float64
+	WindDirection int // degrees, meteorological
+	DateTimeTS    int
+}
+
+// Time returns when the observation was made.
+func (o *Observation) Time() time.Time {
+	return time.Unix(int64(o.DateTimeTS), 0).UTC()
 }
 
 func (f *Forecast) IsRainy() bool {
 	return f.Condition == "rain" || f.Condition == "storm"
 }
 
-func (f *Forecast) FormattedDateTime() string {
-	return time.Unix(int64(f.DateTimeTS), 0).Format(time.RFC1123)
+// Time returns when the forecast is for.
+func (f *Forecast) Time() time.Time {
+	return time.Unix(int64(f.DateTimeTS), 0).UTC()
+}
+
+func (f *Forecast) FormattedDateTime(loc *time.Location) string {
+	return f.Time().In(loc).Format(time.RFC1123)
 }
 
-func (f *Forecast) FormattedDate() string {
-	return time.Unix(int64(f.DateTimeTS), 0).Format("Mon 02")
+func (f *Forecast) FormattedDate(loc *time.Location) string {
+	return f.Time().In(loc).Format("Mon 02")
 }
 
-func (f *Forecast) FormattedTime() string {
-	return time.Unix(int64(f.DateTimeTS), 0).Format("15:04h")
+func (f *Forecast) FormattedTime(loc *time.Location) string {
+	return f.Time().In(loc).Format("15:04h")
 }
 
 func (f *Forecast) LocalTime(timezone string) (string, error) {
@@ -54,8 +87,19 @@ func (f *Forecast) LocalTime(timezone string) (string, error) {
 		return "", err
 	}
 
-	local := time.Unix(int64(f.DateTimeTS), 0).In(loc)
-	return local.Format("Mon, 02 Jan 15:04 MST"), nil
+	return f.Time().In(loc).Format("Mon, 02 Jan 15:04 MST"), nil
+}
+
+// get requests the url with ctx, so the request is part of its trace.
+func (c *owm) get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// The URL has the API key, so it's masked out of errors.
+	res, err := c.h.Do(req)
+	return res, redact.Default.URLError(err)
 }
 
 func NewOpenWeatherMapClient(h *http.Client, apiKey string) *owm {
@@ -67,20 +111,85 @@ type owm struct {
 	apiKey string
 }
 
-func (c *owm) GetCurrentWeather(lat, lon float64) (*Forecast, error) {
-	forecasts, err := c.GetUpcomingWeather(lat, lon)
+func (c *owm) GetCurrentWeather(ctx context.Context, lat, lon float64) (*Observation, error) {
+	u, err := url.Parse("http://api.openweathermap.org/data/2.5/weather")
 	if err != nil {
 		return nil, err
 	}
 
-	return forecasts[0], nil
+	q := u.Query()
+	q.Set("appid", c.apiKey)
+	q.Set("lat", fmt.Sprint(lat))
+	q.Set("lon", fmt.Sprint(lon))
+	q.Set("units", "metric")
+	q.Set("lang", "en")
+	u.RawQuery = q.Encode()
+
+	res, err := c.get(ctx, u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var d CurrentWeatherResponse
+	err = json.Unmarshal(data, &d)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(d.Weather) == 0 {
+		return nil, fmt.Errorf("observation without weather conditions")
+	}
+
+	return &Observation{
+		Coordinates:   Coordinates{lat, lon},
+		Location:      fmt.Sprintf("%s (%s)", d.Name, d.Sys.Country),
+		Condition:     conditionCodeToString(d.Weather[0].ID),
+		Description:   d.Weather[0].Description,
+		Temperature:   d.Main.Temp,
+		FeelsLike:     d.Main.FeelsLike,
+		Humidity:      d.Main.Humidity,
+		WindSpeed:     d.Wind.Speed,
+		WindDirection: d.Wind.Deg,
+		DateTimeTS:    d.DateTimeTS,
+	}, nil
+}
+
+// GetUpcomingWeather returns the daily forecasts of the upcoming week.
+func (c *owm) GetUpcomingWeather(ctx context.Context, lat, lon float64) ([]*Forecast, error) {
+	return c.getDailyForecast(ctx, lat, lon, 0)
+}
+
+// GetDailyForecast returns the daily forecasts of the given amount of days,
+// up to 16.
+func (c *owm) GetDailyForecast(ctx context.Context, lat, lon float64, days int) ([]*Forecast, error) {
+	if days < 1 || days > 16 {
+		return nil, fmt.Errorf("days must be between 1 and 16, got %d", days)
+	}
+
+	return c.getDailyForecast(ctx, lat, lon, days)
 }
 
-func (c *owm) GetUpcomingWeather(lat, lon float64) ([]*Forecast, error) {
+// getDailyForecast gets the daily forecasts. When days is zero, it's up to the
+// API how many.
+func (c *owm) getDailyForecast(ctx context.Context, lat, lon float64, days int) ([]*Forecast, error) {
 	endpoint := fmt.Sprintf("/data/2.5/forecast/daily/?lat=%f&lon=%f&units=metric&lang=en", lat, lon)
+	if days > 0 {
+		endpoint += fmt.Sprintf("&cnt=%d", days)
+	}
+
 	url := fmt.Sprintf("http://api.openweathermap.org%s&appid=%s", endpoint, c.apiKey)
 
-	res, err := c.h.Get(url)
+	res, err := c.get(ctx, url)
 	if err != nil {
 		return nil, err
 	}
@@ -107,14 +216,16 @@ func (c *owm) GetUpcomingWeather(lat, lon float64) ([]*Forecast, error) {
 			Humidity:           v.Humidity,
 			WindSpeed:          v.Speed,
 			DateTimeTS:         v.DateTimeTS,
-			Condition:          d.Condition(),
+			Condition:          conditionCodeToString(v.Weather[0].ID),
+
+			PrecipitationProbability: v.Pop,
 		})
 	}
 
 	return forecasts, nil
 }
 
-func (c *owm) GetHourlyForecast(lat, lon float64) ([]*Forecast, error) {
+func (c *owm) GetHourlyForecast(ctx context.Context, lat, lon float64) ([]*Forecast, error) {
 	u, err := url.Parse("http://api.openweathermap.org/data/2.5/forecast")
 	if err != nil {
 		return nil, err
@@ -128,7 +239,7 @@ func (c *owm) GetHourlyForecast(lat, lon float64) ([]*Forecast, error) {
 	q.Set("lang", "en")
 	u.RawQuery = q.Encode()
 
-	res, err := c.h.Get(u.String())
+	res, err := c.get(ctx, u.String())
 	if err != nil {
 		return nil, err
 	}
@@ -156,6 +267,8 @@ func (c *owm) GetHourlyForecast(lat, lon float64) ([]*Forecast, error) {
 			WindSpeed:          v.Wind.Speed,
 			DateTimeTS:         v.DateTimeTS,
 			Condition:          v.Condition(),
+
+			PrecipitationProbability: v.Pop,
 		}
 	}
 
@@ -210,6 +323,44 @@ type DailyWeatherResponse struct {
 		Pop    float64 `json:"pop"`
 	} `json:"list"`
 }
+type CurrentWeatherResponse struct {
+	Coord struct {
+		Lon float64 `json:"lon"`
+		Lat float64 `json:"lat"`
+	} `json:"coord"`
+	Weather []struct {
+		ID          int    `json:"id"`
+		Main        string `json:"main"`
+		Description string `json:"description"`
+		Icon        string `json:"icon"`
+	} `json:"weather"`
+	Main struct {
+		Temp      float64 `json:"temp"`
+		FeelsLike float64 `json:"feels_like"`
+		TempMin   float64 `json:"temp_min"`
+		TempMax   float64 `json:"temp_max"`
+		Pressure  int     `json:"pressure"`
+		Humidity  int     `json:"humidity"`
+	} `json:"main"`
+	Visibility int `json:"visibility"`
+	Wind       struct {
+		Speed float64 `json:"speed"`
+		Deg   int     `json:"deg"`
+		Gust  float64 `json:"gust"`
+	} `json:"wind"`
+	Clouds struct {
+		All int `json:"all"`
+	} `json:"clouds"`
+	DateTimeTS int `json:"dt"`
+	Sys        struct {
+		Country string `json:"country"`
+		Sunrise int    `json:"sunrise"`
+		Sunset  int    `json:"sunset"`
+	} `json:"sys"`
+	Timezone int    `json:"timezone"`
+	Name     string `json:"name"`
+}
+
 type HourlyWeatherResponse struct {
 	Cod     string           `json:"cod"`
 	Message int              `json:"message"`
@@ -272,12 +423,6 @@ func (r HourlyForecast) Condition() string {
 	return conditionCodeToString(code)
 }
 
-func (r DailyWeatherResponse) Condition() string {
-	// FIXME: this is broken, see HourlyForecast impl.
-	code := r.DaysList[0].Weather[0].ID
-	return conditionCodeToString(code)
-}
-
 func conditionCodeToString(code int) string {
 	if code >= 200 && code <= 299 {
 		return "thunderstorm"
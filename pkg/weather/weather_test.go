@@ -0,0 +1,196 @@
+package weather_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/weather"
+	"github.com/manzanit0/weathry/pkg/whttp"
+)
+
+// rewriteTransport sends every request to the test server, regardless of the
+// host the client was built for.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t rewriteTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func newTestClient(t *testing.T, h http.HandlerFunc) weather.Client {
+	t.Helper()
+
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("parse test server url: %s", err.Error())
+	}
+
+	return weather.NewOpenWeatherMapClient(&http.Client{Transport: rewriteTransport{target}}, "secret")
+}
+
+// newCassetteClient returns a client which replays the cassette in testdata.
+// To record it against the real API instead, set WHTTP_RECORD and
+// OPENWEATHERMAP_API_KEY. The expectations of the tests built on it might have
+// to be updated afterwards, since the weather will have changed.
+func newCassetteClient(t *testing.T, cassette string) weather.Client {
+	t.Helper()
+
+	apiKey := "secret"
+	if whttp.Recording() {
+		apiKey = os.Getenv("OPENWEATHERMAP_API_KEY")
+	}
+
+	rec := whttp.NewTestRecorder(t, filepath.Join("testdata", cassette+".json"))
+	return weather.NewOpenWeatherMapClient(&http.Client{Transport: rec}, apiKey)
+}
+
+func TestGetCurrentWeather(t *testing.T) {
+	t.Run("parses the observation", func(t *testing.T) {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/data/2.5/weather" {
+				t.Errorf("unexpected path %s", r.URL.Path)
+			}
+
+			if r.URL.Query().Get("units") != "metric" {
+				t.Errorf("expected metric units, got %q", r.URL.Query().Get("units"))
+			}
+
+			_, _ = w.Write([]byte(`{
+				"coord": {"lon": -3.7, "lat": 40.42},
+				"weather": [{"id": 501, "main": "Rain", "description": "moderate rain", "icon": "10d"}],
+				"main": {"temp": 14.3, "feels_like": 13.6, "temp_min": 13, "temp_max": 15.2, "pressure": 1012, "humidity": 82},
+				"wind": {"speed": 4.12, "deg": 250},
+				"dt": 1667138400,
+				"sys": {"country": "ES"},
+				"timezone": 3600,
+				"name": "Madrid"
+			}`))
+		})
+
+		o, err := c.GetCurrentWeather(context.Background(), 40.42, -3.7)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+
+		if o.Location != "Madrid (ES)" {
+			t.Errorf("got location %q", o.Location)
+		}
+
+		if o.Condition != "rain" || o.Description != "moderate rain" {
+			t.Errorf("got condition %q and description %q", o.Condition, o.Description)
+		}
+
+		if o.Temperature != 14.3 || o.FeelsLike != 13.6 {
+			t.Errorf("got temperature %.1f feeling like %.1f", o.Temperature, o.FeelsLike)
+		}
+
+		if o.Humidity != 82 || o.WindSpeed != 4.12 || o.WindDirection != 250 {
+			t.Errorf("got humidity %d, wind %.2f m/s at %dº", o.Humidity, o.WindSpeed, o.WindDirection)
+		}
+
+		expected := time.Date(2022, time.October, 30, 14, 0, 0, 0, time.UTC)
+		if !o.Time().Equal(expected) {
+			t.Errorf("got time %s, expected %s", o.Time(), expected)
+		}
+	})
+
+	t.Run("fails on unexpected status codes", func(t *testing.T) {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"cod": 401, "message": "Invalid API key"}`))
+		})
+
+		_, err := c.GetCurrentWeather(context.Background(), 40.42, -3.7)
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestGetDailyForecast(t *testing.T) {
+	t.Run("asks for the given amount of days", func(t *testing.T) {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if got := r.URL.Query().Get("cnt"); got != "14" {
+				t.Errorf("got cnt %q, expected 14", got)
+			}
+
+			_, _ = w.Write([]byte(`{"city": {"name": "Madrid", "country": "ES"}, "list": [
+				{"dt": 1667127600, "temp": {"min": 12, "max": 21}, "weather": [{"id": 800, "description": "clear sky"}]}
+			]}`))
+		})
+
+		forecasts, err := c.GetDailyForecast(context.Background(), 40.42, -3.7, 14)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+
+		if len(forecasts) != 1 || forecasts[0].MaximumTemperature != 21 {
+			t.Errorf("unexpected forecasts %+v", forecasts)
+		}
+	})
+
+	t.Run("refuses more days than available", func(t *testing.T) {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			t.Error("unexpected request")
+		})
+
+		if _, err := c.GetDailyForecast(context.Background(), 40.42, -3.7, 17); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestGetDailyForecastCassette(t *testing.T) {
+	c := newCassetteClient(t, "owm_daily")
+
+	forecasts, err := c.GetDailyForecast(context.Background(), 38.7223, -9.1393, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if len(forecasts) != 3 {
+		t.Fatalf("got %d forecasts, expected 3", len(forecasts))
+	}
+
+	f := forecasts[1]
+	if f.Location != "Lisbon (PT)" || f.Condition != "rain" || f.MinimumTemperature != 17.02 || f.MaximumTemperature != 21.4 || f.PrecipitationProbability != 0.86 {
+		t.Errorf("unexpected forecast %+v", f)
+	}
+
+	if got := f.Time().UTC(); !got.Equal(time.Date(2024, 10, 13, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("got time %s, expected midday of the 13th", got)
+	}
+}
+
+func TestGetHourlyForecastCassette(t *testing.T) {
+	c := newCassetteClient(t, "owm_hourly")
+
+	forecasts, err := c.GetHourlyForecast(context.Background(), 38.7223, -9.1393)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if len(forecasts) != 3 {
+		t.Fatalf("got %d forecasts, expected 3", len(forecasts))
+	}
+
+	if f := forecasts[0]; f.Location != "Lisbon PT" || f.Condition != "clouds" || f.Description != "few clouds" || f.IsRainy() {
+		t.Errorf("unexpected forecast %+v", f)
+	}
+
+	if f := forecasts[2]; !f.IsRainy() || f.PrecipitationProbability != 0.71 || f.WindSpeed != 5.21 || f.Humidity != 68 {
+		t.Errorf("unexpected forecast %+v", f)
+	}
+}
@@ -0,0 +1,179 @@
+package whttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/metrics"
+)
+
+var breakerState = metrics.NewGauge(
+	"weathry_http_client_circuit_state",
+	"State of the circuit breaker of each upstream: 0 closed, 1 half-open and 2 open.",
+	"upstream",
+)
+
+// ErrBreakerOpen is returned instead of making requests while the breaker is
+// open.
+var ErrBreakerOpen = errors.New("circuit breaker is open")
+
+type BreakerState int
+
+const (
+	// BreakerClosed lets requests through.
+	BreakerClosed BreakerState = iota
+
+	// BreakerHalfOpen lets a single request through, to find out whether the
+	// upstream is back.
+	BreakerHalfOpen
+
+	// BreakerOpen fails requests without making them.
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+// BreakerPolicy is when a breaker opens and for how long.
+type BreakerPolicy struct {
+	// Failures is how many requests in a row have to fail for the breaker to
+	// open. The breaker never opens when it's 0.
+	Failures int
+
+	// Cooldown is how long the breaker stays open before letting a request
+	// through to try the upstream again.
+	Cooldown time.Duration
+}
+
+// Breaker stops requests to an upstream which keeps failing, so that callers
+// fail fast instead of waiting on it, and the upstream gets time to recover.
+type Breaker struct {
+	name   string
+	policy BreakerPolicy
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func NewBreaker(name string, policy BreakerPolicy) *Breaker {
+	breakerState.Set(float64(BreakerClosed), name)
+	return &Breaker{name: name, policy: policy}
+}
+
+// State returns the state of the breaker. An open breaker whose cooldown is
+// over is reported as half-open, since it'd let the next request through.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.policy.Cooldown {
+		return BreakerHalfOpen
+	}
+
+	return b.state
+}
+
+// Allow returns ErrBreakerOpen if a request can't be made. Otherwise the
+// outcome of the request must be recorded with Record.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.policy.Cooldown {
+			return fmt.Errorf("%s: %w", b.name, ErrBreakerOpen)
+		}
+
+		b.setState(BreakerHalfOpen)
+		b.probing = true
+		return nil
+
+	case BreakerHalfOpen:
+		if b.probing {
+			return fmt.Errorf("%s: %w", b.name, ErrBreakerOpen)
+		}
+
+		b.probing = true
+		return nil
+
+	default:
+		return nil
+	}
+}
+
+// Record records whether a request let through by Allow succeeded.
+func (b *Breaker) Record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	if success {
+		b.failures = 0
+		b.setState(BreakerClosed)
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || (b.policy.Failures > 0 && b.failures >= b.policy.Failures) {
+		b.openedAt = time.Now()
+		b.setState(BreakerOpen)
+	}
+}
+
+// release gives back the probe of a request which was cancelled, and so
+// neither succeeded nor failed.
+func (b *Breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *Breaker) setState(s BreakerState) {
+	if b.state == s {
+		return
+	}
+
+	slog.Warn("circuit breaker changed state", "upstream", b.name, "from", b.state.String(), "to", s.String())
+	b.state = s
+	breakerState.Set(float64(s), b.name)
+}
+
+// BreakerRoundTripper fails requests with ErrBreakerOpen while the breaker is
+// open. Transport errors and 5xx responses count as failures.
+type BreakerRoundTripper struct {
+	Proxied http.RoundTripper
+	Breaker *Breaker
+}
+
+func (rt BreakerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := rt.Breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	res, err := rt.Proxied.RoundTrip(req)
+	if errors.Is(req.Context().Err(), context.Canceled) {
+		rt.Breaker.release()
+		return res, err
+	}
+
+	rt.Breaker.Record(err == nil && res.StatusCode < http.StatusInternalServerError)
+	return res, err
+}
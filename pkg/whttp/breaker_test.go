@@ -0,0 +1,92 @@
+package whttp_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/whttp"
+)
+
+func TestBreakerRoundTripper(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	breaker := whttp.NewBreaker("test", whttp.BreakerPolicy{Failures: 2, Cooldown: 20 * time.Millisecond})
+	client := &http.Client{Transport: whttp.BreakerRoundTripper{Proxied: http.DefaultTransport, Breaker: breaker}}
+
+	get := func() error {
+		res, err := client.Get(srv.URL)
+		if err == nil {
+			res.Body.Close()
+		}
+
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := get(); err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+	}
+
+	if s := breaker.State(); s != whttp.BreakerOpen {
+		t.Fatalf("got state %s, expected open", s)
+	}
+
+	if err := get(); !errors.Is(err, whttp.ErrBreakerOpen) {
+		t.Fatalf("expected the breaker to fail the request, got %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if s := breaker.State(); s != whttp.BreakerHalfOpen {
+		t.Fatalf("got state %s, expected half-open", s)
+	}
+
+	// The probe fails, so the breaker opens again.
+	if err := get(); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if s := breaker.State(); s != whttp.BreakerOpen {
+		t.Fatalf("got state %s, expected open", s)
+	}
+
+	failing.Store(false)
+	time.Sleep(30 * time.Millisecond)
+
+	if err := get(); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if s := breaker.State(); s != whttp.BreakerClosed {
+		t.Errorf("got state %s, expected closed", s)
+	}
+}
+
+func TestBreakerLetsOneProbeThrough(t *testing.T) {
+	breaker := whttp.NewBreaker("test", whttp.BreakerPolicy{Failures: 1})
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	breaker.Record(false)
+
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("expected the probe to be let through, got %s", err.Error())
+	}
+
+	if err := breaker.Allow(); !errors.Is(err, whttp.ErrBreakerOpen) {
+		t.Errorf("expected only one probe to be let through, got %v", err)
+	}
+}
@@ -0,0 +1,318 @@
+package whttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"github.com/manzanit0/weathry/pkg/redact"
+)
+
+// ErrNoInteraction is returned when replaying a request which wasn't recorded.
+var ErrNoInteraction = errors.New("no recorded interaction matches the request")
+
+// Mode is whether a Recorder records requests or replays them.
+type Mode int
+
+const (
+	// ModeReplay serves the responses of the cassette, without making any
+	// request.
+	ModeReplay Mode = iota
+
+	// ModeRecord makes requests and records them in the cassette.
+	ModeRecord
+)
+
+// Cassette is a list of requests and the responses they got, as they're kept
+// in testdata.
+type Cassette struct {
+	// Comment says where the cassette comes from when it wasn't recorded,
+	// such as written by hand after the API's documentation.
+	Comment string `json:"comment,omitempty"`
+
+	Interactions []Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+
+	// JSON is the body, if it is JSON. Other bodies, such as uploads, aren't
+	// recorded.
+	JSON json.RawMessage `json:"json,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+
+	// The body is recorded as JSON if it is, so cassettes are easy to read
+	// and edit, and as text otherwise.
+	JSON json.RawMessage `json:"json,omitempty"`
+	Body string          `json:"body,omitempty"`
+}
+
+// Recorder is a round tripper for testing clients of APIs without calling
+// them. It records requests and their responses in a cassette, masking their
+// secrets, and replays them later. Requests are matched by method, URL, JSON
+// body and the headers in the cassette, in the order they were recorded, once
+// each.
+type Recorder struct {
+	path    string
+	mode    Mode
+	proxied http.RoundTripper
+
+	// Policy is what's masked out of cassettes. Defaults to redact.Default.
+	Policy *redact.Policy
+
+	mu       sync.Mutex
+	cassette Cassette
+	replayed []bool
+}
+
+// NewRecorder returns a recorder for the cassette at path, which requests are
+// made with proxied when recording. Replaying a cassette which doesn't exist
+// fails.
+func NewRecorder(path string, mode Mode, proxied http.RoundTripper) (*Recorder, error) {
+	r := &Recorder{path: path, mode: mode, proxied: proxied}
+	if mode == ModeRecord {
+		return r, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read cassette: %w", err)
+	}
+
+	if err := json.Unmarshal(b, &r.cassette); err != nil {
+		return nil, fmt.Errorf("unmarshal cassette %s: %w", path, err)
+	}
+
+	r.replayed = make([]bool, len(r.cassette.Interactions))
+	return r, nil
+}
+
+func (r *Recorder) policy() redact.Policy {
+	if r.Policy == nil {
+		return redact.Default
+	}
+
+	return *r.Policy
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.mode == ModeRecord {
+		return r.record(req)
+	}
+
+	return r.replay(req)
+}
+
+func (r *Recorder) replay(req *http.Request) (*http.Response, error) {
+	p := r.policy()
+	url := p.URL(req.URL)
+
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, in := range r.cassette.Interactions {
+		if r.replayed[i] || in.Request.Method != req.Method || in.Request.URL != url {
+			continue
+		}
+
+		if len(in.Request.JSON) > 0 && !sameJSON(in.Request.JSON, p.JSON(body)) {
+			continue
+		}
+
+		if !hasHeader(p.Header(req.Header), in.Request.Header) {
+			continue
+		}
+
+		r.replayed[i] = true
+
+		body := []byte(in.Response.Body)
+		if len(in.Response.JSON) > 0 {
+			// Cassettes are indented, but APIs respond with compact JSON.
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, in.Response.JSON); err != nil {
+				return nil, fmt.Errorf("compact recorded body: %w", err)
+			}
+
+			body = compact.Bytes()
+		}
+
+		header := in.Response.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+			StatusCode:    in.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, req.Method, url)
+}
+
+// readBody returns the body of the request, leaving it to be read again.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	b, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read request body: %w", err)
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
+
+// sameJSON returns whether a and b are the same JSON values, regardless of
+// how they're formatted or the order of their fields.
+func sameJSON(a, b []byte) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(va, vb)
+}
+
+// hasHeader returns whether h has all the values of want. Other headers in h
+// don't matter.
+func hasHeader(h, want http.Header) bool {
+	for k, vv := range want {
+		if !reflect.DeepEqual(h.Values(k), vv) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *Recorder) record(req *http.Request) (*http.Response, error) {
+	p := r.policy()
+	in := Interaction{Request: RecordedRequest{
+		Method: req.Method,
+		URL:    p.URL(req.URL),
+		Header: p.Header(req.Header),
+	}}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+
+		if json.Valid(b) {
+			in.Request.JSON = p.JSON(b)
+		}
+	}
+
+	res, err := r.proxied.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	res.Body = io.NopCloser(bytes.NewReader(b))
+
+	in.Response = RecordedResponse{StatusCode: res.StatusCode, Header: p.Header(res.Header)}
+	if json.Valid(b) {
+		in.Response.JSON = p.JSON(b)
+	} else {
+		in.Response.Body = p.String(string(b))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+
+	return res, nil
+}
+
+// Save writes the recorded interactions to the cassette. It does nothing when
+// replaying.
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal cassette: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("create cassette directory: %w", err)
+	}
+
+	if err := os.WriteFile(r.path, append(b, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write cassette: %w", err)
+	}
+
+	return nil
+}
+
+// Unplayed returns the recorded requests which haven't been replayed, such as
+// "GET http://api.positionstack.com/v1/forward?...", so tests can check that
+// the client made all the requests it was expected to.
+func (r *Recorder) Unplayed() []string {
+	if r.mode != ModeReplay {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var out []string
+	for i, in := range r.cassette.Interactions {
+		if !r.replayed[i] {
+			out = append(out, in.Request.Method+" "+in.Request.URL)
+		}
+	}
+
+	return out
+}
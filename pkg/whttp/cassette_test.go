@@ -0,0 +1,166 @@
+package whttp_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/manzanit0/weathry/pkg/whttp"
+)
+
+func TestRecorder(t *testing.T) {
+	const botToken = "123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=s3cr3t")
+		switch r.URL.Path {
+		case "/bot" + botToken + "/sendMessage":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"ok": true, "result": {"message_id": 42, "token": "s3cr3t"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("not found"))
+		}
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "testdata", "cassette.json")
+
+	do := func(client *http.Client, method, url string) (int, string, error) {
+		req, _ := http.NewRequest(method, url, strings.NewReader(`{"chat_id": 1, "password": "s3cr3t"}`))
+		res, err := client.Do(req)
+		if err != nil {
+			return 0, "", err
+		}
+
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(b), nil
+	}
+
+	rec, err := whttp.NewRecorder(path, whttp.ModeRecord, http.DefaultTransport)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	recording := &http.Client{Transport: rec}
+	if _, _, err := do(recording, http.MethodPost, srv.URL+"/bot"+botToken+"/sendMessage?appid=s3cr3t"); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if _, _, err := do(recording, http.MethodGet, srv.URL+"/missing"); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if err := rec.Save(); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if strings.Contains(string(b), botToken) || strings.Contains(string(b), "s3cr3t") {
+		t.Errorf("expected the secrets to be masked out of the cassette, got %s", b)
+	}
+
+	srv.Close()
+
+	rec, err = whttp.NewRecorder(path, whttp.ModeReplay, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	replaying := &http.Client{Transport: rec}
+
+	status, body, err := do(replaying, http.MethodGet, srv.URL+"/missing")
+	if err != nil || status != http.StatusNotFound || body != "not found" {
+		t.Errorf("got %d %q and error %v, expected the recorded 404", status, body, err)
+	}
+
+	if unplayed := rec.Unplayed(); len(unplayed) != 1 {
+		t.Errorf("expected one unplayed interaction, got %v", unplayed)
+	}
+
+	// The body is part of the request, so another message isn't the one
+	// recorded.
+	other, _ := http.NewRequest(http.MethodPost, srv.URL+"/bot"+botToken+"/sendMessage?appid=s3cr3t", strings.NewReader(`{"chat_id": 2, "password": "s3cr3t"}`))
+	if _, err := replaying.Do(other); !errors.Is(err, whttp.ErrNoInteraction) {
+		t.Errorf("expected a request with another body not to be replayed, got %v", err)
+	}
+
+	status, body, err = do(replaying, http.MethodPost, srv.URL+"/bot"+botToken+"/sendMessage?appid=0th3r")
+	if err != nil || status != http.StatusOK || !strings.Contains(body, `"message_id":42`) {
+		t.Errorf("got %d %q and error %v, expected the recorded message", status, body, err)
+	}
+
+	if _, _, err := do(replaying, http.MethodGet, srv.URL+"/missing"); !errors.Is(err, whttp.ErrNoInteraction) {
+		t.Errorf("expected requests to be replayed once, got %v", err)
+	}
+}
+
+// fakeTB records the errors of a test and runs its cleanups on demand.
+type fakeTB struct {
+	testing.TB
+	errors   []string
+	cleanups []func()
+}
+
+func (f *fakeTB) Helper()           {}
+func (f *fakeTB) Cleanup(fn func()) { f.cleanups = append(f.cleanups, fn) }
+func (f *fakeTB) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func TestNewTestRecorderFailsOnUnplayedRequests(t *testing.T) {
+	t.Setenv(whttp.RecordEnv, "")
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	cassette := `{"interactions": [{"request": {"method": "GET", "url": "https://example.com/a"}, "response": {"status_code": 204}}]}`
+	if err := os.WriteFile(path, []byte(cassette), 0o644); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	tb := &fakeTB{TB: t}
+	whttp.NewTestRecorder(tb, path)
+	for _, fn := range tb.cleanups {
+		fn()
+	}
+
+	if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "GET https://example.com/a") {
+		t.Errorf("expected the test to fail for the unplayed request, got %v", tb.errors)
+	}
+}
+
+func TestRecorderMatchesRecordedHeaders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	cassette := `{"interactions": [{"request": {"method": "GET", "url": "https://example.com/a", "header": {"User-Agent": ["weathry"]}}, "response": {"status_code": 204}}]}`
+	if err := os.WriteFile(path, []byte(cassette), 0o644); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	rec, err := whttp.NewRecorder(path, whttp.ModeReplay, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	c := &http.Client{Transport: rec}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/a", nil)
+	if _, err := c.Do(req); !errors.Is(err, whttp.ErrNoInteraction) {
+		t.Errorf("expected a request without the recorded headers not to be replayed, got %v", err)
+	}
+
+	req.Header.Set("User-Agent", "weathry")
+	req.Header.Set("Accept", "application/json")
+	if res, err := c.Do(req); err != nil || res.StatusCode != http.StatusNoContent {
+		t.Errorf("got %v and error %v, expected the recorded 204", res, err)
+	}
+}
@@ -1,34 +1,75 @@
 package whttp
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"log/slog"
+
+	"github.com/manzanit0/weathry/pkg/metrics"
+	"github.com/manzanit0/weathry/pkg/redact"
+	"github.com/manzanit0/weathry/pkg/tracing"
+)
+
+var requestLatency = metrics.NewHistogram(
+	"weathry_http_client_request_duration_seconds",
+	"Time taken by outbound HTTP requests, by host and response status.",
+	metrics.DefBuckets,
+	"host", "status",
 )
 
 type LoggingRoundTripper struct {
 	Proxied http.RoundTripper
+
+	// Policy is what's masked out of the logs. Defaults to redact.Default.
+	Policy *redact.Policy
+}
+
+func (lrt LoggingRoundTripper) policy() redact.Policy {
+	if lrt.Policy == nil {
+		return redact.Default
+	}
+
+	return *lrt.Policy
 }
 
 func (lrt LoggingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	t0 := time.Now()
+	p := lrt.policy()
+
+	// The path is left out of the span since Telegram's has the bot token.
+	ctx, span := tracing.Start(req.Context(), fmt.Sprintf("%s %s", req.Method, req.URL.Host),
+		tracing.WithKind(tracing.KindClient),
+		tracing.WithAttributes(
+			slog.String("http.request.method", req.Method),
+			slog.String("server.address", req.URL.Host),
+		))
+	defer span.End()
+
+	// RoundTrippers mustn't modify the request, so the header goes on a copy.
+	req = req.Clone(ctx)
+	tracing.Inject(ctx, req.Header)
 
 	res, err := lrt.Proxied.RoundTrip(req)
 	if err != nil {
-		slog.ErrorContext(req.Context(), err.Error())
+		requestLatency.Observe(metrics.Since(t0), req.URL.Host, "error")
+		msg := p.Error(err)
+		span.RecordError(errors.New(msg))
+		slog.ErrorContext(req.Context(), msg, "http.request.method", req.Method, "http.request.url", p.URL(req.URL))
 		return res, err
 	}
 
-	requestDuration := time.Since(t0).Milliseconds()
-
-	// Ofuscate access_key if it exists.
-	q := req.URL.Query()
-	if q.Has("access_key") {
-		q.Set("access_key", "*****")
+	requestLatency.Observe(metrics.Since(t0), req.URL.Host, strconv.Itoa(res.StatusCode))
+	span.SetAttributes(slog.Int("http.response.status_code", res.StatusCode))
+	if res.StatusCode >= http.StatusInternalServerError {
+		span.RecordError(fmt.Errorf("responded with status %d", res.StatusCode))
 	}
 
+	requestDuration := time.Since(t0).Milliseconds()
+
 	// Note: this is useful if we want to print the body.
 	// b := bytes.NewBuffer(make([]byte, 0))
 	// reader := io.TeeReader(res.Body, b)
@@ -36,28 +77,22 @@ func (lrt LoggingRoundTripper) RoundTrip(req *http.Request) (*http.Response, err
 	// defer res.Body.Close()
 	// res.Body = ioutil.NopCloser(b)
 
-	msg := fmt.Sprintf("%s %s://%s%s -> %d (%d ms)", req.Method, req.URL.Scheme, req.URL.Host, req.URL.Path, res.StatusCode, requestDuration)
+	path := p.String(req.URL.Path)
+	msg := fmt.Sprintf("%s %s://%s%s -> %d (%d ms)", req.Method, req.URL.Scheme, req.URL.Host, path, res.StatusCode, requestDuration)
 	slog.InfoContext(req.Context(), msg,
 		"http.request.duration_ms", requestDuration,
 		"http.request.method", req.Method,
 		"http.request.url.scheme", req.URL.Scheme,
 		"http.request.url.host", req.URL.Host,
-		"http.request.url.path", req.URL.Path,
-		"http.request.url.query_params", q,
+		"http.request.url.path", path,
+		"http.request.url.query_params", p.Query(req.URL.Query()),
 		"http.request.content_length", req.ContentLength,
-		"http.request.headers", req.Header,
+		"http.request.headers", p.Header(req.Header),
 		"http.response.status_code", res.StatusCode,
-		"http.response.headers", res.Header,
+		"http.response.headers", p.Header(res.Header),
 		"http.response.content_length", res.ContentLength,
 		"http.response.uncompressed", res.Uncompressed,
 		"http.response.protocol", res.Proto)
 
 	return res, nil
 }
-
-func NewLoggingClient() *http.Client {
-	return &http.Client{
-		Transport: LoggingRoundTripper{Proxied: http.DefaultTransport},
-		Timeout:   10 * time.Second,
-	}
-}
@@ -0,0 +1,69 @@
+package whttp_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/manzanit0/weathry/pkg/whttp"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestLoggingRoundTripperRedactsSecrets(t *testing.T) {
+	const botToken = "123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw"
+
+	testCases := []struct {
+		desc    string
+		url     string
+		failing bool
+	}{
+		{desc: "bot token", url: "https://api.telegram.org/bot" + botToken + "/sendMessage"},
+		{desc: "api key", url: "https://api.openweathermap.org/data/3.0/onecall?lat=41.38&lon=2.17&appid=s3cr3t"},
+		{desc: "failed request", url: "https://api.telegram.org/bot" + botToken + "/sendMessage?appid=s3cr3t", failing: true},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			var logs bytes.Buffer
+			defer slog.SetDefault(slog.Default())
+			slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+
+			rt := whttp.LoggingRoundTripper{Proxied: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if tC.failing {
+					return nil, errors.New("dial tcp: connection refused")
+				}
+
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Set-Cookie": {"session=s3cr3t"}},
+					Body:       io.NopCloser(strings.NewReader("{}")),
+				}, nil
+			})}
+
+			req, err := http.NewRequest(http.MethodGet, tC.url, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			req.Header.Set("Authorization", "Bearer s3cr3t")
+
+			_, _ = (&http.Client{Transport: rt}).Do(req)
+
+			out := logs.String()
+			if out == "" {
+				t.Fatalf("expected the request to be logged")
+			}
+
+			if strings.Contains(out, botToken) || strings.Contains(out, "s3cr3t") {
+				t.Errorf("expected the secrets to be masked, got %s", out)
+			}
+		})
+	}
+}
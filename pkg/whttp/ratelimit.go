@@ -0,0 +1,63 @@
+package whttp
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/manzanit0/weathry/pkg/ratelimit"
+)
+
+// RateLimit is how many requests can be made to a host.
+type RateLimit struct {
+	// PerSecond is how many requests can be made every second, on average.
+	// Requests aren't limited when it's 0.
+	PerSecond float64
+
+	// Burst is how many requests can be made at once, after a quiet spell.
+	Burst int
+}
+
+// Limiter limits requests with a token bucket per host.
+type Limiter struct {
+	limit RateLimit
+
+	mu      sync.Mutex
+	buckets map[string]*ratelimit.Limiter
+}
+
+func NewLimiter(limit RateLimit) *Limiter {
+	return &Limiter{limit: limit, buckets: map[string]*ratelimit.Limiter{}}
+}
+
+// Wait blocks until a request can be made to host, or ctx is done.
+func (l *Limiter) Wait(ctx context.Context, host string) error {
+	if l == nil || l.limit.PerSecond <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	b, ok := l.buckets[host]
+	if !ok {
+		b = ratelimit.New(l.limit.PerSecond, l.limit.Burst)
+		l.buckets[host] = b
+	}
+	l.mu.Unlock()
+
+	return b.Wait(ctx)
+}
+
+// RateLimitRoundTripper holds requests back until the limiter lets them
+// through.
+type RateLimitRoundTripper struct {
+	Proxied http.RoundTripper
+	Limiter *Limiter
+}
+
+func (rt RateLimitRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := rt.Limiter.Wait(req.Context(), req.URL.Host); err != nil {
+		return nil, err
+	}
+
+	return rt.Proxied.RoundTrip(req)
+}
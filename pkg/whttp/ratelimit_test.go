@@ -0,0 +1,45 @@
+package whttp_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/whttp"
+)
+
+func TestLimiter(t *testing.T) {
+	l := whttp.NewLimiter(whttp.RateLimit{PerSecond: 20, Burst: 2})
+	ctx := context.Background()
+
+	t0 := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(ctx, "api.openweathermap.org"); err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+	}
+
+	// The burst goes through right away, and the third request waits for a
+	// token, which takes 50ms at 20 a second.
+	if d := time.Since(t0); d < 40*time.Millisecond {
+		t.Errorf("expected the third request to wait, took %s", d)
+	}
+
+	t0 = time.Now()
+	if err := l.Wait(ctx, "api.telegram.org"); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if d := time.Since(t0); d > 20*time.Millisecond {
+		t.Errorf("expected hosts to have their own buckets, waited %s", d)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+	defer cancel()
+
+	_ = l.Wait(ctx, "api.openweathermap.org")
+	if err := l.Wait(ctx, "api.openweathermap.org"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected waiting to stop with the context, got %v", err)
+	}
+}
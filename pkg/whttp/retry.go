@@ -0,0 +1,187 @@
+package whttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy is how failed requests are retried.
+type RetryPolicy struct {
+	// MaxAttempts is how many times a request is made, counting the first.
+	// Requests aren't retried when it's 0 or 1.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry, which doubles with every
+	// retry up to MaxDelay. Delays are jittered so that clients which failed
+	// together don't retry together.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// AttemptTimeout bounds each attempt, so a hung one leaves time for the
+	// rest. Attempts aren't bounded when it's 0.
+	AttemptTimeout time.Duration
+}
+
+// backoff returns the delay before the retry which follows attempt, between
+// half and all of the exponential delay.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay << (attempt - 1)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+
+	if d <= 0 {
+		return 0
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// RetryRoundTripper retries idempotent requests which timed out or got a 5xx
+// or 429 response. A Retry-After header is waited for rather than the backoff,
+// unless it's longer than MaxDelay, in which case the response is returned.
+type RetryRoundTripper struct {
+	Proxied http.RoundTripper
+	Policy  RetryPolicy
+}
+
+func (rt RetryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt.Policy.MaxAttempts <= 1 || !isIdempotent(req) {
+		return rt.attempt(req)
+	}
+
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		// RoundTrippers mustn't modify the request, so every attempt gets a
+		// copy with a fresh body.
+		r := req.Clone(ctx)
+		if req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+
+			r.Body = body
+		}
+
+		res, err := rt.attempt(r)
+		if attempt >= rt.Policy.MaxAttempts || !isRetryable(ctx, res, err) {
+			return res, err
+		}
+
+		delay := rt.Policy.backoff(attempt)
+		if after, ok := retryAfter(res); ok {
+			if rt.Policy.MaxDelay > 0 && after > rt.Policy.MaxDelay {
+				return res, err
+			}
+
+			delay = after
+		}
+
+		if res != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+			res.Body.Close()
+		}
+
+		slog.WarnContext(ctx, "retrying request",
+			"http.request.method", req.Method,
+			"server.address", req.URL.Host,
+			"attempt", attempt,
+			"delay_ms", delay.Milliseconds())
+
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (rt RetryRoundTripper) attempt(req *http.Request) (*http.Response, error) {
+	if rt.Policy.AttemptTimeout <= 0 {
+		return rt.Proxied.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), rt.Policy.AttemptTimeout)
+	res, err := rt.Proxied.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// The body is read after returning, so the attempt's context can only be
+	// cancelled once it's closed.
+	res.Body = &cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// isIdempotent tells whether req can be made again without side effects,
+// either because of its method or because it has an Idempotency-Key.
+func isIdempotent(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return req.Header.Get("Idempotency-Key") != ""
+	}
+}
+
+func isRetryable(ctx context.Context, res *http.Response, err error) bool {
+	if err != nil {
+		// Once the caller has given up there's no point in retrying.
+		if ctx.Err() != nil {
+			return false
+		}
+
+		var netErr net.Error
+		return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+	}
+
+	return res.StatusCode >= http.StatusInternalServerError || res.StatusCode == http.StatusTooManyRequests
+}
+
+// retryAfter returns how long the Retry-After header of res asks to wait,
+// which is either in seconds or a date.
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	if res == nil {
+		return 0, false
+	}
+
+	h := res.Header.Get("Retry-After")
+	if h == "" {
+		return 0, false
+	}
+
+	if s, err := strconv.Atoi(h); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(h); err == nil {
+		return max(time.Until(t), 0), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,97 @@
+package whttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/manzanit0/weathry/pkg/whttp"
+)
+
+func TestRetryRoundTripper(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		method     string
+		statuses   []int
+		retryAfter string
+		attempts   int32
+		status     int
+	}{
+		{desc: "recovers from 5xx", method: http.MethodGet, statuses: []int{502, 503, 200}, attempts: 3, status: 200},
+		{desc: "gives up after max attempts", method: http.MethodGet, statuses: []int{500, 500, 500, 500}, attempts: 3, status: 500},
+		{desc: "retries 429", method: http.MethodGet, statuses: []int{429, 200}, retryAfter: "0", attempts: 2, status: 200},
+		{desc: "returns when Retry-After is too long", method: http.MethodGet, statuses: []int{429, 200}, retryAfter: "3600", attempts: 1, status: 429},
+		{desc: "doesn't retry 4xx", method: http.MethodGet, statuses: []int{404, 200}, attempts: 1, status: 404},
+		{desc: "doesn't retry POST", method: http.MethodPost, statuses: []int{503, 200}, attempts: 1, status: 503},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := attempts.Add(1)
+				if tC.retryAfter != "" {
+					w.Header().Set("Retry-After", tC.retryAfter)
+				}
+
+				w.WriteHeader(tC.statuses[n-1])
+			}))
+			defer srv.Close()
+
+			client := &http.Client{Transport: whttp.RetryRoundTripper{
+				Proxied: http.DefaultTransport,
+				Policy:  whttp.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond},
+			}}
+
+			req, _ := http.NewRequest(tC.method, srv.URL, strings.NewReader("{}"))
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			res.Body.Close()
+
+			if res.StatusCode != tC.status {
+				t.Errorf("got status %d, expected %d", res.StatusCode, tC.status)
+			}
+
+			if got := attempts.Load(); got != tC.attempts {
+				t.Errorf("got %d attempts, expected %d", got, tC.attempts)
+			}
+		})
+	}
+}
+
+func TestRetryRoundTripperRetriesTimeouts(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}
+
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: whttp.RetryRoundTripper{
+		Proxied: http.DefaultTransport,
+		Policy:  whttp.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, AttemptTimeout: 50 * time.Millisecond},
+	}}
+
+	res, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK || attempts.Load() != 2 {
+		t.Errorf("got status %d after %d attempts, expected 200 after 2", res.StatusCode, attempts.Load())
+	}
+}
@@ -0,0 +1,46 @@
+package whttp
+
+import (
+	"net/http"
+	"os"
+	"testing"
+)
+
+// RecordEnv is the environment variable which makes NewTestRecorder record
+// cassettes against the real APIs, rather than replay them, when it is set.
+const RecordEnv = "WHTTP_RECORD"
+
+// Recording returns whether tests are recording cassettes, so they can use
+// real credentials instead of fake ones.
+func Recording() bool {
+	return os.Getenv(RecordEnv) != ""
+}
+
+// NewTestRecorder returns a recorder for the cassette at path which records it
+// if Recording, and replays it otherwise. Once the test is over, the cassette
+// is saved, and the test fails unless all its requests were made.
+func NewTestRecorder(t testing.TB, path string) *Recorder {
+	t.Helper()
+
+	mode := ModeReplay
+	if Recording() {
+		mode = ModeRecord
+	}
+
+	rec, err := NewRecorder(path, mode, http.DefaultTransport)
+	if err != nil {
+		t.Fatalf("open cassette: %s", err.Error())
+	}
+
+	t.Cleanup(func() {
+		if err := rec.Save(); err != nil {
+			t.Errorf("save cassette: %s", err.Error())
+		}
+
+		if unplayed := rec.Unplayed(); len(unplayed) > 0 {
+			t.Errorf("expected the recorded requests to be made: %v", unplayed)
+		}
+	})
+
+	return rec
+}
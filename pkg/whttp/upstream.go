@@ -0,0 +1,117 @@
+package whttp
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Upstream is how an API is called: how long requests can take, how they're
+// retried, how many can be made and when to stop making them.
+type Upstream struct {
+	Name string
+
+	// Timeout bounds requests, counting all their attempts.
+	Timeout time.Duration
+
+	Retry     RetryPolicy
+	RateLimit RateLimit
+	Breaker   BreakerPolicy
+}
+
+// The APIs we call. This is the one place to tune how each of them is called.
+var (
+	// OpenWeatherMap's free plan allows 60 calls a minute.
+	OpenWeatherMap = Upstream{
+		Name:      "openweathermap",
+		Timeout:   15 * time.Second,
+		Retry:     RetryPolicy{MaxAttempts: 3, BaseDelay: 250 * time.Millisecond, MaxDelay: 2 * time.Second, AttemptTimeout: 5 * time.Second},
+		RateLimit: RateLimit{PerSecond: 1, Burst: 10},
+		Breaker:   BreakerPolicy{Failures: 5, Cooldown: 30 * time.Second},
+	}
+
+	// Nominatim's usage policy allows a request a second at most.
+	//
+	// @see https://operations.osmfoundation.org/policies/nominatim/
+	Nominatim = Upstream{
+		Name:      "nominatim",
+		Timeout:   15 * time.Second,
+		Retry:     RetryPolicy{MaxAttempts: 2, BaseDelay: time.Second, MaxDelay: 2 * time.Second, AttemptTimeout: 5 * time.Second},
+		RateLimit: RateLimit{PerSecond: 1, Burst: 1},
+		Breaker:   BreakerPolicy{Failures: 5, Cooldown: time.Minute},
+	}
+
+	PositionStack = Upstream{
+		Name:      "positionstack",
+		Timeout:   15 * time.Second,
+		Retry:     RetryPolicy{MaxAttempts: 3, BaseDelay: 250 * time.Millisecond, MaxDelay: 2 * time.Second, AttemptTimeout: 5 * time.Second},
+		RateLimit: RateLimit{PerSecond: 1, Burst: 5},
+		Breaker:   BreakerPolicy{Failures: 5, Cooldown: time.Minute},
+	}
+
+	// Telegram allows bots around 30 messages a second. Sending messages
+	// isn't idempotent, so only the requests which are get retried.
+	Telegram = Upstream{
+		Name:      "telegram",
+		Timeout:   10 * time.Second,
+		Retry:     RetryPolicy{MaxAttempts: 3, BaseDelay: 250 * time.Millisecond, MaxDelay: 5 * time.Second},
+		RateLimit: RateLimit{PerSecond: 30, Burst: 30},
+		Breaker:   BreakerPolicy{Failures: 10, Cooldown: 30 * time.Second},
+	}
+)
+
+// upstreamState is what clients of the same upstream share, so that the
+// limits and the breaker apply to all of them together.
+type upstreamState struct {
+	limiter *Limiter
+	breaker *Breaker
+}
+
+var (
+	upstreamsMu sync.Mutex
+	upstreams   = map[string]*upstreamState{}
+)
+
+func (u Upstream) state() *upstreamState {
+	upstreamsMu.Lock()
+	defer upstreamsMu.Unlock()
+
+	s, ok := upstreams[u.Name]
+	if !ok {
+		s = &upstreamState{limiter: NewLimiter(u.RateLimit), breaker: NewBreaker(u.Name, u.Breaker)}
+		upstreams[u.Name] = s
+	}
+
+	return s
+}
+
+// Transport returns a round tripper which makes requests to the upstream with
+// proxied. Requests go through the breaker first, so it only sees how they
+// ended after retries, and then every attempt is rate limited and logged.
+func (u Upstream) Transport(proxied http.RoundTripper) http.RoundTripper {
+	s := u.state()
+
+	var rt http.RoundTripper = LoggingRoundTripper{Proxied: proxied}
+	rt = RateLimitRoundTripper{Proxied: rt, Limiter: s.limiter}
+	rt = RetryRoundTripper{Proxied: rt, Policy: u.Retry}
+	return BreakerRoundTripper{Proxied: rt, Breaker: s.breaker}
+}
+
+// NewClient returns a client for the upstream.
+func NewClient(u Upstream) *http.Client {
+	return &http.Client{Transport: u.Transport(http.DefaultTransport), Timeout: u.Timeout}
+}
+
+// Breakers returns the state of the breaker of every upstream which has been
+// called, by name, for health checks.
+func Breakers() map[string]BreakerState {
+	upstreamsMu.Lock()
+	defer upstreamsMu.Unlock()
+
+	out := make(map[string]BreakerState, len(upstreams))
+	for name, s := range upstreams {
+		out[name] = s.breaker.State()
+	}
+
+	return out
+}